@@ -0,0 +1,645 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pkgInfo holds the struct types declared in a package
+type pkgInfo struct {
+	name string
+	// structs maps the name of each struct type to its declaration, and order holds the names in declaration order
+	structs map[string]*ast.TypeSpec
+	order   []string
+}
+
+// loadPackage parses the Go package in dir, excluding the file named output
+func loadPackage(dir, output string) (*pkgInfo, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &pkgInfo{
+		name:    bp.Name,
+		structs: make(map[string]*ast.TypeSpec),
+	}
+	fset := token.NewFileSet()
+	files := append([]string(nil), bp.GoFiles...)
+	sort.Strings(files)
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if filepath.Clean(path) == filepath.Clean(output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.StructType); ok && !ts.Assign.IsValid() {
+					pkg.structs[ts.Name.Name] = ts
+					pkg.order = append(pkg.order, ts.Name.Name)
+				}
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// structField describes a field of a struct for which methods are generated
+type structField struct {
+	// path is the selector of the field relative to the struct, eg: Inner.Port for the field Port of an embedded struct
+	// named Inner
+	path string
+	// name is the field's name and tag is its complete struct tag
+	name string
+	tag  string
+	// kdlName is the name of the node or property that the field represents, in lower case
+	kdlName string
+	attrs   []string
+	typ     ast.Expr
+}
+
+// has returns true if the field's kdl tag includes attr
+func (f *structField) has(attr string) bool {
+	for _, a := range f.attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// isChecked returns true if the field's kdl tag marks it required, gives it a default, constrains its value, or gives
+// it a type annotation, in which case the struct must be checked once it has been unmarshaled
+func (f *structField) isChecked() bool {
+	for _, a := range f.attrs {
+		if a == "required" {
+			return true
+		}
+		for _, prefix := range []string{"default:", "min:", "max:", "len:", "enum:", "match:", "oneof:", "type:"} {
+			if strings.HasPrefix(a, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isConstrained returns true if the field's kdl tag constrains its value or places it in a "oneof:" group
+func (f *structField) isConstrained() bool {
+	for _, a := range f.attrs {
+		for _, prefix := range []string{"min:", "max:", "len:", "enum:", "match:", "oneof:"} {
+			if strings.HasPrefix(a, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCapture returns true if the field captures a node's arguments, properties, or children
+func (f *structField) isCapture() bool {
+	return f.has("arg") || f.has("args") || f.has("props") || f.has("children")
+}
+
+// hasKDLTags returns true if any field of st has a kdl struct tag
+func hasKDLTags(st *ast.StructType) bool {
+	for _, fld := range st.Fields.List {
+		if fld.Tag == nil {
+			continue
+		}
+		if tag, err := strconv.Unquote(fld.Tag.Value); err == nil {
+			if _, ok := reflect.StructTag(tag).Lookup("kdl"); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generator generates methods for the struct types in a package
+type generator struct {
+	b   bytes.Buffer
+	pkg *pkgInfo
+	// selected holds the names of the types for which methods are being generated
+	selected map[string]bool
+}
+
+// generate returns formatted Go source declaring methods for the struct types in pkg named in typeNames, or for all
+// struct types with kdl tags if typeNames is empty
+func generate(pkg *pkgInfo, typeNames []string) ([]byte, error) {
+	g := &generator{
+		pkg:      pkg,
+		selected: make(map[string]bool),
+	}
+
+	if len(typeNames) == 0 {
+		for _, name := range pkg.order {
+			if hasKDLTags(pkg.structs[name].Type.(*ast.StructType)) {
+				typeNames = append(typeNames, name)
+			}
+		}
+		if len(typeNames) == 0 {
+			return nil, fmt.Errorf("package %s has no struct types with kdl tags", pkg.name)
+		}
+	}
+	for _, name := range typeNames {
+		if _, ok := pkg.structs[name]; !ok {
+			return nil, fmt.Errorf("struct type %s not found in package %s", name, pkg.name)
+		}
+		g.selected[name] = true
+	}
+
+	fmt.Fprintf(&g.b, "// Code generated by kdl-gen-marshal; DO NOT EDIT.\n\npackage %s\n\n", pkg.name)
+	g.b.WriteString("import (\n\t\"github.com/sblinch/kdl-go/codegen\"\n\t\"github.com/sblinch/kdl-go/document\"\n)\n")
+	for _, name := range typeNames {
+		if err := g.writeType(pkg.structs[name]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	src, err := format.Source(g.b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// fields returns the fields of st that are handled by Marshal and Unmarshal, in the order in which they are handled,
+// flattening embedded structs; prefix is the selector of st relative to the struct for which methods are generated
+func (g *generator) fields(st *ast.StructType, prefix string) ([]*structField, error) {
+	var fields []*structField
+	for _, fld := range st.Fields.List {
+		var tag string
+		if fld.Tag != nil {
+			var err error
+			if tag, err = strconv.Unquote(fld.Tag.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		names := make([]string, 0, len(fld.Names))
+		for _, ident := range fld.Names {
+			names = append(names, ident.Name)
+		}
+		if len(names) == 0 {
+			switch t := fld.Type.(type) {
+			case *ast.Ident:
+				// embedded structs are flattened into the embedding struct
+				if ts, ok := g.pkg.structs[t.Name]; ok {
+					if ts.TypeParams != nil {
+						return nil, fmt.Errorf("embedded generic type %s is not supported", t.Name)
+					}
+					embedded, err := g.fields(ts.Type.(*ast.StructType), prefix+t.Name+".")
+					if err != nil {
+						return nil, err
+					}
+					fields = append(fields, embedded...)
+					continue
+				}
+				names = append(names, t.Name)
+			case *ast.StarExpr:
+				ident, ok := t.X.(*ast.Ident)
+				if !ok {
+					return nil, fmt.Errorf("embedded field of type %s is not supported", typeString(fld.Type))
+				}
+				names = append(names, ident.Name)
+			default:
+				return nil, fmt.Errorf("embedded field of type %s is not supported", typeString(fld.Type))
+			}
+		}
+
+		value, _ := reflect.StructTag(tag).Lookup("kdl")
+		var attrs []string
+		tagName := value
+		if i := strings.IndexByte(value, ','); i >= 0 {
+			tagName = value[:i]
+			attrs = strings.Split(value[i+1:], ",")
+		}
+		for _, name := range names {
+			for _, attr := range []string{"structure", "unknown"} {
+				if slices.Contains(attrs, attr) {
+					return nil, fmt.Errorf("field %s%s: fields tagged \",%s\" are not supported", prefix, name, attr)
+				}
+			}
+			if !ast.IsExported(name) {
+				continue
+			}
+			f := &structField{
+				path:    prefix + name,
+				name:    name,
+				tag:     tag,
+				kdlName: strings.ToLower(tagName),
+				attrs:   attrs,
+				typ:     fld.Type,
+			}
+			if f.kdlName == "" {
+				f.kdlName = strings.ToLower(strings.TrimSpace(name))
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// typeString returns the source representation of the type expression expr
+func typeString(expr ast.Expr) string {
+	var b bytes.Buffer
+	_ = format.Node(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+// checkFields returns an error if fields cannot be handled by generated methods
+func checkFields(fields []*structField) error {
+	byName := make(map[string]*structField, len(fields))
+	for _, f := range fields {
+		if f.kdlName == "-" {
+			continue
+		}
+		if other, ok := byName[f.kdlName]; ok {
+			return fmt.Errorf("fields %s and %s both represent %q", other.path, f.path, f.kdlName)
+		}
+		byName[f.kdlName] = f
+	}
+
+	for _, attr := range []string{"args", "props", "children"} {
+		n := 0
+		for _, f := range fields {
+			if f.has(attr) {
+				n++
+			}
+		}
+		if n > 1 {
+			return fmt.Errorf("only one field may be tagged \",%s\"", attr)
+		}
+	}
+	return nil
+}
+
+// isGenerated returns true if expr names a type for which methods are being generated
+func (g *generator) isGenerated(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && g.selected[ident.Name]
+}
+
+// isGeneratedPtr returns true if expr is a pointer to a type for which methods are being generated
+func (g *generator) isGeneratedPtr(expr ast.Expr) bool {
+	star, ok := expr.(*ast.StarExpr)
+	return ok && g.isGenerated(star.X)
+}
+
+// sliceElem returns the element type of expr if it is a slice type
+func sliceElem(expr ast.Expr) (ast.Expr, bool) {
+	at, ok := expr.(*ast.ArrayType)
+	if !ok || at.Len != nil {
+		return nil, false
+	}
+	return at.Elt, true
+}
+
+// numFields returns the number of fields declared in st
+func numFields(st *ast.StructType) int {
+	n := 0
+	for _, fld := range st.Fields.List {
+		if len(fld.Names) == 0 {
+			n++
+		} else {
+			n += len(fld.Names)
+		}
+	}
+	return n
+}
+
+// typeSrc holds the details of a struct type needed to write its methods
+type typeSrc struct {
+	name      string
+	fieldsVar string
+	fields    []*structField
+	numFields int
+}
+
+// args returns the arguments that identify field i in calls to codegen functions, preceded by prefix
+func (t *typeSrc) args(prefix string, i int) string {
+	return fmt.Sprintf("%s%s, %d, &t.%s", prefix, t.fieldsVar, i, t.fields[i].path)
+}
+
+// checked returns the indexes of the fields that must be checked once the struct has been unmarshaled
+func (t *typeSrc) checked() []int {
+	var indexes []int
+	for i, f := range t.fields {
+		if f.isChecked() {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// withAttr returns the indexes of the fields tagged with attr
+func (t *typeSrc) withAttr(attr string) []int {
+	var indexes []int
+	for i, f := range t.fields {
+		if f.has(attr) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// writeType writes the field description and methods for the struct type declared by ts
+func (g *generator) writeType(ts *ast.TypeSpec) error {
+	if ts.TypeParams != nil {
+		return fmt.Errorf("generic types are not supported")
+	}
+	st := ts.Type.(*ast.StructType)
+	fields, err := g.fields(st, "")
+	if err != nil {
+		return err
+	}
+	if err := checkFields(fields); err != nil {
+		return err
+	}
+
+	t := &typeSrc{
+		name:      ts.Name.Name,
+		fieldsVar: "kdl" + ts.Name.Name + "Fields",
+		fields:    fields,
+		numFields: numFields(st),
+	}
+
+	fmt.Fprintf(&g.b, "\n// %s describes the fields of %s.\nvar %s = codegen.NewFields(%q,\n", t.fieldsVar, t.name, t.fieldsVar, t.name)
+	for _, f := range fields {
+		if f.tag == "" {
+			fmt.Fprintf(&g.b, "\tcodegen.Field{Name: %q},\n", f.name)
+		} else if strings.Contains(f.tag, "`") {
+			fmt.Fprintf(&g.b, "\tcodegen.Field{Name: %q, Tag: %q},\n", f.name, f.tag)
+		} else {
+			fmt.Fprintf(&g.b, "\tcodegen.Field{Name: %q, Tag: `%s`},\n", f.name, f.tag)
+		}
+	}
+	g.b.WriteString(")\n")
+
+	g.writeUnmarshal(t)
+	g.writeUnmarshalNodes(t)
+	g.writeCheck(t)
+	g.writeMarshal(t)
+	g.writeMarshalNodes(t)
+	return nil
+}
+
+// writeUnmarshal writes the UnmarshalKDL and UnmarshalKDLWith methods for t
+func (g *generator) writeUnmarshal(t *typeSrc) {
+	fmt.Fprintf(&g.b, `
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *%[1]s) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *%[1]s) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+`, t.name)
+
+	// arguments are assigned to fields tagged ",arg" in order, then to the field tagged ",args"
+	argFields := t.withAttr("arg")
+	argsFields := t.withAttr("args")
+	g.b.WriteString("\tif len(node.Arguments) > 0 {\n")
+	if len(argsFields) == 0 {
+		fmt.Fprintf(&g.b, "\t\tif err := d.CheckArgs(node, %d); err != nil {\n\t\t\treturn err\n\t\t}\n", len(argFields))
+	}
+	if len(argFields) > 0 || len(argsFields) > 0 {
+		g.b.WriteString("\t\targs := node.Arguments\n")
+	}
+	for _, i := range argFields {
+		fmt.Fprintf(&g.b, "\t\tif len(args) > 0 {\n\t\t\tif err := d.Value(args[0], %s); err != nil {\n\t\t\t\treturn err\n\t\t\t}\n\t\t\targs = args[1:]\n\t\t}\n", t.args("", i))
+	}
+	for _, i := range argsFields {
+		fmt.Fprintf(&g.b, "\t\tif err := d.Args(node, args, %s); err != nil {\n\t\t\treturn err\n\t\t}\n", t.args("", i))
+	}
+	g.b.WriteString("\t}\n")
+
+	// properties are assigned to the fields named for them, then all are assigned to the field tagged ",props"
+	propsFields := t.withAttr("props")
+	g.b.WriteString("\tif node.Properties.Len() > 0 {\n")
+	countHandled := len(propsFields) == 0
+	if countHandled {
+		g.b.WriteString("\t\thandled := 0\n")
+	}
+	if len(t.fields) > 0 {
+		fmt.Fprintf(&g.b, "\t\tfor key, v := range node.Properties.Unordered() {\n\t\t\tvar err error\n\t\t\tswitch %s.Index(d, key) {\n", t.fieldsVar)
+		for i := range t.fields {
+			fmt.Fprintf(&g.b, "\t\t\tcase %d:\n\t\t\t\terr = d.Value(v, %s)\n", i, t.args("", i))
+		}
+		g.b.WriteString("\t\t\tdefault:\n\t\t\t\tcontinue\n\t\t\t}\n\t\t\tif err != nil {\n\t\t\t\treturn err\n\t\t\t}\n")
+		if countHandled {
+			g.b.WriteString("\t\t\thandled++\n")
+		}
+		g.b.WriteString("\t\t}\n")
+	}
+	if countHandled {
+		g.b.WriteString("\t\tif err := d.CheckProps(node, handled); err != nil {\n\t\t\treturn err\n\t\t}\n")
+	}
+	for _, i := range propsFields {
+		fmt.Fprintf(&g.b, "\t\tif err := d.Props(node, %s); err != nil {\n\t\t\treturn err\n\t\t}\n", t.args("", i))
+	}
+	g.b.WriteString("\t}\n")
+
+	// children are assigned to the field tagged ",children", or to the fields named for them
+	childrenFields := t.withAttr("children")
+	checked := len(t.checked()) > 0
+	g.b.WriteString("\tif len(node.Children) > 0 {\n")
+	if len(childrenFields) == 0 {
+		if checked {
+			g.b.WriteString("\t\tif err := t.UnmarshalKDLNodes(d, node.Children); err != nil {\n\t\t\treturn err\n\t\t}\n")
+		} else {
+			g.b.WriteString("\t\treturn t.UnmarshalKDLNodes(d, node.Children)\n")
+		}
+	}
+	for _, i := range childrenFields {
+		fmt.Fprintf(&g.b, "\t\tif err := d.Children(node, %s); err != nil {\n\t\t\treturn err\n\t\t}\n", t.args("", i))
+	}
+	if checked {
+		// absent fields can only be identified once the arguments, properties, and children have all been handled
+		g.b.WriteString("\t}\n\treturn t.CheckKDL(d.Checker(node))\n}\n")
+	} else {
+		g.b.WriteString("\t}\n\treturn nil\n}\n")
+	}
+}
+
+// nodeCall returns the call that unmarshals a node into field i of t
+func (g *generator) nodeCall(t *typeSrc, i int) string {
+	f := t.fields[i]
+	if f.has("multiple") {
+		if elem, ok := sliceElem(f.typ); ok {
+			if g.isGenerated(elem) {
+				return "codegen.AppendNode(" + t.args("d, node, ", i) + ")"
+			} else if g.isGeneratedPtr(elem) {
+				return "codegen.AppendNodePtr(" + t.args("d, node, ", i) + ")"
+			}
+		}
+		return "d.Multiple(" + t.args("node, ", i) + ")"
+	}
+	if g.isGeneratedPtr(f.typ) {
+		return "codegen.NodePtr(" + t.args("d, node, ", i) + ")"
+	}
+	return "d.Node(" + t.args("node, ", i) + ")"
+}
+
+// writeUnmarshalNodes writes the UnmarshalKDLNodes method for t
+func (g *generator) writeUnmarshalNodes(t *typeSrc) {
+	fmt.Fprintf(&g.b, `
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *%s) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch %s.Index(d, node.Name.ValueString()) {
+`, t.name, t.fieldsVar)
+	for i := range t.fields {
+		fmt.Fprintf(&g.b, "\t\tcase %d:\n\t\t\terr = %s\n", i, g.nodeCall(t, i))
+	}
+	g.b.WriteString("\t\tdefault:\n\t\t\terr = d.UnhandledNode(node)\n\t\t}\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t}\n\treturn nil\n}\n")
+}
+
+// checkedScalars holds the scalar types whose constraints are checked by codegen.CheckValue and, as the elements of
+// slices, codegen.CheckValues
+var checkedScalars = map[string]bool{
+	"string": true, "int": true, "int8": true, "int16": true, "int32": true, "int64": true, "uint": true,
+	"uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true, "float32": true, "float64": true,
+	"time.Duration": true,
+}
+
+// checkCall returns the call that checks field i of t against its constraints
+func checkCall(t *typeSrc, i int) string {
+	f := t.fields[i]
+	if checkedScalars[typeString(f.typ)] {
+		return fmt.Sprintf("codegen.CheckValue(chk, %s, %d, t.%s)", t.fieldsVar, i, f.path)
+	}
+	// the constraints of a []byte apply to the slice as a whole
+	if elem, ok := sliceElem(f.typ); ok && checkedScalars[typeString(elem)] && typeString(elem) != "uint8" {
+		return fmt.Sprintf("codegen.CheckValues(chk, %s, %d, t.%s)", t.fieldsVar, i, f.path)
+	}
+	return "chk.Value(" + t.args("", i) + ")"
+}
+
+// writeCheck writes the CheckKDL method for t
+func (g *generator) writeCheck(t *typeSrc) {
+	fmt.Fprintf(&g.b, `
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *%s) CheckKDL(chk *codegen.Checker) error {
+`, t.name)
+	oneOf := false
+	for _, i := range t.checked() {
+		f := t.fields[i]
+		if !f.isConstrained() {
+			fmt.Fprintf(&g.b, "\tif _, err := chk.Field(%s); err != nil {\n\t\treturn err\n\t}\n", t.args("", i))
+			continue
+		}
+		fmt.Fprintf(&g.b, "\tif ok, err := chk.Field(%s); err != nil {\n\t\treturn err\n\t} else if ok {\n", t.args("", i))
+		fmt.Fprintf(&g.b, "\t\tif err := %s; err != nil {\n\t\t\treturn err\n\t\t}\n\t}\n", checkCall(t, i))
+		for _, a := range f.attrs {
+			oneOf = oneOf || strings.HasPrefix(a, "oneof:")
+		}
+	}
+	if oneOf {
+		fmt.Fprintf(&g.b, "\treturn chk.OneOf(%s)\n}\n", t.fieldsVar)
+	} else {
+		g.b.WriteString("\treturn nil\n}\n")
+	}
+}
+
+// writeMarshal writes the MarshalKDL and MarshalKDLWith methods for t
+func (g *generator) writeMarshal(t *typeSrc) {
+	fmt.Fprintf(&g.b, `
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *%[1]s) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *%[1]s) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+`, t.name)
+
+	call := func(method string) {
+		fmt.Fprintf(&g.b, "\tif err := %s; err != nil {\n\t\treturn err\n\t}\n", method)
+	}
+
+	argFields := t.withAttr("arg")
+	fmt.Fprintf(&g.b, "\tnode.ExpectArguments(%d)\n", len(argFields))
+	for _, i := range argFields {
+		call("e.Arg(" + t.args("node, ", i) + ")")
+	}
+	for _, i := range t.withAttr("args") {
+		call("e.Args(" + t.args("node, ", i) + ")")
+	}
+	for _, i := range t.withAttr("props") {
+		call("e.Props(" + t.args("node, ", i) + ")")
+	}
+	for i, f := range t.fields {
+		if f.kdlName == "-" || f.isCapture() {
+			continue
+		}
+		if g.isGeneratedPtr(f.typ) {
+			call("codegen.FieldPtr(" + t.args("e, node, ", i) + ")")
+		} else {
+			call("e.Field(" + t.args("node, ", i) + ")")
+		}
+	}
+	childrenFields := t.withAttr("children")
+	fmt.Fprintf(&g.b, "\tnode.ExpectChildren(%d)\n", len(childrenFields))
+	for _, i := range childrenFields {
+		call("e.Children(" + t.args("node, ", i) + ")")
+	}
+	g.b.WriteString("\treturn nil\n}\n")
+}
+
+// writeMarshalNodes writes the MarshalKDLNodes method for t
+func (g *generator) writeMarshalNodes(t *typeSrc) {
+	fmt.Fprintf(&g.b, `
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *%s) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, %d)
+	}
+`, t.name, t.numFields)
+
+	declared := false
+	for i, f := range t.fields {
+		if f.kdlName == "-" {
+			continue
+		}
+		if !declared {
+			g.b.WriteString("\tvar err error\n")
+			declared = true
+		}
+		var call string
+		if g.isGeneratedPtr(f.typ) {
+			call = "codegen.NodesPtr(" + t.args("e, nodes, ", i) + ")"
+		} else {
+			call = "e.Nodes(" + t.args("nodes, ", i) + ")"
+		}
+		fmt.Fprintf(&g.b, "\tif nodes, err = %s; err != nil {\n\t\treturn nil, err\n\t}\n", call)
+	}
+	g.b.WriteString("\treturn nodes, nil\n}\n")
+}
@@ -0,0 +1,123 @@
+// Command kdl-gen-marshal generates MarshalKDL and UnmarshalKDL methods for struct types tagged for use with kdl-go, so
+// that Marshal and Unmarshal can handle their fields without reflection.
+//
+// Usage:
+//
+//	kdl-gen-marshal [flags] [dir]
+//
+// kdl-gen-marshal reads the Go package in dir (or the current directory) and, for each struct type named with -type
+// (or, by default, each struct type with at least one kdl-tagged field), generates:
+//
+//   - MarshalKDL and UnmarshalKDL methods, which Marshal and Unmarshal call as they would any kdl.Marshaler or
+//     kdl.Unmarshaler, and which marshal and unmarshal the struct's fields with the caller's options (or the default
+//     options when called directly) via the methods below
+//   - MarshalKDLWith and UnmarshalKDLWith methods, which marshal and unmarshal the struct's fields with the given
+//     options
+//   - MarshalKDLNodes and UnmarshalKDLNodes methods, which do the same when the struct represents a document or a
+//     node's children
+//   - a CheckKDL method, which checks the fields tagged ",required", ",default:...", with constraints, or with type
+//     annotations once the struct has been unmarshaled
+//
+// Generated methods honor the same struct tags and options as Marshal and Unmarshal, and produce the same results
+// and errors. Field values of types other than scalars, slices of scalars, and structs with generated methods, and
+// the constraints on fields of other types, are still handled reflectively.
+//
+// Types with fields tagged ",structure" or ",unknown", more than one field tagged ",args", ",props", or ",children",
+// or fields whose names conflict, are not supported. It is typically run via go generate:
+//
+//	//go:generate go run github.com/sblinch/kdl-go/cmd/kdl-gen-marshal
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// env provides the command's standard input and output streams
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errorf writes a formatted error message to stderr
+func (e *env) errorf(format string, v ...interface{}) {
+	fmt.Fprintf(e.stderr, "kdl-gen-marshal: "+format+"\n", v...)
+}
+
+func main() {
+	os.Exit(run(&env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// run runs kdl-gen-marshal with args (excluding the program name) and returns the exit status
+func run(e *env, args []string) int {
+	fs := flag.NewFlagSet("kdl-gen-marshal", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: kdl-gen-marshal [flags] [dir]\n\ngenerate KDL marshaling methods for tagged Go structs\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+
+	var (
+		types  string
+		output string
+	)
+	fs.StringVar(&types, "type", "", "comma-separated list of struct types for which to generate methods (defaults to all struct types with kdl tags)")
+	fs.StringVar(&output, "o", "kdl_generated.go", "name of the generated file, relative to dir, or - for standard output")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		e.errorf("only one directory may be given")
+		return exitUsage
+	}
+
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	if output != "-" && !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+
+	var typeNames []string
+	if types != "" {
+		typeNames = strings.Split(types, ",")
+	}
+
+	pkg, err := loadPackage(dir, output)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	src, err := generate(pkg, typeNames)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	if output == "-" {
+		_, err = e.stdout.Write(src)
+	} else {
+		err = os.WriteFile(output, src, 0o644)
+	}
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTypesDir holds the types whose generated methods are tested against the reflective implementation
+const testTypesDir = "../../codegen/internal/testtypes"
+
+func TestGeneratedTestTypesUpToDate(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join(testTypesDir, "kdl_generated.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	e := &env{stdout: &stdout, stderr: &stderr}
+	if status := run(e, []string{"-o", "-", testTypesDir}); status != exitOK {
+		t.Fatalf("run() = %d; stderr: %s", status, stderr.String())
+	}
+	if stdout.String() != string(want) {
+		t.Errorf("%s/kdl_generated.go is out of date; run go generate", testTypesDir)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		args       []string
+		wantStatus int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name: "minimal",
+			src:  "package p\n\ntype T struct {\n\tA string `kdl:\",arg\"`\n\tB []string `kdl:\",args\"`\n\tP map[string]int `kdl:\",props\"`\n\tC map[string]interface{} `kdl:\",children\"`\n}\n",
+			wantStdout: `// Code generated by kdl-gen-marshal; DO NOT EDIT.
+
+package p
+
+import (
+	"github.com/sblinch/kdl-go/codegen"
+	"github.com/sblinch/kdl-go/document"
+)
+
+// kdlTFields describes the fields of T.
+var kdlTFields = codegen.NewFields("T",
+	codegen.Field{Name: "A", Tag: ` + "`" + `kdl:",arg"` + "`" + `},
+	codegen.Field{Name: "B", Tag: ` + "`" + `kdl:",args"` + "`" + `},
+	codegen.Field{Name: "P", Tag: ` + "`" + `kdl:",props"` + "`" + `},
+	codegen.Field{Name: "C", Tag: ` + "`" + `kdl:",children"` + "`" + `},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *T) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *T) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		args := node.Arguments
+		if len(args) > 0 {
+			if err := d.Value(args[0], kdlTFields, 0, &t.A); err != nil {
+				return err
+			}
+			args = args[1:]
+		}
+		if err := d.Args(node, args, kdlTFields, 1, &t.B); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlTFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlTFields, 0, &t.A)
+			case 1:
+				err = d.Value(v, kdlTFields, 1, &t.B)
+			case 2:
+				err = d.Value(v, kdlTFields, 2, &t.P)
+			case 3:
+				err = d.Value(v, kdlTFields, 3, &t.C)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := d.Props(node, kdlTFields, 2, &t.P); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		if err := d.Children(node, kdlTFields, 3, &t.C); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *T) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlTFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlTFields, 0, &t.A)
+		case 1:
+			err = d.Node(node, kdlTFields, 1, &t.B)
+		case 2:
+			err = d.Node(node, kdlTFields, 2, &t.P)
+		case 3:
+			err = d.Node(node, kdlTFields, 3, &t.C)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *T) CheckKDL(chk *codegen.Checker) error {
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *T) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *T) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(1)
+	if err := e.Arg(node, kdlTFields, 0, &t.A); err != nil {
+		return err
+	}
+	if err := e.Args(node, kdlTFields, 1, &t.B); err != nil {
+		return err
+	}
+	if err := e.Props(node, kdlTFields, 2, &t.P); err != nil {
+		return err
+	}
+	node.ExpectChildren(1)
+	if err := e.Children(node, kdlTFields, 3, &t.C); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *T) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 4)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlTFields, 0, &t.A); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlTFields, 1, &t.B); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlTFields, 2, &t.P); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlTFields, 3, &t.C); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+`,
+		},
+		{
+			name:       "no tagged types",
+			src:        "package p\n\ntype T struct{ A int }\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: package p has no struct types with kdl tags\n",
+		},
+		{
+			name:       "type not found",
+			src:        "package p\n\ntype T struct{ A int }\n",
+			args:       []string{"-type", "T,U"},
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: struct type U not found in package p\n",
+		},
+		{
+			name:       "structure",
+			src:        "package p\n\ntype T struct {\n\tA string `kdl:\"a\"`\n\tS interface{} `kdl:\",structure\"`\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: field S: fields tagged \",structure\" are not supported\n",
+		},
+		{
+			name:       "unknown",
+			src:        "package p\n\ntype T struct {\n\tA string `kdl:\"a\"`\n\tRest map[string]interface{} `kdl:\",unknown\"`\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: field Rest: fields tagged \",unknown\" are not supported\n",
+		},
+		{
+			name:       "conflicting names",
+			src:        "package p\n\ntype T struct {\n\tName string `kdl:\"a\"`\n\tA string\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: fields Name and A both represent \"a\"\n",
+		},
+		{
+			name:       "multiple props",
+			src:        "package p\n\ntype T struct {\n\tP map[string]int `kdl:\",props\"`\n\tQ map[string]int `kdl:\",props\"`\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: only one field may be tagged \",props\"\n",
+		},
+		{
+			name:       "generic",
+			src:        "package p\n\ntype T[V any] struct {\n\tA V `kdl:\"a\"`\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: generic types are not supported\n",
+		},
+		{
+			name:       "embedded from another package",
+			src:        "package p\n\nimport \"time\"\n\ntype T struct {\n\ttime.Time\n\tA string `kdl:\"a\"`\n}\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-marshal: T: embedded field of type time.Time is not supported\n",
+		},
+		{
+			name:       "too many directories",
+			args:       []string{"a", "b"},
+			wantStatus: exitUsage,
+			wantStderr: "kdl-gen-marshal: only one directory may be given\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			args := tt.args
+			if len(args) == 0 || args[0] == "-type" {
+				args = append(append([]string{"-o", "-"}, args...), dir)
+			}
+
+			var stdout, stderr bytes.Buffer
+			e := &env{stdout: &stdout, stderr: &stderr}
+			if status := run(e, args); status != tt.wantStatus {
+				t.Errorf("run() = %d, want %d; stderr: %s", status, tt.wantStatus, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout:\n%s\nwant:\n%s", stdout.String(), tt.wantStdout)
+			}
+			if !strings.HasPrefix(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want prefix %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\ntype T struct {\n\tA string `kdl:\"a\"`\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	e := &env{stdout: &stdout, stderr: &stderr}
+	for i := 0; i < 2; i++ {
+		// the second run must ignore the output of the first
+		if status := run(e, []string{dir}); status != exitOK {
+			t.Fatalf("run() = %d; stderr: %s", status, stderr.String())
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "kdl_generated.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("func (t *T) UnmarshalKDLWith(")) {
+		t.Errorf("generated file does not declare UnmarshalKDLWith:\n%s", data)
+	}
+}
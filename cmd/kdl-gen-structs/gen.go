@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms lists the words that are written in upper case in Go identifiers
+var initialisms = map[string]bool{
+	"api": true, "cpu": true, "dns": true, "html": true, "http": true, "https": true, "id": true, "ip": true,
+	"json": true, "kdl": true, "sql": true, "ssh": true, "tcp": true, "tls": true, "ttl": true, "udp": true,
+	"uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// goName converts a KDL node or property name into an exported Go identifier, eg: max-conn-count becomes MaxConnCount
+func goName(name string) string {
+	var b strings.Builder
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		for i, r := range w {
+			if i == 0 {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+		}
+	}
+
+	s := b.String()
+	if s == "" {
+		return "Node"
+	}
+	for _, r := range s {
+		if !unicode.IsUpper(r) {
+			// identifiers that begin with a digit or a letter without case must be prefixed to be exported
+			s = "X" + s
+		}
+		break
+	}
+	return s
+}
+
+// taggable returns true if name can be used as the name in a kdl struct tag
+func taggable(name string) bool {
+	return name != "" && name != "-" && !strings.ContainsAny(name, ",`")
+}
+
+// generator generates Go struct definitions from node shapes
+type generator struct {
+	b bytes.Buffer
+	// types maps each shape for which a struct has been generated to the struct's name, and used holds all type names
+	types map[*nodeShape]string
+	used  map[string]bool
+	// queue holds the shapes whose structs have yet to be generated
+	queue []*nodeShape
+}
+
+// generate returns formatted Go source declaring package pkg, and a struct named typeName that represents a
+// document containing the nodes described by set, along with any structs it requires; source describes the input
+// from which the shapes were inferred
+func generate(set *nodeSet, pkg, typeName, source string) ([]byte, error) {
+	g := &generator{
+		types: make(map[*nodeShape]string),
+		used:  make(map[string]bool),
+	}
+	fmt.Fprintf(&g.b, "// Code generated by kdl-gen-structs from %s.\n\npackage %s\n", source, pkg)
+
+	root := &nodeShape{children: set}
+	g.types[root] = typeName
+	g.used[typeName] = true
+	g.queue = append(g.queue, root)
+
+	for len(g.queue) > 0 {
+		shape := g.queue[0]
+		g.queue = g.queue[1:]
+		g.writeStruct(shape, shape == root)
+	}
+
+	src, err := format.Source(g.b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// typeName returns the name of the struct generated for shape, which is a child of the struct named parent, and queues
+// the struct for generation if necessary
+func (g *generator) typeName(shape *nodeShape, parent string) string {
+	if name, ok := g.types[shape]; ok {
+		return name
+	}
+
+	base := goName(shape.name)
+	name := base
+	if g.used[name] {
+		name = parent + base
+	}
+	for n := 2; g.used[name]; n++ {
+		name = parent + base + strconv.Itoa(n)
+	}
+
+	g.types[shape] = name
+	g.used[name] = true
+	g.queue = append(g.queue, shape)
+	return name
+}
+
+// fieldNames ensures that the names of a struct's fields are unique
+type fieldNames map[string]bool
+
+// add returns a unique field name based on name
+func (f fieldNames) add(name string) string {
+	unique := name
+	for n := 2; f[unique]; n++ {
+		unique = name + strconv.Itoa(n)
+	}
+	f[unique] = true
+	return unique
+}
+
+// field writes a struct field declaration
+func (g *generator) field(name, typ, tag string) {
+	fmt.Fprintf(&g.b, "\t%s %s `kdl:%s`\n", name, typ, strconv.Quote(tag))
+}
+
+// writeStruct writes the struct generated for shape, which represents a document if root is true
+func (g *generator) writeStruct(shape *nodeShape, root bool) {
+	name := g.types[shape]
+	if root {
+		fmt.Fprintf(&g.b, "\n// %s represents a KDL document.\n", name)
+	} else {
+		fmt.Fprintf(&g.b, "\n// %s represents a %s node.\n", name, strconv.Quote(shape.name))
+	}
+	fmt.Fprintf(&g.b, "type %s struct {\n", name)
+
+	fields := make(fieldNames)
+	if shape.maxArgs != 0 {
+		if shape.minArgs == shape.maxArgs {
+			for i, k := range shape.args {
+				fieldName := "Arg"
+				if len(shape.args) > 1 {
+					fieldName += strconv.Itoa(i + 1)
+				}
+				g.field(fields.add(fieldName), k.goType(), ",arg")
+			}
+		} else {
+			g.field(fields.add("Args"), "[]"+shape.argKind().goType(), ",args")
+		}
+	}
+
+	for _, p := range shape.props {
+		if !taggable(p.key) {
+			fmt.Fprintf(&g.b, "\t// property %s cannot be represented by a struct field\n", strconv.Quote(p.key))
+			continue
+		}
+		tag := p.key
+		if p.optional {
+			tag += ",omitempty"
+		}
+		g.field(fields.add(goName(p.key)), p.kind.goType(), tag)
+	}
+
+	for _, child := range shape.children.nodes {
+		if !taggable(child.name) {
+			fmt.Fprintf(&g.b, "\t// %s nodes cannot be represented by a struct field\n", strconv.Quote(child.name))
+			continue
+		}
+		typ, tag := g.childField(child, name)
+		g.field(fields.add(goName(child.name)), typ, tag)
+	}
+
+	g.b.WriteString("}\n")
+}
+
+// childField returns the type and struct tag of the field representing the child nodes described by shape within the
+// struct named parent
+func (g *generator) childField(shape *nodeShape, parent string) (string, string) {
+	var typ string
+	tag := shape.name
+	switch {
+	case shape.hasStructure():
+		typ = g.typeName(shape, parent)
+		if shape.optional && !shape.multiple {
+			typ = "*" + typ
+		}
+	case shape.maxArgs == 0:
+		// a node without arguments is represented by its presence
+		typ = "struct{}"
+		if !shape.multiple {
+			typ = "*" + typ
+		}
+	case shape.minArgs == 1 && shape.maxArgs == 1:
+		typ = shape.args[0].goType()
+	default:
+		typ = "[]" + shape.argKind().goType()
+	}
+
+	if shape.optional && !shape.multiple {
+		tag += ",omitempty"
+	}
+	if shape.multiple {
+		typ = "[]" + typ
+		tag += ",multiple"
+	}
+	return typ, tag
+}
@@ -0,0 +1,173 @@
+// Command kdl-gen-structs generates Go struct definitions, tagged for use with kdl-go's Unmarshal and Marshal, from
+// sample KDL documents or from a KDL Schema.
+//
+// Usage:
+//
+//	kdl-gen-structs [flags] [file ...]
+//
+// When generating from samples, the structure of each node is inferred from every node with the same name and parent
+// in all of the given documents:
+//
+//   - a node with neither properties nor children becomes a field of its argument's type if it always has a single
+//     argument, a *struct{} field if it never has arguments, or a slice field otherwise
+//   - any other node becomes a struct, with its arguments in fields tagged ",arg" (or a slice tagged ",args" if the
+//     number of arguments varies), its properties in fields tagged with their names, and its children in fields
+//     generated by the same rules
+//   - a node that appears more than once within the same parent becomes a slice field tagged ",multiple"
+//   - a node or property that does not appear in every parent is tagged ",omitempty", and optional structs are
+//     referenced by pointer
+//
+// When generating from a schema (-schema), the same rules are applied to the nodes, values, and properties the schema
+// describes; nodes whose max is not 1 become ",multiple" slices, and nodes without names are ignored.
+//
+// If no files are given, kdl-gen-structs reads from standard input.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/relaxed"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// env provides the command's standard input and output streams
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errorf writes a formatted error message to stderr
+func (e *env) errorf(format string, v ...interface{}) {
+	fmt.Fprintf(e.stderr, "kdl-gen-structs: "+format+"\n", v...)
+}
+
+func main() {
+	os.Exit(run(&env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// run runs kdl-gen-structs with args (excluding the program name) and returns the exit status
+func run(e *env, args []string) int {
+	fs := flag.NewFlagSet("kdl-gen-structs", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: kdl-gen-structs [flags] [file ...]\n\ngenerate Go structs from sample KDL documents or a KDL Schema\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+
+	defaultPkg := os.Getenv("GOPACKAGE")
+	if defaultPkg == "" {
+		defaultPkg = "main"
+	}
+	var (
+		fromSchema bool
+		pkg        string
+		typeName   string
+		output     string
+		nginx      bool
+		yamlToml   bool
+	)
+	fs.BoolVar(&fromSchema, "schema", false, "treat the input as a KDL Schema rather than a sample document")
+	fs.StringVar(&pkg, "package", defaultPkg, "package name of the generated code (defaults to $GOPACKAGE, or main)")
+	fs.StringVar(&typeName, "type", "Config", "name of the struct representing the whole document")
+	fs.StringVar(&output, "o", "", "write the generated code to this file rather than standard output")
+	fs.BoolVar(&nginx, "nginx", false, "accept nginx-style syntax in sample documents")
+	fs.BoolVar(&yamlToml, "yaml-toml", false, "accept YAML/TOML-style assignments (name: value, name = value) in sample documents")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if goName(typeName) != typeName {
+		e.errorf("invalid type name %q", typeName)
+		return exitUsage
+	}
+	if fromSchema && fs.NArg() > 1 {
+		e.errorf("only one schema may be given")
+		return exitUsage
+	}
+
+	opts := kdl.DefaultParseOptions
+	if nginx {
+		opts.RelaxedNonCompliant |= relaxed.NGINXSyntax
+	}
+	if yamlToml {
+		opts.RelaxedNonCompliant |= relaxed.YAMLTOMLAssignments
+	}
+
+	docs, names, err := readDocuments(e, fs.Args(), opts)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	var set *nodeSet
+	if fromSchema {
+		if set, err = inferSchema(docs[0]); err != nil {
+			e.errorf("%s: %v", names[0], err)
+			return exitError
+		}
+	} else {
+		set = inferDocument(docs)
+	}
+
+	src, err := generate(set, pkg, typeName, strings.Join(names, ", "))
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	if output == "" {
+		_, err = e.stdout.Write(src)
+	} else {
+		err = os.WriteFile(output, src, 0o644)
+	}
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// readDocuments parses the files named in paths, or standard input if paths is empty, and returns the documents along
+// with their names
+func readDocuments(e *env, paths []string, opts kdl.ParseOptions) ([]*document.Document, []string, error) {
+	if len(paths) == 0 {
+		data, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading standard input: %w", err)
+		}
+		doc, err := kdl.ParseWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("<stdin>: %w", err)
+		}
+		return []*document.Document{doc}, []string{"<stdin>"}, nil
+	}
+
+	docs := make([]*document.Document, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc, err := kdl.ParseWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, paths, nil
+}
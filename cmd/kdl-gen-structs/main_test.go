@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go"
+)
+
+const sample = `title "example"
+debug
+server "alpha" port=8080 {
+	listen "127.0.0.1" "::1"
+	tls cert="a.pem" key="a.key"
+	location "/" { root "/var/www"; }
+	location "/api" { proxy "http://localhost:9000"; }
+}
+server "beta" port=8081 enabled=true {
+	listen "10.0.0.1"
+}
+max-conn-count 100
+ratio 1
+ratio 1.5
+`
+
+const sampleStructs = `// Code generated by kdl-gen-structs from <stdin>.
+
+package config
+
+// Config represents a KDL document.
+type Config struct {
+	Title        string    ` + "`" + `kdl:"title"` + "`" + `
+	Debug        *struct{} ` + "`" + `kdl:"debug"` + "`" + `
+	Server       []Server  ` + "`" + `kdl:"server,multiple"` + "`" + `
+	MaxConnCount int       ` + "`" + `kdl:"max-conn-count"` + "`" + `
+	Ratio        []float64 ` + "`" + `kdl:"ratio,multiple"` + "`" + `
+}
+
+// Server represents a "server" node.
+type Server struct {
+	Arg      string     ` + "`" + `kdl:",arg"` + "`" + `
+	Port     int        ` + "`" + `kdl:"port"` + "`" + `
+	Enabled  bool       ` + "`" + `kdl:"enabled,omitempty"` + "`" + `
+	Listen   []string   ` + "`" + `kdl:"listen"` + "`" + `
+	TLS      *TLS       ` + "`" + `kdl:"tls,omitempty"` + "`" + `
+	Location []Location ` + "`" + `kdl:"location,multiple"` + "`" + `
+}
+
+// TLS represents a "tls" node.
+type TLS struct {
+	Cert string ` + "`" + `kdl:"cert"` + "`" + `
+	Key  string ` + "`" + `kdl:"key"` + "`" + `
+}
+
+// Location represents a "location" node.
+type Location struct {
+	Arg   string ` + "`" + `kdl:",arg"` + "`" + `
+	Root  string ` + "`" + `kdl:"root,omitempty"` + "`" + `
+	Proxy string ` + "`" + `kdl:"proxy,omitempty"` + "`" + `
+}
+`
+
+const schemaDoc = `document {
+	node "server" {
+		min 1
+		value { min 1; max 1; type "string"; }
+		prop "port" { required true; type "number"; % 1; }
+		prop "enabled" { type "boolean"; }
+		children {
+			node "listen" { max 1; value { min 1; type "string"; }; }
+			node "location" ref="definitions > node[val() = \"location\"]"
+		}
+	}
+	node "debug" { max 1; }
+	node "title" { min 1; max 1; value { min 1; max 1; type "string"; }; }
+	definitions {
+		node "location" {
+			value { min 1; max 1; }
+			children { node "location" ref="definitions > node[val() = \"location\"]"; }
+		}
+	}
+}
+`
+
+const schemaStructs = `// Code generated by kdl-gen-structs from <stdin>.
+
+package main
+
+// Settings represents a KDL document.
+type Settings struct {
+	Server []Server  ` + "`" + `kdl:"server,multiple"` + "`" + `
+	Debug  *struct{} ` + "`" + `kdl:"debug,omitempty"` + "`" + `
+	Title  string    ` + "`" + `kdl:"title"` + "`" + `
+}
+
+// Server represents a "server" node.
+type Server struct {
+	Arg      string     ` + "`" + `kdl:",arg"` + "`" + `
+	Port     int        ` + "`" + `kdl:"port"` + "`" + `
+	Enabled  bool       ` + "`" + `kdl:"enabled,omitempty"` + "`" + `
+	Listen   []string   ` + "`" + `kdl:"listen,omitempty"` + "`" + `
+	Location []Location ` + "`" + `kdl:"location,multiple"` + "`" + `
+}
+
+// Location represents a "location" node.
+type Location struct {
+	Arg      interface{} ` + "`" + `kdl:",arg"` + "`" + `
+	Location []Location  ` + "`" + `kdl:"location,multiple"` + "`" + `
+}
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantStatus int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "sample",
+			args:       []string{"-package", "config"},
+			stdin:      sample,
+			wantStdout: sampleStructs,
+		},
+		{
+			name:       "schema",
+			args:       []string{"-schema", "-type", "Settings"},
+			stdin:      schemaDoc,
+			wantStdout: schemaStructs,
+		},
+		{
+			name:  "names",
+			args:  []string{"-type", "Doc"},
+			stdin: "doc 1 http-url=\"x\" \"2fa\"=true {\n\tdoc-type \"a\" \"b\"\n}\n\"-\" 1\n",
+			wantStdout: "// Code generated by kdl-gen-structs from <stdin>.\n\npackage main\n\n" +
+				"// Doc represents a KDL document.\ntype Doc struct {\n" +
+				"\tDoc DocDoc `kdl:\"doc\"`\n" +
+				"\t// \"-\" nodes cannot be represented by a struct field\n}\n\n" +
+				"// DocDoc represents a \"doc\" node.\ntype DocDoc struct {\n" +
+				"\tArg     int      `kdl:\",arg\"`\n" +
+				"\tHTTPURL string   `kdl:\"http-url\"`\n" +
+				"\tX2fa    bool     `kdl:\"2fa\"`\n" +
+				"\tDocType []string `kdl:\"doc-type\"`\n}\n",
+		},
+		{
+			name:       "nginx",
+			args:       []string{"-nginx"},
+			stdin:      "location / {\n\troot /var/www;\n}\n",
+			wantStdout: "// Code generated by kdl-gen-structs from <stdin>.\n\npackage main\n\n// Config represents a KDL document.\ntype Config struct {\n\tLocation Location `kdl:\"location\"`\n}\n\n// Location represents a \"location\" node.\ntype Location struct {\n\tArg  string `kdl:\",arg\"`\n\tRoot string `kdl:\"root\"`\n}\n",
+		},
+		{
+			name:       "syntax error",
+			stdin:      "a {\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-structs: <stdin>: ",
+		},
+		{
+			name:       "invalid schema",
+			args:       []string{"-schema"},
+			stdin:      "node \"a\"\n",
+			wantStatus: exitError,
+			wantStderr: "kdl-gen-structs: <stdin>: schema: missing document node",
+		},
+		{
+			name:       "invalid type name",
+			args:       []string{"-type", "config"},
+			wantStatus: exitUsage,
+			wantStderr: "kdl-gen-structs: invalid type name \"config\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			e := &env{stdin: strings.NewReader(tt.stdin), stdout: &stdout, stderr: &stderr}
+			if status := run(e, tt.args); status != tt.wantStatus {
+				t.Errorf("run() = %d, want %d; stderr: %s", status, tt.wantStatus, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout:\n%s\nwant:\n%s", stdout.String(), tt.wantStdout)
+			}
+			if !strings.HasPrefix(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want prefix %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunMultipleSamples(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.kdl")
+	b := filepath.Join(dir, "b.kdl")
+	out := filepath.Join(dir, "config.go")
+	if err := os.WriteFile(a, []byte("name \"a\"\nport 80\nflag\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("name \"b\"\nport 80.5\nflag 1 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	e := &env{stdout: &stdout, stderr: &stderr}
+	if status := run(e, []string{"-o", out, a, b}); status != exitOK {
+		t.Fatalf("run() = %d; stderr: %s", status, stderr.String())
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// Code generated by kdl-gen-structs from " + a + ", " + b + ".\n\npackage main\n\n" +
+		"// Config represents a KDL document.\ntype Config struct {\n" +
+		"\tName string  `kdl:\"name\"`\n" +
+		"\tPort float64 `kdl:\"port\"`\n" +
+		"\tFlag []int   `kdl:\"flag\"`\n}\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// The types below are those in sampleStructs; unmarshaling the sample into them verifies that the generated tags are
+// accepted by the unmarshaler
+type genConfig struct {
+	Title        string      `kdl:"title"`
+	Debug        *struct{}   `kdl:"debug"`
+	Server       []genServer `kdl:"server,multiple"`
+	MaxConnCount int         `kdl:"max-conn-count"`
+	Ratio        []float64   `kdl:"ratio,multiple"`
+}
+
+type genServer struct {
+	Arg      string        `kdl:",arg"`
+	Port     int           `kdl:"port"`
+	Enabled  bool          `kdl:"enabled,omitempty"`
+	Listen   []string      `kdl:"listen"`
+	TLS      *genTLS       `kdl:"tls,omitempty"`
+	Location []genLocation `kdl:"location,multiple"`
+}
+
+type genTLS struct {
+	Cert string `kdl:"cert"`
+	Key  string `kdl:"key"`
+}
+
+type genLocation struct {
+	Arg   string `kdl:",arg"`
+	Root  string `kdl:"root,omitempty"`
+	Proxy string `kdl:"proxy,omitempty"`
+}
+
+func TestGeneratedStructsUnmarshal(t *testing.T) {
+	var got genConfig
+	if err := kdl.Unmarshal([]byte(sample), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := genConfig{
+		Title: "example",
+		Debug: &struct{}{},
+		Server: []genServer{
+			{
+				Arg:    "alpha",
+				Port:   8080,
+				Listen: []string{"127.0.0.1", "::1"},
+				TLS:    &genTLS{Cert: "a.pem", Key: "a.key"},
+				Location: []genLocation{
+					{Arg: "/", Root: "/var/www"},
+					{Arg: "/api", Proxy: "http://localhost:9000"},
+				},
+			},
+			{Arg: "beta", Port: 8081, Enabled: true, Listen: []string{"10.0.0.1"}},
+		},
+		MaxConnCount: 100,
+		Ratio:        []float64{1, 1.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// and that they can be marshaled back into an equivalent document
+	data, err := kdl.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again genConfig
+	if err := kdl.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("after round trip got %+v, want %+v", again, want)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/query"
+	"github.com/sblinch/kdl-go/schema"
+)
+
+// schemaReader infers node shapes from a KDL Schema
+type schemaReader struct {
+	doc *document.Document
+	// shapes and sets hold the shapes inferred from each schema node; used to resolve recursive references
+	shapes map[*document.Node]*nodeShape
+	sets   map[*document.Node]*nodeSet
+}
+
+// inferSchema infers the shape of the top-level nodes described by the KDL Schema in doc
+func inferSchema(doc *document.Document) (*nodeSet, error) {
+	if _, err := schema.New(doc); err != nil {
+		return nil, err
+	}
+
+	r := &schemaReader{
+		doc:    doc,
+		shapes: make(map[*document.Node]*nodeShape),
+		sets:   make(map[*document.Node]*nodeSet),
+	}
+	for _, n := range doc.Nodes {
+		if nodeName(n) == "document" {
+			return r.nodes(n)
+		}
+	}
+	return nil, fmt.Errorf("schema: missing document node")
+}
+
+// nodeName returns the name of n
+func nodeName(n *document.Node) string {
+	if n.Name == nil {
+		return ""
+	}
+	return n.Name.ValueString()
+}
+
+// stringArg returns n's first argument if it is a string
+func stringArg(n *document.Node) (string, bool) {
+	if len(n.Arguments) == 0 {
+		return "", false
+	}
+	s, ok := n.Arguments[0].ResolvedValue().(string)
+	return s, ok
+}
+
+// intArg returns n's first argument if it is an integer
+func intArg(n *document.Node) (int, bool) {
+	if len(n.Arguments) == 0 {
+		return 0, false
+	}
+	i, ok := n.Arguments[0].ResolvedValue().(int64)
+	return int(i), ok
+}
+
+// resolve returns the schema node referenced by n's ref property, or n itself if it has no ref property; references
+// have already been checked by schema.New
+func (r *schemaReader) resolve(n *document.Node) (*document.Node, error) {
+	ref, ok := n.Properties.Get("ref")
+	if !ok {
+		return n, nil
+	}
+	s, _ := ref.ResolvedValue().(string)
+	matches, err := query.Select(r.doc, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("schema: ref %q does not match any schema node", s)
+	}
+	return matches[0], nil
+}
+
+// nodes infers the shapes of the nodes described by the node children of n; nodes without names, which describe any
+// node, cannot be represented by struct fields and are ignored
+func (r *schemaReader) nodes(n *document.Node) (*nodeSet, error) {
+	n, err := r.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := r.sets[n]; ok {
+		return s, nil
+	}
+	s := newNodeSet()
+	r.sets[n] = s
+
+	for _, child := range n.Children {
+		if nodeName(child) != "node" {
+			continue
+		}
+		shape, err := r.node(child)
+		if err != nil {
+			return nil, err
+		}
+		if shape != nil {
+			s.nodes = append(s.nodes, shape)
+			s.byName[shape.name] = shape
+		}
+	}
+	return s, nil
+}
+
+// node infers the shape of the node described by n, or returns nil if n does not name a node
+func (r *schemaReader) node(n *document.Node) (*nodeShape, error) {
+	name, named := stringArg(n)
+	n, err := r.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if !named {
+		if name, named = stringArg(n); !named {
+			return nil, nil
+		}
+	}
+	if shape, ok := r.shapes[n]; ok {
+		if shape.name == name {
+			return shape, nil
+		}
+		renamed := *shape
+		renamed.name = name
+		return &renamed, nil
+	}
+
+	// by default, a node may appear any number of times, including not at all
+	shape := &nodeShape{name: name, children: newNodeSet(), multiple: true, optional: true}
+	r.shapes[n] = shape
+
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "min":
+			if min, ok := intArg(child); ok {
+				shape.optional = min == 0
+			}
+		case "max":
+			if max, ok := intArg(child); ok {
+				shape.multiple = max > 1
+			}
+		case "value":
+			if err := r.values(child, shape); err != nil {
+				return nil, err
+			}
+		case "prop":
+			if err := r.prop(child, shape); err != nil {
+				return nil, err
+			}
+		case "children":
+			if shape.children, err = r.nodes(child); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return shape, nil
+}
+
+// values infers the arguments of shape from the value description n
+func (r *schemaReader) values(n *document.Node, shape *nodeShape) error {
+	n, err := r.resolve(n)
+	if err != nil {
+		return err
+	}
+
+	shape.minArgs, shape.maxArgs = 0, -1
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "min":
+			shape.minArgs, _ = intArg(child)
+		case "max":
+			if max, ok := intArg(child); ok {
+				shape.maxArgs = max
+			}
+		}
+	}
+
+	k := r.kind(n)
+	if shape.maxArgs < 0 {
+		shape.args = []kind{k}
+	} else {
+		shape.args = make([]kind, shape.maxArgs)
+		for i := range shape.args {
+			shape.args[i] = k
+		}
+	}
+	return nil
+}
+
+// prop adds the property described by n to shape
+func (r *schemaReader) prop(n *document.Node, shape *nodeShape) error {
+	key, ok := stringArg(n)
+	n, err := r.resolve(n)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		key, _ = stringArg(n)
+	}
+
+	p := shape.prop(key)
+	p.kind = r.kind(n)
+	p.optional = true
+	for _, child := range n.Children {
+		if nodeName(child) == "required" && len(child.Arguments) > 0 {
+			required, _ := child.Arguments[0].ResolvedValue().(bool)
+			p.optional = !required
+		}
+	}
+	return nil
+}
+
+// kind infers the kind of the values described by the validations in n's children
+func (r *schemaReader) kind(n *document.Node) kind {
+	k := kindUnknown
+	integer := false
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "type":
+			for _, arg := range child.Arguments {
+				switch arg.ResolvedValue() {
+				case "string":
+					k = k.merge(kindString)
+				case "boolean":
+					k = k.merge(kindBool)
+				case "number":
+					k = k.merge(kindFloat)
+				}
+			}
+		case "enum":
+			for _, arg := range child.Arguments {
+				k = k.merge(valueKind(arg))
+			}
+		case "%":
+			// numbers that must be a multiple of an integer are integers
+			_, integer = intArg(child)
+		}
+	}
+	if k == kindFloat && integer {
+		k = kindInt
+	}
+	return k
+}
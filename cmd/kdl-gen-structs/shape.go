@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// kind is the Go type inferred for a value
+type kind int
+
+const (
+	// kindUnknown is used for values about which nothing is known, such as null
+	kindUnknown kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+	// kindAny is used for values of conflicting kinds
+	kindAny
+)
+
+// merge returns the kind able to represent values of both k and o
+func (k kind) merge(o kind) kind {
+	switch {
+	case k == o || o == kindUnknown:
+		return k
+	case k == kindUnknown:
+		return o
+	case (k == kindInt && o == kindFloat) || (k == kindFloat && o == kindInt):
+		return kindFloat
+	default:
+		return kindAny
+	}
+}
+
+// goType returns the Go type used for values of kind k
+func (k kind) goType() string {
+	switch k {
+	case kindBool:
+		return "bool"
+	case kindInt:
+		return "int"
+	case kindFloat:
+		return "float64"
+	case kindString:
+		return "string"
+	default:
+		return "interface{}"
+	}
+}
+
+// valueKind returns the kind of v
+func valueKind(v *document.Value) kind {
+	switch v.ResolvedValue().(type) {
+	case bool:
+		return kindBool
+	case int64, *big.Int:
+		return kindInt
+	case float64, *big.Float:
+		return kindFloat
+	case string:
+		return kindString
+	case nil:
+		return kindUnknown
+	default:
+		return kindAny
+	}
+}
+
+// nodeShape describes the arguments, properties, and children observed in (or permitted by a schema for) the nodes
+// with a given name within a given parent
+type nodeShape struct {
+	name string
+	// minArgs and maxArgs are the minimum and maximum number of arguments; maxArgs is -1 if unlimited
+	minArgs int
+	maxArgs int
+	// args contains the kind of the argument at each position; if maxArgs is unlimited, the last kind applies to all
+	// subsequent arguments
+	args     []kind
+	props    []*propShape
+	children *nodeSet
+	// multiple is true if more than one such node may appear within the same parent
+	multiple bool
+	// optional is true if the node may be absent from its parent
+	optional bool
+	// seen is the number of nodes from which the shape was inferred, and parents is the number of distinct parents in
+	// which they appeared
+	seen    int
+	parents int
+}
+
+// propShape describes a property
+type propShape struct {
+	key      string
+	kind     kind
+	optional bool
+	// seen is the number of nodes in which the property was observed
+	seen int
+}
+
+// nodeSet describes the nodes that may appear at the top level of a document or within a node, in order of first
+// appearance
+type nodeSet struct {
+	nodes  []*nodeShape
+	byName map[string]*nodeShape
+}
+
+func newNodeSet() *nodeSet {
+	return &nodeSet{byName: make(map[string]*nodeShape)}
+}
+
+// get returns the shape of the nodes named name, adding it if necessary
+func (s *nodeSet) get(name string) *nodeShape {
+	if n, ok := s.byName[name]; ok {
+		return n
+	}
+	n := &nodeShape{name: name, children: newNodeSet()}
+	s.nodes = append(s.nodes, n)
+	s.byName[name] = n
+	return n
+}
+
+// hasStructure returns true if the nodes have properties or children, and therefore require a struct
+func (n *nodeShape) hasStructure() bool {
+	return len(n.props) > 0 || len(n.children.nodes) > 0
+}
+
+// argKind returns the kind able to represent all of the node's arguments
+func (n *nodeShape) argKind() kind {
+	k := kindUnknown
+	for _, a := range n.args {
+		k = k.merge(a)
+	}
+	return k
+}
+
+// prop returns the shape of the property named key, adding it if necessary
+func (n *nodeShape) prop(key string) *propShape {
+	for _, p := range n.props {
+		if p.key == key {
+			return p
+		}
+	}
+	p := &propShape{key: key}
+	n.props = append(n.props, p)
+	return p
+}
+
+// inferDocument infers the shape of the top-level nodes of one or more sample documents
+func inferDocument(docs []*document.Document) *nodeSet {
+	s := newNodeSet()
+	for _, doc := range docs {
+		s.infer(doc.Nodes)
+	}
+	s.finish(len(docs))
+	return s
+}
+
+// infer merges the shapes of nodes, all of which appear within the same parent, into s
+func (s *nodeSet) infer(nodes []*document.Node) {
+	counts := make(map[string]int)
+	for _, node := range nodes {
+		name := node.Name.ValueString()
+		n := s.get(name)
+		if counts[name]++; counts[name] == 1 {
+			n.parents++
+		} else {
+			n.multiple = true
+		}
+		n.infer(node)
+	}
+}
+
+// infer merges the shape of node into n
+func (n *nodeShape) infer(node *document.Node) {
+	argc := len(node.Arguments)
+	if n.seen == 0 || argc < n.minArgs {
+		n.minArgs = argc
+	}
+	if argc > n.maxArgs {
+		n.maxArgs = argc
+	}
+	for i, arg := range node.Arguments {
+		if i < len(n.args) {
+			n.args[i] = n.args[i].merge(valueKind(arg))
+		} else {
+			n.args = append(n.args, valueKind(arg))
+		}
+	}
+
+	for _, key := range node.Properties.Keys() {
+		v, _ := node.Properties.Get(key)
+		p := n.prop(key)
+		p.kind = p.kind.merge(valueKind(v))
+		p.seen++
+	}
+
+	n.children.infer(node.Children)
+	n.seen++
+}
+
+// finish marks the nodes and properties that were absent from some of the parents from which they were inferred as
+// optional; parents is the number of parents from which s was inferred
+func (s *nodeSet) finish(parents int) {
+	for _, n := range s.nodes {
+		n.optional = n.parents < parents
+		for _, p := range n.props {
+			p.optional = p.seen < n.seen
+		}
+		n.children.finish(n.seen)
+	}
+}
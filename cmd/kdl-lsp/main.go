@@ -0,0 +1,21 @@
+// Command kdl-lsp is a Language Server Protocol server for KDL documents.
+//
+// It communicates with the client over standard input and output, and provides:
+//
+//   - diagnostics for syntax errors, published whenever a document is opened or changed
+//   - hover information describing the node, argument, or property under the cursor
+//   - document symbols for each node, nested according to the document's structure
+//   - document formatting, which fails rather than drop comments other than those preceding a node, or
+//     slashdash-commented content
+//   - go-to-definition for ref properties containing KDL queries, such as those used by KDL Schema
+//
+// Documents are synchronized in full on every change.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	os.Exit(newServer(os.Stdin, os.Stdout).run())
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/cst"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/query"
+)
+
+// server is a Language Server Protocol server for KDL documents
+type server struct {
+	conn *conn
+	docs map[string]*textDocument
+	// shutdown is true once a shutdown request has been received
+	shutdown bool
+}
+
+// newServer returns a server that reads messages from r and writes them to w
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		conn: newConn(r, w),
+		docs: make(map[string]*textDocument),
+	}
+}
+
+// run processes messages until an exit notification is received or the input is closed, and returns the process exit
+// status: 0 if the client requested a shutdown before exiting, otherwise 1
+func (s *server) run() int {
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			return 1
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.respondError(nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, err := s.safeHandle(&req)
+		if req.isNotification() {
+			continue
+		}
+		if err != nil {
+			rerr, ok := err.(*responseError)
+			if !ok {
+				rerr = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+			s.respondError(req.ID, rerr)
+			continue
+		}
+		if err := s.conn.write(&response{JSONRPC: "2.0", ID: req.ID, Result: result}); err != nil {
+			return 1
+		}
+	}
+}
+
+// respondError sends an error response to the request identified by id
+func (s *server) respondError(id json.RawMessage, err *responseError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	_ = s.conn.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+}
+
+// notify sends a notification to the client
+func (s *server) notify(method string, params interface{}) {
+	_ = s.conn.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// safeHandle handles req and returns its result, recovering from any panic while doing so so that a single bad request
+// cannot take down the server
+func (s *server) safeHandle(req *request) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = &responseError{Code: codeInternalError, Message: fmt.Sprintf("%s: internal error: %v", req.Method, r)}
+		}
+	}()
+	return s.handle(req)
+}
+
+// handle handles req and returns its result
+func (s *server) handle(req *request) (interface{}, error) {
+	if s.shutdown && !req.isNotification() {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return &initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				HoverProvider:              true,
+				DocumentSymbolProvider:     true,
+				DocumentFormattingProvider: true,
+				DefinitionProvider:         true,
+			},
+			ServerInfo: serverInfo{Name: "kdl-lsp"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			// with full synchronization, the last change contains the entire document
+			s.update(params.TextDocument.URI, params.TextDocument.Version, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+		return nil, nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params)
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.format(params)
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	}
+
+	if req.isNotification() {
+		// unsupported notifications, including $/ notifications, are ignored
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not supported", req.Method)}
+}
+
+// unmarshalParams unmarshals the parameters of req into v
+func unmarshalParams(req *request, v interface{}) error {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// document returns the open document identified by uri
+func (s *server) document(uri string) (*textDocument, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document %q is not open", uri)}
+	}
+	return d, nil
+}
+
+// update parses the new text of the document identified by uri and publishes its diagnostics
+func (s *server) update(uri string, version int, text string) {
+	d := newTextDocument(uri, version, text)
+	s.docs[uri] = d
+
+	diags := make([]diagnostic, 0, len(d.errs))
+	for _, err := range d.errs {
+		start := d.position(err.Offset)
+		end := start
+		if len(err.Token.Data) > 0 {
+			end = d.position(err.Offset + len(err.Token.Data))
+		}
+		diags = append(diags, diagnostic{
+			Range:    lspRange{Start: start, End: end},
+			Severity: diagnosticSeverityError,
+			Source:   "kdl",
+			Message:  fmt.Sprintf("%s failed: %v", err.Op, err.Err),
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: uri, Version: version, Diagnostics: diags})
+}
+
+// hover describes the node, argument, or property at the requested position
+func (s *server) hover(params textDocumentPositionParams) (interface{}, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	t := d.find(d.offset(params.Position))
+	if t == nil {
+		return nil, nil
+	}
+
+	path := strings.Join(t.path, " > ")
+	var b strings.Builder
+	span := t.node.Name.Span
+	switch {
+	case t.value == nil:
+		fmt.Fprintf(&b, "**%s**", path)
+		if len(t.node.Type) > 0 {
+			fmt.Fprintf(&b, " (type `%s`)", t.node.Type)
+		}
+		fmt.Fprintf(&b, "\n\n%d argument(s), %d property(ies), %d child(ren)", len(t.node.Arguments), t.node.Properties.Len(), len(t.node.Children))
+	case t.argument >= 0:
+		fmt.Fprintf(&b, "**%s** argument %d: %s", path, t.argument, describeValue(t.value))
+		span = t.value.Span
+	default:
+		fmt.Fprintf(&b, "**%s** property `%s`: %s", path, t.property, describeValue(t.value))
+		span = t.value.Span
+	}
+
+	h := &hover{Contents: markupContent{Kind: "markdown", Value: b.String()}}
+	if span.IsValid() {
+		r := d.spanRange(span)
+		h.Range = &r
+	}
+	return h, nil
+}
+
+// describeValue returns a description of v's type and value for use in a hover
+func describeValue(v *document.Value) string {
+	var kind string
+	switch v.Value.(type) {
+	case nil:
+		kind = "null"
+	case bool:
+		kind = "boolean"
+	case string:
+		kind = "string"
+	case int64, *big.Int:
+		kind = "integer"
+	case float64, *big.Float:
+		kind = "float"
+	case document.SuffixedDecimal:
+		kind = "suffixed number"
+	default:
+		kind = fmt.Sprintf("%T", v.Value)
+	}
+	if len(v.Type) > 0 {
+		kind += fmt.Sprintf(" (type `%s`)", v.Type)
+	}
+	return fmt.Sprintf("%s `%s`", kind, v.UnformattedString())
+}
+
+// documentSymbols returns a symbol for each node in the requested document
+func (s *server) documentSymbols(params documentSymbolParams) (interface{}, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.symbols(d.doc.Nodes), nil
+}
+
+// symbols returns a symbol for each of nodes, with their children as nested symbols
+func (d *textDocument) symbols(nodes []*document.Node) []documentSymbol {
+	symbols := make([]documentSymbol, 0, len(nodes))
+	for _, n := range nodes {
+		if !n.Span.IsValid() {
+			continue
+		}
+		sym := documentSymbol{
+			Name:           n.Name.NodeNameString(),
+			Kind:           symbolKindProperty,
+			Range:          d.spanRange(n.Span),
+			SelectionRange: d.spanRange(n.Name.Span),
+		}
+		if sym.Name == "" {
+			// clients reject symbols with empty names
+			sym.Name = `""`
+		}
+		details := make([]string, 0, len(n.Arguments)+n.Properties.Len())
+		for _, arg := range n.Arguments {
+			details = append(details, arg.UnformattedString())
+		}
+		for _, key := range n.Properties.Keys() {
+			prop, _ := n.Properties.Get(key)
+			details = append(details, key+"="+prop.UnformattedString())
+		}
+		sym.Detail = strings.Join(details, " ")
+		if len(n.Children) > 0 {
+			sym.Kind = symbolKindObject
+			if len(n.Arguments) == 0 && !n.Properties.Exist() {
+				sym.Kind = symbolKindNamespace
+			}
+			sym.Children = d.symbols(n.Children)
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// format reformats the requested document via the generator; documents containing syntax errors are not formatted,
+// and documents containing comments that the generator would drop (any other than those preceding a node, and
+// slashdash-commented content) fail to format rather than lose them
+func (s *server) format(params documentFormattingParams) (interface{}, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.errs) > 0 {
+		return nil, nil
+	}
+
+	opts := kdl.DefaultGenerateOptions
+	if params.Options.InsertSpaces && params.Options.TabSize > 0 {
+		opts.Indent = strings.Repeat(" ", params.Options.TabSize)
+	}
+	var b bytes.Buffer
+	if err := kdl.GenerateWithOptions(d.doc, &b, opts); err != nil {
+		return nil, err
+	}
+
+	popts := kdl.ParseOptions{Flags: parser.ParseComments}
+	src, err := cst.ParseBytes([]byte(d.text), popts)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := cst.ParseBytes(b.Bytes(), popts)
+	if err != nil {
+		return nil, fmt.Errorf("formatted document is invalid: %w", err)
+	}
+	if missing := src.MissingComments(formatted); len(missing) > 0 {
+		return nil, &responseError{
+			Code:    codeRequestFailed,
+			Message: fmt.Sprintf("formatting would drop %d comment(s), starting with %q", len(missing), missing[0]),
+		}
+	}
+
+	if b.String() == d.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{Range: d.fullRange(), NewText: b.String()}}, nil
+}
+
+// definition resolves a ref property at the requested position, such as those used by KDL Schema, by treating its
+// value as a KDL query and returning the locations of the matching nodes
+func (s *server) definition(params textDocumentPositionParams) (interface{}, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	t := d.find(d.offset(params.Position))
+	if t == nil || t.value == nil || t.property != "ref" {
+		return nil, nil
+	}
+	ref, ok := t.value.Value.(string)
+	if !ok {
+		return nil, nil
+	}
+	matches, err := query.Select(d.doc, ref)
+	if err != nil {
+		return nil, nil
+	}
+
+	locations := make([]location, 0, len(matches))
+	for _, n := range matches {
+		if n.Span.IsValid() {
+			locations = append(locations, location{URI: d.uri, Range: d.spanRange(n.Span)})
+		}
+	}
+	return locations, nil
+}
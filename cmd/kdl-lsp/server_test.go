@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// client is a test client communicating with a server over a pipe
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// notifications received while waiting for responses
+	notifications []request
+	done          chan int
+}
+
+func newClient(t *testing.T) *client {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	c := &client{t: t, conn: newConn(clientReader, clientWriter), done: make(chan int, 1)}
+	go func() {
+		status := newServer(serverReader, serverWriter).run()
+		serverWriter.Close()
+		c.done <- status
+	}()
+	t.Cleanup(func() {
+		clientWriter.Close()
+	})
+	return c
+}
+
+// read reads the next message from the server
+func (c *client) read() map[string]json.RawMessage {
+	body, err := c.conn.read()
+	if err != nil {
+		c.t.Fatalf("read() failed: %v", err)
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("invalid message %s: %v", body, err)
+	}
+	return msg
+}
+
+// notify sends a notification to the server
+func (c *client) notify(method string, params interface{}) {
+	if err := c.conn.write(&notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		c.t.Fatalf("write() failed: %v", err)
+	}
+}
+
+// call sends a request to the server and stores its result in result, returning the error response if any
+func (c *client) call(method string, params interface{}, result interface{}) *responseError {
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	req := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  interface{}     `json:"params"`
+	}{"2.0", id, method, params}
+	if err := c.conn.write(&req); err != nil {
+		c.t.Fatalf("write() failed: %v", err)
+	}
+
+	for {
+		msg := c.read()
+		if _, ok := msg["id"]; !ok {
+			var n request
+			_ = json.Unmarshal(msg["method"], &n.Method)
+			n.Params = msg["params"]
+			c.notifications = append(c.notifications, n)
+			continue
+		}
+		if string(msg["id"]) != string(id) {
+			c.t.Fatalf("unexpected response ID %s, want %s", msg["id"], id)
+		}
+		if e, ok := msg["error"]; ok {
+			var rerr responseError
+			_ = json.Unmarshal(e, &rerr)
+			return &rerr
+		}
+		if err := json.Unmarshal(msg["result"], result); err != nil {
+			c.t.Fatalf("invalid result %s: %v", msg["result"], err)
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the next diagnostics notification
+func (c *client) diagnostics() publishDiagnosticsParams {
+	var params publishDiagnosticsParams
+	for len(c.notifications) == 0 {
+		msg := c.read()
+		var n request
+		_ = json.Unmarshal(msg["method"], &n.Method)
+		n.Params = msg["params"]
+		c.notifications = append(c.notifications, n)
+	}
+	n := c.notifications[0]
+	c.notifications = c.notifications[1:]
+	if n.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("unexpected notification %s", n.Method)
+	}
+	if err := json.Unmarshal(n.Params, &params); err != nil {
+		c.t.Fatalf("invalid diagnostics %s: %v", n.Params, err)
+	}
+	return params
+}
+
+const testURI = "file:///test.kdl"
+
+func (c *client) open(text string) publishDiagnosticsParams {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": textDocumentItem{URI: testURI, LanguageID: "kdl", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func positionParams(line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: testURI},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+func TestServerLifecycle(t *testing.T) {
+	c := newClient(t)
+
+	var init initializeResult
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &init); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if !init.Capabilities.HoverProvider || init.Capabilities.TextDocumentSync != textDocumentSyncFull {
+		t.Fatalf("initialize: unexpected capabilities %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	var result interface{}
+	if err := c.call("textDocument/unknown", struct{}{}, &result); err == nil || err.Code != codeMethodNotFound {
+		t.Fatalf("unknown method: got %v, want method not found", err)
+	}
+
+	if err := c.call("shutdown", nil, &result); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	c.notify("exit", nil)
+	if status := <-c.done; status != 0 {
+		t.Fatalf("exit status: got %d, want 0", status)
+	}
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	diags := c.open("a 1\nb }\n\"ü\" 2 }\n")
+	want := []diagnostic{
+		{Range: lspRange{Start: position{1, 2}, End: position{1, 3}}, Severity: diagnosticSeverityError, Source: "kdl", Message: "parse failed: unexpected BraceClose in state stateNodeParams"},
+		{Range: lspRange{Start: position{2, 6}, End: position{2, 7}}, Severity: diagnosticSeverityError, Source: "kdl", Message: "parse failed: unexpected BraceClose in state stateNodeParams"},
+	}
+	if !reflect.DeepEqual(diags.Diagnostics, want) {
+		t.Fatalf("diagnostics:\ngot : %+v\nwant: %+v", diags.Diagnostics, want)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": testURI, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": "a 1\n"}},
+	})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 || diags.Version != 2 {
+		t.Fatalf("diagnostics after change: got %+v, want none", diags)
+	}
+}
+
+func TestServerFeatures(t *testing.T) {
+	c := newClient(t)
+	c.open(`server "web" port=8080 {
+    listen "127.0.0.1"
+}
+definitions {
+    limits id="default" max=10
+}
+uses ref="[id=\"default\"]"
+`)
+
+	tests := []struct {
+		name   string
+		method string
+		params interface{}
+		want   string
+	}{
+		{
+			name:   "hover node",
+			method: "textDocument/hover",
+			params: positionParams(1, 6),
+			want:   `{"contents":{"kind":"markdown","value":"**server \u003e listen**\n\n1 argument(s), 0 property(ies), 0 child(ren)"},"range":{"start":{"line":1,"character":4},"end":{"line":1,"character":10}}}`,
+		},
+		{
+			name:   "hover argument",
+			method: "textDocument/hover",
+			params: positionParams(0, 9),
+			want:   "{\"contents\":{\"kind\":\"markdown\",\"value\":\"**server** argument 0: string `\\\"web\\\"`\"},\"range\":{\"start\":{\"line\":0,\"character\":7},\"end\":{\"line\":0,\"character\":12}}}",
+		},
+		{
+			name:   "hover property",
+			method: "textDocument/hover",
+			params: positionParams(0, 20),
+			want:   "{\"contents\":{\"kind\":\"markdown\",\"value\":\"**server** property `port`: integer `8080`\"},\"range\":{\"start\":{\"line\":0,\"character\":13},\"end\":{\"line\":0,\"character\":22}}}",
+		},
+		{
+			name:   "hover outside nodes",
+			method: "textDocument/hover",
+			params: positionParams(7, 0),
+			want:   `null`,
+		},
+		{
+			name:   "definition",
+			method: "textDocument/definition",
+			params: positionParams(6, 10),
+			want:   `[{"uri":"file:///test.kdl","range":{"start":{"line":4,"character":4},"end":{"line":4,"character":30}}}]`,
+		},
+		{
+			name:   "definition outside ref",
+			method: "textDocument/definition",
+			params: positionParams(6, 1),
+			want:   `null`,
+		},
+		{
+			name:   "formatting",
+			method: "textDocument/formatting",
+			params: map[string]interface{}{
+				"textDocument": textDocumentIdentifier{URI: testURI},
+				"options":      map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+			},
+			want: `[{"range":{"start":{"line":0,"character":0},"end":{"line":7,"character":0}},"newText":"server \"web\" port=8080 {\n  listen \"127.0.0.1\"\n}\ndefinitions {\n  limits id=\"default\" max=10\n}\nuses ref=\"[id=\\\"default\\\"]\"\n"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got json.RawMessage
+			if err := c.call(tt.method, tt.params, &got); err != nil {
+				t.Fatalf("%s failed: %v", tt.method, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("%s:\ngot : %s\nwant: %s", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open("server \"web\" {\n\tlisten 80\n}\nempty\n")
+
+	var got []documentSymbol
+	if err := c.call("textDocument/documentSymbol", documentSymbolParams{TextDocument: textDocumentIdentifier{URI: testURI}}, &got); err != nil {
+		t.Fatalf("documentSymbol failed: %v", err)
+	}
+	want := []documentSymbol{
+		{
+			Name:           "server",
+			Detail:         `"web"`,
+			Kind:           symbolKindObject,
+			Range:          lspRange{Start: position{0, 0}, End: position{2, 1}},
+			SelectionRange: lspRange{Start: position{0, 0}, End: position{0, 6}},
+			Children: []documentSymbol{
+				{
+					Name:           "listen",
+					Detail:         "80",
+					Kind:           symbolKindProperty,
+					Range:          lspRange{Start: position{1, 1}, End: position{1, 10}},
+					SelectionRange: lspRange{Start: position{1, 1}, End: position{1, 7}},
+				},
+			},
+		},
+		{
+			Name:           "empty",
+			Kind:           symbolKindProperty,
+			Range:          lspRange{Start: position{3, 0}, End: position{3, 5}},
+			SelectionRange: lspRange{Start: position{3, 0}, End: position{3, 5}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("documentSymbol:\ngot : %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestServerFormattingComments(t *testing.T) {
+	c := newClient(t)
+	params := documentFormattingParams{TextDocument: textDocumentIdentifier{URI: testURI}}
+
+	c.open("// leading\na   1\n")
+	var edits []textEdit
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %v", err)
+	}
+	if len(edits) != 1 || edits[0].NewText != "// leading\na 1\n" {
+		t.Fatalf("formatting: got %+v, want leading comment retained", edits)
+	}
+
+	c.open("a 1 // trailing\n/-b\n")
+	err := c.call("textDocument/formatting", params, &edits)
+	if err == nil || err.Code != codeRequestFailed || err.Message != `formatting would drop 2 comment(s), starting with "// trailing"` {
+		t.Fatalf("formatting: got %v, want request failed", err)
+	}
+}
+
+func TestServerRecover(t *testing.T) {
+	s := newServer(strings.NewReader(""), io.Discard)
+	// a nil document causes hover to panic
+	s.docs[testURI] = nil
+	params, _ := json.Marshal(positionParams(0, 0))
+
+	_, err := s.safeHandle(&request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "textDocument/hover", Params: params})
+	rerr, ok := err.(*responseError)
+	if !ok || rerr.Code != codeInternalError || !strings.HasPrefix(rerr.Message, "textDocument/hover: internal error: ") {
+		t.Fatalf("safeHandle() = %v, want internal error", err)
+	}
+}
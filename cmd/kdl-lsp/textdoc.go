@@ -0,0 +1,136 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+)
+
+// textDocument is an open document and the result of parsing it
+type textDocument struct {
+	uri     string
+	version int
+	text    string
+	// lines contains the byte offset at which each line begins
+	lines []int
+	doc   *document.Document
+	errs  kdl.SyntaxErrors
+}
+
+// newTextDocument parses text and returns a textDocument for it
+func newTextDocument(uri string, version int, text string) *textDocument {
+	d := &textDocument{uri: uri, version: version, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	d.doc, d.errs = kdl.ParseRecover(strings.NewReader(text), kdl.ParseOptions{Flags: parser.ParseComments})
+	return d
+}
+
+// position returns the LSP position of byte offset
+func (d *textDocument) position(offset int) position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	} else if offset < 0 {
+		offset = 0
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	chars := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		chars += utf16.RuneLen(r)
+	}
+	return position{Line: line, Character: chars}
+}
+
+// offset returns the byte offset of LSP position p
+func (d *textDocument) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	} else if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[p.Line]
+	for chars := 0; chars < p.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		chars += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// spanRange returns the LSP range of span
+func (d *textDocument) spanRange(span document.Span) lspRange {
+	return lspRange{Start: d.position(span.Start.Offset), End: d.position(span.End.Offset)}
+}
+
+// fullRange returns the LSP range of the entire document
+func (d *textDocument) fullRange() lspRange {
+	return lspRange{Start: position{}, End: d.position(len(d.text))}
+}
+
+// contains returns true if span contains byte offset
+func contains(span document.Span, offset int) bool {
+	return span.IsValid() && span.Start.Offset <= offset && offset < span.End.Offset
+}
+
+// target describes the part of a document at a given position
+type target struct {
+	// node is the innermost node containing the position, and path lists the names of its ancestors and itself
+	node *document.Node
+	path []string
+	// value is the argument or property value at the position, if any
+	value *document.Value
+	// argument is the index of value in node's arguments, or -1 if value is a property
+	argument int
+	// property is the key of value in node's properties, if value is a property
+	property string
+}
+
+// find returns the target at byte offset, or nil if offset is not within a node
+func (d *textDocument) find(offset int) *target {
+	var t *target
+	nodes := d.doc.Nodes
+	var path []string
+	for {
+		var found *document.Node
+		for _, n := range nodes {
+			if contains(n.Span, offset) {
+				found = n
+				break
+			}
+		}
+		if found == nil {
+			break
+		}
+		path = append(path, found.Name.NodeNameString())
+		t = &target{node: found, path: append([]string(nil), path...), argument: -1}
+		nodes = found.Children
+	}
+	if t == nil {
+		return nil
+	}
+
+	for i, arg := range t.node.Arguments {
+		if contains(arg.Span, offset) {
+			t.value, t.argument = arg, i
+			return t
+		}
+	}
+	for key, prop := range t.node.Properties.Unordered() {
+		if contains(prop.Span, offset) {
+			t.value, t.property = prop, key
+			return t
+		}
+	}
+	return t
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sblinch/kdl-go"
+)
+
+// runCheck implements the check command
+func runCheck(e *env, args []string) int {
+	fs := newFlagSet(e, "check")
+	var pf parseFlags
+	pf.register(fs)
+	quiet := fs.Bool("q", false, "report only the file names of invalid documents")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	inputs, err := readInputs(e, fs.Args())
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	status := exitOK
+	for _, in := range inputs {
+		_, errs := kdl.ParseRecover(bytes.NewReader(in.data), pf.options())
+		if len(errs) == 0 {
+			continue
+		}
+		status = exitFailure
+		if *quiet {
+			fmt.Fprintln(e.stdout, in.name)
+			continue
+		}
+		for _, err := range errs {
+			fmt.Fprintf(e.stdout, "%s:%d:%d: %s failed: %v\n", in.name, err.Line, err.Column, err.Op, err.Err)
+		}
+	}
+	return status
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+)
+
+// runConvert implements the convert command
+func runConvert(e *env, args []string) int {
+	fs := newFlagSet(e, "convert")
+	var pf parseFlags
+	pf.register(fs)
+	opts := kdl.DefaultGenerateOptions
+	fs.StringVar(&opts.Indent, "indent", opts.Indent, "indent child nodes with `string`")
+	inPlace := fs.Bool("w", false, "rewrite files in place instead of writing to standard output")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if !pf.nginx && !pf.yamlToml && !pf.suffixes {
+		// with no relaxed syntax specified, accept all of them
+		pf.nginx, pf.yamlToml, pf.suffixes = true, true, true
+	}
+
+	inputs, err := readInputs(e, fs.Args())
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	status := exitOK
+	for _, in := range inputs {
+		doc, ok := parse(e, in, pf.options())
+		if !ok {
+			status = exitError
+			continue
+		}
+		strictNodes(doc.Nodes)
+
+		var b bytes.Buffer
+		if err := kdl.GenerateWithOptions(doc, &b, opts); err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+			continue
+		}
+		if err := writeOutput(e, in, b.Bytes(), *inPlace); err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+		}
+	}
+	return status
+}
+
+// strictNodes rewrites any values in nodes (and their descendants) that can only be represented in relaxed syntax
+func strictNodes(nodes []*document.Node) {
+	for _, n := range nodes {
+		for _, arg := range n.Arguments {
+			strictValue(arg)
+		}
+		for _, prop := range n.Properties.Unordered() {
+			strictValue(prop)
+		}
+		strictNodes(n.Children)
+	}
+}
+
+// strictValue rewrites v as a string if it can only be represented in relaxed syntax, such as a number with a
+// multiplier suffix (eg: 10ms)
+func strictValue(v *document.Value) {
+	switch x := v.Value.(type) {
+	case document.SuffixedDecimal:
+		v.Value = x.String()
+		v.Flag = document.FlagQuoted
+	case string:
+		if v.Flag == document.FlagBareSuffixed || v.Flag == document.FlagNone {
+			v.Flag = document.FlagQuoted
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/cst"
+)
+
+// runFmt implements the fmt command
+func runFmt(e *env, args []string) int {
+	fs := newFlagSet(e, "fmt")
+	var pf parseFlags
+	pf.register(fs)
+	opts := kdl.DefaultGenerateOptions
+	fs.StringVar(&opts.Indent, "indent", opts.Indent, "indent child nodes with `string`")
+	fs.BoolVar(&opts.AddSemicolons, "semicolons", false, "terminate nodes with semicolons")
+	fs.BoolVar(&opts.IgnoreFlags, "ignore-flags", false, "write numbers in decimal and strings quoted, regardless of their original notation")
+	list := fs.Bool("l", false, "list files whose formatting differs, without rewriting them")
+	stdout := fs.Bool("stdout", false, "write the result to standard output instead of rewriting files")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	inputs, err := readInputs(e, fs.Args())
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	status := exitOK
+	for _, in := range inputs {
+		tree, err := cst.ParseBytes(in.data, pf.options())
+		if err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+			continue
+		}
+
+		var b bytes.Buffer
+		if err := kdl.GenerateWithOptions(tree.Document, &b, opts); err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+			continue
+		}
+		// the generator does not retain every comment (such as those within a node, or slashdash-commented content),
+		// so refuse to reformat documents that would lose any
+		if missing, err := missingComments(tree, b.Bytes(), pf.options()); err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+			continue
+		} else if len(missing) > 0 {
+			e.errorf("%s: formatting would drop %d comment(s), starting with %q; leaving it unchanged", in.name, len(missing), missing[0])
+			status = exitError
+			continue
+		}
+
+		if *list {
+			if !bytes.Equal(b.Bytes(), in.data) {
+				fmt.Fprintln(e.stdout, in.name)
+			}
+			continue
+		}
+		if err := writeOutput(e, in, b.Bytes(), !*stdout); err != nil {
+			e.errorf("%s: %v", in.name, err)
+			status = exitError
+		}
+	}
+	return status
+}
+
+// missingComments returns the comments in tree's source that do not appear in the formatted document out
+func missingComments(tree *cst.Tree, out []byte, opts kdl.ParseOptions) ([]string, error) {
+	formatted, err := cst.ParseBytes(out, opts)
+	if err != nil {
+		return nil, fmt.Errorf("formatted document is invalid: %w", err)
+	}
+	return tree.MissingComments(formatted), nil
+}
@@ -0,0 +1,206 @@
+// Command kdl formats, checks, converts, and queries KDL documents.
+//
+// Usage:
+//
+//	kdl <command> [flags] [file ...]
+//
+// The commands are:
+//
+//	fmt      reformat KDL documents, rewriting files in place
+//	check    parse KDL documents and report syntax errors
+//	convert  convert relaxed (nginx-style or YAML/TOML-style) documents to strict KDL
+//	query    print the nodes matching a KDL query
+//
+// If no files are given, each command reads from standard input and writes to standard output. Comments preceding a
+// node are retained when a document is rewritten by fmt or convert; as any other comments (such as those following a
+// node or within it) and slashdash-commented content would be lost, fmt reports an error and leaves documents
+// containing them unchanged.
+//
+// kdl exits with status 0 on success, 1 if check finds syntax errors or query finds no matching nodes, and 2 on any
+// other error.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/relaxed"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitError   = 2
+)
+
+// env provides a command's standard input and output streams
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errorf writes a formatted error message to stderr
+func (e *env) errorf(format string, v ...interface{}) {
+	fmt.Fprintf(e.stderr, "kdl: "+format+"\n", v...)
+}
+
+// command is a kdl subcommand
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(e *env, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "fmt", args: "[flags] [file ...]", short: "reformat KDL documents, rewriting files in place", run: runFmt},
+		{name: "check", args: "[flags] [file ...]", short: "parse KDL documents and report syntax errors", run: runCheck},
+		{name: "convert", args: "[flags] [file ...]", short: "convert relaxed documents to strict KDL", run: runConvert},
+		{name: "query", args: "[flags] query [file ...]", short: "print the nodes matching a KDL query", run: runQuery},
+	}
+}
+
+// usage writes the top-level usage message to w
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: kdl <command> [flags] [file ...]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nrun 'kdl <command> -h' for details on a command\n")
+}
+
+// run runs the kdl command with args (excluding the program name) and returns the exit status
+func run(e *env, args []string) int {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return exitError
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(e, args[1:])
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		usage(e.stdout)
+		return exitOK
+	}
+	e.errorf("unknown command %q", args[0])
+	usage(e.stderr)
+	return exitError
+}
+
+func main() {
+	os.Exit(run(&env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// newFlagSet returns a FlagSet for command c that writes its output to e.stderr
+func newFlagSet(e *env, c string) *flag.FlagSet {
+	fs := flag.NewFlagSet("kdl "+c, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	for _, cmd := range commands {
+		if cmd.name == c {
+			fs.Usage = func() {
+				fmt.Fprintf(e.stderr, "usage: kdl %s %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.short)
+				fs.PrintDefaults()
+			}
+		}
+	}
+	return fs
+}
+
+// parseFlags holds the flags controlling how documents are parsed
+type parseFlags struct {
+	nginx    bool
+	yamlToml bool
+	suffixes bool
+}
+
+// register registers the parse flags in fs
+func (p *parseFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&p.nginx, "nginx", false, "accept nginx-style syntax")
+	fs.BoolVar(&p.yamlToml, "yaml-toml", false, "accept YAML/TOML-style assignments (name: value, name = value)")
+	fs.BoolVar(&p.suffixes, "suffixes", false, "accept multiplier suffixes on numbers (10ms, 32kb)")
+}
+
+// options returns the ParseOptions described by p; comments are always retained so that they are not lost when
+// documents are rewritten
+func (p *parseFlags) options() kdl.ParseOptions {
+	opts := kdl.ParseOptions{Flags: parser.ParseComments}
+	if p.nginx {
+		opts.RelaxedNonCompliant |= relaxed.NGINXSyntax
+	}
+	if p.yamlToml {
+		opts.RelaxedNonCompliant |= relaxed.YAMLTOMLAssignments
+	}
+	if p.suffixes {
+		opts.RelaxedNonCompliant |= relaxed.MultiplierSuffixes
+	}
+	return opts
+}
+
+// input is a document to be processed by a command
+type input struct {
+	// name is the file name, or "<stdin>"
+	name string
+	// path is the file path, or "" for standard input
+	path string
+	data []byte
+}
+
+// readInputs reads the files named in paths, or standard input if paths is empty
+func readInputs(e *env, paths []string) ([]input, error) {
+	if len(paths) == 0 {
+		data, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading standard input: %w", err)
+		}
+		return []input{{name: "<stdin>", data: data}}, nil
+	}
+
+	inputs := make([]input, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{name: path, path: path, data: data})
+	}
+	return inputs, nil
+}
+
+// parse parses in using opts, and reports any error to e prefixed with the input name
+func parse(e *env, in input, opts kdl.ParseOptions) (*document.Document, bool) {
+	doc, err := kdl.ParseWithOptions(bytes.NewReader(in.data), opts)
+	if err != nil {
+		e.errorf("%s: %v", in.name, err)
+		return nil, false
+	}
+	return doc, true
+}
+
+// writeOutput writes data to the file from which in was read if inPlace is true and in was read from a file,
+// otherwise to standard output
+func writeOutput(e *env, in input, data []byte, inPlace bool) error {
+	if !inPlace || in.path == "" {
+		_, err := e.stdout.Write(data)
+		return err
+	}
+	if bytes.Equal(data, in.data) {
+		return nil
+	}
+	fi, err := os.Stat(in.path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(in.path, data, fi.Mode().Perm())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantStatus int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "fmt",
+			args:       []string{"fmt"},
+			stdin:      "a   1 {\n  b key=\"val\"; c\n}\n",
+			wantStdout: "a 1 {\n\tb key=\"val\"\n\tc\n}\n",
+		},
+		{
+			name:       "fmt with options",
+			args:       []string{"fmt", "-indent", "  ", "-semicolons", "-ignore-flags"},
+			stdin:      "a 0x10 {\n  b\n}\n",
+			wantStdout: "a 16 {\n  b;\n}\n",
+		},
+		{
+			name:       "fmt retains leading comments",
+			args:       []string{"fmt"},
+			stdin:      "// a\na   1\n",
+			wantStdout: "// a\na 1\n",
+		},
+		{
+			name:       "fmt dropping comments",
+			args:       []string{"fmt"},
+			stdin:      "a 1 // trailing\n/-b\n",
+			wantStatus: exitError,
+			wantStderr: "kdl: <stdin>: formatting would drop 2 comment(s), starting with \"// trailing\"; leaving it unchanged",
+		},
+		{
+			name:       "fmt syntax error",
+			args:       []string{"fmt"},
+			stdin:      "a {\n",
+			wantStatus: exitError,
+			wantStderr: "kdl: <stdin>: ",
+		},
+		{
+			name:  "check valid",
+			args:  []string{"check"},
+			stdin: "a 1\nb {\n\tc\n}\n",
+		},
+		{
+			name:       "check invalid",
+			args:       []string{"check"},
+			stdin:      "a 1\nb }\nc\nd 1 }\n",
+			wantStatus: exitFailure,
+			wantStdout: "<stdin>:2:3: parse failed: unexpected BraceClose in state stateNodeParams\n<stdin>:4:5: parse failed: unexpected BraceClose in state stateNodeParams\n",
+		},
+		{
+			name:       "check relaxed",
+			args:       []string{"check", "-nginx"},
+			stdin:      "location / {\n\troot /var/www;\n}\n",
+			wantStatus: exitOK,
+		},
+		{
+			name:       "convert nginx",
+			args:       []string{"convert"},
+			stdin:      "server {\n  listen 80;\n  server_name example.com;\n  timeout 10s;\n  location / {\n    root /var/www;\n  }\n}\n",
+			wantStdout: "server {\n\tlisten 80\n\tserver_name \"example.com\"\n\ttimeout \"10s\"\n\tlocation \"/\" {\n\t\troot \"/var/www\"\n\t}\n}\n",
+		},
+		{
+			name:       "convert yaml/toml",
+			args:       []string{"convert", "-yaml-toml"},
+			stdin:      "port: 8080\nname = \"x\"\n",
+			wantStdout: "port 8080\nname \"x\"\n",
+		},
+		{
+			name:       "query",
+			args:       []string{"query", "top() > server > listen"},
+			stdin:      "server {\n\tlisten 80\n\tlisten 443\n}\nlisten 1\n",
+			wantStdout: "listen 80\nlisten 443\n",
+		},
+		{
+			name:       "query with positions",
+			args:       []string{"query", "-H", "server"},
+			stdin:      "// comment\nserver {\n\tlisten 80\n}\n",
+			wantStdout: "<stdin>:2:1: server\n",
+		},
+		{
+			name:       "query without matches",
+			args:       []string{"query", "missing"},
+			stdin:      "server\n",
+			wantStatus: exitFailure,
+		},
+		{
+			name:       "query invalid",
+			args:       []string{"query", "a[", "-"},
+			wantStatus: exitError,
+			wantStderr: "kdl: invalid query",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"bogus"},
+			wantStatus: exitError,
+			wantStderr: "kdl: unknown command \"bogus\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			e := &env{stdin: strings.NewReader(tt.stdin), stdout: &stdout, stderr: &stderr}
+			if got := run(e, tt.args); got != tt.wantStatus {
+				t.Fatalf("run() status:\ngot : %d\nwant: %d\nstderr: %s", got, tt.wantStatus, stderr.String())
+			}
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("run() stdout:\ngot : %q\nwant: %q", got, tt.wantStdout)
+			}
+			if !strings.HasPrefix(stderr.String(), tt.wantStderr) {
+				t.Fatalf("run() stderr:\ngot : %q\nwant: %q...", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestFmtInPlace(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "formatted.kdl")
+	unformatted := filepath.Join(dir, "unformatted.kdl")
+	if err := os.WriteFile(formatted, []byte("a 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unformatted, []byte("a   1 {  b; }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	e := &env{stdout: &stdout, stderr: &stderr}
+	if got := run(e, []string{"fmt", "-l", formatted, unformatted}); got != exitOK {
+		t.Fatalf("fmt -l status: got %d, stderr: %s", got, stderr.String())
+	}
+	if got, want := stdout.String(), unformatted+"\n"; got != want {
+		t.Fatalf("fmt -l:\ngot : %q\nwant: %q", got, want)
+	}
+
+	if got := run(e, []string{"fmt", formatted, unformatted}); got != exitOK {
+		t.Fatalf("fmt status: got %d, stderr: %s", got, stderr.String())
+	}
+	data, err := os.ReadFile(unformatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "a 1 {\n\tb\n}\n"; got != want {
+		t.Fatalf("fmt:\ngot : %q\nwant: %q", got, want)
+	}
+}
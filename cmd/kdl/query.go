@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/query"
+)
+
+// runQuery implements the query command
+func runQuery(e *env, args []string) int {
+	fs := newFlagSet(e, "query")
+	var pf parseFlags
+	pf.register(fs)
+	names := fs.Bool("names", false, "print only the names of matching nodes")
+	withFile := fs.Bool("H", false, "prefix each match with its file name and position")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	q, err := query.Compile(fs.Arg(0))
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+	inputs, err := readInputs(e, fs.Args()[1:])
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	status := exitFailure
+	for _, in := range inputs {
+		doc, ok := parse(e, in, pf.options())
+		if !ok {
+			return exitError
+		}
+
+		for _, n := range q.Match(doc) {
+			if status == exitFailure {
+				status = exitOK
+			}
+			if *withFile {
+				fmt.Fprintf(e.stdout, "%s:%d:%d: ", in.name, n.Span.Start.Line, n.Span.Start.Column)
+			}
+			if *names {
+				fmt.Fprintln(e.stdout, n.Name.NodeNameString())
+				continue
+			}
+			if *withFile {
+				// print only the node itself, so that each match occupies a single line
+				n = n.ShallowCopy()
+				n.Children = nil
+				n.Comment = nil
+			}
+			if err := kdl.Generate(&document.Document{Nodes: []*document.Node{n}}, e.stdout); err != nil {
+				e.errorf("%s: %v", in.name, err)
+				return exitError
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,105 @@
+// Package codegen provides the runtime support for the MarshalKDL and UnmarshalKDL methods generated by
+// kdl-gen-marshal; it is not intended to be used directly.
+//
+// Generated methods locate a struct's fields, handle values of the common scalar types (booleans, strings, numbers,
+// and time.Duration), slices of them, and other structs with generated methods, and check the constraints on fields of
+// those scalar types, without reflection; all other values, and all values when custom marshalers or unmarshalers are
+// registered, are handled by the same reflective functions used by kdl-go's Marshal and Unmarshal. Generated methods
+// are otherwise equivalent to reflective marshaling and unmarshaling: they honor the same struct tags and options, and
+// produce the same results and errors. Marshal and Unmarshal call the generated MarshalKDL and UnmarshalKDL methods as
+// they would any others, and the methods retrieve the caller's options via EncoderFor and DecoderFor.
+package codegen
+
+import (
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/marshaler"
+)
+
+type (
+	// UnmarshalOptions configures unmarshaling; it is identical to kdl.UnmarshalOptions
+	UnmarshalOptions = marshaler.UnmarshalOptions
+	// MarshalOptions configures marshaling; it is identical to kdl.MarshalerOptions
+	MarshalOptions = marshaler.MarshalOptions
+
+	// Decoder carries the state of an Unmarshal call into generated methods
+	Decoder = marshaler.GenDecoder
+	// Encoder carries the state of a Marshal call into generated methods
+	Encoder = marshaler.GenEncoder
+	// Checker checks the fields of a struct with generated methods once it has been unmarshaled
+	Checker = marshaler.GenChecker
+
+	// Fields describes the fields of a struct type with generated methods
+	Fields = marshaler.GenFields
+	// Field identifies a struct field for NewFields
+	Field = marshaler.GenField
+
+	// Unmarshaler is implemented by types with generated UnmarshalKDL methods
+	Unmarshaler = marshaler.GenUnmarshaler
+	// Marshaler is implemented by types with generated MarshalKDL methods
+	Marshaler = marshaler.GenMarshaler
+)
+
+// NewDecoder returns a Decoder that unmarshals according to opts
+func NewDecoder(opts UnmarshalOptions) *Decoder {
+	return marshaler.NewGenDecoder(opts)
+}
+
+// NewEncoder returns an Encoder that marshals according to opts
+func NewEncoder(opts MarshalOptions) *Encoder {
+	return marshaler.NewGenEncoder(opts)
+}
+
+// DecoderFor returns the Decoder with which the generated UnmarshalKDL method of t unmarshals node: that of the
+// Unmarshal call that invoked the method, or one using the default options if the method was called directly
+func DecoderFor(t interface{}, node *document.Node) *Decoder {
+	return marshaler.GenDecoderFor(t, node)
+}
+
+// EncoderFor returns the Encoder with which the generated MarshalKDL method of t marshals it into node: that of the
+// Marshal call that invoked the method, or one using the default options if the method was called directly
+func EncoderFor(t interface{}, node *document.Node) *Encoder {
+	return marshaler.GenEncoderFor(t, node)
+}
+
+// NewFields returns a description of the fields of the struct type named typeName
+func NewFields(typeName string, fields ...Field) *Fields {
+	return marshaler.NewGenFields(typeName, fields...)
+}
+
+// NodePtr unmarshals node into field i, a pointer to a struct with generated methods to which dst points
+func NodePtr[T any, PT marshaler.GenUnmarshalerPtr[T]](d *Decoder, node *document.Node, f *Fields, i int, dst *PT) error {
+	return marshaler.GenNodePtr[T, PT](d, node, f, i, dst)
+}
+
+// AppendNode unmarshals node into a new element appended to field i, a slice of structs with generated methods to
+// which dst points
+func AppendNode[T any, PT marshaler.GenUnmarshalerPtr[T]](d *Decoder, node *document.Node, f *Fields, i int, dst *[]T) error {
+	return marshaler.GenAppendNode[T, PT](d, node, f, i, dst)
+}
+
+// AppendNodePtr unmarshals node into a new element appended to field i, a slice of pointers to structs with generated
+// methods to which dst points
+func AppendNodePtr[T any, PT marshaler.GenUnmarshalerPtr[T]](d *Decoder, node *document.Node, f *Fields, i int, dst *[]PT) error {
+	return marshaler.GenAppendNodePtr[T, PT](d, node, f, i, dst)
+}
+
+// FieldPtr adds field i, a pointer to a struct with generated methods to which src points, to node
+func FieldPtr[T any, PT marshaler.GenMarshalerPtr[T]](e *Encoder, node *document.Node, f *Fields, i int, src *PT) error {
+	return marshaler.GenFieldPtr[T, PT](e, node, f, i, src)
+}
+
+// NodesPtr appends the nodes representing field i, a pointer to a struct with generated methods to which src points,
+// to nodes
+func NodesPtr[T any, PT marshaler.GenMarshalerPtr[T]](e *Encoder, nodes []*document.Node, f *Fields, i int, src *PT) ([]*document.Node, error) {
+	return marshaler.GenNodesPtr[T, PT](e, nodes, f, i, src)
+}
+
+// CheckValue checks field i, a scalar holding v, against its constraints
+func CheckValue[T marshaler.GenScalar](chk *Checker, f *Fields, i int, v T) error {
+	return marshaler.GenCheckValue(chk, f, i, v)
+}
+
+// CheckValues checks field i, a slice of scalars holding s, against its constraints
+func CheckValues[T marshaler.GenScalar](chk *Checker, f *Fields, i int, s []T) error {
+	return marshaler.GenCheckValues(chk, f, i, s)
+}
@@ -0,0 +1,1211 @@
+// Code generated by kdl-gen-marshal; DO NOT EDIT.
+
+package testtypes
+
+import (
+	"github.com/sblinch/kdl-go/codegen"
+	"github.com/sblinch/kdl-go/document"
+)
+
+// kdlConfigFields describes the fields of Config.
+var kdlConfigFields = codegen.NewFields("Config",
+	codegen.Field{Name: "Name", Tag: `kdl:"name"`},
+	codegen.Field{Name: "Debug", Tag: `kdl:"debug,omitempty"`},
+	codegen.Field{Name: "Port", Tag: `kdl:"port,omitempty"`},
+	codegen.Field{Name: "Ratio", Tag: `kdl:"ratio,omitempty,format:nonfinite"`},
+	codegen.Field{Name: "Timeout", Tag: `kdl:"timeout,omitempty"`},
+	codegen.Field{Name: "Interval", Tag: `kdl:"interval,omitempty,format:sec"`},
+	codegen.Field{Name: "Created", Tag: `kdl:"created,omitempty,format:RFC3339"`},
+	codegen.Field{Name: "Tags", Tag: `kdl:"tags,omitempty"`},
+	codegen.Field{Name: "Levels", Tag: `kdl:"levels,omitempty"`},
+	codegen.Field{Name: "Labels", Tag: `kdl:"labels,omitempty"`},
+	codegen.Field{Name: "Server", Tag: `kdl:"server,multiple"`},
+	codegen.Field{Name: "Backup", Tag: `kdl:"backup,omitempty"`},
+	codegen.Field{Name: "Include", Tag: `kdl:"include,multiple"`},
+	codegen.Field{Name: "Limits", Tag: `kdl:"limits,omitempty"`},
+	codegen.Field{Name: "Version", Tag: `kdl:"version,omitempty,type:semver"`},
+	codegen.Field{Name: "Mode", Tag: `kdl:"mode,omitempty"`},
+	codegen.Field{Name: "Any", Tag: `kdl:"any,omitempty"`},
+	codegen.Field{Name: "Ignored", Tag: `kdl:"-"`},
+	codegen.Field{Name: "Comment"},
+	codegen.Field{Name: "Workers", Tag: `kdl:"workers,omitempty,min:1,max:64,default:4"`},
+	codegen.Field{Name: "Level", Tag: `kdl:"level,omitempty,enum:1|2|3"`},
+	codegen.Field{Name: "Zone", Tag: `kdl:"zone,multiple,len:-3,match:[a-z]+"`},
+	codegen.Field{Name: "Shards", Tag: `kdl:"shards,omitempty,max:10"`},
+	codegen.Field{Name: "Grace", Tag: `kdl:"grace,omitempty,max:1m"`},
+	codegen.Field{Name: "Socket", Tag: `kdl:"socket,omitempty,oneof:addr"`},
+	codegen.Field{Name: "Address", Tag: `kdl:"address,omitempty,oneof:addr,len:1-"`},
+	codegen.Field{Name: "Owner", Tag: `kdl:"owner,omitempty"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Config) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Config) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 0); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		handled := 0
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlConfigFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlConfigFields, 0, &t.Name)
+			case 1:
+				err = d.Value(v, kdlConfigFields, 1, &t.Debug)
+			case 2:
+				err = d.Value(v, kdlConfigFields, 2, &t.Port)
+			case 3:
+				err = d.Value(v, kdlConfigFields, 3, &t.Ratio)
+			case 4:
+				err = d.Value(v, kdlConfigFields, 4, &t.Timeout)
+			case 5:
+				err = d.Value(v, kdlConfigFields, 5, &t.Interval)
+			case 6:
+				err = d.Value(v, kdlConfigFields, 6, &t.Created)
+			case 7:
+				err = d.Value(v, kdlConfigFields, 7, &t.Tags)
+			case 8:
+				err = d.Value(v, kdlConfigFields, 8, &t.Levels)
+			case 9:
+				err = d.Value(v, kdlConfigFields, 9, &t.Labels)
+			case 10:
+				err = d.Value(v, kdlConfigFields, 10, &t.Server)
+			case 11:
+				err = d.Value(v, kdlConfigFields, 11, &t.Backup)
+			case 12:
+				err = d.Value(v, kdlConfigFields, 12, &t.Include)
+			case 13:
+				err = d.Value(v, kdlConfigFields, 13, &t.Limits)
+			case 14:
+				err = d.Value(v, kdlConfigFields, 14, &t.Version)
+			case 15:
+				err = d.Value(v, kdlConfigFields, 15, &t.Mode)
+			case 16:
+				err = d.Value(v, kdlConfigFields, 16, &t.Any)
+			case 17:
+				err = d.Value(v, kdlConfigFields, 17, &t.Ignored)
+			case 18:
+				err = d.Value(v, kdlConfigFields, 18, &t.Comment)
+			case 19:
+				err = d.Value(v, kdlConfigFields, 19, &t.Workers)
+			case 20:
+				err = d.Value(v, kdlConfigFields, 20, &t.Level)
+			case 21:
+				err = d.Value(v, kdlConfigFields, 21, &t.Zone)
+			case 22:
+				err = d.Value(v, kdlConfigFields, 22, &t.Shards)
+			case 23:
+				err = d.Value(v, kdlConfigFields, 23, &t.Grace)
+			case 24:
+				err = d.Value(v, kdlConfigFields, 24, &t.Socket)
+			case 25:
+				err = d.Value(v, kdlConfigFields, 25, &t.Address)
+			case 26:
+				err = d.Value(v, kdlConfigFields, 26, &t.Shared.Owner)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			handled++
+		}
+		if err := d.CheckProps(node, handled); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		if err := t.UnmarshalKDLNodes(d, node.Children); err != nil {
+			return err
+		}
+	}
+	return t.CheckKDL(d.Checker(node))
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Config) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlConfigFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlConfigFields, 0, &t.Name)
+		case 1:
+			err = d.Node(node, kdlConfigFields, 1, &t.Debug)
+		case 2:
+			err = d.Node(node, kdlConfigFields, 2, &t.Port)
+		case 3:
+			err = d.Node(node, kdlConfigFields, 3, &t.Ratio)
+		case 4:
+			err = d.Node(node, kdlConfigFields, 4, &t.Timeout)
+		case 5:
+			err = d.Node(node, kdlConfigFields, 5, &t.Interval)
+		case 6:
+			err = d.Node(node, kdlConfigFields, 6, &t.Created)
+		case 7:
+			err = d.Node(node, kdlConfigFields, 7, &t.Tags)
+		case 8:
+			err = d.Node(node, kdlConfigFields, 8, &t.Levels)
+		case 9:
+			err = d.Node(node, kdlConfigFields, 9, &t.Labels)
+		case 10:
+			err = codegen.AppendNode(d, node, kdlConfigFields, 10, &t.Server)
+		case 11:
+			err = codegen.NodePtr(d, node, kdlConfigFields, 11, &t.Backup)
+		case 12:
+			err = d.Multiple(node, kdlConfigFields, 12, &t.Include)
+		case 13:
+			err = codegen.NodePtr(d, node, kdlConfigFields, 13, &t.Limits)
+		case 14:
+			err = d.Node(node, kdlConfigFields, 14, &t.Version)
+		case 15:
+			err = d.Node(node, kdlConfigFields, 15, &t.Mode)
+		case 16:
+			err = d.Node(node, kdlConfigFields, 16, &t.Any)
+		case 17:
+			err = d.Node(node, kdlConfigFields, 17, &t.Ignored)
+		case 18:
+			err = d.Node(node, kdlConfigFields, 18, &t.Comment)
+		case 19:
+			err = d.Node(node, kdlConfigFields, 19, &t.Workers)
+		case 20:
+			err = d.Node(node, kdlConfigFields, 20, &t.Level)
+		case 21:
+			err = d.Multiple(node, kdlConfigFields, 21, &t.Zone)
+		case 22:
+			err = d.Node(node, kdlConfigFields, 22, &t.Shards)
+		case 23:
+			err = d.Node(node, kdlConfigFields, 23, &t.Grace)
+		case 24:
+			err = d.Node(node, kdlConfigFields, 24, &t.Socket)
+		case 25:
+			err = d.Node(node, kdlConfigFields, 25, &t.Address)
+		case 26:
+			err = d.Node(node, kdlConfigFields, 26, &t.Shared.Owner)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Config) CheckKDL(chk *codegen.Checker) error {
+	if _, err := chk.Field(kdlConfigFields, 14, &t.Version); err != nil {
+		return err
+	}
+	if ok, err := chk.Field(kdlConfigFields, 19, &t.Workers); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlConfigFields, 19, t.Workers); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 20, &t.Level); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlConfigFields, 20, t.Level); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 21, &t.Zone); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValues(chk, kdlConfigFields, 21, t.Zone); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 22, &t.Shards); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValues(chk, kdlConfigFields, 22, t.Shards); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 23, &t.Grace); err != nil {
+		return err
+	} else if ok {
+		if err := chk.Value(kdlConfigFields, 23, &t.Grace); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 24, &t.Socket); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlConfigFields, 24, t.Socket); err != nil {
+			return err
+		}
+	}
+	if ok, err := chk.Field(kdlConfigFields, 25, &t.Address); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlConfigFields, 25, t.Address); err != nil {
+			return err
+		}
+	}
+	return chk.OneOf(kdlConfigFields)
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Config) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Config) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(0)
+	if err := e.Field(node, kdlConfigFields, 0, &t.Name); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 1, &t.Debug); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 2, &t.Port); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 3, &t.Ratio); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 4, &t.Timeout); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 5, &t.Interval); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 6, &t.Created); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 7, &t.Tags); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 8, &t.Levels); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 9, &t.Labels); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 10, &t.Server); err != nil {
+		return err
+	}
+	if err := codegen.FieldPtr(e, node, kdlConfigFields, 11, &t.Backup); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 12, &t.Include); err != nil {
+		return err
+	}
+	if err := codegen.FieldPtr(e, node, kdlConfigFields, 13, &t.Limits); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 14, &t.Version); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 15, &t.Mode); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 16, &t.Any); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 18, &t.Comment); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 19, &t.Workers); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 20, &t.Level); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 21, &t.Zone); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 22, &t.Shards); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 23, &t.Grace); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 24, &t.Socket); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 25, &t.Address); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlConfigFields, 26, &t.Shared.Owner); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Config) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 27)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 0, &t.Name); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 1, &t.Debug); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 2, &t.Port); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 3, &t.Ratio); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 4, &t.Timeout); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 5, &t.Interval); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 6, &t.Created); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 7, &t.Tags); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 8, &t.Levels); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 9, &t.Labels); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 10, &t.Server); err != nil {
+		return nil, err
+	}
+	if nodes, err = codegen.NodesPtr(e, nodes, kdlConfigFields, 11, &t.Backup); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 12, &t.Include); err != nil {
+		return nil, err
+	}
+	if nodes, err = codegen.NodesPtr(e, nodes, kdlConfigFields, 13, &t.Limits); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 14, &t.Version); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 15, &t.Mode); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 16, &t.Any); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 18, &t.Comment); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 19, &t.Workers); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 20, &t.Level); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 21, &t.Zone); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 22, &t.Shards); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 23, &t.Grace); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 24, &t.Socket); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 25, &t.Address); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlConfigFields, 26, &t.Shared.Owner); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlSharedFields describes the fields of Shared.
+var kdlSharedFields = codegen.NewFields("Shared",
+	codegen.Field{Name: "Owner", Tag: `kdl:"owner,omitempty"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Shared) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Shared) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 0); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		handled := 0
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlSharedFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlSharedFields, 0, &t.Owner)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			handled++
+		}
+		if err := d.CheckProps(node, handled); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		return t.UnmarshalKDLNodes(d, node.Children)
+	}
+	return nil
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Shared) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlSharedFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlSharedFields, 0, &t.Owner)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Shared) CheckKDL(chk *codegen.Checker) error {
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Shared) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Shared) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(0)
+	if err := e.Field(node, kdlSharedFields, 0, &t.Owner); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Shared) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 1)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlSharedFields, 0, &t.Owner); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlServerFields describes the fields of Server.
+var kdlServerFields = codegen.NewFields("Server",
+	codegen.Field{Name: "Name", Tag: `kdl:",arg"`},
+	codegen.Field{Name: "Port", Tag: `kdl:"port,type:u16"`},
+	codegen.Field{Name: "Enabled", Tag: `kdl:"enabled,omitempty"`},
+	codegen.Field{Name: "Weight", Tag: `kdl:"weight,omitempty"`},
+	codegen.Field{Name: "Listen", Tag: `kdl:"listen,omitempty"`},
+	codegen.Field{Name: "Env", Tag: `kdl:"env,omitempty"`},
+	codegen.Field{Name: "Location", Tag: `kdl:"location,multiple"`},
+	codegen.Field{Name: "Proto", Tag: `kdl:"proto,enum:tcp|udp,default:tcp"`},
+	codegen.Field{Name: "Idle", Tag: `kdl:"idle,format:sec,default:90"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Server) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Server) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 1); err != nil {
+			return err
+		}
+		args := node.Arguments
+		if len(args) > 0 {
+			if err := d.Value(args[0], kdlServerFields, 0, &t.Name); err != nil {
+				return err
+			}
+			args = args[1:]
+		}
+	}
+	if node.Properties.Len() > 0 {
+		handled := 0
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlServerFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlServerFields, 0, &t.Name)
+			case 1:
+				err = d.Value(v, kdlServerFields, 1, &t.Port)
+			case 2:
+				err = d.Value(v, kdlServerFields, 2, &t.Enabled)
+			case 3:
+				err = d.Value(v, kdlServerFields, 3, &t.Weight)
+			case 4:
+				err = d.Value(v, kdlServerFields, 4, &t.Listen)
+			case 5:
+				err = d.Value(v, kdlServerFields, 5, &t.Env)
+			case 6:
+				err = d.Value(v, kdlServerFields, 6, &t.Location)
+			case 7:
+				err = d.Value(v, kdlServerFields, 7, &t.Proto)
+			case 8:
+				err = d.Value(v, kdlServerFields, 8, &t.Idle)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			handled++
+		}
+		if err := d.CheckProps(node, handled); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		if err := t.UnmarshalKDLNodes(d, node.Children); err != nil {
+			return err
+		}
+	}
+	return t.CheckKDL(d.Checker(node))
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Server) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlServerFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlServerFields, 0, &t.Name)
+		case 1:
+			err = d.Node(node, kdlServerFields, 1, &t.Port)
+		case 2:
+			err = d.Node(node, kdlServerFields, 2, &t.Enabled)
+		case 3:
+			err = d.Node(node, kdlServerFields, 3, &t.Weight)
+		case 4:
+			err = d.Node(node, kdlServerFields, 4, &t.Listen)
+		case 5:
+			err = d.Node(node, kdlServerFields, 5, &t.Env)
+		case 6:
+			err = codegen.AppendNodePtr(d, node, kdlServerFields, 6, &t.Location)
+		case 7:
+			err = d.Node(node, kdlServerFields, 7, &t.Proto)
+		case 8:
+			err = d.Node(node, kdlServerFields, 8, &t.Idle)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Server) CheckKDL(chk *codegen.Checker) error {
+	if _, err := chk.Field(kdlServerFields, 1, &t.Port); err != nil {
+		return err
+	}
+	if ok, err := chk.Field(kdlServerFields, 7, &t.Proto); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlServerFields, 7, t.Proto); err != nil {
+			return err
+		}
+	}
+	if _, err := chk.Field(kdlServerFields, 8, &t.Idle); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Server) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Server) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(1)
+	if err := e.Arg(node, kdlServerFields, 0, &t.Name); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 1, &t.Port); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 2, &t.Enabled); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 3, &t.Weight); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 4, &t.Listen); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 5, &t.Env); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 6, &t.Location); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 7, &t.Proto); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlServerFields, 8, &t.Idle); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Server) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 9)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 0, &t.Name); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 1, &t.Port); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 2, &t.Enabled); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 3, &t.Weight); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 4, &t.Listen); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 5, &t.Env); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 6, &t.Location); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 7, &t.Proto); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlServerFields, 8, &t.Idle); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlLocationFields describes the fields of Location.
+var kdlLocationFields = codegen.NewFields("Location",
+	codegen.Field{Name: "Path", Tag: `kdl:",arg,required,match:/.*"`},
+	codegen.Field{Name: "Extra", Tag: `kdl:",args"`},
+	codegen.Field{Name: "Props", Tag: `kdl:",props"`},
+	codegen.Field{Name: "Root", Tag: `kdl:"root,omitempty"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Location) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Location) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		args := node.Arguments
+		if len(args) > 0 {
+			if err := d.Value(args[0], kdlLocationFields, 0, &t.Path); err != nil {
+				return err
+			}
+			args = args[1:]
+		}
+		if err := d.Args(node, args, kdlLocationFields, 1, &t.Extra); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlLocationFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlLocationFields, 0, &t.Path)
+			case 1:
+				err = d.Value(v, kdlLocationFields, 1, &t.Extra)
+			case 2:
+				err = d.Value(v, kdlLocationFields, 2, &t.Props)
+			case 3:
+				err = d.Value(v, kdlLocationFields, 3, &t.Root)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := d.Props(node, kdlLocationFields, 2, &t.Props); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		if err := t.UnmarshalKDLNodes(d, node.Children); err != nil {
+			return err
+		}
+	}
+	return t.CheckKDL(d.Checker(node))
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Location) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlLocationFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlLocationFields, 0, &t.Path)
+		case 1:
+			err = d.Node(node, kdlLocationFields, 1, &t.Extra)
+		case 2:
+			err = d.Node(node, kdlLocationFields, 2, &t.Props)
+		case 3:
+			err = d.Node(node, kdlLocationFields, 3, &t.Root)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Location) CheckKDL(chk *codegen.Checker) error {
+	if ok, err := chk.Field(kdlLocationFields, 0, &t.Path); err != nil {
+		return err
+	} else if ok {
+		if err := codegen.CheckValue(chk, kdlLocationFields, 0, t.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Location) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Location) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(1)
+	if err := e.Arg(node, kdlLocationFields, 0, &t.Path); err != nil {
+		return err
+	}
+	if err := e.Args(node, kdlLocationFields, 1, &t.Extra); err != nil {
+		return err
+	}
+	if err := e.Props(node, kdlLocationFields, 2, &t.Props); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlLocationFields, 3, &t.Root); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Location) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 4)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlLocationFields, 0, &t.Path); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlLocationFields, 1, &t.Extra); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlLocationFields, 2, &t.Props); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlLocationFields, 3, &t.Root); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlEnvFields describes the fields of Env.
+var kdlEnvFields = codegen.NewFields("Env",
+	codegen.Field{Name: "Vars", Tag: `kdl:",props"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Env) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Env) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 0); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlEnvFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlEnvFields, 0, &t.Vars)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := d.Props(node, kdlEnvFields, 0, &t.Vars); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		return t.UnmarshalKDLNodes(d, node.Children)
+	}
+	return nil
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Env) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlEnvFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlEnvFields, 0, &t.Vars)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Env) CheckKDL(chk *codegen.Checker) error {
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Env) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Env) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(0)
+	if err := e.Props(node, kdlEnvFields, 0, &t.Vars); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Env) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 1)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlEnvFields, 0, &t.Vars); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlLimitsFields describes the fields of Limits.
+var kdlLimitsFields = codegen.NewFields("Limits",
+	codegen.Field{Name: "Kind", Tag: `kdl:",arg"`},
+	codegen.Field{Name: "Size", Tag: `kdl:"size,omitempty"`},
+	codegen.Field{Name: "Children", Tag: `kdl:",children"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Limits) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Limits) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 1); err != nil {
+			return err
+		}
+		args := node.Arguments
+		if len(args) > 0 {
+			if err := d.Value(args[0], kdlLimitsFields, 0, &t.Kind); err != nil {
+				return err
+			}
+			args = args[1:]
+		}
+	}
+	if node.Properties.Len() > 0 {
+		handled := 0
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlLimitsFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlLimitsFields, 0, &t.Kind)
+			case 1:
+				err = d.Value(v, kdlLimitsFields, 1, &t.Size)
+			case 2:
+				err = d.Value(v, kdlLimitsFields, 2, &t.Children)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			handled++
+		}
+		if err := d.CheckProps(node, handled); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		if err := d.Children(node, kdlLimitsFields, 2, &t.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Limits) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlLimitsFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlLimitsFields, 0, &t.Kind)
+		case 1:
+			err = d.Node(node, kdlLimitsFields, 1, &t.Size)
+		case 2:
+			err = d.Node(node, kdlLimitsFields, 2, &t.Children)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Limits) CheckKDL(chk *codegen.Checker) error {
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Limits) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Limits) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(1)
+	if err := e.Arg(node, kdlLimitsFields, 0, &t.Kind); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlLimitsFields, 1, &t.Size); err != nil {
+		return err
+	}
+	node.ExpectChildren(1)
+	if err := e.Children(node, kdlLimitsFields, 2, &t.Children); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Limits) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 3)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlLimitsFields, 0, &t.Kind); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlLimitsFields, 1, &t.Size); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlLimitsFields, 2, &t.Children); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// kdlVersionFields describes the fields of Version.
+var kdlVersionFields = codegen.NewFields("Version",
+	codegen.Field{Name: "Major", Tag: `kdl:"major"`},
+	codegen.Field{Name: "Minor", Tag: `kdl:"minor"`},
+)
+
+// UnmarshalKDL unmarshals node into t using the options of the Unmarshal call that invoked it, or the default options
+// if called directly.
+func (t *Version) UnmarshalKDL(node *document.Node) error {
+	return t.UnmarshalKDLWith(codegen.DecoderFor(t, node), node)
+}
+
+// UnmarshalKDLWith unmarshals node into t using d.
+func (t *Version) UnmarshalKDLWith(d *codegen.Decoder, node *document.Node) error {
+	if done, err := d.ValueNode(t, node); done {
+		return err
+	}
+	if len(node.Arguments) > 0 {
+		if err := d.CheckArgs(node, 0); err != nil {
+			return err
+		}
+	}
+	if node.Properties.Len() > 0 {
+		handled := 0
+		for key, v := range node.Properties.Unordered() {
+			var err error
+			switch kdlVersionFields.Index(d, key) {
+			case 0:
+				err = d.Value(v, kdlVersionFields, 0, &t.Major)
+			case 1:
+				err = d.Value(v, kdlVersionFields, 1, &t.Minor)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			handled++
+		}
+		if err := d.CheckProps(node, handled); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		return t.UnmarshalKDLNodes(d, node.Children)
+	}
+	return nil
+}
+
+// UnmarshalKDLNodes unmarshals nodes into the fields of t using d.
+func (t *Version) UnmarshalKDLNodes(d *codegen.Decoder, nodes []*document.Node) error {
+	for _, node := range nodes {
+		var err error
+		switch kdlVersionFields.Index(d, node.Name.ValueString()) {
+		case 0:
+			err = d.Node(node, kdlVersionFields, 0, &t.Major)
+		case 1:
+			err = d.Node(node, kdlVersionFields, 1, &t.Minor)
+		default:
+			err = d.UnhandledNode(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKDL checks the fields of t using chk once t has been unmarshaled.
+func (t *Version) CheckKDL(chk *codegen.Checker) error {
+	return nil
+}
+
+// MarshalKDL marshals t into node using the options of the Marshal call that invoked it, or the default options if
+// called directly.
+func (t *Version) MarshalKDL(node *document.Node) error {
+	return t.MarshalKDLWith(codegen.EncoderFor(t, node), node)
+}
+
+// MarshalKDLWith marshals t into node using e.
+func (t *Version) MarshalKDLWith(e *codegen.Encoder, node *document.Node) error {
+	node.ExpectArguments(0)
+	if err := e.Field(node, kdlVersionFields, 0, &t.Major); err != nil {
+		return err
+	}
+	if err := e.Field(node, kdlVersionFields, 1, &t.Minor); err != nil {
+		return err
+	}
+	node.ExpectChildren(0)
+	return nil
+}
+
+// MarshalKDLNodes appends the nodes representing the fields of t to nodes using e.
+func (t *Version) MarshalKDLNodes(e *codegen.Encoder, nodes []*document.Node) ([]*document.Node, error) {
+	if nodes == nil {
+		nodes = make([]*document.Node, 0, 2)
+	}
+	var err error
+	if nodes, err = e.Nodes(nodes, kdlVersionFields, 0, &t.Major); err != nil {
+		return nil, err
+	}
+	if nodes, err = e.Nodes(nodes, kdlVersionFields, 1, &t.Minor); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
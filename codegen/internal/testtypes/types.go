@@ -0,0 +1,107 @@
+// Package testtypes declares types with methods generated by kdl-gen-marshal, which are tested against identical
+// types without generated methods to verify that the generated methods behave identically to kdl-go's reflective
+// marshaling and unmarshaling.
+package testtypes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//go:generate go run github.com/sblinch/kdl-go/cmd/kdl-gen-marshal
+
+// Config represents a document
+type Config struct {
+	Name     string            `kdl:"name"`
+	Debug    bool              `kdl:"debug,omitempty"`
+	Port     int               `kdl:"port,omitempty"`
+	Ratio    float64           `kdl:"ratio,omitempty,format:nonfinite"`
+	Timeout  time.Duration     `kdl:"timeout,omitempty"`
+	Interval time.Duration     `kdl:"interval,omitempty,format:sec"`
+	Created  time.Time         `kdl:"created,omitempty,format:RFC3339"`
+	Tags     []string          `kdl:"tags,omitempty"`
+	Levels   []int             `kdl:"levels,omitempty"`
+	Labels   map[string]string `kdl:"labels,omitempty"`
+	Server   []Server          `kdl:"server,multiple"`
+	Backup   *Server           `kdl:"backup,omitempty"`
+	Include  []string          `kdl:"include,multiple"`
+	Limits   *Limits           `kdl:"limits,omitempty"`
+	Version  Version           `kdl:"version,omitempty,type:semver"`
+	Mode     Mode              `kdl:"mode,omitempty"`
+	Any      interface{}       `kdl:"any,omitempty"`
+	Ignored  string            `kdl:"-"`
+	Comment  string
+	Workers  int            `kdl:"workers,omitempty,min:1,max:64,default:4"`
+	Level    int8           `kdl:"level,omitempty,enum:1|2|3"`
+	Zone     []string       `kdl:"zone,multiple,len:-3,match:[a-z]+"`
+	Shards   []uint16       `kdl:"shards,omitempty,max:10"`
+	Grace    *time.Duration `kdl:"grace,omitempty,max:1m"`
+	Socket   string         `kdl:"socket,omitempty,oneof:addr"`
+	Address  string         `kdl:"address,omitempty,oneof:addr,len:1-"`
+	Shared
+}
+
+// Shared is embedded in Config
+type Shared struct {
+	Owner string `kdl:"owner,omitempty"`
+}
+
+// Server represents a "server" node
+type Server struct {
+	Name     string        `kdl:",arg"`
+	Port     uint16        `kdl:"port,type:u16"`
+	Enabled  *bool         `kdl:"enabled,omitempty"`
+	Weight   float32       `kdl:"weight,omitempty"`
+	Listen   []string      `kdl:"listen,omitempty"`
+	Env      Env           `kdl:"env,omitempty"`
+	Location []*Location   `kdl:"location,multiple"`
+	Proto    string        `kdl:"proto,enum:tcp|udp,default:tcp"`
+	Idle     time.Duration `kdl:"idle,format:sec,default:90"`
+}
+
+// Location represents a "location" node
+type Location struct {
+	Path  string            `kdl:",arg,required,match:/.*"`
+	Extra []string          `kdl:",args"`
+	Props map[string]string `kdl:",props"`
+	Root  string            `kdl:"root,omitempty"`
+}
+
+// Env represents an "env" node
+type Env struct {
+	Vars map[string]interface{} `kdl:",props"`
+}
+
+// Limits represents a "limits" node
+type Limits struct {
+	Kind     string                 `kdl:",arg"`
+	Size     int64                  `kdl:"size,omitempty"`
+	Children map[string]interface{} `kdl:",children"`
+}
+
+// Version is unmarshaled from either a single "major.minor" argument, or from major and minor properties
+type Version struct {
+	Major int `kdl:"major"`
+	Minor int `kdl:"minor"`
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *Version) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+// Mode is a string type that unmarshals from text
+type Mode string
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *Mode) UnmarshalText(b []byte) error {
+	*m = Mode(strings.ToUpper(string(b)))
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(string(m))), nil
+}
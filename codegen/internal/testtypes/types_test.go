@@ -0,0 +1,547 @@
+package testtypes
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/relaxed"
+)
+
+// the types below are identical to those in types.go, but have no generated methods and are therefore marshaled and
+// unmarshaled reflectively
+
+type plainConfig struct {
+	Name     string            `kdl:"name"`
+	Debug    bool              `kdl:"debug,omitempty"`
+	Port     int               `kdl:"port,omitempty"`
+	Ratio    float64           `kdl:"ratio,omitempty,format:nonfinite"`
+	Timeout  time.Duration     `kdl:"timeout,omitempty"`
+	Interval time.Duration     `kdl:"interval,omitempty,format:sec"`
+	Created  time.Time         `kdl:"created,omitempty,format:RFC3339"`
+	Tags     []string          `kdl:"tags,omitempty"`
+	Levels   []int             `kdl:"levels,omitempty"`
+	Labels   map[string]string `kdl:"labels,omitempty"`
+	Server   []plainServer     `kdl:"server,multiple"`
+	Backup   *plainServer      `kdl:"backup,omitempty"`
+	Include  []string          `kdl:"include,multiple"`
+	Limits   *plainLimits      `kdl:"limits,omitempty"`
+	Version  plainVersion      `kdl:"version,omitempty,type:semver"`
+	Mode     Mode              `kdl:"mode,omitempty"`
+	Any      interface{}       `kdl:"any,omitempty"`
+	Ignored  string            `kdl:"-"`
+	Comment  string
+	Workers  int            `kdl:"workers,omitempty,min:1,max:64,default:4"`
+	Level    int8           `kdl:"level,omitempty,enum:1|2|3"`
+	Zone     []string       `kdl:"zone,multiple,len:-3,match:[a-z]+"`
+	Shards   []uint16       `kdl:"shards,omitempty,max:10"`
+	Grace    *time.Duration `kdl:"grace,omitempty,max:1m"`
+	Socket   string         `kdl:"socket,omitempty,oneof:addr"`
+	Address  string         `kdl:"address,omitempty,oneof:addr,len:1-"`
+	plainShared
+}
+
+type plainShared struct {
+	Owner string `kdl:"owner,omitempty"`
+}
+
+type plainServer struct {
+	Name     string           `kdl:",arg"`
+	Port     uint16           `kdl:"port,type:u16"`
+	Enabled  *bool            `kdl:"enabled,omitempty"`
+	Weight   float32          `kdl:"weight,omitempty"`
+	Listen   []string         `kdl:"listen,omitempty"`
+	Env      plainEnv         `kdl:"env,omitempty"`
+	Location []*plainLocation `kdl:"location,multiple"`
+	Proto    string           `kdl:"proto,enum:tcp|udp,default:tcp"`
+	Idle     time.Duration    `kdl:"idle,format:sec,default:90"`
+}
+
+type plainLocation struct {
+	Path  string            `kdl:",arg,required,match:/.*"`
+	Extra []string          `kdl:",args"`
+	Props map[string]string `kdl:",props"`
+	Root  string            `kdl:"root,omitempty"`
+}
+
+type plainEnv struct {
+	Vars map[string]interface{} `kdl:",props"`
+}
+
+type plainLimits struct {
+	Kind     string                 `kdl:",arg"`
+	Size     int64                  `kdl:"size,omitempty"`
+	Children map[string]interface{} `kdl:",children"`
+}
+
+type plainVersion struct {
+	Major int `kdl:"major"`
+	Minor int `kdl:"minor"`
+}
+
+func (v *plainVersion) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+const fullDocument = `
+name "example"
+debug true
+port 8080
+ratio 1.5
+timeout "1m30s"
+interval 90
+created "2024-01-02T03:04:05Z"
+tags "a" "b"
+levels 1 2 3
+labels env="prod" tier="web"
+server "alpha" port=80 enabled=true weight=0.5 {
+	listen "127.0.0.1" "::1"
+	env HOME="/root" N=3
+	location "/" "x" "y" root="/var/www" index="index.html"
+	location "/api"
+}
+server "beta" port=81
+backup "gamma" port=82
+include "a.kdl"
+include "b.kdl"
+limits "hard" size=100 {
+	cpu 2
+	mem "1g"
+}
+version "1.2"
+mode "fast"
+any 1 2 3
+comment "hello"
+owner "me"
+`
+
+var documents = []string{
+	fullDocument,
+	"version major=3 minor=4",
+	"version 1 2",
+	"name \"x\"\nbogus 1",
+	"port 1 2",
+	"server \"a\" \"b\" port=1",
+	"server \"a\" port=1 bogus=2",
+	"server \"a\" port=1 {\n\tbogus 1\n}",
+	"server \"a\" {\n\tenv 1\n}",
+	"server port=1",
+	"server \"a\" name=\"b\"",
+	"Name \"x\"\nPORT 1\nserver \"a\" PORT=2",
+	"port 8k\ntimeout 15s\nlimits \"x\" size=2kb",
+	"ratio \"+Inf\"\nserver \"a\" weight=\"NaN\"",
+	"ratio \"NaN\"",
+	"port \"abc\"\nlevels \"a\" 2",
+	"interval \"x\"",
+	"created \"not a time\"",
+	"timeout \"bogus\"",
+	"name null\nport null",
+	"any a=1 {\n\tb 2\n}",
+	"\"-\" \"ignored\"",
+	"backup {\n\tlisten \"x\"\n}",
+	"mode \"a\" \"b\"",
+	"include \"a\" x=1",
+	"tags",
+	"limits {\n\tx 1\n}\nlimits \"y\"",
+	"server \"a\" {\n\tlocation \"/\" {\n\t\troot \"/srv\"\n\t}\n}",
+	"server \"a\" proto=\"udp\" idle=5 {\n\tlocation root=\"/srv\"\n}",
+	"server \"a\" {\n\tproto \"udp\"\n\tlocation path=\"/\"\n}",
+	"server \"a\" proto=\"sctp\"",
+	"server \"a\" {\n\tlocation \"x\"\n\tlocation \"/\"\n\tlocation \"y\"\n}",
+	"workers 0\nlevel 1",
+	"workers 65\nlevel 2",
+	"level 4",
+	"zone \"a\"\nzone \"B\"",
+	"zone \"a\"\nzone \"b\"\nzone \"c\"\nzone \"d\"",
+	"shards 1 2\ngrace \"30s\"",
+	"shards 1 20",
+	"grace \"2m\"",
+	"socket \"/x\"\naddress \"y\"",
+	"address \"\"",
+}
+
+var unmarshalOptions = []kdl.UnmarshalOptions{
+	{},
+	{AllowUnhandledNodes: true},
+	{AllowUnhandledArgs: true},
+	{AllowUnhandledProps: true},
+	{AllowUnhandledChildren: true},
+	{AllowUnhandledNodes: true, AllowUnhandledArgs: true, AllowUnhandledProps: true, AllowUnhandledChildren: true},
+	{CaseSensitive: true},
+	{RelaxedNonCompliant: relaxed.MultiplierSuffixes},
+	{Unmarshalers: customUnmarshalers()},
+	{CollectErrors: true},
+}
+
+// customUnmarshalers returns a registry of custom unmarshalers, which must be honored by generated methods
+func customUnmarshalers() *kdl.Unmarshalers {
+	u := kdl.NewUnmarshalers()
+	kdl.AddValueUnmarshaler[uint16](u, func(value *document.Value, v reflect.Value, format string) error {
+		v.SetUint(uint64(value.ResolvedValue().(int64)) + 1)
+		return nil
+	})
+	return u
+}
+
+// dump returns a representation of v that does not depend on type names or pointer addresses
+func dump(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "invalid"
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return "&" + dump(v.Elem())
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			return v.Interface().(time.Time).String()
+		}
+		fields := make([]string, v.NumField())
+		for i := range fields {
+			f := v.Type().Field(i)
+			name := f.Name
+			if f.Anonymous {
+				name = "embedded"
+			}
+			fields[i] = name + ":" + dump(v.Field(i))
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	case reflect.Slice:
+		if v.IsNil() {
+			return "nil"
+		}
+		els := make([]string, v.Len())
+		for i := range els {
+			els[i] = dump(v.Index(i))
+		}
+		return "[" + strings.Join(els, " ") + "]"
+	case reflect.Map:
+		if v.IsNil() {
+			return "nil"
+		}
+		entries := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			entries = append(entries, fmt.Sprint(k.Interface())+":"+dump(v.MapIndex(k)))
+		}
+		sort.Strings(entries)
+		return "map[" + strings.Join(entries, " ") + "]"
+	case reflect.Bool:
+		return fmt.Sprintf("%s(%t)", v.Type(), v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%s(%d)", v.Type(), v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprintf("%s(%d)", v.Type(), v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%s(%v)", v.Type(), v.Float())
+	default:
+		return fmt.Sprintf("%s(%q)", v.Type(), v.String())
+	}
+}
+
+// errString returns the message of err with the names of the plain types replaced with those of the generated types
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return strings.ReplaceAll(err.Error(), "plain", "")
+}
+
+// sortNodes sorts nodes, and the children of each, by their KDL representations, so that documents that differ only in
+// the order of the nodes marshaled from maps compare equal
+func sortNodes(nodes []*document.Node) {
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].String() < nodes[j].String()
+	})
+}
+
+// canonical returns the document in data with its nodes sorted by sortNodes
+func canonical(data []byte) string {
+	doc, err := kdl.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err.Error()
+	}
+	sortNodes(doc.Nodes)
+	var b bytes.Buffer
+	if err := kdl.Generate(doc, &b); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
+
+func TestUnmarshalEquivalence(t *testing.T) {
+	for i, doc := range documents {
+		for j, opts := range unmarshalOptions {
+			t.Run(fmt.Sprintf("doc%d/opts%d", i, j), func(t *testing.T) {
+				var (
+					gen   Config
+					plain plainConfig
+				)
+				genErr := kdl.UnmarshalWithOptions([]byte(doc), &gen, opts)
+				plainErr := kdl.UnmarshalWithOptions([]byte(doc), &plain, opts)
+				if errString(genErr) != errString(plainErr) {
+					t.Fatalf("generated error %q, reflective error %q", errString(genErr), errString(plainErr))
+				}
+				if got, want := dump(reflect.ValueOf(gen)), dump(reflect.ValueOf(plain)); got != want {
+					t.Errorf("generated:\n%s\nreflective:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestUnmarshalNodeEquivalence(t *testing.T) {
+	doc, err := kdl.Parse(strings.NewReader("server \"a\" port=1 {\n\tlocation \"/\" x=1\n}\nserver port=1 bogus=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range doc.Nodes {
+		var (
+			gen   Server
+			plain plainServer
+		)
+		genErr := kdl.UnmarshalNode(node, &gen)
+		plainErr := kdl.UnmarshalNode(node, &plain)
+		if errString(genErr) != errString(plainErr) {
+			t.Fatalf("generated error %q, reflective error %q", errString(genErr), errString(plainErr))
+		}
+		if got, want := dump(reflect.ValueOf(gen)), dump(reflect.ValueOf(plain)); got != want {
+			t.Errorf("generated:\n%s\nreflective:\n%s", got, want)
+		}
+	}
+}
+
+var marshalOptions = []kdl.MarshalerOptions{
+	{},
+	{CaseSensitive: true},
+	{BareSuffixed: true},
+	{Marshalers: customMarshalers()},
+	{OmitDefaults: true},
+}
+
+// customMarshalers returns a registry of custom marshalers, which must be honored by generated methods
+func customMarshalers() *kdl.Marshalers {
+	m := kdl.NewMarshalers()
+	kdl.AddValueMarshaler[uint16](m, func(v reflect.Value, value *document.Value, format string) error {
+		value.Value = fmt.Sprintf("port-%d", v.Uint())
+		return nil
+	})
+	return m
+}
+
+func TestMarshalEquivalence(t *testing.T) {
+	// values that exercise the less common paths
+	variants := []func(gen *Config, plain *plainConfig){
+		func(gen *Config, plain *plainConfig) {},
+		func(gen *Config, plain *plainConfig) {
+			gen.Ratio, plain.Ratio = math.Inf(-1), math.Inf(-1)
+			gen.Server[0].Weight, plain.Server[0].Weight = float32(math.NaN()), float32(math.NaN())
+		},
+		func(gen *Config, plain *plainConfig) {
+			gen.Backup, plain.Backup = nil, nil
+			gen.Limits, plain.Limits = nil, nil
+			gen.Server, plain.Server = nil, nil
+			gen.Debug, plain.Debug = false, false
+			gen.Any, plain.Any = nil, nil
+		},
+		func(gen *Config, plain *plainConfig) {
+			gen.Ratio, plain.Ratio = math.Copysign(0, -1), math.Copysign(0, -1)
+			gen.Any, plain.Any = map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}
+		},
+	}
+
+	for i, variant := range variants {
+		var (
+			g Config
+			p plainConfig
+		)
+		if err := kdl.Unmarshal([]byte(fullDocument), &g); err != nil {
+			t.Fatal(err)
+		}
+		if err := kdl.Unmarshal([]byte(fullDocument), &p); err != nil {
+			t.Fatal(err)
+		}
+		variant(&g, &p)
+		for j, opts := range marshalOptions {
+			t.Run(fmt.Sprintf("variant%d/opts%d", i, j), func(t *testing.T) {
+				genData, genErr := kdl.MarshalWithOptions(g, kdl.MarshalOptions{MarshalerOptions: opts})
+				plainData, plainErr := kdl.MarshalWithOptions(p, kdl.MarshalOptions{MarshalerOptions: opts})
+				if errString(genErr) != errString(plainErr) {
+					t.Fatalf("generated error %q, reflective error %q", errString(genErr), errString(plainErr))
+				}
+				if canonical(genData) != canonical(plainData) {
+					t.Errorf("generated:\n%s\nreflective:\n%s", genData, plainData)
+				}
+
+				// marshaling a pointer to a single struct into a node
+				genNode, genErr := kdl.MarshalNodeWithOptions(g.Backup, opts)
+				plainNode, plainErr := kdl.MarshalNodeWithOptions(p.Backup, opts)
+				if errString(genErr) != errString(plainErr) {
+					t.Fatalf("generated node error %q, reflective node error %q", errString(genErr), errString(plainErr))
+				}
+				if genNode != nil && plainNode != nil {
+					sortNodes([]*document.Node{genNode, plainNode})
+				}
+				if genNode != nil && plainNode != nil && genNode.String() != plainNode.String() {
+					t.Errorf("generated node:\n%s\nreflective node:\n%s", genNode, plainNode)
+				}
+			})
+		}
+	}
+}
+
+// Wrapper holds structs with generated methods within a struct without them, so that they are unmarshaled and
+// marshaled via their UnmarshalKDL and MarshalKDL methods
+type Wrapper struct {
+	Server Server            `kdl:"server"`
+	Backup *Server           `kdl:"backup"`
+	Pools  map[string]Limits `kdl:"pools,omitempty"`
+}
+
+type plainWrapper struct {
+	Server plainServer            `kdl:"server"`
+	Backup *plainServer           `kdl:"backup"`
+	Pools  map[string]plainLimits `kdl:"pools,omitempty"`
+}
+
+func TestMethodEquivalence(t *testing.T) {
+	docs := []string{
+		"server \"a\" port=1 {\n\tlocation \"/\" root=\"/srv\"\n}\npools {\n\tx \"hard\" size=1\n}",
+		"server \"a\" port=1 bogus=2",
+		"server \"a\" PORT=1 proto=\"sctp\"",
+		"server \"a\" port=8k {\n\tbogus 1\n}",
+		"backup \"b\" \"c\" port=2 {\n\tlocation root=\"/srv\"\n}",
+	}
+	for i, doc := range docs {
+		for j, opts := range unmarshalOptions {
+			t.Run(fmt.Sprintf("doc%d/opts%d", i, j), func(t *testing.T) {
+				var (
+					gen   Wrapper
+					plain plainWrapper
+				)
+				genErr := kdl.UnmarshalWithOptions([]byte(doc), &gen, opts)
+				plainErr := kdl.UnmarshalWithOptions([]byte(doc), &plain, opts)
+				if errString(genErr) != errString(plainErr) {
+					t.Fatalf("generated error %q, reflective error %q", errString(genErr), errString(plainErr))
+				}
+				if got, want := dump(reflect.ValueOf(gen)), dump(reflect.ValueOf(plain)); got != want {
+					t.Errorf("generated:\n%s\nreflective:\n%s", got, want)
+				}
+			})
+		}
+	}
+
+	var (
+		gen   Wrapper
+		plain plainWrapper
+	)
+	if err := kdl.Unmarshal([]byte(docs[0]), &gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := kdl.Unmarshal([]byte(docs[0]), &plain); err != nil {
+		t.Fatal(err)
+	}
+	for j, opts := range marshalOptions {
+		t.Run(fmt.Sprintf("marshal/opts%d", j), func(t *testing.T) {
+			genData, genErr := kdl.MarshalWithOptions(gen, kdl.MarshalOptions{MarshalerOptions: opts})
+			plainData, plainErr := kdl.MarshalWithOptions(plain, kdl.MarshalOptions{MarshalerOptions: opts})
+			if errString(genErr) != errString(plainErr) {
+				t.Fatalf("generated error %q, reflective error %q", errString(genErr), errString(plainErr))
+			}
+			if canonical(genData) != canonical(plainData) {
+				t.Errorf("generated:\n%s\nreflective:\n%s", genData, plainData)
+			}
+		})
+	}
+}
+
+func TestDirectMethods(t *testing.T) {
+	doc, err := kdl.Parse(strings.NewReader(`server "alpha" port=80 { listen "::1"; location "/" root="/srv"; }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s Server
+	if err := s.UnmarshalKDL(doc.Nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := Server{Name: "alpha", Port: 80, Listen: []string{"::1"}, Location: []*Location{{Path: "/", Extra: []string{}, Props: map[string]string{"root": "/srv"}, Root: "/srv"}}, Proto: "tcp", Idle: 90 * time.Second}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	node := document.NewNode()
+	node.SetName("server")
+	if err := s.MarshalKDL(node); err != nil {
+		t.Fatal(err)
+	}
+	var again Server
+	if err := again.UnmarshalKDL(node); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("after round trip got %+v, want %+v", again, want)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data := []byte(fullDocument)
+	b.Run("generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var g Config
+			if err := kdl.Unmarshal(data, &g); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflective", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var p plainConfig
+			if err := kdl.Unmarshal(data, &p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	var (
+		g Config
+		p plainConfig
+	)
+	if err := kdl.Unmarshal([]byte(fullDocument), &g); err != nil {
+		b.Fatal(err)
+	}
+	if err := kdl.Unmarshal([]byte(fullDocument), &p); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := kdl.Marshal(g); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflective", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := kdl.Marshal(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,256 @@
+// Package cst provides a lossless concrete syntax tree for KDL documents.
+//
+// A Tree retains every token of its source document, including the whitespace, comments, line continuations,
+// slashdash-commented content, and number and string spellings (collectively, trivia) that the document model discards,
+// alongside the document.Document parsed from those tokens. Changes made to the Document are written back into the
+// original source, so that only the nodes, arguments, and properties that were actually changed are reformatted and the
+// remainder of the output is byte-identical to the input.
+package cst
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// Tree is a lossless concrete syntax tree for a KDL document
+type Tree struct {
+	// Document is the document parsed from the source; changes made to it are reflected in the output of WriteTo
+	Document *document.Document
+
+	src    []byte
+	tokens []tokenizer.Token
+	// top-level nodes as originally parsed
+	nodes []*document.Node
+	// snapshots of every node as originally parsed
+	snapshots map[*document.Node]*nodeSnapshot
+	// indentation unit used for newly-added nodes
+	indent []byte
+}
+
+// nodeSnapshot records the state of a node as originally parsed
+type nodeSnapshot struct {
+	start   int
+	end     int
+	nameEnd int
+	typ     document.TypeAnnotation
+	name    document.Value
+
+	args    []*document.Value
+	argVals []document.Value
+
+	props []propSnapshot
+
+	children []*document.Node
+	// offsets of the opening and closing braces of the node's child block, or -1 if the node has no child block
+	blockOpen  int
+	blockClose int
+}
+
+// propSnapshot records the state of a property as originally parsed
+type propSnapshot struct {
+	key string
+	ptr *document.Value
+	val document.Value
+}
+
+// Parse parses a KDL document from r and returns its concrete syntax tree, or a non-nil error on failure
+func Parse(r io.Reader) (*Tree, error) {
+	return ParseWithOptions(r, parser.ParseContextOptions{})
+}
+
+// ParseWithOptions parses a KDL document from r using the specified options and returns its concrete syntax tree, or
+// a non-nil error on failure
+func ParseWithOptions(r io.Reader, opts parser.ParseContextOptions) (*Tree, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(src, opts)
+}
+
+// ParseBytes parses the KDL document in src using the specified options and returns its concrete syntax tree, or a
+// non-nil error on failure; the returned Tree retains src
+func ParseBytes(src []byte, opts parser.ParseContextOptions) (*Tree, error) {
+	// positions are required to map the document back onto its source
+	opts.Flags &^= parser.OmitPositions | parser.RecoverErrors
+
+	s := tokenizer.NewSlice(src)
+	s.RelaxedNonCompliant = opts.RelaxedNonCompliant
+	s.ParseComments = opts.Flags.Has(parser.ParseComments)
+	defer s.Close()
+
+	p := parser.New()
+	c := p.NewContextOptions(opts)
+	tokens := make([]tokenizer.Token, 0, len(src)/2)
+	for s.Scan() {
+		t := s.Token()
+		if err := p.Parse(c, t); err != nil {
+			return nil, err
+		}
+		if t.ID != tokenizer.EOF {
+			tokens = append(tokens, t)
+		}
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+
+	t := &Tree{
+		Document:  c.Document(),
+		src:       src,
+		tokens:    tokens,
+		snapshots: make(map[*document.Node]*nodeSnapshot),
+		indent:    detectIndent(src),
+	}
+	t.nodes = append([]*document.Node(nil), t.Document.Nodes...)
+	t.snapshotNodes(t.nodes)
+	return t, nil
+}
+
+// Tokens returns every token in the source document, including whitespace, newlines, and comments
+func (t *Tree) Tokens() []tokenizer.Token {
+	return t.tokens
+}
+
+// NodeTokens returns the tokens from which n was originally parsed, from its type annotation or name to the end of its
+// last argument, property, or child block; it returns nil if n was not parsed from the source document
+func (t *Tree) NodeTokens(n *document.Node) []tokenizer.Token {
+	s, ok := t.snapshots[n]
+	if !ok {
+		return nil
+	}
+	first, last := t.tokenRange(s.start, s.end)
+	return t.tokens[first:last]
+}
+
+// MissingComments returns the comments in t's source document that do not appear in other's, such as those dropped by
+// reformatting t's document with a generator that does not retain every comment; each comment is compared without its
+// surrounding whitespace, and each slashdash ("/-") in t's source that is not matched by one in other's is returned as
+// "/-"
+func (t *Tree) MissingComments(other *Tree) []string {
+	remaining := make(map[string]int)
+	for _, tok := range other.tokens {
+		if text, ok := commentText(tok); ok {
+			remaining[text]++
+		}
+	}
+
+	var missing []string
+	for _, tok := range t.tokens {
+		if text, ok := commentText(tok); ok {
+			if remaining[text] > 0 {
+				remaining[text]--
+			} else {
+				missing = append(missing, text)
+			}
+		}
+	}
+	return missing
+}
+
+// commentText returns the text of tok without its surrounding whitespace and true if tok is a comment or slashdash
+func commentText(tok tokenizer.Token) (string, bool) {
+	switch tok.ID {
+	case tokenizer.SingleLineComment, tokenizer.MultiLineComment, tokenizer.TokenComment:
+		return string(bytes.TrimSpace(tok.Data)), true
+	default:
+		return "", false
+	}
+}
+
+// Source returns the source document from which t was parsed
+func (t *Tree) Source() []byte {
+	return t.src
+}
+
+// tokenRange returns the indexes of the first token starting at or after start, and of the first token starting at or
+// after end
+func (t *Tree) tokenRange(start int, end int) (int, int) {
+	first := sort.Search(len(t.tokens), func(i int) bool { return t.tokens[i].Offset >= start })
+	last := first + sort.Search(len(t.tokens)-first, func(i int) bool { return t.tokens[first+i].Offset >= end })
+	return first, last
+}
+
+// snapshotNodes records the original state of each of nodes and their descendants
+func (t *Tree) snapshotNodes(nodes []*document.Node) {
+	for _, n := range nodes {
+		s := &nodeSnapshot{
+			start:      n.Span.Start.Offset,
+			end:        n.Span.End.Offset,
+			nameEnd:    n.Name.Span.End.Offset,
+			typ:        n.Type,
+			name:       *n.Name,
+			args:       append([]*document.Value(nil), n.Arguments...),
+			argVals:    make([]document.Value, len(n.Arguments)),
+			children:   append([]*document.Node(nil), n.Children...),
+			blockOpen:  -1,
+			blockClose: -1,
+		}
+		for i, arg := range n.Arguments {
+			s.argVals[i] = *arg
+		}
+		for key, val := range n.Properties.Unordered() {
+			s.props = append(s.props, propSnapshot{key: key, ptr: val, val: *val})
+		}
+		sort.Slice(s.props, func(i, j int) bool { return s.props[i].val.Span.Start.Offset < s.props[j].val.Span.Start.Offset })
+
+		t.findBlock(s)
+		t.snapshots[n] = s
+		t.snapshotNodes(n.Children)
+	}
+}
+
+// findBlock locates the child block (if any) of the node described by s; slashdash-commented child blocks are ignored
+func (t *Tree) findBlock(s *nodeSnapshot) {
+	first, last := t.tokenRange(s.start, s.end)
+	depth := 0
+	open := -1
+	slashdash := false
+	prev := tokenizer.Unknown
+	for _, tok := range t.tokens[first:last] {
+		switch tok.ID {
+		case tokenizer.BraceOpen:
+			if depth == 0 {
+				open = tok.Offset
+				slashdash = prev == tokenizer.TokenComment
+			}
+			depth++
+		case tokenizer.BraceClose:
+			depth--
+			if depth == 0 && !slashdash {
+				s.blockOpen, s.blockClose = open, tok.Offset
+			}
+		}
+		if tok.ID != tokenizer.Whitespace {
+			prev = tok.ID
+		}
+	}
+}
+
+// detectIndent returns the indentation of the first indented line in src, or a tab if no lines are indented
+func detectIndent(src []byte) []byte {
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\n' {
+			continue
+		}
+		j := i + 1
+		for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+			j++
+		}
+		if j > i+1 && j < len(src) && src[j] != '\n' && src[j] != '\r' {
+			return src[i+1 : j]
+		}
+	}
+	return []byte{'\t'}
+}
+
+// valueChanged returns true if v differs from its original state orig
+func valueChanged(v *document.Value, orig *document.Value) bool {
+	return v.Type != orig.Type || v.Flag != orig.Flag || !reflect.DeepEqual(v.Value, orig.Value)
+}
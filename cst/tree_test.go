@@ -0,0 +1,215 @@
+package cst
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+const treeInput = `// configuration
+/* block */ (t)server "alpha" 0x1F 1.5e3 r#"raw"# \
+    // continued
+    port=8080 /-"ignored" {
+    listen "0.0.0.0"; backlog 1_000
+    /-disabled true
+}
+
+/-skipped 1
+  tls true null -1_000 ;	
+empty {}
+`
+
+func newNode(name string, args ...interface{}) *document.Node {
+	n := document.NewNode()
+	n.SetName(name)
+	for _, arg := range args {
+		n.AddArgument(arg, "")
+	}
+	return n
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	inputs := []string{
+		treeInput,
+		"",
+		"node",
+		"a 1\r\nb 2\r\n",
+		"a; b; c {d;e;}",
+	}
+	for _, input := range inputs {
+		tree, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", input, err)
+		}
+		if got := tree.String(); got != input {
+			t.Fatalf("String():\ngot : %q\nwant: %q", got, input)
+		}
+	}
+}
+
+func TestTreeEdit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edit  func(doc *document.Document)
+		want  string
+	}{
+		{
+			name:  "change argument",
+			input: treeInput,
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].Arguments[1].Value = int64(32)
+			},
+			want: strings.Replace(treeInput, "0x1F", "0x20", 1),
+		},
+		{
+			name:  "replace argument",
+			input: treeInput,
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].Arguments[0] = &document.Value{Value: "beta"}
+			},
+			want: strings.Replace(treeInput, `"alpha"`, `"beta"`, 1),
+		},
+		{
+			name:  "change property",
+			input: treeInput,
+			edit: func(doc *document.Document) {
+				v, _ := doc.Nodes[0].Properties.Get("port")
+				v.Type = "u16"
+				v.Value = int64(443)
+			},
+			want: strings.Replace(treeInput, "port=8080", "port=(u16)443", 1),
+		},
+		{
+			name:  "rename node",
+			input: treeInput,
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].Children[1].SetName("queue")
+			},
+			want: strings.Replace(treeInput, "backlog", "queue", 1),
+		},
+		{
+			name:  "remove argument",
+			input: treeInput,
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].Children[1].Arguments = nil
+			},
+			want: strings.Replace(treeInput, "backlog 1_000", "backlog", 1),
+		},
+		{
+			name:  "add argument and property",
+			input: "a 1 {\n    b\n}\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].AddArgument(int64(2), "")
+				doc.Nodes[0].AddProperty("c", true, "")
+			},
+			want: "a 1 2 c=true {\n    b\n}\n",
+		},
+		{
+			name:  "remove node",
+			input: "a 1\n// about b\nb 2\nc 3\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes = append(doc.Nodes[:1], doc.Nodes[2])
+			},
+			want: "a 1\nc 3\n",
+		},
+		{
+			name:  "remove first node",
+			input: "a 1\nb 2\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes = doc.Nodes[1:]
+			},
+			want: "b 2\n",
+		},
+		{
+			name:  "add child",
+			input: "a {\n    b 1\n}\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].AddNode(newNode("c", int64(2)))
+			},
+			want: "a {\n    b 1\n    c 2\n}\n",
+		},
+		{
+			name:  "add child to empty block",
+			input: "a {\n    b {}\n}\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].Children[0].AddNode(newNode("c"))
+			},
+			want: "a {\n    b {\n        c\n    }\n}\n",
+		},
+		{
+			name:  "add children block",
+			input: "a 1 // comment\nb 2\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes[0].AddNode(newNode("c", "x"))
+			},
+			want: "a 1 {\n\tc \"x\"\n} // comment\nb 2\n",
+		},
+		{
+			name:  "add top-level node",
+			input: "a 1\n",
+			edit: func(doc *document.Document) {
+				doc.AddNode(newNode("b", int64(2)))
+			},
+			want: "a 1\nb 2\n",
+		},
+		{
+			name:  "prepend top-level node",
+			input: "a 1\n",
+			edit: func(doc *document.Document) {
+				doc.Nodes = append([]*document.Node{newNode("b")}, doc.Nodes...)
+			},
+			want: "b\na 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			tt.edit(tree.Document)
+			if got := tree.String(); got != tt.want {
+				t.Fatalf("String():\ngot : %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTreeTokens(t *testing.T) {
+	tree, err := Parse(strings.NewReader("a 1 /* c */ 2\nb"))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	b := strings.Builder{}
+	for _, tok := range tree.NodeTokens(tree.Document.Nodes[0]) {
+		b.Write(tok.Data)
+	}
+	if b.String() != "a 1 /* c */ 2" {
+		t.Fatalf("NodeTokens():\ngot : %q\nwant: %q", b.String(), "a 1 /* c */ 2")
+	}
+	if len(tree.Tokens()) != 9 {
+		t.Fatalf("Tokens(): got %d tokens, want 9", len(tree.Tokens()))
+	}
+}
+
+func TestTreeMissingComments(t *testing.T) {
+	src, err := Parse(strings.NewReader("// a\nn 1 // b\n/-m /* c */ 2\n/* c */\n"))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	out, err := Parse(strings.NewReader("// a\n/* c */\nn 1\n"))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if got, want := strings.Join(src.MissingComments(out), ","), "// b,/-,/* c */"; got != want {
+		t.Fatalf("MissingComments():\ngot : %q\nwant: %q", got, want)
+	}
+	if got := out.MissingComments(src); len(got) != 0 {
+		t.Fatalf("MissingComments(): got %q, want none", got)
+	}
+}
@@ -0,0 +1,252 @@
+package cst
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// writer regenerates a document from its Tree, reusing the original source for anything that has not changed
+type writer struct {
+	t *Tree
+	b []byte
+}
+
+// WriteTo writes the document to w, preserving the original source text of every node, argument, and property that
+// has not been changed, along with all whitespace and comments
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(t.Bytes())
+	return int64(n), err
+}
+
+// Bytes returns the document as it would be written by WriteTo
+func (t *Tree) Bytes() []byte {
+	wr := &writer{t: t, b: make([]byte, 0, len(t.src)+64)}
+	wr.nodes(t.Document.Nodes, t.nodes, 0, len(t.src), nil, 0)
+	return wr.b
+}
+
+// String returns the document as it would be written by WriteTo
+func (t *Tree) String() string {
+	return string(t.Bytes())
+}
+
+// nodes writes the list of nodes cur, which was originally parsed as orig from src[start:end]; parentIndent is the
+// indentation of the parent node and depth is the nesting depth of the nodes
+func (w *writer) nodes(cur []*document.Node, orig []*document.Node, start int, end int, parentIndent []byte, depth int) {
+	src := w.t.src
+	prevEnd := make(map[*document.Node]int, len(orig))
+	pos := start
+	for _, n := range orig {
+		prevEnd[n] = pos
+		pos = w.t.snapshots[n].end
+	}
+	trailing := src[pos:end]
+
+	indent := parentIndent
+	if len(orig) > 0 {
+		indent = w.lineIndent(w.t.snapshots[orig[0]].start)
+	} else if depth > 0 {
+		indent = append(append([]byte(nil), parentIndent...), w.t.indent...)
+	}
+
+	emitted := false
+	for _, n := range cur {
+		if from, ok := prevEnd[n]; ok {
+			leading := src[from:w.t.snapshots[n].start]
+			if !emitted && depth == 0 && n != orig[0] {
+				// the first node was removed, so drop the terminator of the node that preceded this one
+				leading = trimTerminator(leading)
+			} else if emitted && !bytes.ContainsAny(leading, "\n;") {
+				// a new or reordered node precedes this one, so it needs a terminator
+				w.b = append(w.b, '\n')
+			}
+			w.b = append(w.b, leading...)
+			w.node(n, depth)
+		} else {
+			if emitted || depth > 0 {
+				w.b = append(w.b, '\n')
+			}
+			w.b = append(w.b, indent...)
+			w.newNode(n, indent, depth)
+		}
+		emitted = true
+	}
+
+	if len(orig) == 0 && emitted && depth > 0 && !bytes.ContainsAny(trailing, "\r\n") {
+		w.b = append(w.b, '\n')
+		w.b = append(w.b, parentIndent...)
+	}
+	w.b = append(w.b, trailing...)
+}
+
+// element identifies a span of a node's original source occupied by its name, an argument, or a property
+type element struct {
+	start int
+	end   int
+	arg   int
+	prop  *propSnapshot
+}
+
+// node writes n, which was originally parsed from the source
+func (w *writer) node(n *document.Node, depth int) {
+	s := w.t.snapshots[n]
+	src := w.t.src
+
+	elements := make([]element, 0, len(s.args)+len(s.props))
+	for i, arg := range s.args {
+		elements = append(elements, element{start: arg.Span.Start.Offset, end: arg.Span.End.Offset, arg: i})
+	}
+	for i := range s.props {
+		p := &s.props[i]
+		elements = append(elements, element{start: p.val.Span.Start.Offset, end: p.val.Span.End.Offset, arg: -1, prop: p})
+	}
+	sort.Slice(elements, func(i, j int) bool { return elements[i].start < elements[j].start })
+
+	if n.Type != s.typ || valueChanged(n.Name, &s.name) {
+		w.name(n)
+	} else {
+		w.b = append(w.b, src[s.start:s.nameEnd]...)
+	}
+
+	pos := s.nameEnd
+	for _, el := range elements {
+		if el.prop == nil {
+			if el.arg >= len(n.Arguments) {
+				// removed; skip the whitespace preceding it as well
+				pos = el.end
+				continue
+			}
+			w.b = append(w.b, src[pos:el.start]...)
+			arg := n.Arguments[el.arg]
+			if arg == s.args[el.arg] && !valueChanged(arg, &s.argVals[el.arg]) {
+				w.b = append(w.b, src[el.start:el.end]...)
+			} else {
+				w.b = append(w.b, arg.FormattedString()...)
+			}
+		} else {
+			val, ok := n.Properties.Get(el.prop.key)
+			if !ok {
+				pos = el.end
+				continue
+			}
+			w.b = append(w.b, src[pos:el.start]...)
+			if val == el.prop.ptr && !valueChanged(val, &el.prop.val) {
+				w.b = append(w.b, src[el.start:el.end]...)
+			} else {
+				w.b = appendProperty(w.b, el.prop.key, val)
+			}
+		}
+		pos = el.end
+	}
+
+	// new arguments and properties follow the last existing argument or property
+	for _, arg := range n.Arguments[min(len(s.args), len(n.Arguments)):] {
+		w.b = append(w.b, ' ')
+		w.b = append(w.b, arg.FormattedString()...)
+	}
+	w.newProperties(n, s)
+
+	indent := w.lineIndent(s.start)
+	if s.blockOpen >= 0 {
+		w.b = append(w.b, src[pos:s.blockOpen+1]...)
+		w.nodes(n.Children, s.children, s.blockOpen+1, s.blockClose, indent, depth+1)
+		pos = s.blockClose
+	} else if len(n.Children) > 0 {
+		w.b = append(w.b, src[pos:s.end]...)
+		pos = s.end
+		w.b = append(w.b, " {"...)
+		w.nodes(n.Children, nil, pos, pos, indent, depth+1)
+		w.b = append(w.b, '}')
+	}
+	w.b = append(w.b, src[pos:s.end]...)
+}
+
+// newProperties writes the properties of n that did not exist when it was originally parsed, in alphabetical order
+func (w *writer) newProperties(n *document.Node, s *nodeSnapshot) {
+	existing := make(map[string]bool, len(s.props))
+	for _, p := range s.props {
+		existing[p.key] = true
+	}
+	props := n.Properties.Unordered()
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		if !existing[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		w.b = append(w.b, ' ')
+		w.b = appendProperty(w.b, key, props[key])
+	}
+}
+
+// name writes the type annotation and name of n
+func (w *writer) name(n *document.Node) {
+	if len(n.Type) > 0 {
+		w.b = append(w.b, '(')
+		w.b = append(w.b, n.Type...)
+		w.b = append(w.b, ')')
+	}
+	w.b = append(w.b, n.Name.NodeNameString()...)
+}
+
+// newNode writes n, which was not parsed from the source, at the specified indentation and depth
+func (w *writer) newNode(n *document.Node, indent []byte, depth int) {
+	b := bytes.Buffer{}
+	_, _ = n.WriteToOptions(&b, document.NodeWriteOptions{
+		LeadingTrailingSpace: true,
+		NameAndType:          true,
+		Depth:                depth,
+		Indent:               w.t.indent,
+	})
+	out := bytes.TrimSuffix(b.Bytes(), []byte{'\n'})
+	// the first line is already indented by the caller
+	out = bytes.TrimLeft(out, " \t")
+	w.b = append(w.b, out...)
+}
+
+// lineIndent returns the whitespace preceding offset on its line, or nil if offset is preceded by anything other than
+// whitespace
+func (w *writer) lineIndent(offset int) []byte {
+	src := w.t.src
+	i := offset
+	for i > 0 && (src[i-1] == ' ' || src[i-1] == '\t') {
+		i--
+	}
+	if i > 0 && src[i-1] != '\n' && src[i-1] != '\r' {
+		return nil
+	}
+	return src[i:offset]
+}
+
+// trimTerminator removes leading whitespace, semicolons, and the first newline from b
+func trimTerminator(b []byte) []byte {
+	b = bytes.TrimLeft(b, " \t;")
+	if bytes.HasPrefix(b, []byte{'\r', '\n'}) {
+		return b[2:]
+	}
+	return bytes.TrimPrefix(b, []byte{'\n'})
+}
+
+// appendProperty appends the KDL representation of the property key=val to b
+func appendProperty(b []byte, key string, val *document.Value) []byte {
+	if len(key) > 0 && tokenizer.IsBareIdentifier(key, 0) {
+		b = append(b, key...)
+	} else {
+		b = document.AppendQuotedString(b, key, '"')
+	}
+	b = append(b, '=')
+	return append(b, val.FormattedString()...)
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
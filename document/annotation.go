@@ -0,0 +1,173 @@
+package document
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// AnnotationFunc converts v, the resolved value of a Value with a registered type annotation (see
+// Value.ResolvedValue), into the Go value that the annotation represents; v is never nil
+type AnnotationFunc func(v interface{}) (interface{}, error)
+
+// builtinAnnotations holds the functions built into this package, which are registered by default
+var builtinAnnotations = map[TypeAnnotation]AnnotationFunc{
+	"date-time": annotatedDateTime,
+	"ipv4":      annotatedIPv4,
+	"ipv6":      annotatedIPv6,
+	"decimal":   annotatedDecimal,
+	"uuid":      annotatedUUID,
+	"base64":    annotatedBase64,
+}
+
+var (
+	annotationsMu sync.RWMutex
+	annotations   = maps.Clone(builtinAnnotations)
+)
+
+// RegisterAnnotation registers fn to convert the values annotated with name, replacing any function previously
+// registered for it (including the built-in functions for "date-time", "ipv4", "ipv6", "decimal", "uuid", and
+// "base64"); if fn is nil, name is unregistered. It may be called at any time, including concurrently with calls to
+// Value.Annotated.
+func RegisterAnnotation(name TypeAnnotation, fn AnnotationFunc) {
+	annotationsMu.Lock()
+	defer annotationsMu.Unlock()
+	if fn == nil {
+		delete(annotations, name)
+	} else {
+		annotations[name] = fn
+	}
+}
+
+// LookupAnnotation returns the function registered to convert the values annotated with name, or nil if there is none
+func LookupAnnotation(name TypeAnnotation) AnnotationFunc {
+	annotationsMu.RLock()
+	defer annotationsMu.RUnlock()
+	return annotations[name]
+}
+
+// BuiltinAnnotation returns the function built into this package to convert the values annotated with name, regardless
+// of any function registered for name via RegisterAnnotation, or nil if there is none
+func BuiltinAnnotation(name TypeAnnotation) AnnotationFunc {
+	return builtinAnnotations[name]
+}
+
+// Annotated returns the Go value represented by this Value according to its type annotation: if a function has been
+// registered for the annotation via RegisterAnnotation, it returns the result of calling it with ResolvedValue(),
+// otherwise it returns ResolvedValue() itself. The built-in annotations are converted as follows:
+//   - (date-time) strings in RFC3339 format are returned as a time.Time
+//   - (ipv4) and (ipv6) strings are returned as a netip.Addr, which must be of the annotated family
+//   - (decimal) strings and numbers are returned as a *big.Float
+//   - (uuid) strings such as "123e4567-e89b-12d3-a456-426614174000" are returned as a [16]byte
+//   - (base64) strings in standard base64 encoding are returned as a []byte
+//
+// Null values are returned as nil regardless of their annotation.
+func (v *Value) Annotated() (interface{}, error) {
+	val := v.ResolvedValue()
+	if v.Type == "" || val == nil {
+		return val, nil
+	}
+	fn := LookupAnnotation(v.Type)
+	if fn == nil {
+		return val, nil
+	}
+	a, err := fn(val)
+	if err != nil {
+		return nil, fmt.Errorf("(%s): %w", v.Type, err)
+	}
+	return a, nil
+}
+
+// annotatedString returns v if it is a string, or an error otherwise
+func annotatedString(v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, not %v", v)
+	}
+	return s, nil
+}
+
+func annotatedDateTime(v interface{}) (interface{}, error) {
+	s, err := annotatedString(v)
+	if err != nil {
+		return nil, err
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func annotatedIPv4(v interface{}) (interface{}, error) {
+	return annotatedAddr(v, true)
+}
+
+func annotatedIPv6(v interface{}) (interface{}, error) {
+	return annotatedAddr(v, false)
+}
+
+// annotatedAddr returns v as a netip.Addr if it is a string containing an IPv4 address (if is4 is true) or an IPv6
+// address (otherwise)
+func annotatedAddr(v interface{}, is4 bool) (interface{}, error) {
+	s, err := annotatedString(v)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	if is4 && !addr.Is4() {
+		return nil, fmt.Errorf("%q is not an IPv4 address", s)
+	} else if !is4 && addr.Is4() {
+		return nil, fmt.Errorf("%q is not an IPv6 address", s)
+	}
+	return addr, nil
+}
+
+func annotatedDecimal(v interface{}) (interface{}, error) {
+	switch n := v.(type) {
+	case int64:
+		return new(big.Float).SetInt64(n), nil
+	case float64:
+		return new(big.Float).SetFloat64(n), nil
+	case *big.Int:
+		return new(big.Float).SetInt(n), nil
+	case *big.Float:
+		return n, nil
+	case string:
+		f, ok := new(big.Float).SetString(n)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal %q", n)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("expected a number or string, not %v", v)
+	}
+}
+
+func annotatedUUID(v interface{}) (interface{}, error) {
+	s, err := annotatedString(v)
+	if err != nil {
+		return nil, err
+	}
+	var u [16]byte
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return nil, fmt.Errorf("invalid UUID %q", s)
+	}
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return nil, fmt.Errorf("invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+func annotatedBase64(v interface{}) (interface{}, error) {
+	s, err := annotatedString(v)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
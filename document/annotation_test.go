@@ -0,0 +1,84 @@
+package document
+
+import (
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValue_Annotated(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *Value
+		want  interface{}
+	}{
+		{"date-time", &Value{Type: "date-time", Value: "2024-01-02T03:04:05Z"}, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"ipv6", &Value{Type: "ipv6", Value: "::1"}, netip.MustParseAddr("::1")},
+		{"ipv4", &Value{Type: "ipv4", Value: "10.0.0.1"}, netip.MustParseAddr("10.0.0.1")},
+		{"decimal string", &Value{Type: "decimal", Value: "1.10"}, "1.1"},
+		{"decimal number", &Value{Type: "decimal", Value: int64(2)}, "2"},
+		{"uuid", &Value{Type: "uuid", Value: "123e4567-e89b-12d3-a456-426614174000"}, [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}},
+		{"base64", &Value{Type: "base64", Value: "aGk="}, []byte("hi")},
+		{"unregistered", &Value{Type: "other", Value: "x"}, "x"},
+		{"unannotated", &Value{Value: "::1"}, "::1"},
+		{"null", &Value{Type: "uuid"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.Annotated()
+			if err != nil {
+				t.Fatalf("Annotated() failed: %v", err)
+			}
+			if f, ok := got.(*big.Float); ok {
+				// decimals are compared by their text, as they are parsed exactly rather than from a float64
+				if f.String() != tt.want {
+					t.Errorf("Annotated() = %v, want %v", f, tt.want)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Annotated() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	for _, v := range []*Value{
+		{Type: "date-time", Value: "yesterday"},
+		{Type: "ipv6", Value: int64(1)},
+		{Type: "ipv4", Value: "::1"},
+		{Type: "ipv6", Value: "10.0.0.1"},
+		{Type: "uuid", Value: "123e4567e89b12d3a456426614174000"},
+		{Type: "base64", Value: "a"},
+	} {
+		if _, err := v.Annotated(); err == nil || !strings.HasPrefix(err.Error(), "("+string(v.Type)+"): ") {
+			t.Errorf("Annotated(%s) error = %v, want error", v, err)
+		}
+	}
+}
+
+func TestRegisterAnnotation(t *testing.T) {
+	RegisterAnnotation("upper", func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	})
+	defer RegisterAnnotation("upper", nil)
+
+	if got, err := (&Value{Type: "upper", Value: "abc"}).Annotated(); err != nil || got != "ABC" {
+		t.Errorf("Annotated() = %v, %v, want ABC", got, err)
+	}
+
+	RegisterAnnotation("upper", nil)
+	if LookupAnnotation("upper") != nil {
+		t.Error("LookupAnnotation() returned an unregistered annotation")
+	}
+
+	// replacing a built-in annotation does not replace the function returned by BuiltinAnnotation
+	RegisterAnnotation("uuid", nil)
+	defer RegisterAnnotation("uuid", BuiltinAnnotation("uuid"))
+	if BuiltinAnnotation("uuid") == nil || BuiltinAnnotation("upper") != nil {
+		t.Error("BuiltinAnnotation() returned the wrong functions")
+	}
+	if got, _ := (&Value{Type: "upper", Value: "abc"}).Annotated(); got != "abc" {
+		t.Errorf("Annotated() = %v, want abc", got)
+	}
+}
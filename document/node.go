@@ -32,6 +32,9 @@ type Node struct {
 	Children []*Node
 	// Comment is the comment for the node, or nil if none
 	Comment *Comment
+	// Span is the location of the node in the source document (from its type annotation or name to the end of its
+	// last argument, property, or child block), if known
+	Span Span
 }
 
 func (n *Node) ShallowCopy() *Node {
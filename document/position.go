@@ -0,0 +1,63 @@
+package document
+
+import (
+	"strconv"
+
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// Position identifies a location in a KDL document
+type Position struct {
+	// Line is the 1-based line number
+	Line int
+	// Column is the 1-based column number, counted in characters
+	Column int
+	// Offset is the 0-based byte offset from the start of the input
+	Offset int
+}
+
+// IsValid returns true if p represents a known position
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position formatted as line:column, or "-" if the position is not known
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Span identifies the range of a KDL document occupied by a node or value; End refers to the position immediately
+// following the last character of the range
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsValid returns true if s represents a known range
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+// String returns the span's start position formatted as line:column
+func (s Span) String() string {
+	return s.Start.String()
+}
+
+// TokenStart returns the Position at which t starts
+func TokenStart(t tokenizer.Token) Position {
+	return Position{Line: t.Line + 1, Column: t.Column + 1, Offset: t.Offset}
+}
+
+// TokenEnd returns the Position immediately following the end of t
+func TokenEnd(t tokenizer.Token) Position {
+	line, column, offset := t.End()
+	return Position{Line: line + 1, Column: column + 1, Offset: offset}
+}
+
+// TokenSpan returns the Span from the start of the first token to the end of the last token
+func TokenSpan(first tokenizer.Token, last tokenizer.Token) Span {
+	return Span{Start: TokenStart(first), End: TokenEnd(last)}
+}
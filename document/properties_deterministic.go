@@ -44,6 +44,12 @@ func (p *Properties) Add(name string, val *Value) {
 	p.props[name] = val
 }
 
+func (p Properties) Keys() []string {
+	keys := make([]string, len(p.order))
+	copy(keys, p.order)
+	return keys
+}
+
 func (p *Properties) Exist() bool {
 	return len(p.order) > 0
 }
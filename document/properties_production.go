@@ -7,6 +7,8 @@
 package document
 
 import (
+	"sort"
+
 	"github.com/sblinch/kdl-go/internal/tokenizer"
 )
 
@@ -45,15 +47,34 @@ func (p Properties) Add(name string, val *Value) {
 	p[name] = val
 }
 
+// Keys returns the property names; as the insertion order is not retained in this implementation, names are returned
+// in the order in which their properties appear in the source document if known, otherwise in ascending order
+func (p Properties) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := p[keys[i]].Span, p[keys[j]].Span
+		if a.IsValid() && b.IsValid() && a.Start.Offset != b.Start.Offset {
+			return a.Start.Offset < b.Start.Offset
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
 // Exist indicates whether any properties exist
 func (p Properties) Exist() bool {
 	return len(p) > 0
 }
 
-// String returns the KDL representation of the property list, formatting numbers per their flags
+// String returns the KDL representation of the property list in the order given by Keys, formatting numbers per their
+// flags
 func (p Properties) String() string {
 	b := make([]byte, 0, len(p)*(1+8+1+8))
-	for k, v := range p {
+	for _, k := range p.Keys() {
+		v := p[k]
 		b = append(b, ' ')
 		if len(k) > 0 && tokenizer.IsBareIdentifier(k, 0) {
 			b = append(b, k...)
@@ -67,10 +88,12 @@ func (p Properties) String() string {
 	return string(b)
 }
 
-// UnformattedString returns the KDL representation of the property list, formatting numbers in decimal
+// UnformattedString returns the KDL representation of the property list in the order given by Keys, formatting
+// numbers in decimal
 func (p Properties) UnformattedString() string {
 	b := make([]byte, 0, len(p)*(1+8+1+8))
-	for k, v := range p {
+	for _, k := range p.Keys() {
+		v := p[k]
 		b = append(b, ' ')
 		if len(k) > 0 && tokenizer.IsBareIdentifier(k, 0) {
 			b = append(b, k...)
@@ -84,7 +107,8 @@ func (p Properties) UnformattedString() string {
 	return string(b)
 }
 
-// AppendTo appends the KDL representation of the property list to b, formatting numbers in decimal, and returns b
+// AppendTo appends the KDL representation of the property list to b in the order given by Keys, formatting numbers in
+// decimal, and returns b
 func (p Properties) AppendTo(b []byte) []byte {
 	required := len(p) * (1 + 8 + 1 + 8)
 	if cap(b)-len(b) < required {
@@ -92,7 +116,8 @@ func (p Properties) AppendTo(b []byte) []byte {
 		r = append(r, b...)
 		b = r
 	}
-	for k, v := range p {
+	for _, k := range p.Keys() {
+		v := p[k]
 		b = append(b, ' ')
 		if len(k) > 0 && tokenizer.IsBareIdentifier(k, 0) {
 			b = append(b, k...)
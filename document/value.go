@@ -39,6 +39,9 @@ type Value struct {
 	Value interface{}
 	// Flag is any flag assigned for use in output
 	Flag ValueFlag
+	// Span is the location of the value in the source document, if known; for an argument, this includes its type
+	// annotation, and for a property, this includes its key
+	Span Span
 }
 
 // valueOpts specify options for rendering Values as strings
@@ -210,7 +213,11 @@ func (v *Value) value(b []byte, opts valueOpts) []byte {
 		if exp > 9 || exp < -9 {
 			b = x.Append(b, 'E', -1)
 		} else {
-			b = x.Append(b, 'f', 6)
+			// make sure floats in decimal notation always include a decimal point
+			b = x.Append(b, 'f', -1)
+			if x.IsInt() {
+				b = append(b, '.', '0')
+			}
 		}
 
 	case SuffixedDecimal:
@@ -290,7 +297,7 @@ func (v *Value) ValueString() string {
 // - strings are returned as strings containing the unquoted representation of the string
 func (v *Value) ResolvedValue() interface{} {
 	if _, ok := v.Value.(string); ok {
-		return v.string(voNoQuotes)
+		return string(v.value(nil, voNoQuotes))
 	} else {
 		return v.Value
 	}
@@ -0,0 +1,26 @@
+package kdl
+
+import (
+	"github.com/sblinch/kdl-go/internal/marshaler"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// SyntaxError is returned when a KDL document cannot be scanned or parsed; use errors.As to retrieve it from an error
+// returned by Parse, Unmarshal, or Decoder.Decode
+type SyntaxError = tokenizer.SyntaxError
+
+// SyntaxErrors is the list of syntax errors returned by ParseRecover
+type SyntaxErrors = tokenizer.SyntaxErrors
+
+// UnmarshalError describes a failure to unmarshal a single node, argument, or property, including its path in the
+// document, its position, and the Go field and type into which it was being unmarshaled
+type UnmarshalError = marshaler.UnmarshalError
+
+// UnmarshalErrors is the list of errors returned by Unmarshal when UnmarshalOptions.CollectErrors is set
+type UnmarshalErrors = marshaler.UnmarshalErrors
+
+// Token is a single token scanned from a KDL document
+type Token = tokenizer.Token
+
+// TokenID identifies the type or class of a Token
+type TokenID = tokenizer.TokenID
@@ -0,0 +1,43 @@
+package coerce
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// numberer is implemented by values (such as document.SuffixedDecimal) that can be converted to a number
+type numberer interface {
+	AsNumber() (interface{}, error)
+}
+
+// ToBigFloat converts v to a *big.Float if it is numeric, and returns false otherwise
+func ToBigFloat(v interface{}) (*big.Float, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return new(big.Float).SetInt(n), true
+	case *big.Float:
+		return n, true
+	case numberer:
+		num, err := n.AsNumber()
+		if err != nil {
+			return nil, false
+		}
+		return ToBigFloat(num)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Float).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Float).SetUint64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(rv.Float()) {
+			return nil, false
+		}
+		return big.NewFloat(rv.Float()), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,254 @@
+package marshaler
+
+// Handling of the ",type:..." struct tag option, which names the type annotation written before the values of a field
+// when it is marshaled, and which the values of the field must agree with when it is unmarshaled. The annotations
+// reserved by the KDL specification for numbers, dates and times, addresses, and other formats are also checked
+// against the values they annotate.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// annotatesNode returns true if the type annotation of a field of type t is applied to the node representing its
+// value rather than to the node's arguments, which is the case for structs and maps that are not represented by a
+// single value; such types are identified by their text and KDL value marshalers if marshal is true, or by their
+// unmarshalers otherwise
+func annotatesNode(i *typeIndexer, t reflect.Type, marshal bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		return false
+	}
+	d := i.Get(t)
+	if d == nil {
+		return true
+	} else if marshal {
+		return !d.CanMarshalText() && !d.CanMarshalKDLValue()
+	}
+	return !d.CanUnmarshalText() && !d.CanUnmarshalKDLValue()
+}
+
+// annotateNode applies the type annotation of the field described by fld, if any, to node, which was marshaled from v;
+// null arguments are not annotated, and an error is returned if any other argument is invalid for the annotation
+func annotateNode(c *marshalContext, node *document.Node, v reflect.Value, fld *structFieldDetails) error {
+	if fld == nil || fld.Annotation == "" || node == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.IsValid() && annotatesNode(c.indexer, v.Type(), true) {
+		node.Type = fld.Annotation
+		return nil
+	}
+	for _, arg := range node.Arguments {
+		if err := annotateValue(arg, fld); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotateValue applies the type annotation of the field described by fld, if any, to dv unless it is null; an error
+// is returned if dv is invalid for the annotation, per checkReservedAnnotation, so that the annotated document remains
+// valid
+func annotateValue(dv *document.Value, fld *structFieldDetails) error {
+	if fld.Annotation == "" || dv.Value == nil {
+		return nil
+	}
+	if err := checkReservedAnnotation(fld.Annotation, dv.ResolvedValue()); err != nil {
+		return err
+	}
+	dv.Type = fld.Annotation
+	return nil
+}
+
+// checkAnnotations returns an error, reported via report, if any of sources, the values and child nodes from which the
+// field of a struct named name (and described by fld, of type fieldType) was unmarshaled, has a type annotation other
+// than the field's, or is invalid for the field's annotation if it is one of those reserved by the KDL specification
+func checkAnnotations(c *unmarshalContext, report fieldErrorFunc, fieldType reflect.Type, name string, fld *structFieldDetails, sources []fieldSource) error {
+	if fld.Attrs.Has("props") || fld.Attrs.Has("children") {
+		// captured properties and child nodes are not annotated
+		return nil
+	}
+	key := fieldKey(name, fld)
+	if fld.IsMultiple() && (fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array) {
+		fieldType = fieldType.Elem()
+	}
+
+	for _, src := range sources {
+		values := []*document.Value{src.value}
+		if src.value == nil {
+			if annotatesNode(c.indexer, fieldType, false) {
+				err := checkAnnotationConflict(src.node.Type, fld.Annotation)
+				if err = report(err, src.position(), key); err != nil {
+					return err
+				}
+				continue
+			}
+			values = src.node.Arguments
+		}
+
+		for _, val := range values {
+			err := checkAnnotationConflict(val.Type, fld.Annotation)
+			if err == nil {
+				err = checkReservedAnnotation(fld.Annotation, val.ResolvedValue())
+			}
+			if err = report(err, valuePosition(src.node, val), key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAnnotationConflict returns an error if annotation, the type annotation of a value or node, is neither absent
+// nor the same as want, the type annotation of the field into which it is unmarshaled
+func checkAnnotationConflict(annotation document.TypeAnnotation, want document.TypeAnnotation) error {
+	if annotation == "" || annotation == want {
+		return nil
+	}
+	return fmt.Errorf("type annotation (%s) conflicts with the field's type (%s)", annotation, want)
+}
+
+// annotationBounds holds the range of each integer type annotation reserved by the KDL specification
+var annotationBounds = map[document.TypeAnnotation]struct{ min, max *big.Int }{
+	"i8":    {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	"i16":   {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	"i32":   {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	"i64":   {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	"isize": {big.NewInt(math.MinInt), big.NewInt(math.MaxInt)},
+	"u8":    {big.NewInt(0), big.NewInt(math.MaxUint8)},
+	"u16":   {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	"u32":   {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	"u64":   {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+	"usize": {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint)},
+}
+
+// checkReservedAnnotation returns an error if v, the resolved value of a document.Value, is not valid for annotation;
+// only the annotations reserved by the KDL specification for numbers, dates and times, IP addresses, URLs, UUIDs,
+// regular expressions, and base64 data are checked, and any value (and null) is valid for other annotations
+func checkReservedAnnotation(annotation document.TypeAnnotation, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if bounds, ok := annotationBounds[annotation]; ok {
+		n, ok := annotatedInteger(v)
+		if !ok {
+			return fmt.Errorf("%s is not an integer", annotatedString(v))
+		}
+		if n.Cmp(bounds.min) < 0 || n.Cmp(bounds.max) > 0 {
+			return fmt.Errorf("%s is out of range for (%s)", n, annotation)
+		}
+		return nil
+	}
+
+	var valid bool
+	switch annotation {
+	case "f32", "f64", "decimal64", "decimal128":
+		f, ok := annotatedFloat(v)
+		if !ok {
+			return fmt.Errorf("%s is not a number", annotatedString(v))
+		}
+		if annotation == "f32" && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return fmt.Errorf("%s is out of range for (%s)", annotatedString(v), annotation)
+		}
+		return nil
+	case "decimal":
+		if _, ok := annotatedFloat(v); ok {
+			return nil
+		}
+		if s, ok := v.(string); ok {
+			_, valid = new(big.Float).SetString(s)
+		}
+	case "date-time", "ipv4", "ipv6", "uuid", "base64":
+		// these are checked by converting them as document.Value.Annotated would
+		_, err := document.BuiltinAnnotation(annotation)(v)
+		valid = err == nil
+	case "date":
+		valid = parsesAs(v, func(s string) error { _, err := time.Parse("2006-01-02", s); return err })
+	case "time":
+		valid = parsesAs(v, func(s string) error {
+			if _, err := time.Parse("15:04:05.999999999Z07:00", s); err == nil {
+				return nil
+			}
+			_, err := time.Parse("15:04:05.999999999", s)
+			return err
+		})
+	case "url":
+		valid = parsesAs(v, func(s string) error {
+			u, err := url.Parse(s)
+			if err == nil && !u.IsAbs() {
+				err = errors.New("not absolute")
+			}
+			return err
+		})
+	case "url-reference":
+		valid = parsesAs(v, func(s string) error { _, err := url.Parse(s); return err })
+	case "regex":
+		valid = parsesAs(v, func(s string) error { _, err := regexp.Compile(s); return err })
+	default:
+		return nil
+	}
+	if !valid {
+		return fmt.Errorf("%s is not a valid (%s)", annotatedString(v), annotation)
+	}
+	return nil
+}
+
+// parsesAs returns true if v is a string that parse accepts
+func parsesAs(v interface{}, parse func(s string) error) bool {
+	s, ok := v.(string)
+	return ok && parse(s) == nil
+}
+
+// annotatedInteger returns v as a *big.Int if it is an integer
+func annotatedInteger(v interface{}) (*big.Int, bool) {
+	if n, ok := v.(*big.Int); ok {
+		return n, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	default:
+		return nil, false
+	}
+}
+
+// annotatedFloat returns v as a float64 if it is a number
+func annotatedFloat(v interface{}) (float64, bool) {
+	if n, ok := v.(*big.Float); ok {
+		f, _ := n.Float64()
+		return f, true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+		return rv.Float(), true
+	}
+	if n, ok := annotatedInteger(v); ok {
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	}
+	return 0, false
+}
+
+// annotatedString returns a representation of v, the resolved value of a document.Value, for use in error messages
+func annotatedString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,165 @@
+package marshaler
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+)
+
+// builtinFuncs holds the functions that marshal and unmarshal the standard library types that do not implement
+// encoding.TextMarshaler and encoding.TextUnmarshaler (or that are better represented otherwise), keyed by type; they
+// are attached to the types' details when they are indexed, and are replaced by any custom functions registered for
+// the same types
+var builtinFuncs = map[reflect.Type]*customFuncs{
+	reflect.TypeFor[url.URL](): {
+		typ:            reflect.TypeFor[url.URL](),
+		valueMarshal:   marshalURLValue,
+		valueUnmarshal: unmarshalURLValue,
+	},
+	reflect.TypeFor[regexp.Regexp](): {
+		typ:            reflect.TypeFor[regexp.Regexp](),
+		valueMarshal:   marshalRegexpValue,
+		valueUnmarshal: unmarshalRegexpValue,
+	},
+	reflect.TypeFor[time.Location](): {
+		typ:            reflect.TypeFor[time.Location](),
+		valueMarshal:   marshalLocationValue,
+		valueUnmarshal: unmarshalLocationValue,
+	},
+	reflect.TypeFor[os.FileMode](): {
+		typ:            reflect.TypeFor[os.FileMode](),
+		valueMarshal:   marshalFileModeValue,
+		valueUnmarshal: unmarshalFileModeValue,
+	},
+}
+
+// builtinPointerUnmarshalers holds the functions that unmarshal values into pointers to the standard library types in
+// builtinFuncs whose values must not be copied, keyed by pointer type; they assign the pointers returned by the
+// standard library, which may be shared (eg: time.UTC) or hold internal state, rather than copies of their values
+var builtinPointerUnmarshalers = map[reflect.Type]customValueUnmarshalFunc{
+	reflect.TypeFor[*regexp.Regexp](): unmarshalRegexpPointer,
+	reflect.TypeFor[*time.Location](): unmarshalLocationPointer,
+}
+
+// unmarshalBuiltinPointer unmarshals dv into dest and returns true if dest is a settable pointer to which a function in
+// builtinPointerUnmarshalers applies, and no custom function has replaced the built-in ones for the type it points to
+func unmarshalBuiltinPointer(c *unmarshalContext, dest reflect.Value, dv *document.Value, format string) (bool, error) {
+	f := builtinPointerUnmarshalers[dest.Type()]
+	if f == nil || !dest.CanSet() {
+		return false, nil
+	}
+	if d := c.indexer.Get(dest.Type()); d == nil || d.custom != builtinFuncs[dest.Type().Elem()] {
+		return false, nil
+	}
+	return true, f(dv, dest, format)
+}
+
+// marshalURLValue marshals v, a url.URL, as a string
+func marshalURLValue(v reflect.Value, value *document.Value, format string) error {
+	u := v.Interface().(url.URL)
+	value.Value = u.String()
+	return nil
+}
+
+// unmarshalURLValue unmarshals a string into v, a url.URL
+func unmarshalURLValue(value *document.Value, v reflect.Value, format string) error {
+	u, err := url.Parse(coerce.ToString(value.ResolvedValue()))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(*u))
+	return nil
+}
+
+// marshalRegexpValue marshals v, a regexp.Regexp, as its source text; its MarshalText method is not used, as its
+// output would be scanned as a KDL value and truncated to its first token
+func marshalRegexpValue(v reflect.Value, value *document.Value, format string) error {
+	re := v.Interface().(regexp.Regexp)
+	value.Value = re.String()
+	return nil
+}
+
+// unmarshalRegexpValue compiles a string into v, a regexp.Regexp
+func unmarshalRegexpValue(value *document.Value, v reflect.Value, format string) error {
+	re, err := regexp.Compile(coerce.ToString(value.ResolvedValue()))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(re).Elem())
+	return nil
+}
+
+// unmarshalRegexpPointer compiles a string into v, a *regexp.Regexp, which is assigned the compiled expression itself
+func unmarshalRegexpPointer(value *document.Value, v reflect.Value, format string) error {
+	re, err := regexp.Compile(coerce.ToString(value.ResolvedValue()))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(re))
+	return nil
+}
+
+// marshalLocationValue marshals v, a time.Location, as its name
+func marshalLocationValue(v reflect.Value, value *document.Value, format string) error {
+	loc := v.Interface().(time.Location)
+	value.Value = loc.String()
+	return nil
+}
+
+// unmarshalLocationValue unmarshals the name of a location in the IANA Time Zone database (or "UTC" or "Local") into
+// v, a time.Location
+func unmarshalLocationValue(value *document.Value, v reflect.Value, format string) error {
+	loc, err := time.LoadLocation(coerce.ToString(value.ResolvedValue()))
+	if err != nil {
+		return err
+	}
+	// the name of a location is resolved before it is copied, as time.Local is only initialized when first used
+	_ = loc.String()
+	v.Set(reflect.ValueOf(loc).Elem())
+	return nil
+}
+
+// unmarshalLocationPointer unmarshals the name of a location into v, a *time.Location, which is assigned the location
+// returned by time.LoadLocation itself, so that eg: "UTC" yields time.UTC
+func unmarshalLocationPointer(value *document.Value, v reflect.Value, format string) error {
+	loc, err := time.LoadLocation(coerce.ToString(value.ResolvedValue()))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(loc))
+	return nil
+}
+
+// marshalFileModeValue marshals v, an os.FileMode, as an octal number
+func marshalFileModeValue(v reflect.Value, value *document.Value, format string) error {
+	value.Value = uint32(v.Uint())
+	value.Flag = document.FlagOctal
+	return nil
+}
+
+// unmarshalFileModeValue unmarshals a number, or a string containing an octal number (eg: "0644"), into v, an
+// os.FileMode
+func unmarshalFileModeValue(value *document.Value, v reflect.Value, format string) error {
+	var mode uint64
+	switch val := value.ResolvedValue().(type) {
+	case string:
+		var err error
+		if mode, err = strconv.ParseUint(val, 8, 32); err != nil {
+			return fmt.Errorf("invalid file mode %q", val)
+		}
+	default:
+		if !coerce.IsInteger(val) || coerce.ToInt64(val) < 0 || coerce.ToInt64(val) > 0xffffffff {
+			return fmt.Errorf("invalid file mode %v", val)
+		}
+		mode = uint64(coerce.ToInt64(val))
+	}
+	v.SetUint(mode)
+	return nil
+}
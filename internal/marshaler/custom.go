@@ -0,0 +1,194 @@
+package marshaler
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+type (
+	customMarshalFunc        func(v reflect.Value, node *document.Node) error
+	customValueMarshalFunc   func(v reflect.Value, value *document.Value, format string) error
+	customUnmarshalFunc      func(node *document.Node, v reflect.Value) error
+	customValueUnmarshalFunc func(value *document.Value, v reflect.Value, format string) error
+)
+
+// Marshalers is a registry of custom marshaling functions keyed by the type they marshal. A Marshalers may be assigned
+// to MarshalOptions.Marshalers, in which case its functions take precedence over those registered globally via
+// AddCustomMarshaler and AddCustomValueMarshaler. Functions may be added at any time, including concurrently with
+// marshaling.
+type Marshalers struct {
+	mu    sync.RWMutex
+	count atomic.Int32
+	node  map[reflect.Type]customMarshalFunc
+	value map[reflect.Type]customValueMarshalFunc
+}
+
+// NewMarshalers returns an empty Marshalers
+func NewMarshalers() *Marshalers {
+	return &Marshalers{}
+}
+
+// AddMarshaler registers a function in m that marshals values of type T into nodes
+func AddMarshaler[T any](m *Marshalers, marshal func(v reflect.Value, node *document.Node) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.node == nil {
+		m.node = make(map[reflect.Type]customMarshalFunc)
+	}
+	m.node[reflect.TypeFor[T]()] = marshal
+	m.count.Add(1)
+}
+
+// AddValueMarshaler registers a function in m that marshals values of type T into argument or property values
+func AddValueMarshaler[T any](m *Marshalers, marshal func(v reflect.Value, value *document.Value, format string) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.value == nil {
+		m.value = make(map[reflect.Type]customValueMarshalFunc)
+	}
+	m.value[reflect.TypeFor[T]()] = marshal
+	m.count.Add(1)
+}
+
+// empty returns true if no functions have been registered in m
+func (m *Marshalers) empty() bool {
+	return m == nil || m.count.Load() == 0
+}
+
+// lookup returns the functions registered in m for typ
+func (m *Marshalers) lookup(typ reflect.Type) (customMarshalFunc, customValueMarshalFunc) {
+	if m.empty() {
+		return nil, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.node[typ], m.value[typ]
+}
+
+// Unmarshalers is a registry of custom unmarshaling functions keyed by the type they unmarshal into. An Unmarshalers
+// may be assigned to UnmarshalOptions.Unmarshalers, in which case its functions take precedence over those registered
+// globally via AddCustomUnmarshaler and AddCustomValueUnmarshaler. Functions may be added at any time, including
+// concurrently with unmarshaling.
+type Unmarshalers struct {
+	mu    sync.RWMutex
+	count atomic.Int32
+	node  map[reflect.Type]customUnmarshalFunc
+	value map[reflect.Type]customValueUnmarshalFunc
+}
+
+// NewUnmarshalers returns an empty Unmarshalers
+func NewUnmarshalers() *Unmarshalers {
+	return &Unmarshalers{}
+}
+
+// AddUnmarshaler registers a function in u that unmarshals nodes into values of type T
+func AddUnmarshaler[T any](u *Unmarshalers, unmarshal func(node *document.Node, v reflect.Value) error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.node == nil {
+		u.node = make(map[reflect.Type]customUnmarshalFunc)
+	}
+	u.node[reflect.TypeFor[T]()] = unmarshal
+	u.count.Add(1)
+}
+
+// AddValueUnmarshaler registers a function in u that unmarshals argument or property values into values of type T
+func AddValueUnmarshaler[T any](u *Unmarshalers, unmarshal func(value *document.Value, v reflect.Value, format string) error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.value == nil {
+		u.value = make(map[reflect.Type]customValueUnmarshalFunc)
+	}
+	u.value[reflect.TypeFor[T]()] = unmarshal
+	u.count.Add(1)
+}
+
+// empty returns true if no functions have been registered in u
+func (u *Unmarshalers) empty() bool {
+	return u == nil || u.count.Load() == 0
+}
+
+// lookup returns the functions registered in u for typ
+func (u *Unmarshalers) lookup(typ reflect.Type) (customUnmarshalFunc, customValueUnmarshalFunc) {
+	if u.empty() {
+		return nil, nil
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.node[typ], u.value[typ]
+}
+
+// defaultMarshalers and defaultUnmarshalers hold the functions registered via the AddCustom* functions, and are
+// consulted after those in the options
+var (
+	defaultMarshalers   = NewMarshalers()
+	defaultUnmarshalers = NewUnmarshalers()
+)
+
+// AddCustomMarshaler registers a function that marshals values of type T into nodes for all Marshal calls
+func AddCustomMarshaler[T any](marshal func(v reflect.Value, node *document.Node) error) {
+	AddMarshaler[T](defaultMarshalers, marshal)
+}
+
+// AddCustomValueMarshaler registers a function that marshals values of type T into argument or property values for
+// all Marshal calls
+func AddCustomValueMarshaler[T any](marshal func(v reflect.Value, value *document.Value, format string) error) {
+	AddValueMarshaler[T](defaultMarshalers, marshal)
+}
+
+// AddCustomUnmarshaler registers a function that unmarshals nodes into values of type T for all Unmarshal calls
+func AddCustomUnmarshaler[T any](unmarshal func(node *document.Node, v reflect.Value) error) {
+	AddUnmarshaler[T](defaultUnmarshalers, unmarshal)
+}
+
+// AddCustomValueUnmarshaler registers a function that unmarshals argument or property values into values of type T
+// for all Unmarshal calls
+func AddCustomValueUnmarshaler[T any](unmarshal func(value *document.Value, v reflect.Value, format string) error) {
+	AddValueUnmarshaler[T](defaultUnmarshalers, unmarshal)
+}
+
+// customFuncs holds the custom functions that apply to a type for a single Marshal/Unmarshal call, along with the type
+// for which they were registered
+type customFuncs struct {
+	typ            reflect.Type
+	marshal        customMarshalFunc
+	valueMarshal   customValueMarshalFunc
+	unmarshal      customUnmarshalFunc
+	valueUnmarshal customValueUnmarshalFunc
+}
+
+// lookupCustomFuncs returns the functions registered in the first of the marshalers and unmarshalers layers that has
+// any for typ, or nil if there are none
+func lookupCustomFuncs(typ reflect.Type, marshalers []*Marshalers, unmarshalers []*Unmarshalers) *customFuncs {
+	f := &customFuncs{typ: typ}
+	for _, m := range marshalers {
+		if f.marshal, f.valueMarshal = m.lookup(typ); f.marshal != nil || f.valueMarshal != nil {
+			break
+		}
+	}
+	for _, u := range unmarshalers {
+		if f.unmarshal, f.valueUnmarshal = u.lookup(typ); f.unmarshal != nil || f.valueUnmarshal != nil {
+			break
+		}
+	}
+	if f.marshal == nil && f.valueMarshal == nil && f.unmarshal == nil && f.valueUnmarshal == nil {
+		return nil
+	}
+	return f
+}
+
+// value adapts v to the type for which the functions were registered, taking its address or dereferencing it if
+// necessary and possible
+func (f *customFuncs) value(v reflect.Value) reflect.Value {
+	switch {
+	case v.Type() == f.typ:
+	case f.typ.Kind() == reflect.Ptr && f.typ.Elem() == v.Type() && v.CanAddr():
+		return v.Addr()
+	case v.Kind() == reflect.Ptr && v.Type().Elem() == f.typ && !v.IsNil():
+		return v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,257 @@
+package marshaler
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// UnmarshalError describes a failure to unmarshal part of a document; such errors are collected and returned as
+// UnmarshalErrors if UnmarshalOptions.CollectErrors is set. Otherwise, an UnmarshalError (without a Path) is returned
+// only for a field tagged ",required" that is absent, whose ",default:..." value is invalid, or whose value violates
+// a constraint such as ",min:..." or ",enum:...".
+type UnmarshalError struct {
+	// Path identifies the offending node or property by its name and the names of its ancestors, separated by " > ";
+	// nodes unmarshaled into fields tagged ",multiple" include their index, eg: server[0] > listen > port
+	Path string
+	// Position is the location of the offending node, argument, or property in the document, if known
+	Position document.Position
+	// Field identifies the Go struct field into which the value was being unmarshaled by the name of its struct type
+	// and its own name, eg: Server.Port for the Port field of a struct named Server; it is empty if the destination was
+	// not a struct field
+	Field string
+	// Type is the Go type into which the value was being unmarshaled, if known
+	Type reflect.Type
+	// Err is the underlying cause
+	Err error
+}
+
+// Error returns a description of the error including its location
+func (e *UnmarshalError) Error() string {
+	b := strings.Builder{}
+	if e.Position.IsValid() {
+		b.WriteString(e.Position.String())
+		b.WriteString(": ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field)
+		if e.Type != nil {
+			b.WriteString(" (")
+			b.WriteString(e.Type.String())
+			b.WriteString(")")
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying cause
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalErrors is a list of errors encountered while unmarshaling a document with UnmarshalOptions.CollectErrors,
+// ordered by their position in the document; only the first error encountered for each field is included
+type UnmarshalErrors []*UnmarshalError
+
+// Error returns the descriptions of all errors, separated by newlines
+func (e UnmarshalErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors in e, so that errors.Is and errors.As consider each of them
+func (e UnmarshalErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// errorFrame describes the node currently being unmarshaled, for errors reported while unmarshaling it
+type errorFrame struct {
+	path  string
+	field string
+	typ   reflect.Type
+	// seen counts the children visited so far by name, to index those unmarshaled into fields tagged ",multiple"
+	seen map[string]int
+}
+
+// errorCollector accumulates the errors encountered while unmarshaling with UnmarshalOptions.CollectErrors
+type errorCollector struct {
+	errs   UnmarshalErrors
+	frames []*errorFrame
+}
+
+// newErrorCollector returns an errorCollector whose outermost frame has the given path and destination type
+func newErrorCollector(path string, typ reflect.Type) *errorCollector {
+	return &errorCollector{frames: []*errorFrame{{path: path, typ: typ}}}
+}
+
+// top returns the frame describing the node currently being unmarshaled
+func (ec *errorCollector) top() *errorFrame {
+	return ec.frames[len(ec.frames)-1]
+}
+
+// childPath returns the path of a child named name of the node currently being unmarshaled
+func (ec *errorCollector) childPath(name string) string {
+	if path := ec.top().path; path != "" {
+		return path + " > " + name
+	}
+	return name
+}
+
+// enter records that node, a child of the node currently being unmarshaled, is being unmarshaled into field (of type
+// typ); if multiple is true, its path includes its index among the children of the same name
+func (ec *errorCollector) enter(node *document.Node, field string, typ reflect.Type, multiple bool) {
+	parent := ec.top()
+	name := node.Name.NodeNameString()
+	if multiple {
+		if parent.seen == nil {
+			parent.seen = make(map[string]int)
+		}
+		n := parent.seen[name]
+		parent.seen[name]++
+		name += "[" + strconv.Itoa(n) + "]"
+	}
+	ec.frames = append(ec.frames, &errorFrame{path: ec.childPath(name), field: field, typ: typ})
+}
+
+// leave records that the node most recently entered has been unmarshaled
+func (ec *errorCollector) leave() {
+	ec.frames = ec.frames[:len(ec.frames)-1]
+}
+
+// add records err, which occurred at pos while unmarshaling the node currently being unmarshaled; if key is not empty,
+// the error applies to the property (or child) of that name. The value was being unmarshaled into field (of type typ),
+// or if both are empty, into that of the node.
+func (ec *errorCollector) add(err error, pos document.Position, key string, field string, typ reflect.Type) {
+	if err == nil {
+		return
+	}
+	if errs, ok := err.(UnmarshalErrors); ok {
+		ec.errs = append(ec.errs, errs...)
+		return
+	}
+	top := ec.top()
+	e := &UnmarshalError{Path: top.path, Position: pos, Field: field, Type: typ, Err: err}
+	if key != "" {
+		e.Path = ec.childPath(key)
+	}
+	if field == "" && typ == nil {
+		e.Field, e.Type = top.field, top.typ
+	}
+	ec.errs = append(ec.errs, e)
+}
+
+// collect records err (if non-nil) and returns nil if c is collecting errors, or returns err otherwise; pos, key,
+// field, and typ are per errorCollector.add
+func (c *unmarshalContext) collect(err error, pos document.Position, key string, field string, typ reflect.Type) error {
+	if err == nil || c.errs == nil {
+		return err
+	}
+	c.errs.add(err, pos, key, field, typ)
+	return nil
+}
+
+// collected returns the errors collected by c, including err (which occurred at pos) if non-nil, or nil if there are
+// none; if c is not collecting errors, it returns err
+func (c *unmarshalContext) collected(err error, pos document.Position) error {
+	if c.errs == nil {
+		return err
+	}
+	c.errs.add(err, pos, "", "", nil)
+	if len(c.errs.errs) == 0 {
+		return nil
+	}
+	return c.errs.sorted()
+}
+
+// sorted returns the collected errors ordered by position, with errors of unknown position last; only the first error
+// recorded for each field at each path is kept, as any later ones (such as a constraint violated by the zero value
+// left behind by a missing argument) are consequences of it
+func (ec *errorCollector) sorted() UnmarshalErrors {
+	type fieldKey struct{ path, field string }
+	seen := make(map[fieldKey]bool, len(ec.errs))
+	errs := make(UnmarshalErrors, 0, len(ec.errs))
+	for _, e := range ec.errs {
+		if e.Field != "" {
+			k := fieldKey{e.Path, e.Field}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		errs = append(errs, e)
+	}
+
+	slices.SortStableFunc(errs, func(a, b *UnmarshalError) int {
+		if a.Position.IsValid() != b.Position.IsValid() {
+			if a.Position.IsValid() {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(a.Position.Line, b.Position.Line); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Position.Column, b.Position.Column)
+	})
+	return errs
+}
+
+// collectField is like collect, for errors unmarshaling into the field described by fld in the struct type structType
+func (c *unmarshalContext) collectField(err error, pos document.Position, key string, structType reflect.Type, fld *structFieldDetails) error {
+	if err == nil || c.errs == nil {
+		return err
+	}
+	return c.collect(err, pos, key, structFieldName(structType, fld), structField(structType, fld).Type)
+}
+
+// fieldError is like collectField, but if c is not collecting errors, it returns err as an *UnmarshalError so that its
+// position is reported
+func (c *unmarshalContext) fieldError(err error, pos document.Position, key string, structType reflect.Type, fld *structFieldDetails) error {
+	if err == nil {
+		return nil
+	}
+	return c.namedFieldError(err, pos, key, structFieldName(structType, fld), structField(structType, fld).Type)
+}
+
+// namedFieldError is like fieldError, for errors unmarshaling into a field identified by its qualified name and type
+// per UnmarshalError
+func (c *unmarshalContext) namedFieldError(err error, pos document.Position, key string, field string, typ reflect.Type) error {
+	if err == nil || c.errs != nil {
+		return c.collect(err, pos, key, field, typ)
+	}
+	return &UnmarshalError{Position: pos, Field: field, Type: typ, Err: err}
+}
+
+// structFieldName returns the name of the field described by fld in the struct type t, qualified by the name of t
+func structFieldName(t reflect.Type, fld *structFieldDetails) string {
+	t = derefType(t)
+	if t.Name() == "" {
+		return structField(t, fld).Name
+	}
+	return t.Name() + "." + structField(t, fld).Name
+}
+
+// valuePosition returns the position of val if known, otherwise the position of node
+func valuePosition(node *document.Node, val *document.Value) document.Position {
+	if val != nil && val.Span.IsValid() {
+		return val.Span.Start
+	}
+	return node.Span.Start
+}
@@ -0,0 +1,1186 @@
+package marshaler
+
+// Support for the methods generated by kdl-gen-marshal. Types with generated methods are unmarshaled and marshaled via
+// their UnmarshalKDL and MarshalKDL methods like any other, which retrieve the caller's options via GenDecoderFor and
+// GenEncoderFor. Generated methods replace the reflective handling of a struct's fields, but delegate the handling of
+// each field's value, and the checking of its tag options once the struct has been unmarshaled, to the functions below;
+// those handle the most common types (scalars, slices of scalars, and other structs with generated methods) directly,
+// and fall back to the same reflective functions used for types without generated methods otherwise. Anything that
+// could cause the reflective path to behave differently (such as a registered custom unmarshaler, or a type that
+// implements encoding.TextUnmarshaler) also causes a fallback, so generated methods behave identically to the
+// reflective path.
+
+import (
+	"cmp"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+	"github.com/sblinch/kdl-go/relaxed"
+)
+
+// GenUnmarshaler is implemented by types with methods generated by kdl-gen-marshal
+type GenUnmarshaler interface {
+	// UnmarshalKDLWith unmarshals node into the struct
+	UnmarshalKDLWith(d *GenDecoder, node *document.Node) error
+	// UnmarshalKDLNodes unmarshals nodes into the struct's fields
+	UnmarshalKDLNodes(d *GenDecoder, nodes []*document.Node) error
+	// CheckKDL checks the struct's fields once it has been unmarshaled; see GenChecker
+	CheckKDL(chk *GenChecker) error
+}
+
+// GenMarshaler is implemented by types with methods generated by kdl-gen-marshal
+type GenMarshaler interface {
+	// MarshalKDLWith marshals the struct into node
+	MarshalKDLWith(e *GenEncoder, node *document.Node) error
+	// MarshalKDLNodes appends the nodes representing the struct's fields to nodes
+	MarshalKDLNodes(e *GenEncoder, nodes []*document.Node) ([]*document.Node, error)
+}
+
+// GenField identifies a struct field for NewGenFields
+type GenField struct {
+	// Name is the name of the field
+	Name string
+	// Tag is the field's complete struct tag
+	Tag string
+}
+
+// GenFields describes the fields of a struct type with generated methods
+type GenFields struct {
+	typeName string
+	details  []*structFieldDetails
+	// goNames holds the name of each field as declared, and argFields the fields tagged ",arg"
+	goNames   []string
+	argFields []*structFieldDetails
+	// checks holds the state needed to check each field; see GenChecker
+	checks []genCheck
+	// names holds the normalized name of each field, and index maps normalized names to field indexes; each is indexed
+	// by caseIndex
+	names [2][]string
+	index [2]map[string]int
+}
+
+// caseIndex returns the index into GenFields.names and GenFields.index for the given case-sensitivity
+func caseIndex(caseSensitive bool) int {
+	if caseSensitive {
+		return 1
+	}
+	return 0
+}
+
+// NewGenFields returns a description of the fields of the struct type named typeName
+func NewGenFields(typeName string, fields ...GenField) *GenFields {
+	f := &GenFields{
+		typeName: typeName,
+		details:  make([]*structFieldDetails, 0, len(fields)),
+		goNames:  make([]string, 0, len(fields)),
+		checks:   make([]genCheck, len(fields)),
+	}
+	for _, caseSensitive := range []bool{false, true} {
+		ci := caseIndex(caseSensitive)
+		f.names[ci] = make([]string, 0, len(fields))
+		f.index[ci] = make(map[string]int, len(fields))
+	}
+
+	for i, fld := range fields {
+		tag := reflect.StructTag(fld.Tag)
+		fd := newStructFieldDetails(i, nil, fieldAttrs(tag))
+		f.details = append(f.details, fd)
+		f.goNames = append(f.goNames, fld.Name)
+		if fd.Attrs.Has("arg") {
+			f.argFields = append(f.argFields, fd)
+		}
+		for _, caseSensitive := range []bool{false, true} {
+			ci := caseIndex(caseSensitive)
+			name := fieldTagOrName(tag, fld.Name, caseSensitive)
+			f.names[ci] = append(f.names[ci], name)
+			f.index[ci][name] = i
+		}
+	}
+	return f
+}
+
+// Index returns the index of the field into which to unmarshal the property or node named key, or -1 if there is none
+func (f *GenFields) Index(d *GenDecoder, key string) int {
+	caseSensitive := d.c.indexer.caseSensitive
+	if i, ok := f.index[caseIndex(caseSensitive)][normalizeKey(key, caseSensitive)]; ok {
+		return i
+	}
+	return -1
+}
+
+// name returns the name of field i as marshaled by e
+func (f *GenFields) name(e *GenEncoder, i int) string {
+	return f.names[caseIndex(e.c.indexer.caseSensitive)][i]
+}
+
+// addressable returns a pointer to v, or to a copy of v if v is not addressable
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr
+}
+
+// isValueUnmarshaler returns true if v unmarshals itself from a single value
+func isValueUnmarshaler(v interface{}) bool {
+	switch v.(type) {
+	case encoding.TextUnmarshaler, valueUnmarshaler:
+		return true
+	}
+	return false
+}
+
+// isValueMarshaler returns true if v marshals itself into a single value
+func isValueMarshaler(v interface{}) bool {
+	switch v.(type) {
+	case encoding.TextMarshaler, valueMarshaler:
+		return true
+	}
+	return false
+}
+
+// scalarKind returns the kind of the value to which dst points if it is one of the scalar types handled without
+// reflection
+func scalarKind(dst interface{}) (reflect.Kind, bool) {
+	switch dst.(type) {
+	case *bool:
+		return reflect.Bool, true
+	case *string:
+		return reflect.String, true
+	case *int, *int8, *int16, *int32, *int64, *time.Duration:
+		return reflect.Int64, true
+	case *uint, *uint8, *uint16, *uint32, *uint64, *uintptr:
+		return reflect.Uint64, true
+	case *float32, *float64:
+		return reflect.Float64, true
+	default:
+		return reflect.Invalid, false
+	}
+}
+
+// GenDecoder carries the state of an Unmarshal call into generated UnmarshalKDLWith and UnmarshalKDLNodes methods
+type GenDecoder struct {
+	c *unmarshalContext
+	// custom is true if any custom unmarshalers are registered; as they may apply to any type, all values are then
+	// unmarshaled reflectively
+	custom bool
+}
+
+// NewGenDecoder returns a GenDecoder that unmarshals according to opts
+func NewGenDecoder(opts UnmarshalOptions) *GenDecoder {
+	c := &unmarshalContext{opts: opts}
+	c.indexer = newTypeIndexer(opts.CaseSensitive, nil, opts.Unmarshalers)
+	return c.decoder()
+}
+
+// decoder returns the GenDecoder for c
+func (c *unmarshalContext) decoder() *GenDecoder {
+	if c.gen == nil {
+		c.gen = &GenDecoder{c: c}
+		for _, u := range c.indexer.unmarshalers {
+			if !u.empty() {
+				c.gen.custom = true
+			}
+		}
+	}
+	return c.gen
+}
+
+// genCall identifies a call made by callGenerated to the generated UnmarshalKDL or MarshalKDL method of the struct to
+// which ptr points
+type genCall struct {
+	ptr  interface{}
+	node *document.Node
+}
+
+// genCalls maps each genCall in progress to the GenDecoder or GenEncoder carrying the options of the Unmarshal or
+// Marshal call that made it
+var genCalls sync.Map
+
+// callGenerated calls the generated UnmarshalKDL or MarshalKDL method (at methodIndex) of structValue with node, as
+// callStructMethod does, while making gen, the GenDecoder or GenEncoder carrying the caller's options, available to it
+// via GenDecoderFor or GenEncoderFor
+func callGenerated(structValue reflect.Value, methodIndex int16, node *document.Node, gen interface{}) error {
+	ptr := structValue
+	if ptr.Kind() != reflect.Pointer {
+		ptr = addressable(structValue)
+	} else if ptr.IsNil() {
+		ptr = reflect.New(structValue.Type().Elem())
+		structValue.Set(ptr)
+	}
+
+	call := genCall{ptr: ptr.Interface(), node: node}
+	genCalls.Store(call, gen)
+	defer genCalls.Delete(call)
+	_, err := callStructMethod(ptr, methodIndex, reflect.ValueOf(node))
+	return err
+}
+
+// GenDecoderFor returns the GenDecoder with which the generated UnmarshalKDL method of the struct to which ptr points
+// unmarshals node: that of the Unmarshal call that invoked the method, or one using the default options if the method
+// was called directly
+func GenDecoderFor(ptr interface{}, node *document.Node) *GenDecoder {
+	if d, ok := genCalls.Load(genCall{ptr: ptr, node: node}); ok {
+		return d.(*GenDecoder)
+	}
+	return NewGenDecoder(UnmarshalOptions{})
+}
+
+// unmarshalNodesWithGenerated unmarshals nodes, the children of parent or the nodes of a document if parent is nil,
+// into destStruct using its generated UnmarshalKDLNodes and CheckKDL methods
+func unmarshalNodesWithGenerated(c *unmarshalContext, parent *document.Node, nodes []*document.Node, destStruct reflect.Value) (reflect.Value, error) {
+	ptr := addressable(destStruct)
+	t := ptr.Interface().(GenUnmarshaler)
+	d := c.decoder()
+	err := t.UnmarshalKDLNodes(d, nodes)
+	if err == nil {
+		err = t.CheckKDL(&GenChecker{d: d, parent: parent, nodes: nodes, pos: nodesPosition(parent, nodes)})
+	}
+	return ptr.Elem(), err
+}
+
+// ValueNode unmarshals node's argument into the struct to which dst points if node has exactly one argument and the
+// struct unmarshals itself from a single value; it returns true if it did so
+func (d *GenDecoder) ValueNode(dst interface{}, node *document.Node) (bool, error) {
+	if len(node.Arguments) != 1 || (!d.custom && !isValueUnmarshaler(dst)) {
+		return false, nil
+	}
+	rv := reflect.ValueOf(dst).Elem()
+	if typeDetails := d.c.indexer.Get(rv.Type()); !typeDetails.CanUnmarshalText() && !typeDetails.CanUnmarshalKDLValue() {
+		return false, nil
+	}
+	_, err := setReflectValueFromIntf(d.c, rv, node.Arguments[0].ResolvedValue(), "")
+	return true, err
+}
+
+// CheckArgs returns an error if node has more than n arguments and unhandled arguments are not allowed
+func (d *GenDecoder) CheckArgs(node *document.Node, n int) error {
+	if !d.c.opts.AllowUnhandledArgs && n < len(node.Arguments) {
+		return errUnexpectedArgs(node)
+	}
+	return nil
+}
+
+// CheckProps returns an error if fewer than all of node's properties were handled and unhandled properties are not
+// allowed
+func (d *GenDecoder) CheckProps(node *document.Node, handled int) error {
+	if !d.c.opts.AllowUnhandledProps && handled < node.Properties.Len() {
+		return errUnexpectedProps(node)
+	}
+	return nil
+}
+
+// UnhandledNode returns an error if unhandled nodes are not allowed; it is called for nodes that do not correspond to
+// any struct field
+func (d *GenDecoder) UnhandledNode(node *document.Node) error {
+	if d.c.opts.AllowUnhandledNodes {
+		return nil
+	}
+	return errNoStructField(node.Name.ValueString())
+}
+
+// Checker returns the GenChecker with which to check the fields of a struct once node has been unmarshaled into it
+func (d *GenDecoder) Checker(node *document.Node) *GenChecker {
+	return &GenChecker{d: d, node: node, parent: node, nodes: node.Children, pos: node.Span.Start}
+}
+
+// setScalar sets the scalar to which dst points from val; it returns false if dst does not point to one of the types
+// handled by scalarKind
+func setScalar(c *unmarshalContext, dst interface{}, val interface{}, format string) (bool, error) {
+	kind, ok := scalarKind(dst)
+	if !ok {
+		return false, nil
+	}
+
+	if format != "" {
+		if p, ok := dst.(*time.Duration); ok {
+			d, err := durationFromIntf(val, format)
+			if err == nil {
+				*p = d
+			}
+			return true, err
+		} else if kind != reflect.Float64 {
+			return true, fmt.Errorf("invalid format string: %s", format)
+		}
+	}
+
+	if c.opts.RelaxedNonCompliant.Permit(relaxed.MultiplierSuffixes) {
+		_, isDuration := dst.(*time.Duration)
+		var err error
+		if val, err = resolveSuffixedDecimalKind(kind, isDuration, val); err != nil {
+			return true, fmt.Errorf("resolving multiplier suffix: %w", err)
+		}
+	}
+
+	switch p := dst.(type) {
+	case *bool:
+		*p = coerce.ToBool(val)
+	case *string:
+		*p = coerce.ToString(val)
+	case *int:
+		*p = int(coerce.ToInt64(val))
+	case *int8:
+		*p = int8(coerce.ToInt64(val))
+	case *int16:
+		*p = int16(coerce.ToInt64(val))
+	case *int32:
+		*p = int32(coerce.ToInt64(val))
+	case *int64:
+		*p = coerce.ToInt64(val)
+	case *time.Duration:
+		d, err := durationFromIntf(val, format)
+		if err != nil {
+			return true, err
+		}
+		*p = d
+	case *uint:
+		*p = uint(coerce.ToUint64(val))
+	case *uint8:
+		*p = uint8(coerce.ToUint64(val))
+	case *uint16:
+		*p = uint16(coerce.ToUint64(val))
+	case *uint32:
+		*p = uint32(coerce.ToUint64(val))
+	case *uint64:
+		*p = coerce.ToUint64(val)
+	case *uintptr:
+		*p = uintptr(coerce.ToUint64(val))
+	case *float32:
+		*p = float32(floatFromIntf(val, format))
+	case *float64:
+		*p = floatFromIntf(val, format)
+	}
+	return true, nil
+}
+
+// appendScalars appends args to the slice to which p points; the slice is allocated with the given capacity if nil
+func appendScalars[T any](c *unmarshalContext, p *[]T, capacity int, args []*document.Value) error {
+	if *p == nil {
+		*p = make([]T, 0, capacity)
+	}
+	s := *p
+	for _, arg := range args {
+		var v T
+		if _, err := setScalar(c, &v, arg.ResolvedValue(), ""); err != nil {
+			return err
+		}
+		s = append(s, v)
+	}
+	*p = s
+	return nil
+}
+
+// Value unmarshals v into field i, to which dst points
+func (d *GenDecoder) Value(v *document.Value, f *GenFields, i int, dst interface{}) error {
+	format := f.details[i].Format
+	if !d.custom {
+		// scalars are never interface{}, so their values need no further resolution
+		if ok, err := setScalar(d.c, dst, v.ResolvedValue(), format); ok {
+			return err
+		}
+	}
+	val, err := resolveValueFor(v, reflect.TypeOf(dst).Elem())
+	if err != nil {
+		return err
+	}
+	_, err = setReflectValueFromIntf(d.c, reflect.ValueOf(dst).Elem(), val, format)
+	return err
+}
+
+// Args unmarshals args, the arguments of node that were not assigned to fields tagged ",arg", into field i (which is
+// tagged ",args"), to which dst points
+func (d *GenDecoder) Args(node *document.Node, args []*document.Value, f *GenFields, i int, dst interface{}) error {
+	if !d.custom {
+		switch p := dst.(type) {
+		case *[]string:
+			return appendScalars(d.c, p, len(node.Arguments), args)
+		case *[]bool:
+			return appendScalars(d.c, p, len(node.Arguments), args)
+		case *[]int:
+			return appendScalars(d.c, p, len(node.Arguments), args)
+		case *[]int64:
+			return appendScalars(d.c, p, len(node.Arguments), args)
+		case *[]float64:
+			return appendScalars(d.c, p, len(node.Arguments), args)
+		}
+	}
+	_, err := withCreatedAndIndirected(reflect.ValueOf(dst).Elem(), func(slice *reflect.Value) error {
+		return unmarshalArgsToSlice(d.c, node, args, slice)
+	})
+	return err
+}
+
+// Props unmarshals all of node's properties into field i (which is tagged ",props"), to which dst points
+func (d *GenDecoder) Props(node *document.Node, f *GenFields, i int, dst interface{}) error {
+	return unmarshalPropsToField(d.c, node, f.typeName, reflect.ValueOf(dst).Elem())
+}
+
+// Children unmarshals node's children into field i (which is tagged ",children"), to which dst points
+func (d *GenDecoder) Children(node *document.Node, f *GenFields, i int, dst interface{}) error {
+	return unmarshalChildrenToField(d.c, node, reflect.ValueOf(dst).Elem())
+}
+
+// nodeScalar unmarshals node into the scalar to which dst points; it returns false if dst does not point to one of the
+// types handled by scalarKind
+func (d *GenDecoder) nodeScalar(node *document.Node, dst interface{}, format string) (bool, error) {
+	if _, ok := scalarKind(dst); !ok {
+		return false, nil
+	}
+	if err := verifyArgsPropsChildren(d.c, node, 1, nil, false); err != nil {
+		return true, err
+	}
+	return setScalar(d.c, dst, node.Arguments[0].ResolvedValue(), format)
+}
+
+// Node unmarshals node into field i, to which dst points
+func (d *GenDecoder) Node(node *document.Node, f *GenFields, i int, dst interface{}) error {
+	format := f.details[i].Format
+	if !d.custom {
+		switch p := dst.(type) {
+		case GenUnmarshaler:
+			if len(node.Arguments) != 1 || !isValueUnmarshaler(dst) {
+				return wrapNodeError(d.c, node, p.UnmarshalKDLWith(d, node))
+			}
+		case *[]string:
+			if node.Properties.Len() == 0 {
+				return wrapNodeError(d.c, node, appendScalars(d.c, p, len(node.Arguments), node.Arguments))
+			}
+		case *[]bool:
+			if node.Properties.Len() == 0 {
+				return wrapNodeError(d.c, node, appendScalars(d.c, p, len(node.Arguments), node.Arguments))
+			}
+		case *[]int:
+			if node.Properties.Len() == 0 {
+				return wrapNodeError(d.c, node, appendScalars(d.c, p, len(node.Arguments), node.Arguments))
+			}
+		case *[]int64:
+			if node.Properties.Len() == 0 {
+				return wrapNodeError(d.c, node, appendScalars(d.c, p, len(node.Arguments), node.Arguments))
+			}
+		case *[]float64:
+			if node.Properties.Len() == 0 {
+				return wrapNodeError(d.c, node, appendScalars(d.c, p, len(node.Arguments), node.Arguments))
+			}
+		default:
+			if ok, err := d.nodeScalar(node, dst, format); ok {
+				return wrapNodeError(d.c, node, err)
+			}
+		}
+	}
+	rv := reflect.ValueOf(dst).Elem()
+	return unmarshalNodeToValue(d.c, node, &rv, format, nil)
+}
+
+// appendNodeScalar appends a scalar unmarshaled from node to the slice to which p points
+func appendNodeScalar[T any](d *GenDecoder, node *document.Node, p *[]T) error {
+	if *p == nil {
+		*p = make([]T, 0, 2)
+	}
+	var v T
+	if _, err := d.nodeScalar(node, &v, ""); err != nil {
+		return wrapNodeError(d.c, node, err)
+	}
+	*p = append(*p, v)
+	return nil
+}
+
+// Multiple unmarshals node into field i (which is tagged ",multiple"), to which dst points
+func (d *GenDecoder) Multiple(node *document.Node, f *GenFields, i int, dst interface{}) error {
+	if !d.custom {
+		switch p := dst.(type) {
+		case *[]string:
+			return appendNodeScalar(d, node, p)
+		case *[]bool:
+			return appendNodeScalar(d, node, p)
+		case *[]int:
+			return appendNodeScalar(d, node, p)
+		case *[]int64:
+			return appendNodeScalar(d, node, p)
+		case *[]float64:
+			return appendNodeScalar(d, node, p)
+		}
+	}
+	field := reflect.ValueOf(dst).Elem()
+	v := field
+	err := unmarshalNodeToMultiple(d.c, node, &v, nil)
+	field.Set(v)
+	return err
+}
+
+// GenUnmarshalerPtr is satisfied by pointers to types with generated methods
+type GenUnmarshalerPtr[T any] interface {
+	*T
+	GenUnmarshaler
+}
+
+// GenNodePtr unmarshals node into field i, to which dst points, allocating the struct to which the field points if
+// necessary
+func GenNodePtr[T any, PT GenUnmarshalerPtr[T]](d *GenDecoder, node *document.Node, f *GenFields, i int, dst *PT) error {
+	if d.custom || (len(node.Arguments) == 1 && isValueUnmarshaler(PT(nil))) {
+		return d.Node(node, f, i, dst)
+	}
+	if *dst == nil {
+		*dst = new(T)
+	}
+	return wrapNodeError(d.c, node, (*dst).UnmarshalKDLWith(d, node))
+}
+
+// GenAppendNode unmarshals node into a new element appended to field i (which is tagged ",multiple"), to which dst
+// points
+func GenAppendNode[T any, PT GenUnmarshalerPtr[T]](d *GenDecoder, node *document.Node, f *GenFields, i int, dst *[]T) error {
+	if d.custom || (len(node.Arguments) == 1 && isValueUnmarshaler(PT(nil))) {
+		return d.Multiple(node, f, i, dst)
+	}
+	if *dst == nil {
+		*dst = make([]T, 0, 2)
+	}
+	var el T
+	if err := PT(&el).UnmarshalKDLWith(d, node); err != nil {
+		return wrapNodeError(d.c, node, err)
+	}
+	*dst = append(*dst, el)
+	return nil
+}
+
+// GenAppendNodePtr unmarshals node into a new element appended to field i (which is tagged ",multiple"), to which dst
+// points
+func GenAppendNodePtr[T any, PT GenUnmarshalerPtr[T]](d *GenDecoder, node *document.Node, f *GenFields, i int, dst *[]PT) error {
+	if d.custom || (len(node.Arguments) == 1 && isValueUnmarshaler(PT(nil))) {
+		return d.Multiple(node, f, i, dst)
+	}
+	if *dst == nil {
+		*dst = make([]PT, 0, 2)
+	}
+	el := PT(new(T))
+	if err := el.UnmarshalKDLWith(d, node); err != nil {
+		return wrapNodeError(d.c, node, err)
+	}
+	*dst = append(*dst, el)
+	return nil
+}
+
+// genCheck holds the state needed to check a field of a struct type with generated methods
+type genCheck struct {
+	// the field's constraints are parsed into its details once its type is known, and err holds any error doing so
+	once sync.Once
+	err  error
+	// typ is the type of the field, and min and max hold the bounds of its min: and max: constraints (if any) as
+	// values of the type of its values
+	typ      reflect.Type
+	min, max interface{}
+}
+
+// check returns the state needed to check field i, of type typ, parsing its constraints if necessary
+func (f *GenFields) check(i int, typ reflect.Type) (*genCheck, error) {
+	gc := &f.checks[i]
+	gc.once.Do(func() {
+		fld := f.details[i]
+		rules, err := parseFieldRules(fld.Attrs, typ, fld.Format)
+		if err != nil {
+			gc.err = fmt.Errorf("field %s.%s: %w", f.typeName, f.goNames[i], err)
+			return
+		}
+		fld.Rules, gc.typ = rules, typ
+		if rules != nil && rules.hasMin {
+			gc.min = rules.minValue.Interface()
+		}
+		if rules != nil && rules.hasMax {
+			gc.max = rules.maxValue.Interface()
+		}
+	})
+	return gc, gc.err
+}
+
+// GenChecker checks the fields of a struct with generated methods that are required, have defaults, or have
+// constraints or type annotations once the struct has been unmarshaled, as checkStructFields does for other structs.
+// The generated CheckKDL method passes each such field to Field, then those with constraints to GenCheckValue,
+// GenCheckValues, or Value, and finally calls OneOf if any fields belong to "oneof:" groups.
+type GenChecker struct {
+	d *GenDecoder
+	// node, parent, nodes, and pos are per checkStructFields
+	node, parent *document.Node
+	nodes        []*document.Node
+	pos          document.Position
+	// positions holds the positions of the sources of the field last passed to Field
+	positions []document.Position
+	// groups holds the indexes of the fields present in each "oneof:" group, and groupNames holds the groups in the
+	// order in which they were first found
+	groups     map[string][]int
+	groupNames []string
+}
+
+// name returns the name of field i as unmarshaled by chk
+func (chk *GenChecker) name(f *GenFields, i int) string {
+	return f.names[caseIndex(chk.d.c.indexer.caseSensitive)][i]
+}
+
+// fieldError returns err, which concerns field i, per unmarshalContext.fieldError
+func (chk *GenChecker) fieldError(f *GenFields, i int, err error, pos document.Position, key string) error {
+	if err == nil {
+		return nil
+	}
+	return chk.d.c.namedFieldError(err, pos, key, f.typeName+"."+f.goNames[i], f.checks[i].typ)
+}
+
+// report returns the function with which errors concerning field i are reported
+func (chk *GenChecker) report(f *GenFields, i int) fieldErrorFunc {
+	return func(err error, pos document.Position, key string) error {
+		return chk.fieldError(f, i, err, pos, key)
+	}
+}
+
+// valuePosition returns the position at which to report violations of the constraints of the field last passed to
+// Field: that of the last value from which it was unmarshaled, which took precedence, or that of the struct if it was
+// absent
+func (chk *GenChecker) valuePosition() document.Position {
+	if len(chk.positions) > 0 {
+		return chk.positions[len(chk.positions)-1]
+	}
+	return chk.pos
+}
+
+// isZero returns true if the value to which dst points is its type's zero value, per reflect.Value.IsZero
+func isZero(dst interface{}) bool {
+	switch p := dst.(type) {
+	case *float32:
+		return math.Float32bits(*p) == 0
+	case *float64:
+		return math.Float64bits(*p) == 0
+	}
+	if _, ok := scalarKind(dst); ok {
+		return scalarIsZero(dst)
+	}
+	return reflect.ValueOf(dst).Elem().IsZero()
+}
+
+// setDefault assigns the default value of the field described by fld to the field to which dst points
+func (d *GenDecoder) setDefault(dst interface{}, fld *structFieldDetails) error {
+	if !d.custom {
+		if ok, err := setScalar(d.c, dst, fld.Default, fld.Format); ok {
+			return err
+		}
+	}
+	_, err := setReflectValueFromIntf(d.c, reflect.ValueOf(dst).Elem(), fld.Default, fld.Format)
+	return err
+}
+
+// Field checks field i, to which dst points: if it is absent, an error is returned if it is required, and it is
+// assigned its default if it has one; the type annotations of its values are then checked. It returns true if the
+// field's value must then be checked against its constraints.
+func (chk *GenChecker) Field(f *GenFields, i int, dst interface{}) (bool, error) {
+	c := chk.d.c
+	fld := f.details[i]
+	if _, err := f.check(i, reflect.TypeOf(dst).Elem()); err != nil {
+		return false, err
+	}
+	name := chk.name(f, i)
+	sources := fieldSources(c, f.argFields, name, fld, chk.node, chk.nodes)
+	chk.positions = fieldPositions(fld, sources)
+
+	if len(chk.positions) == 0 {
+		var err error
+		if fld.IsRequired() && !c.deferred[chk.parent] {
+			err = errMissingField(name, fld, chk.node)
+		} else if fld.HasDefault && isZero(dst) {
+			if err = chk.d.setDefault(dst, fld); err != nil {
+				err = fmt.Errorf("invalid default value %q: %w", fld.Default, err)
+			}
+		} else {
+			// absent fields are only checked against their constraints if they have been given a default
+			return false, nil
+		}
+		if err = chk.fieldError(f, i, err, chk.pos, fieldKey(name, fld)); err != nil || !fld.HasDefault {
+			return false, err
+		}
+	}
+
+	if fld.Annotation != "" {
+		if err := checkAnnotations(c, chk.report(f, i), f.checks[i].typ, name, fld, sources); err != nil {
+			return false, err
+		}
+	}
+	if fld.Rules == nil {
+		return false, nil
+	}
+	if group := fld.Rules.oneOf; group != "" && len(chk.positions) > 0 {
+		if chk.groups == nil {
+			chk.groups = make(map[string][]int)
+		}
+		if _, ok := chk.groups[group]; !ok {
+			chk.groupNames = append(chk.groupNames, group)
+		}
+		chk.groups[group] = append(chk.groups[group], i)
+	}
+	return true, nil
+}
+
+// Value checks field i, to which dst points and which was last passed to Field, against its constraints reflectively;
+// it is used for fields of types not handled by GenCheckValue and GenCheckValues
+func (chk *GenChecker) Value(f *GenFields, i int, dst interface{}) error {
+	return checkField(chk.d.c, chk.report(f, i), chk.name(f, i), f.details[i], reflect.ValueOf(dst).Elem(), chk.positions, chk.pos)
+}
+
+// GenScalar is satisfied by the ordered scalar types whose constraints are checked without reflection
+type GenScalar interface {
+	string | int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64 | uintptr | float32 | float64 | time.Duration
+}
+
+// GenCheckValue checks field i, a scalar holding v which was last passed to Field, against its constraints
+func GenCheckValue[T GenScalar](chk *GenChecker, f *GenFields, i int, v T) error {
+	if chk.d.custom {
+		// custom unmarshalers may apply to the values of enum: constraints
+		return chk.Value(f, i, &v)
+	}
+	fld := f.details[i]
+	r := fld.Rules
+	pos, key := chk.valuePosition(), fieldKey(chk.name(f, i), fld)
+
+	if r.minLen != -1 || r.maxLen != -1 {
+		var err error
+		if s, ok := any(v).(string); ok {
+			err = checkLen(utf8.RuneCountInString(s), r)
+		} else {
+			err = fmt.Errorf("tag option len: cannot be used with %T", v)
+		}
+		if err = chk.fieldError(f, i, err, pos, key); err != nil {
+			return err
+		}
+	}
+	return chk.fieldError(f, i, checkScalar(chk.d, f, i, v), pos, key)
+}
+
+// GenCheckValues checks field i, a slice of scalars holding s which was last passed to Field, against its constraints;
+// the min:, max:, enum:, and match: constraints apply to each element
+func GenCheckValues[T GenScalar](chk *GenChecker, f *GenFields, i int, s []T) error {
+	if chk.d.custom {
+		return chk.Value(f, i, &s)
+	}
+	fld := f.details[i]
+	r := fld.Rules
+	name := chk.name(f, i)
+	pos, key := chk.valuePosition(), fieldKey(name, fld)
+
+	if r.minLen != -1 || r.maxLen != -1 {
+		if err := chk.fieldError(f, i, checkLen(len(s), r), pos, key); err != nil {
+			return err
+		}
+	}
+	if !r.hasMin && !r.hasMax && len(r.enum) == 0 && r.match == nil {
+		return nil
+	}
+
+	// the elements of a field tagged ",multiple" were each unmarshaled from a node, unless the field already held
+	// elements before unmarshaling
+	multiple := fld.IsMultiple() && len(chk.positions) == len(s)
+	for n, v := range s {
+		elPos, elKey := pos, key
+		if multiple {
+			elPos, elKey = chk.positions[n], name+"["+strconv.Itoa(n)+"]"
+		}
+		if err := chk.fieldError(f, i, checkScalar(chk.d, f, i, v), elPos, elKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkScalar returns an error if v, a value of field i (or an element of it), violates the field's min:, max:,
+// enum:, or match: constraints; it is equivalent to checkValue
+func checkScalar[T GenScalar](d *GenDecoder, f *GenFields, i int, v T) error {
+	fld := f.details[i]
+	r := fld.Rules
+	if r.hasMin && cmp.Compare(v, f.checks[i].min.(T)) < 0 {
+		return fmt.Errorf("%s is less than the minimum of %s", scalarString(v), r.min)
+	}
+	if r.hasMax && cmp.Compare(v, f.checks[i].max.(T)) > 0 {
+		return fmt.Errorf("%s is greater than the maximum of %s", scalarString(v), r.max)
+	}
+
+	if len(r.enum) > 0 {
+		found := false
+		for _, s := range r.enum {
+			var ev T
+			if _, err := setScalar(d.c, &ev, s, fld.Format); err != nil {
+				return fmt.Errorf("invalid enum value %q: %w", s, err)
+			}
+			if ev == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not one of %s", scalarString(v), strings.Join(r.enum, ", "))
+		}
+	}
+
+	if r.match != nil {
+		if s := coerce.ToString(v); !r.match.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, r.pattern)
+		}
+	}
+	return nil
+}
+
+// scalarString returns a representation of v for use in error messages, per valueString
+func scalarString[T GenScalar](v T) string {
+	if s, ok := any(v).(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
+
+// OneOf returns an error if more than one field of any "oneof:" group is present; it is called once every field has
+// been checked
+func (chk *GenChecker) OneOf(f *GenFields) error {
+	for _, group := range chk.groupNames {
+		indexes := chk.groups[group]
+		if len(indexes) < 2 {
+			continue
+		}
+		names := make([]string, len(indexes))
+		for n, i := range indexes {
+			names[n] = chk.name(f, i)
+		}
+		i := indexes[1]
+		fld := f.details[i]
+		positions := fieldPositions(fld, fieldSources(chk.d.c, f.argFields, names[1], fld, chk.node, chk.nodes))
+		if err := chk.fieldError(f, i, errOneOf(names), positions[0], fieldKey(names[1], fld)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenEncoder carries the state of a Marshal call into generated MarshalKDLWith and MarshalKDLNodes methods
+type GenEncoder struct {
+	c *marshalContext
+	// custom is true if any custom marshalers are registered; as they may apply to any type, all values are then
+	// marshaled reflectively
+	custom bool
+}
+
+// NewGenEncoder returns a GenEncoder that marshals according to opts
+func NewGenEncoder(opts MarshalOptions) *GenEncoder {
+	c := &marshalContext{opts: opts}
+	c.indexer = newTypeIndexer(opts.CaseSensitive, opts.Marshalers, nil)
+	return c.encoder()
+}
+
+// encoder returns the GenEncoder for c
+func (c *marshalContext) encoder() *GenEncoder {
+	if c.gen == nil {
+		c.gen = &GenEncoder{c: c}
+		for _, m := range c.indexer.marshalers {
+			if !m.empty() {
+				c.gen.custom = true
+			}
+		}
+	}
+	return c.gen
+}
+
+// GenEncoderFor returns the GenEncoder with which the generated MarshalKDL method of the struct to which ptr points
+// marshals it into node; see GenDecoderFor
+func GenEncoderFor(ptr interface{}, node *document.Node) *GenEncoder {
+	if e, ok := genCalls.Load(genCall{ptr: ptr, node: node}); ok {
+		return e.(*GenEncoder)
+	}
+	return NewGenEncoder(MarshalOptions{})
+}
+
+// marshalNodesWithGenerated appends the nodes representing structValue to nodes using its generated MarshalKDLNodes
+// method
+func marshalNodesWithGenerated(c *marshalContext, structValue reflect.Value, nodes []*document.Node) ([]*document.Node, error) {
+	return addressable(structValue).Interface().(GenMarshaler).MarshalKDLNodes(c.encoder(), nodes)
+}
+
+// scalarIsZero returns true if the scalar to which src points is its type's zero value
+func scalarIsZero(src interface{}) bool {
+	switch p := src.(type) {
+	case *bool:
+		return !*p
+	case *string:
+		return *p == ""
+	case *int:
+		return *p == 0
+	case *int8:
+		return *p == 0
+	case *int16:
+		return *p == 0
+	case *int32:
+		return *p == 0
+	case *int64:
+		return *p == 0
+	case *time.Duration:
+		return *p == 0
+	case *uint:
+		return *p == 0
+	case *uint8:
+		return *p == 0
+	case *uint16:
+		return *p == 0
+	case *uint32:
+		return *p == 0
+	case *uint64:
+		return *p == 0
+	case *uintptr:
+		return *p == 0
+	case *float32:
+		return *p == 0
+	case *float64:
+		return *p == 0
+	default:
+		return false
+	}
+}
+
+// scalarValue sets dv to the scalar to which src points; it returns false if src does not point to one of the types
+// handled by scalarKind
+func scalarValue(c *marshalContext, src interface{}, dv *document.Value, format string) (bool, error) {
+	switch p := src.(type) {
+	case *bool:
+		dv.Value = *p
+	case *string:
+		dv.Value = *p
+	case *int:
+		dv.Value = *p
+	case *int8:
+		dv.Value = *p
+	case *int16:
+		dv.Value = *p
+	case *int32:
+		dv.Value = *p
+	case *int64:
+		dv.Value = *p
+	case *time.Duration:
+		var err error
+		dv.Value, err = marshalDurationValue(*p, format)
+		if format == "" && c.opts.BareSuffixed {
+			dv.Flag = document.FlagBareSuffixed
+		}
+		return true, err
+	case *uint:
+		dv.Value = *p
+	case *uint8:
+		dv.Value = *p
+	case *uint16:
+		dv.Value = *p
+	case *uint32:
+		dv.Value = *p
+	case *uint64:
+		dv.Value = *p
+	case *uintptr:
+		dv.Value = *p
+	case *float32:
+		dv.Value = *p
+	case *float64:
+		dv.Value = *p
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// scalarArgs adds the elements of s to node as arguments
+func scalarArgs[T any](c *marshalContext, node *document.Node, s []T, format string) error {
+	node.ExpectArguments(len(s))
+	for i := range s {
+		dv := node.AddArgument(nil, "")
+		if _, err := scalarValue(c, &s[i], dv, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Arg adds field i (which is tagged ",arg"), to which src points, to node as an argument
+func (e *GenEncoder) Arg(node *document.Node, f *GenFields, i int, src interface{}) error {
+	format := f.details[i].Format
+	dv := node.AddArgument(nil, "")
+	if !e.custom {
+		if ok, err := scalarValue(e.c, src, dv, format); ok {
+			if err != nil {
+				return err
+			}
+			return annotateValue(dv, f.details[i])
+		}
+	}
+	if err := reflectValueToDocumentValue(e.c, reflect.Indirect(reflect.ValueOf(src).Elem()), dv, format); err != nil {
+		return err
+	}
+	return annotateValue(dv, f.details[i])
+}
+
+// Args adds the elements of field i (which is tagged ",args"), to which src points, to node as arguments
+func (e *GenEncoder) Args(node *document.Node, f *GenFields, i int, src interface{}) error {
+	format := f.details[i].Format
+	if !e.custom && f.details[i].Annotation == "" {
+		switch p := src.(type) {
+		case *[]string:
+			return scalarArgs(e.c, node, *p, format)
+		case *[]bool:
+			return scalarArgs(e.c, node, *p, format)
+		case *[]int:
+			return scalarArgs(e.c, node, *p, format)
+		case *[]int64:
+			return scalarArgs(e.c, node, *p, format)
+		case *[]float64:
+			return scalarArgs(e.c, node, *p, format)
+		}
+	}
+	return marshalArgsField(e.c, node, reflect.ValueOf(src).Elem(), f.details[i])
+}
+
+// Props adds the entries of field i (which is tagged ",props"), to which src points, to node as properties
+func (e *GenEncoder) Props(node *document.Node, f *GenFields, i int, src interface{}) error {
+	return marshalPropsField(e.c, node, reflect.ValueOf(src).Elem(), f.details[i].Format)
+}
+
+// Children adds the nodes representing field i (which is tagged ",children"), to which src points, to node's children
+func (e *GenEncoder) Children(node *document.Node, f *GenFields, i int, src interface{}) error {
+	return marshalChildrenField(e.c, node, reflect.ValueOf(src).Elem())
+}
+
+// structChild returns the child node representing the struct with generated methods to which src points, or nil if
+// it is omitted
+func (e *GenEncoder) structChild(name string, fld *structFieldDetails, src GenMarshaler) (*document.Node, error) {
+	if fld.Attrs.Has("omitempty") && reflect.ValueOf(src).Elem().IsZero() {
+		return nil, nil
+	}
+	child := document.NewNode()
+	child.SetName(name)
+	if err := src.MarshalKDLWith(e, child); err != nil {
+		return nil, err
+	}
+	child.Type = fld.Annotation
+	return child, nil
+}
+
+// Field adds field i, to which src points, to node as either a property or a child node
+func (e *GenEncoder) Field(node *document.Node, f *GenFields, i int, src interface{}) error {
+	name := f.name(e, i)
+	fld := f.details[i]
+	if omit, err := e.c.isDefault(fld, reflect.ValueOf(src).Elem()); err != nil || omit {
+		return err
+	}
+	if !e.custom {
+		switch p := src.(type) {
+		case GenMarshaler:
+			if !isValueMarshaler(src) {
+				child, err := e.structChild(name, fld, p)
+				if child != nil {
+					node.AddNode(child)
+				}
+				return err
+			}
+		default:
+			if _, ok := scalarKind(src); ok && !fld.Attrs.Has("child") {
+				if fld.Attrs.Has("omitempty") && scalarIsZero(src) {
+					return nil
+				}
+				dv := node.AddProperty(name, nil, "")
+				if _, err := scalarValue(e.c, src, dv, fld.Format); err != nil {
+					return err
+				}
+				return annotateValue(dv, fld)
+			}
+		}
+	}
+	return marshalFieldToNode(e.c, node, name, reflect.Indirect(reflect.ValueOf(src).Elem()), fld, nil)
+}
+
+// Nodes appends the nodes representing field i, to which src points, to nodes
+func (e *GenEncoder) Nodes(nodes []*document.Node, f *GenFields, i int, src interface{}) ([]*document.Node, error) {
+	name := f.name(e, i)
+	fld := f.details[i]
+	if omit, err := e.c.isDefault(fld, reflect.ValueOf(src).Elem()); err != nil {
+		return nil, err
+	} else if omit {
+		return nodes, nil
+	}
+	if !e.custom && !fld.IsMultiple() {
+		switch p := src.(type) {
+		case GenMarshaler:
+			if !isValueMarshaler(src) {
+				child, err := e.structChild(name, fld, p)
+				if err != nil {
+					return nil, err
+				} else if child != nil {
+					nodes = append(nodes, child)
+				}
+				return nodes, nil
+			}
+		default:
+			if kind, ok := scalarKind(src); ok {
+				if fld.Attrs.Has("omitempty") && scalarIsZero(src) {
+					return nodes, nil
+				}
+				child := document.NewNode()
+				child.SetName(name)
+				dv := child.AddArgument(nil, "")
+				if _, err := scalarValue(e.c, src, dv, fld.Format); err != nil {
+					return nil, err
+				}
+				switch kind {
+				case reflect.Float64:
+					finiteFloatValue(dv, fld.Format)
+				case reflect.String:
+					dv.Flag |= document.FlagQuoted
+				}
+				if err := annotateValue(dv, fld); err != nil {
+					return nil, err
+				}
+				return append(nodes, child), nil
+			}
+		}
+	}
+
+	childNodes, err := marshalValueToNodeOrNodes(e.c, name, reflect.ValueOf(src).Elem(), fld, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(nodes, childNodes...), nil
+}
+
+// GenMarshalerPtr is satisfied by pointers to types with generated methods
+type GenMarshalerPtr[T any] interface {
+	*T
+	GenMarshaler
+}
+
+// GenFieldPtr adds field i, to which src points, to node as either a property or a child node
+func GenFieldPtr[T any, PT GenMarshalerPtr[T]](e *GenEncoder, node *document.Node, f *GenFields, i int, src *PT) error {
+	if *src == nil || e.custom || isValueMarshaler(*src) || f.details[i].HasDefault {
+		return e.Field(node, f, i, src)
+	}
+	child, err := e.structChild(f.name(e, i), f.details[i], *src)
+	if child != nil {
+		node.AddNode(child)
+	}
+	return err
+}
+
+// GenNodesPtr appends the nodes representing field i, to which src points, to nodes
+func GenNodesPtr[T any, PT GenMarshalerPtr[T]](e *GenEncoder, nodes []*document.Node, f *GenFields, i int, src *PT) ([]*document.Node, error) {
+	if *src == nil || e.custom || isValueMarshaler(*src) || f.details[i].IsMultiple() || f.details[i].HasDefault {
+		return e.Nodes(nodes, f, i, src)
+	}
+	child, err := e.structChild(f.name(e, i), f.details[i], *src)
+	if err != nil {
+		return nil, err
+	} else if child != nil {
+		nodes = append(nodes, child)
+	}
+	return nodes, nil
+}
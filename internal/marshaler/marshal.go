@@ -1,12 +1,14 @@
 package marshaler
 
 import (
+	"cmp"
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"math"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -23,30 +25,25 @@ type valueMarshaler interface {
 	MarshalKDLValue(value *document.Value) error
 }
 
-var customMarshalers = make(map[reflect.Type]func(v reflect.Value, node *document.Node) error)
-var customValueMarshalers = make(map[reflect.Type]func(v reflect.Value, value *document.Value, format string) error)
-
-func AddCustomMarshaler[T any](marshal func(v reflect.Value, node *document.Node) error) {
-	assertNoIndexers()
-	typeForT := reflect.TypeFor[T]()
-	customMarshalers[typeForT] = marshal
-}
-
-func AddCustomValueMarshaler[T any](marshal func(v reflect.Value, value *document.Value, format string) error) {
-	assertNoIndexers()
-	typeForT := reflect.TypeFor[T]()
-	customValueMarshalers[typeForT] = marshal
-}
-
 type MarshalOptions struct {
 	CaseSensitive bool
 	// BareSuffixed causes suffixed numeric values to be written unquoted to the output file, which is noncompliant with the KDL spec
 	BareSuffixed bool
+	// Marshalers holds custom marshaling functions which take precedence over those registered via AddCustomMarshaler
+	// and AddCustomValueMarshaler
+	Marshalers *Marshalers
+	// OmitDefaults omits struct fields tagged ",default:..." whose values equal their defaults
+	OmitDefaults bool
+	// Unions holds interface types whose concrete types are identified by a discriminator in each node, which take
+	// precedence over those registered via AddCustomUnion
+	Unions *Unions
 }
 
 type marshalContext struct {
 	indexer *typeIndexer
 	opts    MarshalOptions
+	// gen is the encoder passed to generated MarshalKDLWith and MarshalKDLNodes methods; see encoder
+	gen *GenEncoder
 }
 
 func Marshal(v interface{}, doc *document.Document) error {
@@ -58,7 +55,7 @@ func MarshalWithOptions(v interface{}, doc *document.Document, opts MarshalOptio
 	c := &marshalContext{
 		opts: opts,
 	}
-	c.indexer = newTypeIndexer(opts.CaseSensitive)
+	c.indexer = newTypeIndexer(opts.CaseSensitive, opts.Marshalers, nil)
 	if err := c.indexer.IndexIntf(v); err != nil {
 		return err
 	}
@@ -79,7 +76,7 @@ func MarshalNode(v interface{}) (*document.Node, error) {
 
 func MarshalNodeWithOptions(v interface{}, opts MarshalOptions) (*document.Node, error) {
 	c := &marshalContext{opts: opts}
-	c.indexer = newTypeIndexer(opts.CaseSensitive)
+	c.indexer = newTypeIndexer(opts.CaseSensitive, opts.Marshalers, nil)
 	if err := c.indexer.IndexIntf(v); err != nil {
 		return nil, err
 	}
@@ -196,6 +193,11 @@ func marshalSliceToNode(c *marshalContext, name string, slice reflect.Value, fld
 	n := slice.Len()
 	for i := 0; i < n; i++ {
 		el := reflect.Indirect(slice.Index(i))
+		if !el.IsValid() {
+			// nil pointers are marshaled as null
+			node.AddArgument(nil, "")
+			continue
+		}
 		if isStringSlice {
 			// if this is a []string and this element contains a `=`, marshal it as a property
 			if k, v, ok := strings.Cut(el.String(), "="); ok {
@@ -304,16 +306,25 @@ func marshalMapToNode(c *marshalContext, name string, m reflect.Value, fldDetail
 func tryMarshalValueAsChild(c *marshalContext, nameIntf interface{}, val reflect.Value, fldDetails *structFieldDetails, parentStructure *structStructure) (n *document.Node, multiple bool, skip bool, e error) {
 
 	if val.Kind() == reflect.Interface && val.Elem().IsValid() {
+		if un := lookupUnion(val.Type(), c.opts.Unions); un != nil {
+			// members of unions are always marshaled as children, as their discriminators must be written
+			n, err := marshalUnionToNode(c, coerce.ToString(nameIntf), val, un, fldDetails, parentStructure)
+			return n, false, n == nil, err
+		}
 		val = val.Elem()
 	}
 
-	if fldDetails != nil && fldDetails.Attrs.Has("omitempty") && val.IsZero() {
+	// nil pointers have nothing to marshal
+	if !val.IsValid() || (fldDetails != nil && fldDetails.Attrs.Has("omitempty") && val.IsZero()) {
 		return nil, false, true, nil
 	}
+	if omit, err := c.isDefault(fldDetails, val); err != nil || omit {
+		return nil, false, omit, err
+	}
 
-	typeDetails := c.indexer.Get(val.Type().String())
+	typeDetails := c.indexer.Get(val.Type())
 	// if it implements a marshaler interface, it definitely doesn't marshal into child nodes
-	if typeDetails != nil && typeDetails.CanMarshalKDL() {
+	if typeDetails != nil && typeDetails.MarshalsOpaquely() {
 		return nil, false, false, nil
 	}
 
@@ -335,6 +346,9 @@ func tryMarshalValueAsChild(c *marshalContext, nameIntf interface{}, val reflect
 				n = &document.Node{Children: nodes}
 			} else {
 				n, err = marshalMapToNode(c, coerce.ToString(nameIntf), val, fldDetails, parentStructure)
+				if err == nil {
+					err = annotateNode(c, n, val, fldDetails)
+				}
 			}
 			return n, multiple, false, err
 		case reflect.Slice, reflect.Array:
@@ -342,20 +356,33 @@ func tryMarshalValueAsChild(c *marshalContext, nameIntf interface{}, val reflect
 			for e.Kind() == reflect.Pointer {
 				e = e.Elem()
 			}
-			if e.Kind() == reflect.Struct {
+			if e.Kind() == reflect.Struct || (multiple && lookupUnion(e, c.opts.Unions) != nil) {
 				// if structTypeDetails := c.indexer.Get(e.String()); structTypeDetails == nil || !(structTypeDetails.CanMarshalKDL() || structTypeDetails.CanMarshalKDLValue() || structTypeDetails.CanMarshalText()) {
 				// an array of struct, when the struct has no marshaler, can only be marshaled as multiple nodes; if it
 				// does have a marshaler, though, it may still need multiple nodes -- we can't really know until we
 				// try it
 				ns, err := marshalMultiSliceToNodes(c, coerce.ToString(nameIntf), val, &structFieldDetails{})
+				// nil elements have no nodes
+				for i, j := 0, 0; err == nil && i < val.Len() && j < len(ns); i++ {
+					if el := reflect.Indirect(val.Index(i)); el.IsValid() {
+						err = annotateNode(c, ns[j], el, fldDetails)
+						j++
+					}
+				}
 				return &document.Node{Children: ns}, true, false, err
 				// }
 			}
 
 			n, err := marshalSliceToNode(c, coerce.ToString(nameIntf), val, &structFieldDetails{})
+			if err == nil {
+				err = annotateNode(c, n, val, fldDetails)
+			}
 			return n, false, false, err
 		case reflect.Struct:
 			n, err := marshalStructToNode(c, coerce.ToString(nameIntf), val, &structFieldDetails{})
+			if err == nil {
+				err = annotateNode(c, n, val, fldDetails)
+			}
 			return n, false, false, err
 		}
 	}
@@ -374,8 +401,10 @@ func marshalKDLNode(c *marshalContext, name string, srcStruct reflect.Value, typ
 	node.SetName(name)
 
 	var err error
-	if typeDetails.CustomArshalers.Has(hasCustomMarshaler) {
-		err = customMarshalers[srcStruct.Type()](srcStruct, node)
+	if typeDetails.custom != nil && typeDetails.custom.marshal != nil {
+		err = typeDetails.custom.marshal(typeDetails.custom.value(srcStruct), node)
+	} else if typeDetails.GeneratedMarshaler {
+		err = callGenerated(srcStruct, typeDetails.KDLMarshalerMethod, node, c.encoder())
 	} else {
 		_, err = callStructMethod(srcStruct, typeDetails.KDLMarshalerMethod, reflect.ValueOf(node))
 	}
@@ -505,8 +534,8 @@ func marshalTextValue(srcStruct reflect.Value, typeDetails *typeDetails, format
 
 func marshalKDLValue(srcStruct reflect.Value, typeDetails *typeDetails, format string, v *document.Value) error {
 	var err error
-	if typeDetails.CustomArshalers.Has(hasCustomValueMarshaler) {
-		err = customValueMarshalers[srcStruct.Type()](srcStruct, v, format)
+	if typeDetails.custom != nil && typeDetails.custom.valueMarshal != nil {
+		err = typeDetails.custom.valueMarshal(typeDetails.custom.value(srcStruct), v, format)
 	} else {
 		_, err = callStructMethod(srcStruct, typeDetails.KDLValueMarshalerMethod, reflect.ValueOf(v))
 	}
@@ -514,8 +543,12 @@ func marshalKDLValue(srcStruct reflect.Value, typeDetails *typeDetails, format s
 }
 
 func reflectValueToDocumentValue(c *marshalContext, rv reflect.Value, dv *document.Value, format string) (err error) {
-	typeStr := rv.Type().String()
-	typeDetails := c.indexer.Get(typeStr)
+	if !rv.IsValid() {
+		// nil pointers are marshaled as null
+		dv.Value = nil
+		return nil
+	}
+	typeDetails := c.indexer.Get(rv.Type())
 
 	if typeDetails != nil && typeDetails.CanMarshalKDLValue() {
 		err = marshalKDLValue(rv, typeDetails, format, dv)
@@ -543,12 +576,20 @@ func reflectValueToDocumentValue(c *marshalContext, rv reflect.Value, dv *docume
 }
 
 func marshalStructToNode(c *marshalContext, name string, structValue reflect.Value, fldDetails *structFieldDetails) (*document.Node, error) {
-	typeDetails := c.indexer.Get(structValue.Type().String())
-	structure := typeDetails.GetStructure(structValue)
+	typeDetails, err := c.indexer.Lookup(structValue.Type())
+	if err != nil {
+		return nil, err
+	}
 
 	node := document.NewNode()
 	node.SetName(name)
 
+	if typeDetails.GeneratedMarshaler {
+		return marshalKDLNode(c, name, structValue, typeDetails)
+	}
+
+	structure := typeDetails.GetStructure(structValue)
+
 	argFieldInfo := typeDetails.StructAttrs["arg"]
 	argsFieldInfo := typeDetails.StructAttrs["args"]
 	propsFieldInfo := typeDetails.StructAttrs["props"]
@@ -562,45 +603,24 @@ func marshalStructToNode(c *marshalContext, name string, structValue reflect.Val
 		if err := reflectValueToDocumentValue(c, v, dv, argField.Format); err != nil {
 			return nil, err
 		}
+		if err := annotateValue(dv, argField); err != nil {
+			return nil, err
+		}
 	}
 
 	// pull arguments from field tagged `,args`
 	for _, argsField := range argsFieldInfo {
-		slice := reflect.Indirect(argsField.GetValueFrom(structValue))
-		sk := slice.Kind()
-		if sk != reflect.Slice && sk != reflect.Array {
-			return nil, fmt.Errorf("non-slice type %s tagged with ',args'", slice.Kind().String())
-		}
-
-		n := slice.Len()
-		node.ExpectArguments(n)
-		for i := 0; i < n; i++ {
-			el := reflect.Indirect(slice.Index(i))
-			dv := node.AddArgument(nil, "")
-			if err := reflectValueToDocumentValue(c, el, dv, argsField.Format); err != nil {
-				return nil, err
-			}
+		if err := marshalArgsField(c, node, argsField.GetValueFrom(structValue), argsField); err != nil {
+			return nil, err
 		}
 		break
 	}
 
 	// pull properties from field tagged `,props`
 	for _, propsField := range propsFieldInfo {
-		m := reflect.Indirect(propsField.GetValueFrom(structValue))
-		if m.Kind() != reflect.Map {
-			return nil, fmt.Errorf("non-map type %s tagged with ',props'", m.Kind().String())
-		}
-
-		keys := sortMapKeys(m.MapKeys())
-
-		for _, key := range keys {
-			val := m.MapIndex(key)
-			dv := node.AddProperty(coerce.ToString(key.Interface()), nil, "")
-			if err := reflectValueToDocumentValue(c, val, dv, propsField.Format); err != nil {
-				return nil, err
-			}
+		if err := marshalPropsField(c, node, propsField.GetValueFrom(structValue), propsField.Format); err != nil {
+			return nil, err
 		}
-
 		break
 	}
 
@@ -610,21 +630,8 @@ func marshalStructToNode(c *marshalContext, name string, structValue reflect.Val
 		fldDetails := typeDetails.StructFields[safeFldName]
 		if fldName != "-" && !fldDetails.IsCapture() {
 			val := reflect.Indirect(fldDetails.GetValueFrom(structValue))
-			if child, multiple, skip, err := tryMarshalValueAsChild(c, fldName, val, fldDetails, nil); err != nil {
+			if err := marshalFieldToNode(c, node, fldName, val, fldDetails, structure); err != nil {
 				return nil, err
-			} else if child != nil {
-				if multiple {
-					node.Children = append(node.Children, child.Children...)
-					assignCommentToNodes(c, structure, node.Children)
-				} else {
-					node.AddNode(child)
-					assignCommentToNode(c, structure, child)
-				}
-			} else if !skip {
-				dv := node.AddProperty(fldName, nil, "")
-				if err := reflectValueToDocumentValue(c, val, dv, fldDetails.Format); err != nil {
-					return nil, err
-				}
 			}
 		}
 	}
@@ -632,19 +639,135 @@ func marshalStructToNode(c *marshalContext, name string, structValue reflect.Val
 	// pull children from fields tagged with `,children`
 	node.ExpectChildren(len(childrenFieldInfo))
 	for _, childrenField := range childrenFieldInfo {
-		if children, err := marshalValueToNodes(c, childrenField.GetValueFrom(structValue)); err != nil {
+		if err := marshalChildrenField(c, node, childrenField.GetValueFrom(structValue)); err != nil {
 			return nil, err
-		} else if node.Children == nil {
-			node.Children = children
-		} else {
-			node.Children = append(node.Children, children...)
 		}
 		break
 	}
 
+	// restore the content captured by the field tagged `,unknown`
+	if typeDetails.UnknownStructField != nil {
+		if err := marshalUnknownField(c, node, typeDetails.UnknownStructField.GetValueFrom(structValue)); err != nil {
+			return nil, err
+		}
+	}
+
 	return node, nil
 }
 
+// marshalArgsField adds the elements of slice, which represents the field tagged ",args" described by fld, to node as
+// arguments
+func marshalArgsField(c *marshalContext, node *document.Node, slice reflect.Value, fld *structFieldDetails) error {
+	slice = reflect.Indirect(slice)
+	sk := slice.Kind()
+	if sk != reflect.Slice && sk != reflect.Array {
+		return fmt.Errorf("non-slice type %s tagged with ',args'", slice.Kind().String())
+	}
+
+	n := slice.Len()
+	node.ExpectArguments(n)
+	for i := 0; i < n; i++ {
+		el := reflect.Indirect(slice.Index(i))
+		dv := node.AddArgument(nil, "")
+		if err := reflectValueToDocumentValue(c, el, dv, fld.Format); err != nil {
+			return err
+		}
+		if err := annotateValue(dv, fld); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalPropsField adds the entries of m, which represents a field tagged ",props", to node as properties
+func marshalPropsField(c *marshalContext, node *document.Node, m reflect.Value, format string) error {
+	m = reflect.Indirect(m)
+	if m.Kind() != reflect.Map {
+		return fmt.Errorf("non-map type %s tagged with ',props'", m.Kind().String())
+	}
+
+	keys := sortMapKeys(m.MapKeys())
+
+	for _, key := range keys {
+		val := reflect.Indirect(m.MapIndex(key))
+		dv := node.AddProperty(coerce.ToString(key.Interface()), nil, "")
+		if err := reflectValueToDocumentValue(c, val, dv, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalUnknownField adds the content of v, which represents a field tagged ",unknown", to node: the nodes in a
+// []*document.Node are added as children, while each entry of a map is added as an argument if its key is an integer
+// (following the arguments from other fields, in order of their keys), or otherwise as a property or child node per
+// marshalFieldToNode
+func marshalUnknownField(c *marshalContext, node *document.Node, v reflect.Value) error {
+	if v.Kind() != reflect.Map {
+		node.Children = append(node.Children, v.Interface().([]*document.Node)...)
+		return nil
+	}
+
+	type unknownArg struct {
+		index int64
+		value reflect.Value
+	}
+	var args []unknownArg
+	for _, key := range sortMapKeys(v.MapKeys()) {
+		val := reflect.Indirect(v.MapIndex(key))
+		if keyIntf := key.Interface(); coerce.IsInteger(keyIntf) {
+			args = append(args, unknownArg{coerce.ToInt64(keyIntf), val})
+		} else if err := marshalFieldToNode(c, node, coerce.ToString(keyIntf), val, &structFieldDetails{}, nil); err != nil {
+			return err
+		}
+	}
+
+	slices.SortFunc(args, func(a, b unknownArg) int { return cmp.Compare(a.index, b.index) })
+	for _, arg := range args {
+		dv := node.AddArgument(nil, "")
+		if err := reflectValueToDocumentValue(c, arg.value, dv, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalFieldToNode adds val, which represents the struct field named fldName, to node as either a child node or a
+// property
+func marshalFieldToNode(c *marshalContext, node *document.Node, fldName string, val reflect.Value, fldDetails *structFieldDetails, structure *structStructure) error {
+	if child, multiple, skip, err := tryMarshalValueAsChild(c, fldName, val, fldDetails, nil); err != nil {
+		return err
+	} else if child != nil {
+		if multiple {
+			node.Children = append(node.Children, child.Children...)
+			assignCommentToNodes(c, structure, node.Children)
+		} else {
+			node.AddNode(child)
+			assignCommentToNode(c, structure, child)
+		}
+	} else if !skip {
+		dv := node.AddProperty(fldName, nil, "")
+		if err := reflectValueToDocumentValue(c, val, dv, fldDetails.Format); err != nil {
+			return err
+		}
+		return annotateValue(dv, fldDetails)
+	}
+	return nil
+}
+
+// marshalChildrenField adds the nodes representing v, which represents a field tagged ",children", to node's children
+func marshalChildrenField(c *marshalContext, node *document.Node, v reflect.Value) error {
+	children, err := marshalValueToNodes(c, v)
+	if err != nil {
+		return err
+	} else if node.Children == nil {
+		node.Children = children
+	} else {
+		node.Children = append(node.Children, children...)
+	}
+	return nil
+}
+
 func assignCommentToNodes(c *marshalContext, structure *structStructure, nodes []*document.Node) {
 	if structure == nil {
 		return
@@ -664,10 +787,13 @@ func assignCommentToNode(c *marshalContext, structure *structStructure, node *do
 func marshalValueWithMarshaler(c *marshalContext, name string, value reflect.Value, fldDetails *structFieldDetails) (node *document.Node, err error) {
 	v := reflect.Indirect(value)
 
-	typeDetails := c.indexer.Get(value.Type().String())
+	typeDetails := c.indexer.Get(value.Type())
 	if typeDetails != nil {
-		if typeDetails.CanMarshalKDL() {
+		if typeDetails.CanMarshalKDL() && (v.IsValid() || typeDetails.MarshalsOpaquely()) {
 			return marshalKDLNode(c, name, value, typeDetails)
+		} else if !v.IsValid() {
+			// nil pointers have nothing to marshal
+			return nil, nil
 		} else if typeDetails.CanMarshalKDLValue() {
 			node := document.NewNode()
 			node.SetName(name)
@@ -691,12 +817,40 @@ func marshalValueWithMarshaler(c *marshalContext, name string, value reflect.Val
 	return nil, nil
 }
 
-func marshalValueToNode(c *marshalContext, name string, value reflect.Value, fldDetails *structFieldDetails, parentStructure *structStructure) (*document.Node, error) {
+// isDefault returns true if OmitDefaults is set and v, the value of the field described by fldDetails, equals the
+// field's default value
+func (c *marshalContext) isDefault(fldDetails *structFieldDetails, v reflect.Value) (bool, error) {
+	if !c.opts.OmitDefaults || fldDetails == nil || !fldDetails.HasDefault {
+		return false, nil
+	}
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return false, nil
+	}
+
+	// the default is converted exactly as it would be when unmarshaling
+	uc := &unmarshalContext{indexer: newTypeIndexer(c.indexer.caseSensitive, nil, nil)}
+	def := reflect.New(v.Type()).Elem()
+	if _, err := setReflectValueFromIntf(uc, def, fldDetails.Default, fldDetails.Format); err != nil {
+		return false, fmt.Errorf("invalid default value %q: %w", fldDetails.Default, err)
+	}
+	return reflect.DeepEqual(def.Interface(), v.Interface()), nil
+}
+
+func marshalValueToNode(c *marshalContext, name string, value reflect.Value, fldDetails *structFieldDetails, parentStructure *structStructure) (n *document.Node, e error) {
 	v := reflect.Indirect(value)
+	defer func() {
+		if e == nil {
+			e = annotateNode(c, n, v, fldDetails)
+		}
+	}()
 
-	if fldDetails != nil && fldDetails.Attrs.Has("omitempty") && v.IsZero() {
+	if fldDetails != nil && fldDetails.Attrs.Has("omitempty") && (!v.IsValid() || v.IsZero()) {
 		return nil, nil
 	}
+	if omit, err := c.isDefault(fldDetails, v); err != nil || omit {
+		return nil, err
+	}
 
 	if node, err := marshalValueWithMarshaler(c, name, value, fldDetails); err != nil {
 		return nil, err
@@ -719,7 +873,9 @@ func marshalValueToNode(c *marshalContext, name string, value reflect.Value, fld
 		return marshalSliceToNode(c, name, v, fldDetails)
 	case reflect.Interface:
 		el := v.Elem()
-		if el.IsValid() {
+		if un := lookupUnion(v.Type(), c.opts.Unions); un != nil && el.IsValid() {
+			return marshalUnionToNode(c, name, v, un, fldDetails, parentStructure)
+		} else if el.IsValid() {
 			return marshalValueToNode(c, name, v.Elem(), fldDetails, parentStructure)
 		} else {
 			node := document.NewNode()
@@ -748,25 +904,7 @@ func marshalValueToNode(c *marshalContext, name string, value reflect.Value, fld
 		if err := reflectValueToDocumentValue(c, v, dv, format); err != nil {
 			return nil, err
 		} else {
-			f := coerce.ToFloat64(dv.Value)
-			if math.IsInf(f, 0) {
-				if format == "nonfinite" {
-					inf := "+Inf"
-					if math.IsInf(f, -1) {
-						inf = "-Inf"
-					}
-					dv.Value = inf
-				} else {
-					dv.Value = 0.0
-				}
-
-			} else if math.IsNaN(f) {
-				if format == "nonfinite" {
-					dv.Value = "NaN"
-				} else {
-					dv.Value = 0.0
-				}
-			}
+			finiteFloatValue(dv, format)
 		}
 		return node, nil
 	case reflect.String:
@@ -787,6 +925,30 @@ func marshalValueToNode(c *marshalContext, name string, value reflect.Value, fld
 
 }
 
+// finiteFloatValue replaces a non-finite float in dv with its string representation if format is "nonfinite", or with
+// zero otherwise, as KDL has no representation for non-finite numbers
+func finiteFloatValue(dv *document.Value, format string) {
+	f := coerce.ToFloat64(dv.Value)
+	if math.IsInf(f, 0) {
+		if format == "nonfinite" {
+			inf := "+Inf"
+			if math.IsInf(f, -1) {
+				inf = "-Inf"
+			}
+			dv.Value = inf
+		} else {
+			dv.Value = 0.0
+		}
+
+	} else if math.IsNaN(f) {
+		if format == "nonfinite" {
+			dv.Value = "NaN"
+		} else {
+			dv.Value = 0.0
+		}
+	}
+}
+
 func prependArguments(node *document.Node, argStr ...string) {
 	args := node.Arguments
 	node.Arguments = make([]*document.Value, 0, len(args)+len(argStr))
@@ -800,11 +962,15 @@ func marshalMultiSliceToNodes(c *marshalContext, name string, value reflect.Valu
 	nodes := make([]*document.Node, 0, value.Len())
 	for i := 0; i < value.Len(); i++ {
 		el := reflect.Indirect(value.Index(i))
+		if !el.IsValid() {
+			// nil pointers have nothing to marshal
+			continue
+		}
 		if child, err := marshalValueToNode(c, name, el, fldDetails, nil); err != nil {
 			return nil, err
 		} else if child != nil {
 			if el.Kind() == reflect.Struct && child.Comment == nil {
-				typDetails := c.indexer.Get(el.Type().String())
+				typDetails := c.indexer.Get(el.Type())
 				if structure := typDetails.GetStructure(el); structure != nil {
 					if comment := structure.Get("__parent", nil); comment != nil {
 						child.Comment = comment
@@ -829,6 +995,10 @@ func marshalMultiMapToNodes(c *marshalContext, names []string, value reflect.Val
 		moreNames = append(moreNames, coerce.ToString(key.Interface()))
 
 		el := reflect.Indirect(value.MapIndex(key))
+		if !el.IsValid() {
+			// nil pointers have nothing to marshal
+			continue
+		}
 		if nestedFurther {
 			if more, err := marshalMultiMapToNodes(c, moreNames, el, fldDetails, parentStructure); err != nil {
 				return nil, err
@@ -840,7 +1010,7 @@ func marshalMultiMapToNodes(c *marshalContext, names []string, value reflect.Val
 				return nil, err
 			} else if child != nil {
 				if el.Kind() == reflect.Struct && child.Comment == nil {
-					typDetails := c.indexer.Get(el.Type().String())
+					typDetails := c.indexer.Get(el.Type())
 					if structure := typDetails.GetStructure(el); structure != nil {
 						if comment := structure.Get("__parent", nil); comment != nil {
 							child.Comment = comment
@@ -894,13 +1064,21 @@ func marshalValueToNodeOrNodes(c *marshalContext, name string, value reflect.Val
 
 func marshalStructToNodes(c *marshalContext, structValue reflect.Value, nodes []*document.Node) ([]*document.Node, error) {
 
+	if typeDetails := c.indexer.Get(structValue.Type()); typeDetails != nil && typeDetails.GeneratedMarshaler && !typeDetails.MarshalsOpaquely() {
+		// the nodes of a struct with generated methods are its fields, rather than the children of the node produced
+		// by its MarshalKDL method
+		return marshalNodesWithGenerated(c, structValue, nodes)
+	}
 	if node, err := marshalValueWithMarshaler(c, "", structValue, nil); err != nil {
 		return nil, err
 	} else if node != nil {
 		return node.Children, nil
 	}
 
-	typeDetails := c.indexer.Get(structValue.Type().String())
+	typeDetails, err := c.indexer.Lookup(structValue.Type())
+	if err != nil {
+		return nil, err
+	}
 	structure := typeDetails.GetStructure(structValue)
 
 	if nodes == nil {
@@ -926,6 +1104,15 @@ func marshalStructToNodes(c *marshalContext, structValue reflect.Value, nodes []
 		}
 	}
 
+	// restore the nodes captured by the field tagged `,unknown`
+	if fld := typeDetails.UnknownStructField; fld != nil {
+		v := fld.GetValueFrom(structValue)
+		if v.Kind() == reflect.Map {
+			return marshalMapToNodes(c, v, nodes)
+		}
+		nodes = append(nodes, v.Interface().([]*document.Node)...)
+	}
+
 	return nodes, nil
 }
 
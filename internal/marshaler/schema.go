@@ -0,0 +1,791 @@
+package marshaler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+)
+
+// valueSchema describes the values accepted for a Go type when unmarshaling an argument or property
+type valueSchema struct {
+	any     bool     // true if any value is accepted
+	types   []string // KDL Schema value types: string, number, or boolean
+	integer bool     // true if numbers must be integers
+	min     interface{}
+	max     interface{}
+	format  string // KDL Schema format, if any
+
+	// the remainder are declared by the tag options of a struct field (see constrain)
+	enum               []interface{}
+	pattern            string // a regular expression that strings must match
+	minLength          int    // the minimum length of strings, or -1 if unbounded
+	maxLength          int    // the maximum length of strings, or -1 if unbounded
+	hasLength, hasEnum bool
+}
+
+// constrain returns a copy of vs narrowed by the constraints declared by the tag options of fld, a field of type t
+// holding the values described by vs (or, if elem is true, a slice or map whose elements they are). Bounds on
+// durations, and "oneof:" groups, have no equivalent in a schema and are ignored.
+func constrain(vs *valueSchema, t reflect.Type, fld *structFieldDetails, elem bool) *valueSchema {
+	if fld == nil || fld.Rules == nil || vs.any {
+		return vs
+	}
+	r := fld.Rules
+	c := *vs
+	numeric := slices.Equal(vs.types, []string{"number"}) && derefType(t) != durationType
+	if numeric && r.hasMin {
+		if v := coerce.FromString(r.min); coerce.IsNumeric(v) {
+			c.min = v
+		}
+	}
+	if numeric && r.hasMax {
+		if v := coerce.FromString(r.max); coerce.IsNumeric(v) {
+			c.max = v
+		}
+	}
+	if len(r.enum) > 0 {
+		c.enum, c.hasEnum = make([]interface{}, len(r.enum)), true
+		for i, e := range r.enum {
+			c.enum[i] = e
+			if numeric || slices.Equal(vs.types, []string{"boolean"}) {
+				c.enum[i] = coerce.FromString(e)
+			}
+		}
+	}
+	if r.match != nil {
+		c.pattern = r.match.String()
+	}
+	if !elem && derefType(t).Kind() == reflect.String && (r.minLen != -1 || r.maxLen != -1) {
+		c.minLength, c.maxLength, c.hasLength = r.minLen, r.maxLen, true
+	}
+	return &c
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+	urlType      = reflect.TypeOf(url.URL{})
+	regexpType   = reflect.TypeOf(regexp.Regexp{})
+	locationType = reflect.TypeOf(time.Location{})
+	fileModeType = reflect.TypeOf(os.FileMode(0))
+)
+
+// integerBounds lists the inclusive bounds of the sized integer kinds
+var integerBounds = map[reflect.Kind][2]interface{}{
+	reflect.Int8:   {int64(math.MinInt8), int64(math.MaxInt8)},
+	reflect.Int16:  {int64(math.MinInt16), int64(math.MaxInt16)},
+	reflect.Int32:  {int64(math.MinInt32), int64(math.MaxInt32)},
+	reflect.Uint:   {int64(0), nil},
+	reflect.Uint8:  {int64(0), int64(math.MaxUint8)},
+	reflect.Uint16: {int64(0), int64(math.MaxUint16)},
+	reflect.Uint32: {int64(0), int64(math.MaxUint32)},
+	reflect.Uint64: {int64(0), nil},
+}
+
+// schemaBuilder describes the documents accepted by Unmarshal for a Go type
+type schemaBuilder struct {
+	indexer *typeIndexer
+	// building holds the types whose children (or, for JSON Schema, whose values) are currently being described, to
+	// detect recursive types
+	building map[reflect.Type]bool
+	// children holds the first children schema node built for each type, which recursive references refer to by id
+	children map[reflect.Type]*document.Node
+	// ids holds the id assigned to each type that is referred to recursively
+	ids map[reflect.Type]string
+	// defs holds the JSON Schema definitions of recursive types, keyed by id
+	defs map[string]*jsonSchema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		indexer:  newTypeIndexer(false, nil, nil),
+		building: make(map[reflect.Type]bool),
+		children: make(map[reflect.Type]*document.Node),
+		ids:      make(map[reflect.Type]string),
+		defs:     make(map[string]*jsonSchema),
+	}
+}
+
+// id returns the id by which recursive references to t refer to its description
+func (b *schemaBuilder) id(t reflect.Type) string {
+	if id, ok := b.ids[t]; ok {
+		return id
+	}
+	id := t.String()
+	if t.Name() != "" && t.PkgPath() != "" {
+		id = t.PkgPath() + "." + t.Name()
+	}
+	b.ids[t] = id
+	return id
+}
+
+// valueSchema returns a description of the values accepted for t with the given format, or false if t is not
+// unmarshaled from a single value
+func (b *schemaBuilder) valueSchema(t reflect.Type, format string) (*valueSchema, bool) {
+	t = derefType(t)
+	switch t {
+	case timeType:
+		switch format {
+		case "unix", "unixmilli", "unixmicro", "unixnano":
+			return &valueSchema{types: []string{"number"}, integer: true}, true
+		case "", "RFC3339", "RFC3339Nano":
+			return &valueSchema{types: []string{"string"}, format: "date-time"}, true
+		}
+		return &valueSchema{types: []string{"string"}}, true
+	case durationType:
+		switch format {
+		case "sec", "milli", "micro", "nano":
+			return &valueSchema{types: []string{"number"}}, true
+		case "base60":
+			return &valueSchema{types: []string{"string"}}, true
+		}
+		return &valueSchema{types: []string{"string", "number"}}, true
+	case bigIntType:
+		return &valueSchema{types: []string{"number"}, integer: true}, true
+	case bigFloatType:
+		return &valueSchema{types: []string{"number"}}, true
+	case bigRatType, fileModeType:
+		return &valueSchema{types: []string{"string", "number"}}, true
+	case urlType, regexpType, locationType:
+		return &valueSchema{types: []string{"string"}}, true
+	}
+
+	if d := b.indexer.Get(t); d != nil {
+		if d.CanUnmarshalKDLValue() {
+			return &valueSchema{any: true}, true
+		} else if d.CanUnmarshalText() {
+			return &valueSchema{types: []string{"string"}}, true
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &valueSchema{types: []string{"boolean"}}, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		vs := &valueSchema{types: []string{"number"}, integer: true}
+		if bounds, ok := integerBounds[t.Kind()]; ok {
+			vs.min, vs.max = bounds[0], bounds[1]
+		}
+		return vs, true
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return &valueSchema{types: []string{"number"}}, true
+	case reflect.String:
+		return &valueSchema{types: []string{"string"}}, true
+	case reflect.Interface:
+		return &valueSchema{any: true}, true
+	}
+	return nil, false
+}
+
+// byteSliceSchema returns a description of the arguments accepted for a []byte with the given format, and the minimum
+// and maximum number of arguments (or -1 if unbounded)
+func byteSliceSchema(format string) (*valueSchema, int, int) {
+	switch format {
+	case "":
+		return &valueSchema{types: []string{"string", "number"}}, 1, -1
+	case "array":
+		return &valueSchema{types: []string{"number"}, integer: true, min: int64(0), max: int64(math.MaxUint8)}, 1, -1
+	case "base64":
+		return &valueSchema{types: []string{"string"}, format: "base64"}, 1, 1
+	}
+	return &valueSchema{types: []string{"string"}}, 1, 1
+}
+
+// schemaNode returns a new schema node with the given name and arguments
+func schemaNode(name string, args ...interface{}) *document.Node {
+	n := document.NewNode()
+	n.SetName(name)
+	for _, arg := range args {
+		n.AddArgument(arg, "")
+	}
+	return n
+}
+
+// addValidations adds the validations describing vs to n
+func addValidations(n *document.Node, vs *valueSchema) {
+	if vs.any {
+		return
+	}
+	types := make([]interface{}, len(vs.types))
+	for i, t := range vs.types {
+		types[i] = t
+	}
+	n.AddNode(schemaNode("type", types...))
+	if vs.integer {
+		n.AddNode(schemaNode("%", int64(1)))
+	}
+	if vs.min != nil {
+		n.AddNode(schemaNode(">=", vs.min))
+	}
+	if vs.max != nil {
+		n.AddNode(schemaNode("<=", vs.max))
+	}
+	if vs.format != "" {
+		n.AddNode(schemaNode("format", vs.format))
+	}
+	if vs.hasEnum {
+		n.AddNode(schemaNode("enum", vs.enum...))
+	}
+	if vs.pattern != "" {
+		n.AddNode(schemaNode("pattern", vs.pattern))
+	}
+	if vs.hasLength && vs.minLength != -1 {
+		n.AddNode(schemaNode("min-length", int64(vs.minLength)))
+	}
+	if vs.hasLength && vs.maxLength != -1 {
+		n.AddNode(schemaNode("max-length", int64(vs.maxLength)))
+	}
+}
+
+// addValues adds a value schema node to n describing between min and max (or unbounded if -1) arguments matching vs,
+// preceded by keys map keys
+func addValues(n *document.Node, min int, max int, vs *valueSchema, keys int) {
+	if min+keys == 0 && max == -1 && (vs == nil || vs.any) {
+		// any arguments are allowed
+		return
+	}
+	v := schemaNode("value")
+	if min+keys > 0 {
+		v.AddNode(schemaNode("min", int64(min+keys)))
+	}
+	if max != -1 {
+		v.AddNode(schemaNode("max", int64(max+keys)))
+	}
+	if vs != nil && keys == 0 {
+		addValidations(v, vs)
+	}
+	n.AddNode(v)
+}
+
+// addAnything adds schema nodes to n allowing any arguments, properties, and children
+func addAnything(n *document.Node) {
+	n.AddNode(schemaNode("other-props-allowed", true))
+	children := schemaNode("children")
+	children.AddNode(schemaNode("other-nodes-allowed", true))
+	n.AddNode(children)
+}
+
+// fieldType returns the type of the struct field described by fld in the struct type t
+func fieldType(t reflect.Type, fld *structFieldDetails) reflect.Type {
+	return structField(t, fld).Type
+}
+
+// fieldNode returns a schema node describing the nodes unmarshaled into the struct field fld of type t named name
+func (b *schemaBuilder) fieldNode(name string, t reflect.Type, fld *structFieldDetails, required bool) (*document.Node, error) {
+	n := schemaNode("node", name)
+	if !fld.IsMultiple() {
+		if required {
+			n.AddNode(schemaNode("min", int64(1)))
+		}
+		n.AddNode(schemaNode("max", int64(1)))
+		return n, b.nodeBody(n, t, fld, false, 0)
+	}
+
+	// the length of a ",multiple" field is the number of nodes
+	if r := fld.Rules; r != nil && r.minLen > 0 {
+		n.AddNode(schemaNode("min", int64(r.minLen)))
+	} else if required {
+		n.AddNode(schemaNode("min", int64(1)))
+	}
+	if r := fld.Rules; r != nil && r.maxLen != -1 {
+		n.AddNode(schemaNode("max", int64(r.maxLen)))
+	}
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return n, b.nodeBody(n, t.Elem(), fld, true, 0)
+	case reflect.Map:
+		// the first argument is consumed as the map key; if the map's values are also maps, further arguments may be
+		// consumed as their keys, which the map's own description of its arguments permits
+		return n, b.nodeBody(n, t.Elem(), fld, true, 1)
+	}
+	return nil, fmt.Errorf("tag `,multiple` used on %s; must be slice or map", t)
+}
+
+// nodeBody adds schema nodes to n describing the arguments, properties, and children of a node unmarshaled into t,
+// whose first keys arguments are consumed as map keys; t is the type of the field fld (or, if elem is true, of its
+// elements), or fld is nil
+func (b *schemaBuilder) nodeBody(n *document.Node, t reflect.Type, fld *structFieldDetails, elem bool, keys int) error {
+	var format string
+	if fld != nil && !elem {
+		format = fld.Format
+	}
+	t = derefType(t)
+	if d := b.indexer.Get(t); (d != nil && d.UnmarshalsOpaquely()) || t.Kind() == reflect.Interface {
+		addAnything(n)
+		return nil
+	}
+	if vs, ok := b.valueSchema(t, format); ok {
+		addValues(n, 1, 1, constrain(vs, t, fld, elem), keys)
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.structBody(n, t, keys)
+
+	case reflect.Map:
+		// arguments are keyed by their index, properties by their names, and children by their names
+		if vs, ok := b.valueSchema(t.Elem(), ""); ok {
+			addValues(n, 0, -1, vs, keys)
+			n.AddNode(schemaNode("other-props-allowed", true))
+		} else {
+			addValues(n, 0, 0, nil, keys)
+		}
+		children, err := b.childrenNode(t, false)
+		if err != nil {
+			return err
+		}
+		n.AddNode(children)
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			vs, min, max := byteSliceSchema(format)
+			addValues(n, min, max, vs, keys)
+		} else if vs, ok := b.valueSchema(t.Elem(), ""); ok {
+			min, max := 0, -1
+			if fld != nil && fld.Rules != nil && !elem {
+				// the length of a slice is its number of arguments
+				min, max = fld.Rules.minLen, fld.Rules.maxLen
+				if min == -1 {
+					min = 0
+				}
+			}
+			addValues(n, min, max, constrain(vs, t.Elem(), fld, true), keys)
+		} else {
+			addValues(n, 0, 0, nil, keys)
+		}
+
+	default:
+		return fmt.Errorf("cannot describe %s", t)
+	}
+	return nil
+}
+
+// structBody adds schema nodes to n describing the arguments, properties, and children of a node unmarshaled into the
+// struct type t, whose first keys arguments are consumed as map keys
+func (b *schemaBuilder) structBody(n *document.Node, t reflect.Type, keys int) error {
+	d := b.indexer.Get(t)
+	if d == nil {
+		return fmt.Errorf("cannot describe %s", t)
+	}
+
+	argFields := d.StructAttrs["arg"]
+	argsFields := d.StructAttrs["args"]
+	// a map tagged ",unknown" captures any other arguments and properties
+	unknownMap := d.UnknownStructField != nil && fieldType(t, d.UnknownStructField).Kind() == reflect.Map
+	min, max := 0, len(argFields)
+	for i, fld := range argFields {
+		if fld.IsRequired() {
+			min = i + 1
+		}
+	}
+	var vs *valueSchema
+	if unknownMap && len(argsFields) == 0 {
+		max = -1
+	} else if len(argsFields) > 0 {
+		max = -1
+		elem := derefType(fieldType(t, argsFields[0]))
+		if len(argFields) == 0 && (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array) {
+			if vs, _ = b.valueSchema(elem.Elem(), ""); vs != nil {
+				vs = constrain(vs, elem.Elem(), argsFields[0], true)
+			}
+		}
+	} else if len(argFields) == 1 {
+		ft := fieldType(t, argFields[0])
+		if vs, _ = b.valueSchema(ft, argFields[0].Format); vs != nil {
+			vs = constrain(vs, ft, argFields[0], false)
+		}
+	}
+	addValues(n, min, max, vs, keys)
+
+	for _, name := range d.StructFieldNameList {
+		fld := d.StructFields[name]
+		if name == "-" || fld.IsCapture() {
+			continue
+		}
+		ft := fieldType(t, fld)
+		if vs, ok := b.valueSchema(ft, fld.Format); ok {
+			prop := schemaNode("prop", name)
+			if fld.IsRequired() {
+				// Marshal writes fields holding single values as properties
+				prop.AddNode(schemaNode("required", true))
+			}
+			addValidations(prop, constrain(vs, ft, fld, false))
+			n.AddNode(prop)
+		}
+	}
+	if len(d.StructAttrs["props"]) > 0 || unknownMap {
+		n.AddNode(schemaNode("other-props-allowed", true))
+	}
+
+	childrenType := t
+	if childrenFields := d.StructAttrs["children"]; len(childrenFields) > 0 {
+		childrenType = fieldType(t, childrenFields[0])
+	}
+	children, err := b.childrenNode(childrenType, false)
+	if err != nil {
+		return err
+	}
+	n.AddNode(children)
+	return nil
+}
+
+// childrenNode returns a children schema node describing the nodes unmarshaled into t, which must be a struct, map, or
+// interface type; top is true if the nodes are those at the top level of a document, where Marshal writes every field
+// as a node, rather than the children of a node, where it writes fields holding single values as properties
+func (b *schemaBuilder) childrenNode(t reflect.Type, top bool) (*document.Node, error) {
+	t = derefType(t)
+	n := schemaNode("children")
+	if b.building[t] {
+		// t is recursive; refer to the description that is being built
+		def := b.children[t]
+		if _, ok := b.ids[t]; !ok {
+			def.AddProperty("id", b.id(t), "")
+		}
+		n.AddProperty("ref", "[id="+strconv.Quote(b.id(t))+"]", "")
+		return n, nil
+	}
+	if _, ok := b.children[t]; !ok {
+		b.children[t] = n
+	}
+	b.building[t] = true
+	defer delete(b.building, t)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		d := b.indexer.Get(t)
+		if d == nil {
+			return nil, fmt.Errorf("cannot describe %s", t)
+		}
+		for _, name := range d.StructFieldNameList {
+			fld := d.StructFields[name]
+			if name == "-" || fld.IsCapture() {
+				continue
+			}
+			ft := fieldType(t, fld)
+			required := fld.IsRequired()
+			if _, isValue := b.valueSchema(ft, fld.Format); isValue && !top {
+				// required by the description of the property instead
+				required = false
+			}
+			child, err := b.fieldNode(name, ft, fld, required)
+			if err != nil {
+				return nil, err
+			}
+			n.AddNode(child)
+		}
+		if d.UnknownStructField != nil {
+			n.AddNode(schemaNode("other-nodes-allowed", true))
+		}
+
+	case reflect.Map:
+		// a node without a name describes every child
+		child := schemaNode("node")
+		if err := b.nodeBody(child, t.Elem(), nil, false, 0); err != nil {
+			return nil, err
+		}
+		n.AddNode(child)
+
+	case reflect.Interface:
+		n.AddNode(schemaNode("other-nodes-allowed", true))
+
+	default:
+		return nil, ErrStructOrMap
+	}
+	return n, nil
+}
+
+// SchemaFor returns a KDL Schema document describing the documents that Unmarshal accepts for t, which must be a
+// struct, map, or interface type or a pointer to one.
+//
+// The schema describes values in the form in which Marshal produces them, so it is stricter than Unmarshal, which
+// coerces values between types and matches names case-insensitively by default. Fields tagged ",required" are
+// required properties (or, at the top level of the document, nodes), and the constraints declared by their tag options
+// become validations per constrain.
+func SchemaFor(t reflect.Type) (*document.Document, error) {
+	if t == nil {
+		return nil, ErrStructOrMap
+	}
+	b := newSchemaBuilder()
+	children, err := b.childrenNode(t, true)
+	if err != nil {
+		return nil, err
+	}
+	children.SetName("document")
+
+	doc := document.New()
+	doc.AddNode(children)
+	return doc, nil
+}
+
+// jsonSchema is a JSON Schema
+type jsonSchema struct {
+	Schema               string          `json:"$schema,omitempty"`
+	Ref                  string          `json:"$ref,omitempty"`
+	Type                 interface{}     `json:"type,omitempty"`
+	Format               string          `json:"format,omitempty"`
+	ContentEncoding      string          `json:"contentEncoding,omitempty"`
+	Minimum              interface{}     `json:"minimum,omitempty"`
+	Maximum              interface{}     `json:"maximum,omitempty"`
+	Enum                 []interface{}   `json:"enum,omitempty"`
+	Pattern              string          `json:"pattern,omitempty"`
+	MinLength            *int            `json:"minLength,omitempty"`
+	MaxLength            *int            `json:"maxLength,omitempty"`
+	Items                *jsonSchema     `json:"items,omitempty"`
+	MinItems             *int            `json:"minItems,omitempty"`
+	MaxItems             *int            `json:"maxItems,omitempty"`
+	MinProperties        *int            `json:"minProperties,omitempty"`
+	MaxProperties        *int            `json:"maxProperties,omitempty"`
+	Properties           *jsonProperties `json:"properties,omitempty"`
+	Required             []string        `json:"required,omitempty"`
+	AdditionalProperties interface{}     `json:"additionalProperties,omitempty"`
+	Defs                 *jsonProperties `json:"$defs,omitempty"`
+}
+
+// jsonProperties is a set of named JSON Schemas that preserves the order in which they were added
+type jsonProperties struct {
+	names   []string
+	schemas map[string]*jsonSchema
+}
+
+// add adds s named name to p
+func (p *jsonProperties) add(name string, s *jsonSchema) {
+	if p.schemas == nil {
+		p.schemas = make(map[string]*jsonSchema)
+	}
+	if _, exists := p.schemas[name]; !exists {
+		p.names = append(p.names, name)
+	}
+	p.schemas[name] = s
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *jsonProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(p.schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValueSchema returns the JSON Schema corresponding to vs
+func jsonValueSchema(vs *valueSchema) *jsonSchema {
+	s := &jsonSchema{}
+	if vs.any {
+		return s
+	}
+	types := make([]string, len(vs.types))
+	for i, t := range vs.types {
+		switch {
+		case t == "number" && vs.integer:
+			types[i] = "integer"
+		default:
+			types[i] = t
+		}
+	}
+	if len(types) == 1 {
+		s.Type = types[0]
+	} else {
+		s.Type = types
+	}
+	s.Minimum, s.Maximum = vs.min, vs.max
+	s.Enum, s.Pattern = vs.enum, vs.pattern
+	if vs.hasLength {
+		s.MinLength, s.MaxLength = jsonBound(vs.minLength), jsonBound(vs.maxLength)
+	}
+	if vs.format == "base64" {
+		s.ContentEncoding = vs.format
+	} else {
+		s.Format = vs.format
+	}
+	return s
+}
+
+// jsonBound returns a pointer to n, or nil if n is -1 (unbounded)
+func jsonBound(n int) *int {
+	if n == -1 {
+		return nil
+	}
+	return &n
+}
+
+// jsonFieldSchema returns a JSON Schema describing the values of the struct field fld of type t, narrowed by the
+// constraints declared by its tag options
+func (b *schemaBuilder) jsonFieldSchema(t reflect.Type, fld *structFieldDetails) (*jsonSchema, error) {
+	if d := b.indexer.Get(derefType(t)); d == nil || !d.UnmarshalsOpaquely() {
+		if vs, ok := b.valueSchema(t, fld.Format); ok {
+			return jsonValueSchema(constrain(vs, t, fld, false)), nil
+		}
+	}
+	s, err := b.jsonSchema(t, fld.Format)
+	if err != nil || fld.Rules == nil || s.Ref != "" {
+		return s, err
+	}
+	r := fld.Rules
+	switch s.Type {
+	case "array":
+		s.MinItems, s.MaxItems = jsonBound(r.minLen), jsonBound(r.maxLen)
+		if vs, ok := b.valueSchema(derefType(t).Elem(), ""); ok {
+			s.Items = jsonValueSchema(constrain(vs, derefType(t).Elem(), fld, true))
+		}
+	case "object":
+		if derefType(t).Kind() == reflect.Map {
+			s.MinProperties, s.MaxProperties = jsonBound(r.minLen), jsonBound(r.maxLen)
+		}
+	}
+	return s, nil
+}
+
+// jsonSchema returns a JSON Schema describing the values of t with the given format
+func (b *schemaBuilder) jsonSchema(t reflect.Type, format string) (*jsonSchema, error) {
+	t = derefType(t)
+	if d := b.indexer.Get(t); d != nil && d.UnmarshalsOpaquely() {
+		return &jsonSchema{}, nil
+	}
+	if vs, ok := b.valueSchema(t, format); ok {
+		return jsonValueSchema(vs), nil
+	}
+
+	if b.building[t] {
+		return &jsonSchema{Ref: "#/$defs/" + jsonPointerEscape(b.id(t))}, nil
+	}
+	b.building[t] = true
+	defer delete(b.building, t)
+
+	s := &jsonSchema{}
+	switch t.Kind() {
+	case reflect.Struct:
+		d := b.indexer.Get(t)
+		if d == nil {
+			return nil, fmt.Errorf("cannot describe %s", t)
+		}
+		s.Type = "object"
+		s.Properties = &jsonProperties{}
+		for _, name := range d.StructFieldNameList {
+			if name == "-" {
+				continue
+			}
+			fld := d.StructFields[name]
+			ps, err := b.jsonFieldSchema(fieldType(t, fld), fld)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties.add(name, ps)
+			if fld.IsRequired() {
+				s.Required = append(s.Required, name)
+			}
+		}
+		if d.UnknownStructField == nil {
+			s.AdditionalProperties = false
+		}
+
+	case reflect.Map:
+		es, err := b.jsonSchema(t.Elem(), "")
+		if err != nil {
+			return nil, err
+		}
+		s.Type = "object"
+		s.AdditionalProperties = es
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && format != "array" {
+			vs, _, _ := byteSliceSchema(format)
+			if format == "" {
+				vs = &valueSchema{types: []string{"string"}, format: "base64"}
+			}
+			return jsonValueSchema(vs), nil
+		}
+		es, err := b.jsonSchema(t.Elem(), "")
+		if err != nil {
+			return nil, err
+		}
+		s.Type = "array"
+		s.Items = es
+
+	default:
+		return nil, fmt.Errorf("cannot describe %s", t)
+	}
+
+	if _, recursive := b.ids[t]; recursive {
+		// t refers to itself; move its schema to the definitions and refer to it
+		b.defs[b.id(t)] = s
+		return &jsonSchema{Ref: "#/$defs/" + jsonPointerEscape(b.id(t))}, nil
+	}
+	return s, nil
+}
+
+// jsonPointerEscape escapes s for use as a JSON Pointer reference token
+func jsonPointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// JSONSchemaFor returns a JSON Schema describing t, which must be a struct or map type or a pointer to one, for use by
+// tools that support JSON Schema but not KDL Schema.
+//
+// The schema describes the values that Unmarshal stores in t as a tree of JSON objects, keyed by the same names as the
+// nodes and properties in the corresponding KDL document.
+func JSONSchemaFor(t reflect.Type) ([]byte, error) {
+	t = derefType(t)
+	if t == nil || (t.Kind() != reflect.Struct && t.Kind() != reflect.Map) {
+		return nil, ErrStructOrMap
+	}
+	b := newSchemaBuilder()
+	s, err := b.jsonSchema(t, "")
+	if err != nil {
+		return nil, err
+	}
+
+	root := &jsonSchema{Schema: "https://json-schema.org/draft/2020-12/schema"}
+	if s.Ref != "" {
+		root.Ref = s.Ref
+	} else {
+		schema := *s
+		schema.Schema = root.Schema
+		root = &schema
+	}
+	if len(b.defs) > 0 {
+		root.Defs = &jsonProperties{}
+		ids := make([]string, 0, len(b.defs))
+		for id := range b.defs {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			root.Defs.add(id, b.defs[id])
+		}
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
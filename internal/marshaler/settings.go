@@ -0,0 +1,402 @@
+package marshaler
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// Setting describes a struct field holding a single value, identified by the names of the nodes leading to it from the
+// top level of a document
+type Setting struct {
+	// Path holds the names of the nodes leading to the value; unless Arg is true, the last name is that of the node or
+	// property holding it
+	Path []string
+	// Arg is true if the value is the argument of the node named by the last element of Path
+	Arg bool
+	// Types lists the types of the values accepted for the field ("string", "number", or "boolean"), or is empty if
+	// any value is accepted
+	Types []string
+	// Integer is true if the only numbers accepted for the field are integers
+	Integer bool
+
+	// fields holds the fields leading to the value from the struct passed to Settings, ending with the field itself
+	fields []*structFieldDetails
+	c      *unmarshalContext
+}
+
+// Accepts returns true if values of type typ ("string", "number", or "boolean") are accepted for s
+func (s *Setting) Accepts(typ string) bool {
+	return len(s.Types) == 0 || slices.Contains(s.Types, typ)
+}
+
+// In returns true if doc supplies a value for s
+func (s *Setting) In(doc *document.Document) bool {
+	caseSensitive := s.c.opts.CaseSensitive
+	var parents []*document.Node
+	nodes := doc.Nodes
+	for i, name := range s.Path {
+		var matched []*document.Node
+		for _, n := range nodes {
+			if normalizeKey(n.Name.ValueString(), caseSensitive) == name {
+				matched = append(matched, n)
+			}
+		}
+
+		if i == len(s.Path)-1 {
+			if s.Arg {
+				for _, n := range matched {
+					if len(n.Arguments) > 0 {
+						return true
+					}
+				}
+				return false
+			}
+			if len(matched) > 0 {
+				return true
+			}
+			for _, p := range parents {
+				for key := range p.Properties.Unordered() {
+					if normalizeKey(key, caseSensitive) == name {
+						return true
+					}
+				}
+			}
+			return false
+		}
+
+		parents = matched
+		nodes = nil
+		for _, n := range matched {
+			nodes = append(nodes, n.Children...)
+		}
+	}
+	return false
+}
+
+// field returns the field described by s in v, a pointer to a value of the struct type passed to Settings; nil
+// pointers leading to the field are allocated if create is true, and otherwise false is returned
+func (s *Setting) field(v reflect.Value, create bool) (reflect.Value, bool) {
+	for _, fld := range s.fields {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !create {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = fld.GetValueFrom(v)
+	}
+	return v, true
+}
+
+// Set sets the field described by s in v, a pointer to a value of the struct type passed to Settings, to value,
+// converting it as Unmarshal would convert a value in a document; nil pointers leading to the field are allocated
+func (s *Setting) Set(v reflect.Value, value interface{}) error {
+	field, _ := s.field(v, true)
+	_, err := setReflectValueFromDocumentValue(s.c, field, &document.Value{Value: value}, s.fields[len(s.fields)-1].Format)
+	return err
+}
+
+// SetDefault sets the field described by s in v, a pointer to a value of the struct type passed to Settings, to the
+// value given by its ",default:..." tag option if it has one and the field is zero, as Unmarshal does for fields absent
+// from a document; fields within nil pointers are left unset
+func (s *Setting) SetDefault(v reflect.Value) error {
+	fld := s.fields[len(s.fields)-1]
+	if !fld.HasDefault {
+		return nil
+	}
+	field, ok := s.field(v, false)
+	if !ok || !field.IsZero() {
+		return nil
+	}
+	if _, err := setReflectValueFromIntf(s.c, field, fld.Default, fld.Format); err != nil {
+		return fmt.Errorf("invalid default value %q: %w", fld.Default, err)
+	}
+	return nil
+}
+
+// Check checks the field described by s in v, a pointer to a value of the struct type passed to Settings, against the
+// constraints declared by its tag options, as Unmarshal checks a value in a document; fields within nil pointers are
+// not checked
+func (s *Setting) Check(v reflect.Value) error {
+	fld := s.fields[len(s.fields)-1]
+	if fld.Rules == nil {
+		return nil
+	}
+	field, ok := s.field(v, false)
+	if !ok {
+		return nil
+	}
+	structType := derefType(v.Type())
+	for _, f := range s.fields[:len(s.fields)-1] {
+		structType = derefType(fieldType(structType, f))
+	}
+	report := func(err error, pos document.Position, key string) error {
+		return s.c.fieldError(err, pos, key, structType, fld)
+	}
+	return checkField(s.c, report, s.Path[len(s.Path)-1], fld, field, nil, document.Position{})
+}
+
+// Settings returns the settings of the struct type t (or pointer to a struct type) as unmarshaled with opts: the
+// fields holding single values that are unmarshaled from properties, from the arguments of child nodes, or from the
+// sole ",arg" field of a struct field, recursively. Fields tagged ",multiple", maps, slices, and types with their own
+// unmarshalers are not descended into.
+func Settings(t reflect.Type, opts UnmarshalOptions) ([]Setting, error) {
+	c := &unmarshalContext{opts: opts}
+	c.indexer = newTypeIndexer(opts.CaseSensitive, nil, opts.Unmarshalers)
+	return contextSettings(c, t)
+}
+
+// contextSettings returns the settings of the struct type t per Settings, which are set with c
+func contextSettings(c *unmarshalContext, t reflect.Type) ([]Setting, error) {
+	if t == nil || derefType(t).Kind() != reflect.Struct {
+		return nil, ErrStructOrMap
+	}
+
+	b := newSchemaBuilder()
+	b.indexer = c.indexer
+	settings, err := b.settings(t, nil, nil, nil)
+	for i := range settings {
+		settings[i].c = c
+	}
+	return settings, err
+}
+
+// settings appends the settings of the struct type t, whose nodes are found at path and which is reached via fields,
+// to settings
+func (b *schemaBuilder) settings(t reflect.Type, path []string, fields []*structFieldDetails, settings []Setting) ([]Setting, error) {
+	t = derefType(t)
+	if b.building[t] {
+		// t is recursive; its settings would have infinitely long paths
+		return settings, nil
+	}
+	b.building[t] = true
+	defer delete(b.building, t)
+
+	if _, err := b.indexer.index(t); err != nil {
+		return nil, err
+	}
+	d := b.indexer.Get(t)
+	if d == nil || d.UnmarshalsOpaquely() {
+		return settings, nil
+	}
+
+	if argFields := d.StructAttrs["arg"]; len(path) > 0 && len(argFields) == 1 && len(d.StructAttrs["args"]) == 0 {
+		if vs, ok := b.valueSchema(fieldType(t, argFields[0]), argFields[0].Format); ok {
+			settings = append(settings, newSetting(path, true, append(slices.Clip(fields), argFields[0]), vs))
+		}
+	}
+
+	for _, name := range d.StructFieldNameList {
+		fld := d.StructFields[name]
+		if name == "-" || fld.IsCapture() || fld.IsMultiple() {
+			continue
+		}
+		ft := fieldType(t, fld)
+		fieldPath := append(slices.Clip(path), name)
+		fieldFields := append(slices.Clip(fields), fld)
+		if vs, ok := b.valueSchema(ft, fld.Format); ok {
+			settings = append(settings, newSetting(fieldPath, false, fieldFields, vs))
+		} else if derefType(ft).Kind() == reflect.Struct {
+			var err error
+			if settings, err = b.settings(ft, fieldPath, fieldFields, settings); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return settings, nil
+}
+
+// newSetting returns a Setting for the value at path, held by the last of fields, described by vs
+func newSetting(path []string, arg bool, fields []*structFieldDetails, vs *valueSchema) Setting {
+	s := Setting{Path: path, Arg: arg, Integer: vs.integer, fields: fields}
+	if !vs.any {
+		s.Types = vs.types
+	}
+	return s
+}
+
+// Layers unmarshals documents into a struct and sets its settings in layers, each overriding the values supplied by the
+// ones before it. The fields tagged ",required" of the struct, and of the structs containing its settings, may be
+// supplied by any layer, so they are not checked until CheckRequired is called once every layer has been applied.
+type Layers struct {
+	// Settings holds the settings of the struct, per Settings
+	Settings []Setting
+
+	c *unmarshalContext
+	// containers holds the paths of the structs containing settings, joined by "."
+	containers map[string]bool
+	// docs holds the documents unmarshaled so far, and set holds the settings set so far
+	docs []*document.Document
+	set  []*Setting
+}
+
+// NewLayers returns the Layers with which to unmarshal into values of the struct type t (or pointer to a struct type)
+// with opts
+func NewLayers(t reflect.Type, opts UnmarshalOptions) (*Layers, error) {
+	c := &unmarshalContext{opts: opts}
+	c.indexer = newTypeIndexer(opts.CaseSensitive, nil, opts.Unmarshalers)
+	settings, err := contextSettings(c, t)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Layers{Settings: settings, c: c, containers: make(map[string]bool)}
+	for _, s := range settings {
+		n := len(s.Path) - 1
+		if s.Arg {
+			n++
+		}
+		for i := 1; i <= n; i++ {
+			l.containers[strings.Join(s.Path[:i], ".")] = true
+		}
+	}
+	return l, nil
+}
+
+// Unmarshal unmarshals doc into v, a pointer to a value of the struct type passed to NewLayers, as UnmarshalWithOptions
+// would, except that the fields tagged ",required" of the struct and of the structs containing its settings are not
+// checked
+func (l *Layers) Unmarshal(doc *document.Document, v interface{}) error {
+	c := *l.c
+	c.deferred = map[*document.Node]bool{nil: true}
+	l.deferNodes(c.deferred, doc.Nodes, nil)
+	if err := unmarshalDocument(&c, doc, v); err != nil {
+		return err
+	}
+	l.docs = append(l.docs, doc)
+	return nil
+}
+
+// deferNodes adds those of nodes, found at path, from which structs containing settings are unmarshaled to deferred,
+// along with those of their descendants from which such structs are unmarshaled
+func (l *Layers) deferNodes(deferred map[*document.Node]bool, nodes []*document.Node, path []string) {
+	for _, n := range nodes {
+		p := append(slices.Clip(path), normalizeKey(n.Name.ValueString(), l.c.opts.CaseSensitive))
+		if l.containers[strings.Join(p, ".")] {
+			deferred[n] = true
+			l.deferNodes(deferred, n.Children, p)
+		}
+	}
+}
+
+// Set sets the field described by s, one of l.Settings, in v, a pointer to a value of the struct type passed to
+// NewLayers, to value per Setting.Set
+func (l *Layers) Set(s *Setting, v reflect.Value, value interface{}) error {
+	if err := s.Set(v, value); err != nil {
+		return err
+	}
+	l.set = append(l.set, s)
+	return nil
+}
+
+// CheckRequired returns an error if any field tagged ",required" of v, a pointer to a value of the struct type passed
+// to NewLayers, or of a struct containing its settings that was supplied by a layer, was not supplied by any layer; if
+// CollectErrors is set, an error is returned for each such field as an UnmarshalErrors
+func (l *Layers) CheckRequired(v reflect.Value) error {
+	var errs UnmarshalErrors
+	if err := l.checkRequired(v, nil, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRequired checks the fields tagged ",required" of v, the struct at path, and those of the structs it contains
+// that hold settings, per CheckRequired; errors are appended to errs if CollectErrors is set, and otherwise returned
+func (l *Layers) checkRequired(v reflect.Value, path []string, errs *UnmarshalErrors) error {
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	typeDetails, err := l.c.indexer.Lookup(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, name := range typeDetails.StructCheckedFieldNames {
+		fld := typeDetails.StructFields[name]
+		if !fld.IsRequired() || l.supplied(typeDetails, path, name, fld) {
+			continue
+		}
+		// the error describes the field as found in a node unless its struct is at the top level
+		var node *document.Node
+		if len(path) > 0 {
+			node = &document.Node{}
+		}
+		err := &UnmarshalError{
+			Path:  strings.Join(append(slices.Clip(path), name), " > "),
+			Field: structFieldName(v.Type(), fld),
+			Type:  structField(v.Type(), fld).Type,
+			Err:   errMissingField(name, fld, node),
+		}
+		if !l.c.opts.CollectErrors {
+			return err
+		}
+		*errs = append(*errs, err)
+	}
+
+	for _, name := range typeDetails.StructFieldNameList {
+		fld := typeDetails.StructFields[name]
+		p := append(slices.Clip(path), name)
+		if name == "-" || !l.containers[strings.Join(p, ".")] || !l.supplied(typeDetails, path, name, fld) {
+			continue
+		}
+		if err := l.checkRequired(fld.GetValueFrom(v), p, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// supplied returns true if any layer supplied the field named name (and described by fld) of the struct at path,
+// which is described by typeDetails
+func (l *Layers) supplied(typeDetails *typeDetails, path []string, name string, fld *structFieldDetails) bool {
+	for _, doc := range l.docs {
+		if len(path) == 0 {
+			if len(fieldSources(l.c, typeDetails.StructAttrs["arg"], name, fld, nil, doc.Nodes)) > 0 {
+				return true
+			}
+			continue
+		}
+		for _, n := range nodesAt(doc.Nodes, path, l.c.opts.CaseSensitive) {
+			if len(fieldSources(l.c, typeDetails.StructAttrs["arg"], name, fld, n, n.Children)) > 0 {
+				return true
+			}
+		}
+	}
+	for _, s := range l.set {
+		if len(s.fields) > len(path) && s.fields[len(path)] == fld && slices.Equal(s.Path[:len(path)], path) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodesAt returns the nodes found at path among nodes and their descendants
+func nodesAt(nodes []*document.Node, path []string, caseSensitive bool) []*document.Node {
+	for i, name := range path {
+		var matched []*document.Node
+		for _, n := range nodes {
+			if normalizeKey(n.Name.ValueString(), caseSensitive) == name {
+				matched = append(matched, n)
+			}
+		}
+		if i == len(path)-1 {
+			return matched
+		}
+		nodes = nil
+		for _, n := range matched {
+			nodes = append(nodes, n.Children...)
+		}
+	}
+	return nil
+}
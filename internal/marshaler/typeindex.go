@@ -9,8 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"slices"
-	"strings"
-	"sync/atomic"
+	"sync"
 
 	"github.com/sblinch/kdl-go/document"
 )
@@ -31,6 +30,13 @@ type structFieldDetails struct {
 	EmbedIndex []int // if non-nil, the index(es) of the embedded struct to which FieldIndex refers
 	Format     string
 	Attrs      structFieldAttrs
+	// Default is the value assigned to the field if it is absent from the document, if HasDefault is true
+	Default    string
+	HasDefault bool
+	// Rules holds the constraints declared by the field's tag options, if any
+	Rules *fieldRules
+	// Annotation is the type annotation declared by the field's ",type:..." tag option, if any
+	Annotation document.TypeAnnotation
 }
 
 func (f *structFieldDetails) GetValueFrom(structVal reflect.Value) reflect.Value {
@@ -44,10 +50,23 @@ func (f *structFieldDetails) GetValueFrom(structVal reflect.Value) reflect.Value
 	return structVal.Field(f.FieldIndex)
 }
 
+// structField returns the field described by f in the struct type t
+func structField(t reflect.Type, f *structFieldDetails) reflect.StructField {
+	t = derefType(t)
+	for _, i := range f.EmbedIndex {
+		t = derefType(t.Field(i).Type)
+	}
+	return t.Field(f.FieldIndex)
+}
+
 func (f *structFieldDetails) IsMultiple() bool {
 	return f.Attrs.Has("multiple")
 }
 
+func (f *structFieldDetails) IsRequired() bool {
+	return f.Attrs.Has("required")
+}
+
 func (f *structFieldDetails) IsCapture() bool {
 	for _, v := range f.Attrs {
 		switch v {
@@ -58,50 +77,54 @@ func (f *structFieldDetails) IsCapture() bool {
 	return false
 }
 
-type CustomArshalerFlags uint8
-
-func (c CustomArshalerFlags) Has(f CustomArshalerFlags) bool {
-	return (c & f) != 0
-}
-
-const (
-	hasCustomUnmarshaler CustomArshalerFlags = 1 << iota
-	hasCustomValueUnmarshaler
-	hasCustomMarshaler
-	hasCustomValueMarshaler
-)
-
 type typeDetails struct {
 	StructFields              map[string]*structFieldDetails   // if this is a struct type, this is an index of the field names and their indexes
 	StructAttrs               map[string][]*structFieldDetails // if this is a struct type, this is map of attribute names to a list of fields that have this attribute
 	StructFieldNameList       []string                         // if this is a struct type, this is a list of field names in order
 	StructureStructField      *structFieldDetails              // if this is a struct type that includes a "kdl:,structure" field, this identifies that field
+	UnknownStructField        *structFieldDetails              // if this is a struct type that includes a "kdl:,unknown" field, this identifies that field
+	StructCheckedFieldNames   []string                         // if this is a struct type, this is a list of the names of fields that are required, have defaults, or have constraints or type annotations, in order
 	TextUnmarshalerMethod     int16                            // index of the UnmarshalText method, if this type satisfies the encoding.TextUnmarshaler interface
 	KDLUnmarshalerMethod      int16                            // index of the UnmarshalKDL method, if this type satisfies the kdl.Unmarshaler interface
 	KDLValueUnmarshalerMethod int16                            // index of the UnmarshalKDLValue method, if this type satisfies the kdl.ValueUnmarshaler interface
 	TextMarshalerMethod       int16                            // index of the MarshalText method, if this type satisfies the encoding.TextMarshaler interface
 	KDLMarshalerMethod        int16                            // index of the MarshalKDL method, if this type satisfies the kdl.Marshaler interface
 	KDLValueMarshalerMethod   int16                            // index of the MarshalKDLValue method, if this type satisfies the kdl.ValueMarshaler interface
-	CustomArshalers           CustomArshalerFlags
+	GeneratedUnmarshaler      bool                             // true if this type's UnmarshalKDL method was generated by kdl-gen-marshal
+	GeneratedMarshaler        bool                             // true if this type's MarshalKDL method was generated by kdl-gen-marshal
+	custom                    *customFuncs                     // custom (un)marshaling functions that apply to this type; only set on copies returned by typeIndexer.Get, or to the built-in functions for standard library types
 }
 
 func (t *typeDetails) CanUnmarshalText() bool {
 	return t.TextUnmarshalerMethod != -1
 }
 func (t *typeDetails) CanUnmarshalKDL() bool {
-	return t.KDLUnmarshalerMethod != -1 || t.CustomArshalers.Has(hasCustomUnmarshaler)
+	return t.KDLUnmarshalerMethod != -1 || (t.custom != nil && t.custom.unmarshal != nil)
 }
 func (t *typeDetails) CanUnmarshalKDLValue() bool {
-	return t.KDLValueUnmarshalerMethod != -1 || t.CustomArshalers.Has(hasCustomValueUnmarshaler)
+	return t.KDLValueUnmarshalerMethod != -1 || (t.custom != nil && t.custom.valueUnmarshal != nil)
 }
 func (t *typeDetails) CanMarshalText() bool {
 	return t.TextMarshalerMethod != -1
 }
 func (t *typeDetails) CanMarshalKDL() bool {
-	return t.KDLMarshalerMethod != -1 || t.CustomArshalers.Has(hasCustomMarshaler)
+	return t.KDLMarshalerMethod != -1 || (t.custom != nil && t.custom.marshal != nil)
 }
 func (t *typeDetails) CanMarshalKDLValue() bool {
-	return t.KDLValueMarshalerMethod != -1 || t.CustomArshalers.Has(hasCustomValueMarshaler)
+	return t.KDLValueMarshalerMethod != -1 || (t.custom != nil && t.custom.valueMarshal != nil)
+}
+
+// UnmarshalsOpaquely returns true if the type unmarshals itself from a node by means other than its fields: a custom
+// unmarshaler, or an UnmarshalKDL method other than one generated by kdl-gen-marshal (which unmarshals the fields
+// exactly as kdl-go does)
+func (t *typeDetails) UnmarshalsOpaquely() bool {
+	return (t.KDLUnmarshalerMethod != -1 && !t.GeneratedUnmarshaler) || (t.custom != nil && t.custom.unmarshal != nil)
+}
+
+// MarshalsOpaquely returns true if the type marshals itself into a node by means other than its fields; see
+// UnmarshalsOpaquely
+func (t *typeDetails) MarshalsOpaquely() bool {
+	return (t.KDLMarshalerMethod != -1 && !t.GeneratedMarshaler) || (t.custom != nil && t.custom.marshal != nil)
 }
 
 func newTypeDetails() *typeDetails {
@@ -217,25 +240,47 @@ func (t *typeDetails) GetStructure(structValue reflect.Value) *structStructure {
 	return t.getStructureStructField(structValue, false)
 }
 
-type typeIndexer struct {
-	index         map[string]*typeDetails
+// typeCacheKey identifies a type in typeCache; field names are normalized differently depending on case-sensitivity,
+// so each type is indexed separately for each mode
+type typeCacheKey struct {
+	typ           reflect.Type
 	caseSensitive bool
 }
 
-var createdTypeIndexer atomic.Bool
+var (
+	// typeCache holds the *typeDetails for every type indexed by any Marshal/Unmarshal call, keyed by typeCacheKey;
+	// details are never modified once stored, so they may be shared freely between goroutines
+	typeCache sync.Map
+	// typeCacheMu serializes indexing, so that each type is indexed only once and recursive types are indexed safely
+	typeCacheMu sync.Mutex
+)
 
-func newTypeIndexer(caseSensitive bool) *typeIndexer {
-	createdTypeIndexer.CompareAndSwap(false, true)
+type typeIndexer struct {
+	caseSensitive bool
+	// marshalers and unmarshalers list the registries of custom functions to consult, in order of precedence
+	marshalers   []*Marshalers
+	unmarshalers []*Unmarshalers
+	// custom holds copies of the details of types that have custom functions, with those functions attached
+	custom map[reflect.Type]*typeDetails
+	// pending holds the details of types indexed by the current call to index, until all of them are complete
+	pending map[reflect.Type]*typeDetails
+}
+
+func newTypeIndexer(caseSensitive bool, marshalers *Marshalers, unmarshalers *Unmarshalers) *typeIndexer {
 	return &typeIndexer{
-		index:         make(map[string]*typeDetails),
 		caseSensitive: caseSensitive,
+		marshalers:    []*Marshalers{marshalers, defaultMarshalers},
+		unmarshalers:  []*Unmarshalers{unmarshalers, defaultUnmarshalers},
 	}
 }
 
 func (i *typeIndexer) Dump() {
-	for k, v := range i.index {
-		fmt.Printf("[%s](%d)=%#v\n", k, len(k), v)
-	}
+	typeCache.Range(func(k, v interface{}) bool {
+		if key := k.(typeCacheKey); key.caseSensitive == i.caseSensitive {
+			fmt.Printf("[%s]=%#v\n", key.typ, v)
+		}
+		return true
+	})
 }
 
 func Debug(s string, v ...interface{}) {
@@ -243,10 +288,50 @@ func Debug(s string, v ...interface{}) {
 	// fmt.Println()
 }
 
-// Indexes a type and (in the case of structs, maps, pointers, etc.) any of the types it contains
-func (i *typeIndexer) indexType(typ reflect.Type) error {
+// derefType returns the type ultimately pointed to by typ
+func derefType(typ reflect.Type) reflect.Type {
 	for ; typ != nil && typ.Kind() == reflect.Ptr; typ = typ.Elem() {
 	}
+	return typ
+}
+
+// lookup returns the details for typ if it has already been indexed, or is being indexed by the current call to index
+func (i *typeIndexer) lookup(typ reflect.Type) *typeDetails {
+	if v, ok := typeCache.Load(typeCacheKey{typ: typ, caseSensitive: i.caseSensitive}); ok {
+		return v.(*typeDetails)
+	}
+	return i.pending[typ]
+}
+
+// index returns the details for typ, indexing it and any types it contains and adding them to typeCache if it has
+// not already been indexed
+func (i *typeIndexer) index(typ reflect.Type) (*typeDetails, error) {
+	typ = derefType(typ)
+	if typ == nil {
+		return nil, nil
+	}
+	if v, ok := typeCache.Load(typeCacheKey{typ: typ, caseSensitive: i.caseSensitive}); ok {
+		return v.(*typeDetails), nil
+	}
+
+	typeCacheMu.Lock()
+	defer typeCacheMu.Unlock()
+
+	i.pending = make(map[reflect.Type]*typeDetails)
+	defer func() { i.pending = nil }()
+	if err := i.indexType(typ); err != nil {
+		// types indexed before the error may be incomplete, so none of them are cached
+		return nil, err
+	}
+	for t, d := range i.pending {
+		typeCache.Store(typeCacheKey{typ: t, caseSensitive: i.caseSensitive}, d)
+	}
+	return i.lookup(typ), nil
+}
+
+// Indexes a type and (in the case of structs, maps, pointers, etc.) any of the types it contains
+func (i *typeIndexer) indexType(typ reflect.Type) error {
+	typ = derefType(typ)
 	Debug("  indexType: %s", typ)
 
 	if typ == nil {
@@ -254,49 +339,26 @@ func (i *typeIndexer) indexType(typ reflect.Type) error {
 	}
 
 	typName := typ.String()
-	if _, exists := i.index[typName]; exists {
+	if i.lookup(typ) != nil {
 		Debug("    already indexed, skipping")
 		return nil
 	}
 
 	typeDetails := newTypeDetails()
-	i.index[typName] = typeDetails
-
-	if _, ok := customUnmarshalers[typ]; ok {
-		typeDetails.CustomArshalers |= hasCustomUnmarshaler
-	}
-	if _, ok := customValueUnmarshalers[typ]; ok {
-		typeDetails.CustomArshalers |= hasCustomValueUnmarshaler
-	}
-
-	if _, ok := customMarshalers[typ]; ok {
-		typeDetails.CustomArshalers |= hasCustomMarshaler
-	}
-	if _, ok := customValueMarshalers[typ]; ok {
-		typeDetails.CustomArshalers |= hasCustomValueMarshaler
-	}
+	i.pending[typ] = typeDetails
 
 	ptrTyp := reflect.PointerTo(typ)
 
-	if _, ok := customUnmarshalers[ptrTyp]; ok {
-		typeDetails.CustomArshalers |= hasCustomUnmarshaler
-	}
-	if _, ok := customValueUnmarshalers[ptrTyp]; ok {
-		typeDetails.CustomArshalers |= hasCustomValueUnmarshaler
-	}
-
-	if _, ok := customMarshalers[ptrTyp]; ok {
-		typeDetails.CustomArshalers |= hasCustomMarshaler
-	}
-	if _, ok := customValueMarshalers[ptrTyp]; ok {
-		typeDetails.CustomArshalers |= hasCustomValueMarshaler
-	}
-
 	if ptrTyp.NumMethod() > 0 {
 		Debug("    have methods on type %s", typName)
 		v := reflect.New(typ)
 		intf := v.Interface()
 
+		// types with methods generated by kdl-gen-marshal are unmarshaled and marshaled via their UnmarshalKDL and
+		// MarshalKDL methods like any other; see callGenerated
+		_, typeDetails.GeneratedUnmarshaler = intf.(GenUnmarshaler)
+		_, typeDetails.GeneratedMarshaler = intf.(GenMarshaler)
+
 		for i := 0; i < ptrTyp.NumMethod(); i++ {
 			switch ptrTyp.Method(i).Name {
 			case "UnmarshalText":
@@ -330,6 +392,12 @@ func (i *typeIndexer) indexType(typ reflect.Type) error {
 		Debug("    have no methods on type %s", typ.String())
 	}
 
+	if f := builtinFuncs[typ]; f != nil {
+		// standard library types with built-in functions are (un)marshaled as values, so their fields are not indexed
+		typeDetails.custom = f
+		return nil
+	}
+
 	switch typ.Kind() {
 	case reflect.Map:
 		Debug("    this is a map: Map's key type is: %s, value type is: %s\n", typ.Key().String(), typ.Elem().String())
@@ -359,8 +427,35 @@ func (i *typeIndexer) indexType(typ reflect.Type) error {
 	return nil
 }
 
+// newStructFieldDetails returns the details of the field at index n (within the embedded struct identified by
+// embedIndexes, if any) with the given tag attributes
+func newStructFieldDetails(n int, embedIndexes []int, attrs []string) *structFieldDetails {
+	fld := &structFieldDetails{
+		FieldIndex: n,
+		EmbedIndex: embedIndexes,
+		Attrs:      attrs,
+	}
+	fld.Format, _ = fld.Attrs.Value("format")
+	fld.Default, fld.HasDefault = fld.Attrs.Value("default")
+	if annotation, ok := fld.Attrs.Value("type"); ok {
+		fld.Annotation = document.TypeAnnotation(annotation)
+	}
+	return fld
+}
+
 var errUnexportedStructure = errors.New("fields tagged kdl:\",structure\" must be exported")
 
+var (
+	errMultipleUnknown = errors.New("only one field may be tagged kdl:\",unknown\"")
+	nodeSliceType      = reflect.TypeOf([]*document.Node(nil))
+)
+
+// isUnknownFieldType returns true if a field of type t may be tagged ",unknown"; such fields must be of type
+// []*document.Node, or a map with string keys
+func isUnknownFieldType(t reflect.Type) bool {
+	return t == nodeSliceType || (t.Kind() == reflect.Map && t.Key().Kind() == reflect.String)
+}
+
 func (i *typeIndexer) indexStructFields(typ reflect.Type, typeDetails *typeDetails, embedIndexes []int) error {
 	numFields := typ.NumField()
 	for n := 0; n < numFields; n++ {
@@ -387,26 +482,32 @@ func (i *typeIndexer) indexStructFields(typ reflect.Type, typeDetails *typeDetai
 		normalized := fieldTagOrName(field.Tag, field.Name, i.caseSensitive)
 		Debug("  field %s (normalized %s, type %s) is at index %d", field.Name, normalized, ft.String(), n)
 
-		fld := &structFieldDetails{
-			FieldIndex: n,
-			EmbedIndex: embedIndexes,
-			Format:     "",
-			Attrs:      nil,
+		fld := newStructFieldDetails(n, embedIndexes, attrs)
+		rules, err := parseFieldRules(fld.Attrs, ft, fld.Format)
+		if err != nil {
+			return fmt.Errorf("field %s.%s: %w", typ.Name(), field.Name, err)
 		}
-
-		fld.Attrs = attrs
+		fld.Rules = rules
 		for _, name := range fld.Attrs {
-			if strings.HasPrefix(name, "format:") {
-				fld.Format = strings.TrimPrefix(name, "format:")
-			}
 			typeDetails.StructAttrs[name] = append(typeDetails.StructAttrs[name], fld)
 		}
 
 		if structure {
 			typeDetails.StructureStructField = fld
+		} else if slices.Contains(attrs, "unknown") {
+			if typeDetails.UnknownStructField != nil {
+				return errMultipleUnknown
+			}
+			if !isUnknownFieldType(ft) {
+				return fmt.Errorf("field %s.%s is tagged kdl:\",unknown\" and must be a []*document.Node or a map with string keys, but is a %s", typ.Name(), field.Name, ft)
+			}
+			typeDetails.UnknownStructField = fld
 		} else {
 			typeDetails.StructFields[normalized] = fld
 			typeDetails.StructFieldNameList = append(typeDetails.StructFieldNameList, normalized)
+			if fld.IsRequired() || fld.HasDefault || fld.Rules != nil || fld.Annotation != "" {
+				typeDetails.StructCheckedFieldNames = append(typeDetails.StructCheckedFieldNames, normalized)
+			}
 
 			if err := i.indexType(ft); err != nil {
 				return err
@@ -432,23 +533,68 @@ func (i *typeIndexer) IndexIntf(dest interface{}) error {
 		t = v.Type()
 	}
 
-	return i.indexType(t)
+	_, err := i.index(t)
+	return err
 }
 
-func (i *typeIndexer) Get(name string) *typeDetails {
-	if len(name) == 0 {
+// Get returns the details for typ (or the type it points to), indexing it first if necessary; it returns nil if typ
+// cannot be indexed
+func (i *typeIndexer) Get(typ reflect.Type) *typeDetails {
+	d, err := i.Lookup(typ)
+	if err != nil {
+		Debug("typeIndexer \"%s\" cannot be indexed: %v", typ, err)
 		return nil
 	}
-	if name[0] == '*' {
-		name = name[1:]
+	return d
+}
+
+// Lookup is like Get, but returns the error that prevented typ from being indexed; types such as structs whose details
+// are required to marshal or unmarshal them should be looked up with Lookup
+func (i *typeIndexer) Lookup(typ reflect.Type) (*typeDetails, error) {
+	d, err := i.index(typ)
+	if err != nil || d == nil || !i.hasCustom() {
+		return d, err
 	}
-	if v, ok := i.index[name]; ok {
-		Debug("typeIndexer \"%s\" exists", name)
-		return v
-	} else {
-		Debug("typeIndexer \"%s\" does not exist", name)
-		return nil
+	return i.withCustom(derefType(typ), d), nil
+}
+
+// hasCustom returns true if any custom functions have been registered in the indexer's registries
+func (i *typeIndexer) hasCustom() bool {
+	for _, m := range i.marshalers {
+		if !m.empty() {
+			return true
+		}
+	}
+	for _, u := range i.unmarshalers {
+		if !u.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// withCustom returns d, or a copy of d with the custom functions registered for typ (or a pointer to typ) attached
+func (i *typeIndexer) withCustom(typ reflect.Type, d *typeDetails) *typeDetails {
+	if cd, ok := i.custom[typ]; ok {
+		return cd
+	}
+
+	f := lookupCustomFuncs(typ, i.marshalers, i.unmarshalers)
+	if f == nil {
+		f = lookupCustomFuncs(reflect.PointerTo(typ), i.marshalers, i.unmarshalers)
+	}
+	cd := d
+	if f != nil {
+		cd = new(typeDetails)
+		*cd = *d
+		cd.custom = f
+	}
+
+	if i.custom == nil {
+		i.custom = make(map[reflect.Type]*typeDetails)
 	}
+	i.custom[typ] = cd
+	return cd
 }
 
 func (i *typeIndexer) GetEmpty() *typeDetails {
@@ -0,0 +1,158 @@
+package marshaler
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// resetTypeCache discards all cached type details
+func resetTypeCache() {
+	typeCache.Range(func(k, v interface{}) bool {
+		typeCache.Delete(k)
+		return true
+	})
+}
+
+type benchServer struct {
+	Name    string            `kdl:"name"`
+	Port    int               `kdl:"port"`
+	Enabled bool              `kdl:"enabled"`
+	Tags    []string          `kdl:"tags"`
+	Headers map[string]string `kdl:"headers"`
+}
+
+type benchConfig struct {
+	Title   string                  `kdl:"title"`
+	Version int                     `kdl:"version"`
+	Servers map[string]*benchServer `kdl:"servers"`
+	Backup  *benchServer            `kdl:"backup"`
+	Parent  *benchConfig            `kdl:"parent"`
+}
+
+func newBenchConfig() *benchConfig {
+	return &benchConfig{
+		Title:   "example",
+		Version: 3,
+		Servers: map[string]*benchServer{
+			"alpha": {Name: "alpha", Port: 8080, Enabled: true, Tags: []string{"a", "b"}, Headers: map[string]string{"x": "y"}},
+			"beta":  {Name: "beta", Port: 8081, Tags: []string{"c"}},
+		},
+		Backup: &benchServer{Name: "backup", Port: 9090},
+	}
+}
+
+func TestTypeIndexerCache(t *testing.T) {
+	typ := reflect.TypeOf(benchConfig{})
+
+	a := newTypeIndexer(false, nil, nil).Get(typ)
+	b := newTypeIndexer(false, nil, nil).Get(reflect.PointerTo(typ))
+	if a == nil || a != b {
+		t.Fatalf("expected both indexers to share cached details, got %p and %p", a, b)
+	}
+	if newTypeIndexer(false, nil, nil).Get(reflect.TypeOf(benchServer{})) == nil {
+		t.Errorf("expected nested struct type to be cached")
+	}
+
+	cs := newTypeIndexer(true, nil, nil).Get(typ)
+	if cs == a {
+		t.Errorf("expected separate details for case-sensitive indexer")
+	}
+	if _, ok := cs.StructFields["title"]; !ok {
+		t.Errorf("expected case-sensitive field title, got %v", cs.StructFieldNameList)
+	}
+}
+
+func TestTypeIndexerSameName(t *testing.T) {
+	// both types are named "marshaler.config", so they must be distinguished by reflect.Type rather than by name
+	first := func() reflect.Type {
+		type config struct{ First int }
+		return reflect.TypeOf(config{})
+	}()
+	second := func() reflect.Type {
+		type config struct{ Second int }
+		return reflect.TypeOf(config{})
+	}()
+	if first.String() != second.String() {
+		t.Fatalf("expected identical type names, got %s and %s", first, second)
+	}
+
+	i := newTypeIndexer(false, nil, nil)
+	if _, ok := i.Get(first).StructFields["first"]; !ok {
+		t.Errorf("expected field first in %v", i.Get(first).StructFieldNameList)
+	}
+	if _, ok := i.Get(second).StructFields["second"]; !ok {
+		t.Errorf("expected field second in %v", i.Get(second).StructFieldNameList)
+	}
+}
+
+func TestTypeIndexerConcurrent(t *testing.T) {
+	resetTypeCache()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for n := 0; n < cap(errs); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc := document.New()
+			if err := MarshalWithOptions(newBenchConfig(), doc, MarshalOptions{}); err != nil {
+				errs <- err
+				return
+			}
+			var cfg benchConfig
+			if err := UnmarshalWithOptions(doc, &cfg, UnmarshalOptions{}); err != nil {
+				errs <- err
+				return
+			}
+			if cfg.Servers["alpha"] == nil || cfg.Servers["alpha"].Port != 8080 {
+				t.Errorf("unexpected result %#v", cfg.Servers["alpha"])
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	cfg := newBenchConfig()
+	run := func(b *testing.B, reset bool) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if reset {
+				resetTypeCache()
+			}
+			if err := Marshal(cfg, document.New()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("cached", func(b *testing.B) { run(b, false) })
+	b.Run("uncached", func(b *testing.B) { run(b, true) })
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	doc := document.New()
+	if err := Marshal(newBenchConfig(), doc); err != nil {
+		b.Fatal(err)
+	}
+	run := func(b *testing.B, reset bool) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if reset {
+				resetTypeCache()
+			}
+			var cfg benchConfig
+			if err := Unmarshal(doc, &cfg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("cached", func(b *testing.B) { run(b, false) })
+	b.Run("uncached", func(b *testing.B) { run(b, true) })
+}
@@ -0,0 +1,251 @@
+package marshaler
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+)
+
+// UnionDiscriminator identifies the part of a node that names the concrete type of a union member; see
+// UnionByAnnotation, UnionByProperty, and UnionByArgument
+type UnionDiscriminator struct {
+	// property is the name of the property holding the discriminator, if any
+	property string
+	// argument is the index of the argument holding the discriminator, or -1 if the discriminator is not an argument
+	argument int
+}
+
+// UnionByAnnotation returns a UnionDiscriminator that names a member's type with the node's type annotation, eg:
+// (s3)storage bucket="b"
+func UnionByAnnotation() UnionDiscriminator {
+	return UnionDiscriminator{argument: -1}
+}
+
+// UnionByProperty returns a UnionDiscriminator that names a member's type with the property called name, eg:
+// storage type="s3" bucket="b"
+func UnionByProperty(name string) UnionDiscriminator {
+	return UnionDiscriminator{property: name, argument: -1}
+}
+
+// UnionByArgument returns a UnionDiscriminator that names a member's type with the argument at index, eg:
+// storage "s3" bucket="b"
+func UnionByArgument(index int) UnionDiscriminator {
+	return UnionDiscriminator{argument: index}
+}
+
+// String returns a description of the discriminator for use in error messages
+func (d UnionDiscriminator) String() string {
+	switch {
+	case d.property != "":
+		return fmt.Sprintf("property %q", d.property)
+	case d.argument >= 0:
+		return fmt.Sprintf("argument %d", d.argument)
+	default:
+		return "type annotation"
+	}
+}
+
+// extract returns the discriminator of node and a copy of node from which the discriminator has been removed, so that
+// it is not unmarshaled into the member; it returns false if node has no discriminator
+func (d UnionDiscriminator) extract(node *document.Node) (string, *document.Node, bool) {
+	switch {
+	case d.property != "":
+		v, ok := node.Properties.Get(d.property)
+		if !ok {
+			return "", nil, false
+		}
+		n := node.ShallowCopy()
+		n.Properties = document.Properties{}
+		n.Properties.Alloc()
+		for _, key := range node.Properties.Keys() {
+			if key != d.property {
+				pv, _ := node.Properties.Get(key)
+				n.Properties.Add(key, pv)
+			}
+		}
+		return coerce.ToString(v.ResolvedValue()), n, true
+
+	case d.argument >= 0:
+		if d.argument >= len(node.Arguments) {
+			return "", nil, false
+		}
+		n := node.ShallowCopy()
+		n.Arguments = make([]*document.Value, 0, len(node.Arguments)-1)
+		n.Arguments = append(append(n.Arguments, node.Arguments[:d.argument]...), node.Arguments[d.argument+1:]...)
+		return coerce.ToString(node.Arguments[d.argument].ResolvedValue()), n, true
+
+	default:
+		if node.Type == "" {
+			return "", nil, false
+		}
+		n := node.ShallowCopy()
+		n.Type = ""
+		return string(node.Type), n, true
+	}
+}
+
+// insert adds the discriminator name to node, which was marshaled from a member
+func (d UnionDiscriminator) insert(node *document.Node, name string) {
+	switch {
+	case d.property != "":
+		// the discriminator replaces any property of the same name; like any other property, where it is written among
+		// the others depends on the ordering of document.Properties
+		node.AddProperty(d.property, name, "")
+
+	case d.argument >= 0:
+		i := d.argument
+		if i > len(node.Arguments) {
+			i = len(node.Arguments)
+		}
+		args := make([]*document.Value, 0, len(node.Arguments)+1)
+		args = append(append(args, node.Arguments[:i]...), &document.Value{Value: name})
+		node.Arguments = append(args, node.Arguments[i:]...)
+
+	default:
+		node.Type = document.TypeAnnotation(name)
+	}
+}
+
+// union describes the members of an interface type registered via AddUnion or AddCustomUnion
+type union struct {
+	iface         reflect.Type
+	discriminator UnionDiscriminator
+	// types maps discriminators to the concrete types of members, and names maps them back
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// Unions is a registry of the concrete types that implement interface types, keyed by a discriminator found in each
+// node. A Unions may be assigned to UnmarshalOptions.Unions and MarshalOptions.Unions, in which case its interface
+// types take precedence over those registered globally via AddCustomUnion. Interface types may be added at any time,
+// including concurrently with (un)marshaling.
+type Unions struct {
+	mu     sync.RWMutex
+	count  atomic.Int32
+	unions map[reflect.Type]*union
+}
+
+// NewUnions returns an empty Unions
+func NewUnions() *Unions {
+	return &Unions{}
+}
+
+// AddUnion registers the interface type I in u, such that a node unmarshaled into a value of type I is unmarshaled
+// into a new value of the type of the member of members keyed by the node's discriminator, and a value of type I is
+// marshaled with the key of the member of the same type as its discriminator; the values of members serve only to
+// identify their types. Registering I again replaces its members.
+func AddUnion[I any](u *Unions, discriminator UnionDiscriminator, members map[string]I) {
+	iface := reflect.TypeFor[I]()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("kdl: AddUnion: %s is not an interface type", iface))
+	}
+
+	un := &union{
+		iface:         iface,
+		discriminator: discriminator,
+		types:         make(map[string]reflect.Type, len(members)),
+		names:         make(map[reflect.Type]string, len(members)),
+	}
+	for name, member := range members {
+		typ := reflect.TypeOf(member)
+		if typ == nil {
+			panic(fmt.Sprintf("kdl: AddUnion: member %q of %s is nil", name, iface))
+		}
+		un.types[name] = typ
+		un.names[typ] = name
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.unions == nil {
+		u.unions = make(map[reflect.Type]*union)
+	}
+	u.unions[iface] = un
+	u.count.Add(1)
+}
+
+// empty returns true if no interface types have been registered in u
+func (u *Unions) empty() bool {
+	return u == nil || u.count.Load() == 0
+}
+
+// lookup returns the union registered in u for the interface type typ, or nil if there is none
+func (u *Unions) lookup(typ reflect.Type) *union {
+	if u.empty() {
+		return nil
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.unions[typ]
+}
+
+// defaultUnions holds the interface types registered via AddCustomUnion, and is consulted after the Unions in the
+// options
+var defaultUnions = NewUnions()
+
+// AddCustomUnion registers the interface type I for all Marshal and Unmarshal calls; see AddUnion
+func AddCustomUnion[I any](discriminator UnionDiscriminator, members map[string]I) {
+	AddUnion[I](defaultUnions, discriminator, members)
+}
+
+// lookupUnion returns the union registered for typ in unions or via AddCustomUnion, or nil if typ is not an interface
+// type registered in either
+func lookupUnion(typ reflect.Type, unions *Unions) *union {
+	if typ.Kind() != reflect.Interface {
+		return nil
+	}
+	if un := unions.lookup(typ); un != nil {
+		return un
+	}
+	return defaultUnions.lookup(typ)
+}
+
+// unmarshalNodeToUnion unmarshals node into dest, a value of the interface type described by un, by unmarshaling it
+// into a new value of the member type named by its discriminator
+func unmarshalNodeToUnion(c *unmarshalContext, node *document.Node, dest *reflect.Value, un *union, format string, parentStructure *structStructure) error {
+	name, member, ok := un.discriminator.extract(node)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal node %s into %s: missing %s", node.Name.ValueString(), un.iface, un.discriminator)
+	}
+	typ, ok := un.types[name]
+	if !ok {
+		return fmt.Errorf("cannot unmarshal node %s into %s: unknown type %q", node.Name.ValueString(), un.iface, name)
+	}
+
+	v := reflect.New(typ).Elem()
+	if err := unmarshalNodeToValue(c, member, &v, format, parentStructure); err != nil {
+		return err
+	}
+	if dest.CanSet() {
+		dest.Set(v)
+	} else {
+		iv := reflect.New(un.iface).Elem()
+		iv.Set(v)
+		*dest = iv
+	}
+	return nil
+}
+
+// marshalUnionToNode marshals v, a non-nil value of the interface type described by un, into a node named name that
+// includes the discriminator of v's concrete type
+func marshalUnionToNode(c *marshalContext, name string, v reflect.Value, un *union, fldDetails *structFieldDetails, parentStructure *structStructure) (*document.Node, error) {
+	el := v.Elem()
+	discriminator, ok := un.names[el.Type()]
+	if !ok && el.Kind() == reflect.Ptr {
+		// a pointer to a member registered by value
+		discriminator, ok = un.names[el.Type().Elem()]
+	}
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %s as %s: type is not a registered member", el.Type(), un.iface)
+	}
+	node, err := marshalValueToNode(c, name, el, fldDetails, parentStructure)
+	if err != nil || node == nil {
+		return node, err
+	}
+	un.discriminator.insert(node, discriminator)
+	return node, nil
+}
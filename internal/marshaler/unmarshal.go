@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -32,41 +31,29 @@ type UnmarshalOptions struct {
 	RelaxedNonCompliant    relaxed.Flags
 	CaseSensitive          bool
 	ParseComments          bool
-}
-
-func assertNoIndexers() {
-	if createdTypeIndexer.Load() {
-		f := "AddCustom*"
-
-		rpc := make([]uintptr, 1)
-		n := runtime.Callers(0, rpc)
-		if n >= 1 {
-			frame, _ := runtime.CallersFrames(rpc).Next()
-			f = frame.Function
-		}
-
-		panic("cannot call " + f + " after first call to any Marshal/Unmarshal function")
-	}
-}
-
-var customUnmarshalers = make(map[reflect.Type]func(node *document.Node, v reflect.Value) error)
-var customValueUnmarshalers = make(map[reflect.Type]func(value *document.Value, v reflect.Value, format string) error)
-
-func AddCustomUnmarshaler[T any](unmarshal func(node *document.Node, v reflect.Value) error) {
-	assertNoIndexers()
-	typeForT := reflect.TypeFor[T]()
-	customUnmarshalers[typeForT] = unmarshal
-}
-
-func AddCustomValueUnmarshaler[T any](unmarshal func(value *document.Value, v reflect.Value, format string) error) {
-	assertNoIndexers()
-	typeForT := reflect.TypeFor[T]()
-	customValueUnmarshalers[typeForT] = unmarshal
+	// OmitPositions disables recording of source positions on parsed nodes and values
+	OmitPositions bool
+	// Unmarshalers holds custom unmarshaling functions which take precedence over those registered via
+	// AddCustomUnmarshaler and AddCustomValueUnmarshaler
+	Unmarshalers *Unmarshalers
+	// CollectErrors continues unmarshaling past failures to unmarshal individual nodes, arguments, and properties, and
+	// returns all of them as an UnmarshalErrors
+	CollectErrors bool
+	// Unions holds interface types whose concrete types are chosen by a discriminator in each node, which take
+	// precedence over those registered via AddCustomUnion
+	Unions *Unions
 }
 
 type unmarshalContext struct {
 	indexer *typeIndexer
 	opts    UnmarshalOptions
+	// gen is the decoder passed to generated UnmarshalKDLWith and UnmarshalKDLNodes methods; see decoder
+	gen *GenDecoder
+	// errs accumulates errors if CollectErrors is set
+	errs *errorCollector
+	// deferred holds the nodes whose children's structs are unmarshaled without checking their fields tagged
+	// ",required", with nil standing for the nodes of the document itself; see Layers
+	deferred map[*document.Node]bool
 }
 
 // inStrSlice returns true if s is contained in ss
@@ -79,6 +66,30 @@ func inStrSlice(ss []string, s string) bool {
 	return false
 }
 
+// errUnexpectedArgs returns the error reported when node has more arguments than the destination can accept
+func errUnexpectedArgs(node *document.Node) error {
+	return fmt.Errorf("%s has unexpected arguments", node.Name.ValueString())
+}
+
+// errUnexpectedProps returns the error reported when node has properties that the destination cannot accept
+func errUnexpectedProps(node *document.Node) error {
+	return fmt.Errorf("%s has unexpected properties %s", node.Name.ValueString(), strings.Join(node.Properties.Keys(), ", "))
+}
+
+// errNoStructField returns the error reported when a struct has no field into which to unmarshal the node named name
+func errNoStructField(name string) error {
+	return fmt.Errorf("no struct field into which to unmarshal node %q", name)
+}
+
+// wrapNodeError prefixes e with the name of node, if any
+func wrapNodeError(c *unmarshalContext, node *document.Node, e error) error {
+	// collected errors identify the node by their path instead
+	if e != nil && node != nil && node.Name != nil && c.errs == nil {
+		return fmt.Errorf("%s: %w", node.Name.NodeNameString(), e)
+	}
+	return e
+}
+
 // verifyArgsPropsChildren returns an error if the node does not contain the expected number of args or the expected properties
 func verifyArgsPropsChildren(c *unmarshalContext, node *document.Node, expectedArgs int, expectedProps []string, allowChildren bool) error {
 	argCount := len(node.Arguments)
@@ -114,12 +125,7 @@ func verifyArgsPropsChildren(c *unmarshalContext, node *document.Node, expectedA
 		}
 
 	} else if !c.opts.AllowUnhandledProps && node.Properties.Len() > 0 {
-		var extraProps []string
-		for name := range node.Properties.Unordered() {
-			extraProps = append(extraProps, name)
-		}
-
-		return fmt.Errorf("%s has unexpected properties %s", node.Name.ValueString(), strings.Join(extraProps, ", "))
+		return errUnexpectedProps(node)
 	}
 
 	if !allowChildren && len(node.Children) > 0 {
@@ -242,12 +248,12 @@ func unmarshalValueTime(c *unmarshalContext, destTime reflect.Value, val interfa
 
 func unmarshalIntfWithUnmarshaler(c *unmarshalContext, dest reflect.Value, v interface{}, format string) (bool, error) {
 	destType := dest.Type()
-	if typeDetails := c.indexer.Get(destType.String()); typeDetails != nil {
+	if typeDetails := c.indexer.Get(destType); typeDetails != nil {
 		if typeDetails.CanUnmarshalKDLValue() {
 			var err error
 			dv := &document.Value{Value: v}
-			if typeDetails.CustomArshalers.Has(hasCustomValueUnmarshaler) {
-				err = customValueUnmarshalers[destType](dv, dest, format)
+			if typeDetails.custom != nil && typeDetails.custom.valueUnmarshal != nil {
+				err = typeDetails.custom.valueUnmarshal(dv, typeDetails.custom.value(dest), format)
 			} else {
 				_, err = callStructMethod(dest, typeDetails.KDLValueUnmarshalerMethod, reflect.ValueOf(dv))
 			}
@@ -263,11 +269,11 @@ func unmarshalIntfWithUnmarshaler(c *unmarshalContext, dest reflect.Value, v int
 
 func unmarshalDocumentValueWithUnmarshaler(c *unmarshalContext, dest reflect.Value, dv *document.Value, format string) (bool, error) {
 	destType := dest.Type()
-	if typeDetails := c.indexer.Get(destType.String()); typeDetails != nil {
+	if typeDetails := c.indexer.Get(destType); typeDetails != nil {
 		if typeDetails.CanUnmarshalKDLValue() {
 			var err error
-			if typeDetails.CustomArshalers.Has(hasCustomValueUnmarshaler) {
-				err = customValueUnmarshalers[destType](dv, dest, format)
+			if typeDetails.custom != nil && typeDetails.custom.valueUnmarshal != nil {
+				err = typeDetails.custom.valueUnmarshal(dv, typeDetails.custom.value(dest), format)
 			} else {
 				_, err = callStructMethod(dest, typeDetails.KDLValueUnmarshalerMethod, reflect.ValueOf(dv))
 			}
@@ -283,11 +289,18 @@ func unmarshalDocumentValueWithUnmarshaler(c *unmarshalContext, dest reflect.Val
 
 func unmarshalNodeWithUnmarshaler(c *unmarshalContext, dest reflect.Value, node *document.Node, format string) (bool, reflect.Value, error) {
 	destType := dest.Type()
-	if typeDetails := c.indexer.Get(destType.String()); typeDetails != nil {
+	if typeDetails := c.indexer.Get(destType); typeDetails != nil {
 		if typeDetails.CanUnmarshalKDL() {
 			var err error
-			if typeDetails.CustomArshalers.Has(hasCustomUnmarshaler) {
-				err = customUnmarshalers[destType](node, dest)
+			if typeDetails.custom != nil && typeDetails.custom.unmarshal != nil {
+				err = typeDetails.custom.unmarshal(node, typeDetails.custom.value(dest))
+			} else if typeDetails.GeneratedUnmarshaler {
+				if c.errs != nil {
+					// generated methods stop at the first error, so errors are only collected by the reflective
+					// implementation
+					return false, dest, nil
+				}
+				err = callGenerated(dest, typeDetails.KDLUnmarshalerMethod, node, c.decoder())
 			} else {
 				_, err = callStructMethod(dest, typeDetails.KDLUnmarshalerMethod, reflect.ValueOf(node))
 
@@ -375,6 +388,16 @@ var durationFormatFactor = map[string]time.Duration{
 }
 
 func unmarshalValueDuration(c *unmarshalContext, dest reflect.Value, iv interface{}, format string) error {
+	d, err := durationFromIntf(iv, format)
+	if err != nil {
+		return err
+	}
+	dest.Set(reflect.ValueOf(d))
+	return nil
+}
+
+// durationFromIntf converts iv to a time.Duration according to format
+func durationFromIntf(iv interface{}, format string) (time.Duration, error) {
 	var (
 		d   time.Duration
 		err error
@@ -393,40 +416,45 @@ func unmarshalValueDuration(c *unmarshalContext, dest reflect.Value, iv interfac
 		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 			switch format {
 			case "base60":
-				return errors.New("cannot unmarshal numeric value from base60 format")
+				return 0, errors.New("cannot unmarshal numeric value from base60 format")
 			default:
 				d = time.Duration(coerce.ToInt64(iv)) * time.Second
 			}
 		case float32, float64:
 			switch format {
 			case "base60":
-				return errors.New("cannot unmarshal numeric value from base60 format")
+				return 0, errors.New("cannot unmarshal numeric value from base60 format")
 			default:
 				d = time.Duration(coerce.ToFloat64(iv) * float64(time.Second))
 			}
 		case document.SuffixedDecimal:
 			if d, err = iv.(document.SuffixedDecimal).AsDuration(); err != nil {
-				return err
+				return 0, err
 			}
 		default:
 			switch format {
 			case "base60":
 				if d, err = parseHMSDuration(coerce.ToString(iv)); err != nil {
-					return err
+					return 0, err
 				}
 			default:
 				if d, err = time.ParseDuration(coerce.ToString(iv)); err != nil {
-					return err
+					return 0, err
 				}
 			}
 		}
 	}
 
-	dest.Set(reflect.ValueOf(d))
-	return nil
+	return d, nil
 }
 
 func resolveSuffixedDecimal(rv *reflect.Value, val interface{}) (interface{}, error) {
+	return resolveSuffixedDecimalKind(rv.Kind(), IsType[time.Duration](*rv), val)
+}
+
+// resolveSuffixedDecimalKind resolves val, if it is a suffixed decimal, into a value suitable for a destination of
+// kind k; isDuration indicates that the destination is a time.Duration
+func resolveSuffixedDecimalKind(k reflect.Kind, isDuration bool, val interface{}) (interface{}, error) {
 	var err error
 
 	sd, isSuffixed := val.(document.SuffixedDecimal)
@@ -443,12 +471,12 @@ func resolveSuffixedDecimal(rv *reflect.Value, val interface{}) (interface{}, er
 		}
 	}
 
-	switch rv.Kind() {
+	switch k {
 	case reflect.Bool, reflect.String, reflect.Slice, reflect.Array, reflect.Interface:
 		return sd.String(), nil
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if IsType[time.Duration](*rv) {
+		if isDuration {
 			return sd.AsDuration()
 		} else {
 			return sd.AsNumber()
@@ -486,15 +514,7 @@ func directSetReflectValueFromIntf(c *unmarshalContext, rv *reflect.Value, val i
 		rv.SetUint(coerce.ToUint64(val))
 
 	case reflect.Float32, reflect.Float64:
-		if format != "nonfinite" {
-			if s, ok := val.(string); ok {
-				switch s {
-				case "+Inf", "-Inf", "Inf", "NaN":
-					val = 0.0
-				}
-			}
-		}
-		rv.SetFloat(coerce.ToFloat64(val))
+		rv.SetFloat(floatFromIntf(val, format))
 
 	case reflect.Complex64, reflect.Complex128:
 		rv.SetComplex(coerce.ToComplex128(val))
@@ -522,6 +542,20 @@ func directSetReflectValueFromIntf(c *unmarshalContext, rv *reflect.Value, val i
 
 }
 
+// floatFromIntf converts val to a float64; the non-finite values +Inf, -Inf, and NaN are only accepted if format is
+// "nonfinite", and are otherwise treated as zero
+func floatFromIntf(val interface{}, format string) float64 {
+	if format != "nonfinite" {
+		if s, ok := val.(string); ok {
+			switch s {
+			case "+Inf", "-Inf", "Inf", "NaN":
+				val = 0.0
+			}
+		}
+	}
+	return coerce.ToFloat64(val)
+}
+
 // setReflectValueFromIntf sets dest to the value of val, returning a non-nil error if dest is not of a compatible
 // scalar type.
 //
@@ -542,6 +576,9 @@ func directSetReflectValueFromIntf(c *unmarshalContext, rv *reflect.Value, val i
 //   - if dest satisfies the encoding.UnmarshalText interface, val will be stringified per above and passed as a byte slice
 //     to UnmarshalText.
 func setReflectValueFromIntf(c *unmarshalContext, dest reflect.Value, val interface{}, format string) (reflect.Value, error) {
+	if ok, err := unmarshalBuiltinPointer(c, dest, &document.Value{Value: val}, format); ok {
+		return dest, err
+	}
 	return withCreatedAndIndirected(dest, func(rv *reflect.Value) error {
 		var (
 			done bool
@@ -563,6 +600,9 @@ func setReflectValueFromIntf(c *unmarshalContext, dest reflect.Value, val interf
 }
 
 func setReflectValueFromDocumentValue(c *unmarshalContext, dest reflect.Value, dv *document.Value, format string) (reflect.Value, error) {
+	if ok, err := unmarshalBuiltinPointer(c, dest, dv, format); ok {
+		return dest, err
+	}
 	return withCreatedAndIndirected(dest, func(rv *reflect.Value) error {
 		var (
 			done bool
@@ -611,22 +651,30 @@ func createMapIfNil(mapValue reflect.Value, size int) {
 //
 // Conversion rules for keys and values are per setReflectValueFromIntf.
 func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct reflect.Value) (reflect.Value, error) {
-	structName := destStruct.Type().String()
-	typeDetails := c.indexer.Get(structName)
-
+	typeDetails, err := c.indexer.Lookup(destStruct.Type())
+	if err != nil {
+		return destStruct, err
+	}
 	argFieldInfo := typeDetails.StructAttrs["arg"]
 	argsFieldInfo := typeDetails.StructAttrs["args"]
 	propsFieldInfo := typeDetails.StructAttrs["props"]
 	childrenFieldInfo := typeDetails.StructAttrs["children"]
-	var err error
 
 	if len(node.Arguments) > 0 {
 		if len(node.Arguments) == 1 && (typeDetails.CanUnmarshalText() || typeDetails.CanUnmarshalKDLValue()) {
 			return setReflectValueFromIntf(c, destStruct, node.Arguments[0].ResolvedValue(), "")
 		}
 
-		if !c.opts.AllowUnhandledArgs && len(argsFieldInfo) == 0 && len(argFieldInfo) < len(node.Arguments) {
-			return reflect.Value{}, fmt.Errorf("%s has unexpected arguments", node.Name.ValueString())
+		if len(argsFieldInfo) == 0 && len(argFieldInfo) < len(node.Arguments) {
+			if unknownField, ok := unknownMapField(typeDetails, destStruct); ok {
+				if err := captureUnknownArgs(c, node, len(argFieldInfo), unknownField); err != nil {
+					return reflect.Value{}, err
+				}
+			} else if !c.opts.AllowUnhandledArgs {
+				if err := c.collect(errUnexpectedArgs(node), valuePosition(node, node.Arguments[len(argFieldInfo)]), "", "", nil); err != nil {
+					return reflect.Value{}, err
+				}
+			}
 		}
 
 		if len(argsFieldInfo) > 1 {
@@ -635,14 +683,24 @@ func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct
 
 		args := node.Arguments[:]
 
-		// assign as many arguments as possible to fields tagged with ",arg"
-		for _, fieldInfo := range argFieldInfo {
+		// assign as many arguments as possible to fields tagged with ",arg"; any left without one are reported by
+		// checkStructFields if they are required
+		argFields := argFieldInfo
+		if len(args) < len(argFields) {
+			argFields = argFields[:len(args)]
+		}
+		for _, fieldInfo := range argFields {
 			field := fieldInfo.GetValueFrom(destStruct)
 			field, err = withCreatedAndIndirected(field, func(field *reflect.Value) error {
-				f, err := setReflectValueFromIntf(c, *field, args[0].ResolvedValue(), fieldInfo.Format)
+				val, err := resolveValueFor(args[0], field.Type())
+				if err != nil {
+					return err
+				}
+				f, err := setReflectValueFromIntf(c, *field, val, fieldInfo.Format)
 				*field = f
 				return err
 			})
+			err = c.collectField(err, valuePosition(node, args[0]), "", destStruct.Type(), fieldInfo)
 			if err != nil {
 				return reflect.Value{}, err
 			}
@@ -654,20 +712,13 @@ func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct
 			fieldInfo := argsFieldInfo[0]
 			field := fieldInfo.GetValueFrom(destStruct)
 			field, err = withCreatedAndIndirected(field, func(slice *reflect.Value) error {
-				sk := slice.Kind()
-				if sk != reflect.Slice && sk != reflect.Array {
-					return fmt.Errorf("cannot unmarshal arguments for %s into slice %s of non-slice type %s", node.Name.ValueString(), slice.Type().Name(), slice.Kind().String())
-				}
-
-				size := len(node.Arguments)
-				_ = createSliceIfNil(slice, 0, size)
-
-				var err error
-				if err = addArgumentsToSlice(c, args, slice); err != nil {
-					return err
-				}
-				return nil
+				return unmarshalArgsToSlice(c, node, args, slice)
 			})
+			var pos document.Position
+			if len(args) > 0 {
+				pos = valuePosition(node, args[0])
+			}
+			err = c.collectField(err, pos, "", destStruct.Type(), fieldInfo)
 			if err != nil {
 				return reflect.Value{}, err
 			}
@@ -676,28 +727,47 @@ func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct
 
 	if node.Properties.Len() > 0 {
 
+		havePropsField := len(propsFieldInfo) > 0
+		var unknownField reflect.Value
+		if !havePropsField {
+			unknownField, _ = unknownMapField(typeDetails, destStruct)
+		}
+
 		// try to assign each property to a struct field tagged with the property's name
 		handledProps := 0
 		for propKey, propVal := range node.Properties.Unordered() {
 			safePropKey := normalizeKey(propKey, c.indexer.caseSensitive)
 			keyFieldInfo, exists := typeDetails.StructFields[safePropKey]
 			if !exists {
+				if unknownField.IsValid() {
+					// properties that do not correspond to any field are captured by the field tagged ",unknown"
+					createMapIfNil(unknownField, 0)
+					err := setMapKeyValueFromIntf(c, unknownField, unknownField.Type().Key(), unknownField.Type().Elem(), propKey, propVal.ResolvedValue())
+					if err = c.collectField(err, valuePosition(node, propVal), propKey, destStruct.Type(), typeDetails.UnknownStructField); err != nil {
+						return reflect.Value{}, err
+					}
+					handledProps++
+				}
 				continue
 			}
 			field := keyFieldInfo.GetValueFrom(destStruct)
-			if field, err = setReflectValueFromIntf(c, field, propVal.ResolvedValue(), keyFieldInfo.Format); err != nil {
-				return reflect.Value{}, err
+			var val interface{}
+			if val, err = resolveValueFor(propVal, field.Type()); err == nil {
+				field, err = setReflectValueFromIntf(c, field, val, keyFieldInfo.Format)
+			}
+			if err != nil {
+				err = c.collectField(err, valuePosition(node, propVal), propKey, destStruct.Type(), keyFieldInfo)
+				if err != nil {
+					return reflect.Value{}, err
+				}
 			}
 			handledProps++
 		}
 
-		havePropsField := len(propsFieldInfo) > 0
 		if !c.opts.AllowUnhandledProps && !havePropsField && handledProps < node.Properties.Len() {
-			extraProps := make([]string, 0, node.Properties.Len())
-			for name := range node.Properties.Unordered() {
-				extraProps = append(extraProps, name)
+			if err := c.collect(errUnexpectedProps(node), node.Span.Start, "", "", nil); err != nil {
+				return reflect.Value{}, err
 			}
-			return reflect.Value{}, fmt.Errorf("%s has unexpected properties %s", node.Name.ValueString(), strings.Join(extraProps, ", "))
 		}
 
 		// if we have a struct field tagged with ",props" and it's a map, add all of the properties to it
@@ -706,39 +776,9 @@ func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct
 				return reflect.Value{}, fmt.Errorf("%s must have no more than one field tagged ',props'", destStruct.Type().Name())
 			}
 
-			fieldInfo := propsFieldInfo[0]
-			field := fieldInfo.GetValueFrom(destStruct)
-
-			fk := indirectKind(field)
-			if fk != reflect.Map {
-				return reflect.Value{}, fmt.Errorf("%s is tagged ',props' and must be a map, but is a %s", destStruct.Type().Name(), reflect.Indirect(field).Kind().String())
-			}
-
-			field, err := withCreatedAndIndirected(field, func(mapField *reflect.Value) error {
-				createMapIfNil(*mapField, node.Properties.Len())
-
-				mapKeyType := mapField.Type().Key()
-				mapValType := mapField.Type().Elem()
-
-				for propKey, propVal := range node.Properties.Unordered() {
-					if err := setMapKeyValueFromIntf(c, *mapField, mapKeyType, mapValType, propKey, propVal.ResolvedValue()); err != nil {
-						return err
-					}
-					// key := createTypeAndIndirect(mapKeyType)
-					// if err := setReflectValueFromIntf(c, key, propKey, ""); err != nil {
-					// 	return err
-					// }
-					//
-					// val := createTypeAndIndirect(mapValType)
-					// if err := setReflectValueFromIntf(c, val, propVal.ResolvedValue(), ""); err != nil {
-					// 	return err
-					// }
-					//
-					// mapField.SetMapIndex(key, val)
-				}
-				return nil
-			})
-			if err != nil {
+			field := propsFieldInfo[0].GetValueFrom(destStruct)
+			err := unmarshalPropsToField(c, node, destStruct.Type().Name(), field)
+			if err = c.collectField(err, node.Span.Start, "", destStruct.Type(), propsFieldInfo[0]); err != nil {
 				return reflect.Value{}, err
 			}
 		}
@@ -746,40 +786,83 @@ func unmarshalNodeToStruct(c *unmarshalContext, node *document.Node, destStruct
 
 	if len(node.Children) > 0 {
 		haveChildrenField := len(childrenFieldInfo) > 0
-		if !c.opts.AllowUnhandledChildren && !haveChildrenField {
+		if !haveChildrenField {
 			// if we don't have a ",children" field in this struct to put the children into, try unmarshaling each child
 			// directly into this struct to see if it has fields matching the node names
-			return unmarshalNodesToStruct(c, node.Children, destStruct)
+			if err := unmarshalNodesToStructFields(c, node.Children, destStruct); err != nil {
+				return reflect.Value{}, err
+			}
+		} else {
+			field := childrenFieldInfo[0].GetValueFrom(destStruct)
+			err := unmarshalChildrenToField(c, node, field)
+			if err = c.collectField(err, node.Span.Start, "", destStruct.Type(), childrenFieldInfo[0]); err != nil {
+				return reflect.Value{}, err
+			}
 		}
+	}
 
-		fieldInfo := childrenFieldInfo[0]
-		field := fieldInfo.GetValueFrom(destStruct)
+	return destStruct, checkStructFields(c, node, node, node.Children, node.Span.Start, destStruct)
+}
 
-		fk := indirectKind(field)
+// unmarshalArgsToSlice appends args, the arguments of node that were not assigned to fields tagged ",arg", to slice
+func unmarshalArgsToSlice(c *unmarshalContext, node *document.Node, args []*document.Value, slice *reflect.Value) error {
+	sk := slice.Kind()
+	if sk != reflect.Slice && sk != reflect.Array {
+		return fmt.Errorf("cannot unmarshal arguments for %s into slice %s of non-slice type %s", node.Name.ValueString(), slice.Type().Name(), slice.Kind().String())
+	}
 
-		switch fk {
-		case reflect.Map:
-			if field, err = unmarshalNodesToMap(c, node.Children, field, nil); err != nil {
-				return reflect.Value{}, err
-			}
-		case reflect.Struct:
-			if field, err = unmarshalNodesToStruct(c, node.Children, field); err != nil {
-				return reflect.Value{}, err
+	size := len(node.Arguments)
+	_ = createSliceIfNil(slice, 0, size)
+
+	return addArgumentsToSlice(c, args, slice)
+}
+
+// unmarshalPropsToField adds all of node's properties to field, which must represent a map field tagged ",props" in
+// the struct type named structName
+func unmarshalPropsToField(c *unmarshalContext, node *document.Node, structName string, field reflect.Value) error {
+	fk := indirectKind(field)
+	if fk != reflect.Map {
+		return fmt.Errorf("%s is tagged ',props' and must be a map, but is a %s", structName, reflect.Indirect(field).Kind().String())
+	}
+
+	_, err := withCreatedAndIndirected(field, func(mapField *reflect.Value) error {
+		createMapIfNil(*mapField, node.Properties.Len())
+
+		mapKeyType := mapField.Type().Key()
+		mapValType := mapField.Type().Elem()
+
+		for propKey, propVal := range node.Properties.Unordered() {
+			val, err := resolveValueFor(propVal, mapValType)
+			if err != nil {
+				return err
 			}
-		case reflect.Interface:
-			m := make(map[string]interface{})
-			field.Set(reflect.ValueOf(m))
-			if field, err = unmarshalNodesToMap(c, node.Children, field, nil); err != nil {
-				return reflect.Value{}, err
+			if err := setMapKeyValueFromIntf(c, *mapField, mapKeyType, mapValType, propKey, val); err != nil {
+				return err
 			}
-
-		default:
-			return reflect.Value{}, fmt.Errorf("%s is tagged ',children' and must be of type map or struct, but is %s", node.Name.ValueString(), fk.String())
 		}
+		return nil
+	})
+	return err
+}
 
-	}
+// unmarshalChildrenToField unmarshals node's children into field, which must represent a field tagged ",children"
+func unmarshalChildrenToField(c *unmarshalContext, node *document.Node, field reflect.Value) error {
+	var err error
+	fk := indirectKind(field)
 
-	return destStruct, nil
+	switch fk {
+	case reflect.Map:
+		_, err = unmarshalNodesToMap(c, node.Children, field, nil)
+	case reflect.Struct:
+		_, err = unmarshalNodesToStruct(c, node, node.Children, field)
+	case reflect.Interface:
+		m := make(map[string]interface{})
+		field.Set(reflect.ValueOf(m))
+		_, err = unmarshalNodesToMap(c, node.Children, field, nil)
+	default:
+		err = fmt.Errorf("%s is tagged ',children' and must be of type map or struct, but is %s", node.Name.ValueString(), fk.String())
+	}
+	return err
 }
 
 // createSliceIfNil allocates a slice if it is nil, and ensures its capacity and length is at least size; it returns the
@@ -801,6 +884,15 @@ func newValueForSlice(slice reflect.Value) reflect.Value {
 	return el
 }
 
+// resolveValueFor returns the value of dv to be assigned to a value of type t: values assigned to an interface{} are
+// converted per their type annotations (see document.Value.Annotated), while all others are returned as resolved
+func resolveValueFor(dv *document.Value, t reflect.Type) (interface{}, error) {
+	if dv.Type != "" && t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return dv.Annotated()
+	}
+	return dv.ResolvedValue(), nil
+}
+
 // addArgumentsToSlice adds args to slice (which must represent a slice) starting at the specified startIdx; returns
 // the index of the next assignable element and a non-nil error on failure
 //
@@ -809,10 +901,13 @@ func addArgumentsToSlice(c *unmarshalContext, args []*document.Value, destSlice
 	var slice = *destSlice
 	for _, arg := range args {
 		dst := newValueForSlice(slice)
-		dst, err := setReflectValueFromIntf(c, dst, arg.ResolvedValue(), "")
+		val, err := resolveValueFor(arg, dst.Type())
 		if err != nil {
 			return err
 		}
+		if dst, err = setReflectValueFromIntf(c, dst, val, ""); err != nil {
+			return err
+		}
 		slice = reflect.Append(slice, dst)
 	}
 	*destSlice = slice
@@ -916,8 +1011,11 @@ func unmarshalNodeToSlice(c *unmarshalContext, node *document.Node, destSlice *r
 
 			switch sliceElementType {
 			case reflect.Interface:
-				v := []interface{}{key, val.ResolvedValue()}
-				dst.Set(reflect.ValueOf(v))
+				av, err := val.Annotated()
+				if err != nil {
+					return err
+				}
+				dst.Set(reflect.ValueOf([]interface{}{key, av}))
 			case reflect.String:
 				b.Reset()
 				b.WriteString(key)
@@ -1047,14 +1145,22 @@ func unmarshalNodeToMap(c *unmarshalContext, node *document.Node, destMap reflec
 
 	// unmarshal the node's arguments into the map with the argument number as the key, and the argument value as the value
 	for i, arg := range node.Arguments {
-		if err := setMapKeyValueFromIntf(c, destMap, mapKeyType, mapValType, i, arg.ResolvedValue()); err != nil {
+		val, err := resolveValueFor(arg, mapValType)
+		if err == nil {
+			err = setMapKeyValueFromIntf(c, destMap, mapKeyType, mapValType, i, val)
+		}
+		if err = c.collect(err, valuePosition(node, arg), "", "", nil); err != nil {
 			return err
 		}
 	}
 
 	// unmarshal the node's properties into the map
 	for propKey, propVal := range node.Properties.Unordered() {
-		if err := setMapKeyValueFromIntf(c, destMap, mapKeyType, mapValType, propKey, propVal.ResolvedValue()); err != nil {
+		val, err := resolveValueFor(propVal, mapValType)
+		if err == nil {
+			err = setMapKeyValueFromIntf(c, destMap, mapKeyType, mapValType, propKey, val)
+		}
+		if err = c.collect(err, valuePosition(node, propVal), propKey, "", nil); err != nil {
 			return err
 		}
 	}
@@ -1064,10 +1170,7 @@ func unmarshalNodeToMap(c *unmarshalContext, node *document.Node, destMap reflec
 		if parentStructure != nil {
 			parentStructure.SetChildStructure(node, childNode)
 		}
-		err := setMapKeyValueFromFunc(c, destMap, mapKeyType, mapValType, childNode.Name.ResolvedValue(), func(val *reflect.Value) error {
-			return unmarshalNodeToValue(c, childNode, val, "", nil)
-		})
-		if err != nil {
+		if err := unmarshalNodeToMapEntry(c, childNode, destMap, nil); err != nil {
 			return err
 		}
 	}
@@ -1204,8 +1307,7 @@ func unmarshalNodeToMultiple(c *unmarshalContext, node *document.Node, destValue
 
 			// another attempt to preserve corner-case comments
 			if el.Type().Kind() == reflect.Struct && node.Comment != nil {
-				structName := el.Type().String()
-				typeDetails := c.indexer.Get(structName)
+				typeDetails := c.indexer.Get(el.Type())
 				if node.Comment != nil {
 					typeDetails.SetStructure(el, "__parent", node)
 				}
@@ -1227,9 +1329,7 @@ func unmarshalNodeToMultiple(c *unmarshalContext, node *document.Node, destValue
 // failure.
 func unmarshalNodeToValue(c *unmarshalContext, node *document.Node, destValue *reflect.Value, format string, parentStructure *structStructure) (e error) {
 	defer func() {
-		if e != nil && node != nil && node.Name != nil {
-			e = fmt.Errorf("%s: %w", node.Name.NodeNameString(), e)
-		}
+		e = wrapNodeError(c, node, e)
 	}()
 	var (
 		unmarshaled bool
@@ -1268,6 +1368,12 @@ func unmarshalNodeToValue(c *unmarshalContext, node *document.Node, destValue *r
 			return err
 
 		case reflect.Interface:
+			if un := lookupUnion(dest.Type(), c.opts.Unions); un != nil {
+				return unmarshalNodeToUnion(c, node, dest, un, format, parentStructure)
+			} else if dest.Type().NumMethod() > 0 {
+				// generic values can only be assigned to interface{}
+				return fmt.Errorf("cannot unmarshal node %s into %s: the interface type is not a registered union", node.Name.ValueString(), dest.Type())
+			}
 			destVal := dest
 
 			v := *destVal
@@ -1277,7 +1383,11 @@ func unmarshalNodeToValue(c *unmarshalContext, node *document.Node, destValue *r
 
 			if len(node.Arguments) > 0 || node.Properties.Len() > 0 || len(node.Children) > 0 {
 				if len(node.Arguments) == 1 && node.Properties.Len() == 0 && len(node.Children) == 0 {
-					sourceVal := reflect.ValueOf(node.Arguments[0].ResolvedValue())
+					av, err := node.Arguments[0].Annotated()
+					if err != nil {
+						return err
+					}
+					sourceVal := reflect.ValueOf(av)
 					if sourceVal.IsValid() {
 						v.Set(sourceVal)
 					} else {
@@ -1320,18 +1430,20 @@ func unmarshalNodeToValue(c *unmarshalContext, node *document.Node, destValue *r
 // unmarshalNodeToValue.
 func unmarshalNodeToStructField(c *unmarshalContext, node *document.Node, destStruct reflect.Value) error {
 	name := node.Name.ValueString()
-	typeDetails := c.indexer.Get(destStruct.Type().String())
+	typeDetails := c.indexer.Get(destStruct.Type())
 	safeName := normalizeKey(name, c.indexer.caseSensitive)
 	destFieldInfo, exists := typeDetails.StructFields[safeName]
 	if !exists {
-		if c.opts.AllowUnhandledNodes {
+		if typeDetails.UnknownStructField != nil {
+			return captureUnknownNode(c, node, destStruct, typeDetails.UnknownStructField)
+		} else if c.opts.AllowUnhandledNodes {
 			return nil
 		} else {
 			// println(destStruct.Type().String())
 			// for sn, sf := range typeDetails.StructFields {
 			// 	println(sn, ": ", strings.Join(sf.Attrs, ","))
 			// }
-			return fmt.Errorf("no struct field into which to unmarshal node %q", name)
+			return c.collect(errNoStructField(name), node.Span.Start, name, "", destStruct.Type())
 		}
 	}
 
@@ -1343,43 +1455,151 @@ func unmarshalNodeToStructField(c *unmarshalContext, node *document.Node, destSt
 
 	parentStructure := typeDetails.GetStructure(destStruct)
 
+	if c.errs != nil {
+		typ := destFieldValue.Type()
+		if destFieldInfo.IsMultiple() && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			// each node is unmarshaled into an element
+			typ = typ.Elem()
+		}
+		c.errs.enter(node, structFieldName(destStruct.Type(), destFieldInfo), typ, destFieldInfo.IsMultiple())
+		defer c.errs.leave()
+	}
+
+	var err error
 	if destFieldInfo.IsMultiple() {
 		v := destFieldValue
-		err := unmarshalNodeToMultiple(c, node, &v, parentStructure)
+		err = unmarshalNodeToMultiple(c, node, &v, parentStructure)
 		destFieldValue.Set(v)
-		return err
+	} else {
+		err = unmarshalNodeToValue(c, node, &destFieldValue, destFieldInfo.Format, parentStructure)
 	}
+	return c.collect(err, node.Span.Start, "", "", nil)
+}
 
-	return unmarshalNodeToValue(c, node, &destFieldValue, destFieldInfo.Format, parentStructure)
+// unknownMapField returns the field of destStruct tagged ",unknown" and true if there is such a field and it is a map
+func unknownMapField(typeDetails *typeDetails, destStruct reflect.Value) (reflect.Value, bool) {
+	if typeDetails.UnknownStructField == nil {
+		return reflect.Value{}, false
+	}
+	field := typeDetails.UnknownStructField.GetValueFrom(destStruct)
+	if field.Kind() != reflect.Map {
+		return reflect.Value{}, false
+	}
+	return field, true
 }
 
-// unmarshalNodesToStruct unmarshals each node in nodes into the destStruct, which must represent a struct value.
-func unmarshalNodesToStruct(c *unmarshalContext, nodes []*document.Node, destStruct reflect.Value) (reflect.Value, error) {
+// captureUnknownArgs adds the arguments of node from index first onward, which do not correspond to any field, to
+// field, a map tagged ",unknown", keyed by their indexes
+func captureUnknownArgs(c *unmarshalContext, node *document.Node, first int, field reflect.Value) error {
+	createMapIfNil(field, len(node.Arguments)-first)
+	for i := first; i < len(node.Arguments); i++ {
+		arg := node.Arguments[i]
+		err := setMapKeyValueFromIntf(c, field, field.Type().Key(), field.Type().Elem(), i, arg.ResolvedValue())
+		if err = c.collect(err, valuePosition(node, arg), "", "", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureUnknownNode adds node, which does not correspond to any field of destStruct, to the field of destStruct
+// described by fld, which is tagged ",unknown"; a []*document.Node receives node itself, while a map receives the
+// value of node keyed by its name, as for a node unmarshaled into a map. As a map cannot represent them faithfully, an
+// error is returned for a node with child nodes, a node with neither arguments nor properties, or a node whose name is
+// already a key of the map.
+func captureUnknownNode(c *unmarshalContext, node *document.Node, destStruct reflect.Value, fld *structFieldDetails) error {
+	field := fld.GetValueFrom(destStruct)
+	if field.Kind() == reflect.Map {
+		name := node.Name.ValueString()
+		var err error
+		switch {
+		case len(node.Children) > 0:
+			err = fmt.Errorf("unknown node %q has child nodes, which a map tagged ',unknown' cannot capture; use a []*document.Node", name)
+		case len(node.Arguments) == 0 && node.Properties.Len() == 0:
+			err = fmt.Errorf("unknown node %q has no arguments or properties, which a map tagged ',unknown' cannot capture; use a []*document.Node", name)
+		case !field.IsNil() && field.MapIndex(reflect.ValueOf(name).Convert(field.Type().Key())).IsValid():
+			err = fmt.Errorf("unknown node %q is repeated, which a map tagged ',unknown' cannot capture; use a []*document.Node", name)
+		}
+		if err != nil {
+			return c.collect(err, node.Span.Start, "", "", nil)
+		}
+		createMapIfNil(field, 0)
+		return unmarshalNodeToMapEntry(c, node, field, nil)
+	}
+	field.Set(reflect.Append(field, reflect.ValueOf(node)))
+	return nil
+}
+
+// unmarshalNodesToStruct unmarshals each node in nodes, the children of parent or the nodes of a document if parent is
+// nil, into the destStruct, which must represent a struct value.
+func unmarshalNodesToStruct(c *unmarshalContext, parent *document.Node, nodes []*document.Node, destStruct reflect.Value) (reflect.Value, error) {
 	return withCreatedAndIndirected(destStruct, func(destStruct *reflect.Value) error {
-		for _, node := range nodes {
-			if err := unmarshalNodeToStructField(c, node, *destStruct); err != nil {
-				return err
-			}
+		typeDetails, err := c.indexer.Lookup(destStruct.Type())
+		if err != nil {
+			return err
+		}
+		if typeDetails.GeneratedUnmarshaler && c.errs == nil {
+			v, err := unmarshalNodesWithGenerated(c, parent, nodes, *destStruct)
+			*destStruct = v
+			return err
+		}
+		if err := unmarshalNodesToStructFields(c, nodes, *destStruct); err != nil {
+			return err
 		}
 
-		return nil
+		return checkStructFields(c, nil, parent, nodes, nodesPosition(parent, nodes), *destStruct)
 	})
 
 }
 
+// nodesPosition returns the position at which to report errors concerning nodes as a whole: that of parent, or the
+// start of the document if parent is nil and the positions of nodes are known
+func nodesPosition(parent *document.Node, nodes []*document.Node) document.Position {
+	switch {
+	case parent != nil:
+		return parent.Span.Start
+	case len(nodes) == 0 || nodes[0].Span.IsValid():
+		return document.Position{Line: 1, Column: 1}
+	default:
+		return document.Position{}
+	}
+}
+
+// unmarshalNodesToStructFields unmarshals each node in nodes into the corresponding field of destStruct, which must
+// represent a struct value.
+func unmarshalNodesToStructFields(c *unmarshalContext, nodes []*document.Node, destStruct reflect.Value) error {
+	for _, node := range nodes {
+		if err := unmarshalNodeToStructField(c, node, destStruct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalNodeToMapEntry unmarshals node into the entry of destMap, which must represent a map value, keyed by the
+// node's name
+func unmarshalNodeToMapEntry(c *unmarshalContext, node *document.Node, destMap reflect.Value, parentStructure *structStructure) error {
+	mapKeyType := destMap.Type().Key()
+	mapValType := destMap.Type().Elem()
+
+	if c.errs != nil {
+		c.errs.enter(node, "", mapValType, false)
+		defer c.errs.leave()
+	}
+
+	err := setMapKeyValueFromFunc(c, destMap, mapKeyType, mapValType, node.Name.ResolvedValue(), func(val *reflect.Value) error {
+		return unmarshalNodeToValue(c, node, val, "", parentStructure)
+	})
+	return c.collect(err, node.Span.Start, "", "", nil)
+}
+
 // unmarshalNodesToMap unmarshals each node in nodes into the destMap, which must represent a map value.
 func unmarshalNodesToMap(c *unmarshalContext, nodes []*document.Node, destMap reflect.Value, parentStructure *structStructure) (reflect.Value, error) {
 	return withCreatedAndIndirected(destMap, func(destMap *reflect.Value) error {
 		createMapIfNil(*destMap, len(nodes))
 
-		mapKeyType := destMap.Type().Key()
-		mapValType := destMap.Type().Elem()
-
 		for _, node := range nodes {
-			err := setMapKeyValueFromFunc(c, *destMap, mapKeyType, mapValType, node.Name.ResolvedValue(), func(val *reflect.Value) error {
-				return unmarshalNodeToValue(c, node, val, "", parentStructure)
-			})
-			if err != nil {
+			if err := unmarshalNodeToMapEntry(c, node, *destMap, parentStructure); err != nil {
 				return err
 			}
 		}
@@ -1412,7 +1632,7 @@ func unmarshalNodes(c *unmarshalContext, nodes []*document.Node, dest reflect.Va
 
 		switch dest.Kind() {
 		case reflect.Struct:
-			v, err := unmarshalNodesToStruct(c, nodes, *dest)
+			v, err := unmarshalNodesToStruct(c, nil, nodes, *dest)
 			if err == nil {
 				*dest = v
 			}
@@ -1446,7 +1666,12 @@ func UnmarshalWithOptions(doc *document.Document, v interface{}, opts UnmarshalO
 	c := &unmarshalContext{
 		opts: opts,
 	}
-	c.indexer = newTypeIndexer(opts.CaseSensitive)
+	c.indexer = newTypeIndexer(opts.CaseSensitive, nil, opts.Unmarshalers)
+	return unmarshalDocument(c, doc, v)
+}
+
+// unmarshalDocument unmarshals the nodes of doc into v with c
+func unmarshalDocument(c *unmarshalContext, doc *document.Document, v interface{}) error {
 	if err := c.indexer.IndexIntf(v); err != nil {
 		return err
 	}
@@ -1454,8 +1679,11 @@ func UnmarshalWithOptions(doc *document.Document, v interface{}, opts UnmarshalO
 	target := reflect.ValueOf(v)
 	switch target.Kind() {
 	case reflect.Ptr, reflect.Map, reflect.Interface:
+		if c.opts.CollectErrors {
+			c.errs = newErrorCollector("", derefType(target.Type()))
+		}
 		_, err := unmarshalNodes(c, doc.Nodes, target, nil)
-		return err
+		return c.collected(err, document.Position{})
 	default:
 		return ErrNeedPointer
 	}
@@ -1470,7 +1698,7 @@ func UnmarshalNodeWithOptions(node *document.Node, v interface{}, opts Unmarshal
 	c := &unmarshalContext{
 		opts: opts,
 	}
-	c.indexer = newTypeIndexer(opts.CaseSensitive)
+	c.indexer = newTypeIndexer(opts.CaseSensitive, nil, opts.Unmarshalers)
 	if err := c.indexer.IndexIntf(v); err != nil {
 		return err
 	}
@@ -1478,8 +1706,11 @@ func UnmarshalNodeWithOptions(node *document.Node, v interface{}, opts Unmarshal
 	target := reflect.ValueOf(v)
 	switch target.Kind() {
 	case reflect.Ptr, reflect.Map, reflect.Interface:
+		if opts.CollectErrors {
+			c.errs = newErrorCollector(node.Name.NodeNameString(), derefType(target.Type()))
+		}
 		_, err := unmarshalNode(c, node, target)
-		return err
+		return c.collected(err, node.Span.Start)
 	default:
 		return ErrNeedPointer
 	}
@@ -38,3 +38,13 @@ func fieldAttrs(tag reflect.StructTag) []string {
 		return nil
 	}
 }
+
+// Value returns the value of the attribute named name in s, eg: "sec" for "format:sec", and true if s includes it
+func (s structFieldAttrs) Value(name string) (string, bool) {
+	for _, v := range s {
+		if len(v) > len(name) && v[len(name)] == ':' && strings.HasPrefix(v, name) {
+			return v[len(name)+1:], true
+		}
+	}
+	return "", false
+}
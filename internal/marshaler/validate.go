@@ -0,0 +1,499 @@
+package marshaler
+
+// Handling of the struct tag options that apply once a struct has been unmarshaled: ",required" and ",default:..."
+// for fields absent from the document, and the constraints "min:", "max:", "len:", "enum:", "match:", and "oneof:"
+// (and the type annotations checked in annotation.go) for fields present in it. Fields are checked after the whole node has been unmarshaled, so that a field may be
+// supplied by either a property or a child node, and violations are reported at the position of the value that caused
+// them.
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+)
+
+// fieldRules holds the constraints declared by the tag options of a struct field
+type fieldRules struct {
+	// min and max bound numbers and durations as written, and minValue and maxValue hold them converted into the type
+	// of the field's values
+	min, max           string
+	hasMin, hasMax     bool
+	minValue, maxValue reflect.Value
+	// minLen and maxLen bound the length of strings, slices, and maps; each is -1 if unbounded
+	minLen, maxLen int
+	// enum lists the allowed values; they are converted into the field's type when checked
+	enum []string
+	// match is the pattern that strings must match in their entirety, and pattern is the pattern as written (less any
+	// enclosing single quotes)
+	match   *regexp.Regexp
+	pattern string
+	// oneOf names the group of mutually exclusive fields to which the field belongs
+	oneOf string
+}
+
+// parseFieldRules returns the constraints declared by attrs for a field of type t with the given format, or nil if
+// there are none
+func parseFieldRules(attrs structFieldAttrs, t reflect.Type, format string) (*fieldRules, error) {
+	r := &fieldRules{minLen: -1, maxLen: -1}
+	found := false
+	if v, ok := attrs.Value("min"); ok {
+		bv, err := parseBound(ruleType(t), v, format, "min")
+		if err != nil {
+			return nil, err
+		}
+		r.min, r.minValue, r.hasMin, found = v, bv, true, true
+	}
+	if v, ok := attrs.Value("max"); ok {
+		bv, err := parseBound(ruleType(t), v, format, "max")
+		if err != nil {
+			return nil, err
+		}
+		r.max, r.maxValue, r.hasMax, found = v, bv, true, true
+	}
+	if v, ok := attrs.Value("len"); ok {
+		var err error
+		if r.minLen, r.maxLen, err = parseLenRange(v); err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	if v, ok := attrs.Value("enum"); ok {
+		r.enum, found = strings.Split(v, "|"), true
+	}
+	if v, ok := attrs.Value("match"); ok {
+		if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+			v = v[1 : len(v)-1]
+		}
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern %q: %w", v, err)
+		}
+		r.match, r.pattern, found = re, v, true
+	}
+	if v, ok := attrs.Value("oneof"); ok {
+		r.oneOf, found = v, true
+	}
+	if !found {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// ruleType returns the type of the values of a field of type t to which the min:, max:, enum:, and match: constraints
+// apply: the elements of a slice (other than a []byte), with pointers dereferenced
+func ruleType(t reflect.Type) reflect.Type {
+	t = derefType(t)
+	if k := t.Kind(); (k == reflect.Slice || k == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+		t = derefType(t.Elem())
+	}
+	return t
+}
+
+// parseBound returns s, the value of the tag option named option, converted into a value of type t, which must be a
+// number or duration; durations are parsed per format
+func parseBound(t reflect.Type, s string, format string, option string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t == durationType {
+			var d time.Duration
+			d, err = durationFromIntf(s, format)
+			v.SetInt(int64(d))
+			break
+		}
+		var n int64
+		n, err = strconv.ParseInt(s, 0, t.Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		n, err = strconv.ParseUint(s, 0, t.Bits())
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, t.Bits())
+		v.SetFloat(f)
+	default:
+		return v, fmt.Errorf("tag option %s: cannot be used with %s", option, t)
+	}
+	if err != nil {
+		return v, fmt.Errorf("invalid %s value %q: %w", option, s, err)
+	}
+	return v, nil
+}
+
+// parseLenRange parses the value of a "len:" tag option, which is either a length (eg: "3") or an inclusive range of
+// lengths of which either bound may be omitted (eg: "1-8", "1-", or "-8"); an omitted bound is returned as -1
+func parseLenRange(s string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+	min, max := -1, -1
+	var err error
+	if lo != "" {
+		if min, err = strconv.Atoi(lo); err != nil || min < 0 {
+			return 0, 0, fmt.Errorf("invalid len %q", s)
+		}
+	}
+	if hi != "" {
+		if max, err = strconv.Atoi(hi); err != nil || max < 0 {
+			return 0, 0, fmt.Errorf("invalid len %q", s)
+		}
+	}
+	if (min == -1 && max == -1) || (max != -1 && min > max) {
+		return 0, 0, fmt.Errorf("invalid len %q", s)
+	}
+	return min, max, nil
+}
+
+// fieldSource is a value or child node from which a struct field was unmarshaled
+type fieldSource struct {
+	// node is the node holding value, or the child node itself if value is nil
+	node  *document.Node
+	value *document.Value
+}
+
+// position returns the position of the value or child node
+func (s fieldSource) position() document.Position {
+	if s.value != nil {
+		return valuePosition(s.node, s.value)
+	}
+	return s.node.Span.Start
+}
+
+// fieldSources returns the values and child nodes from which the field of a struct named name (and described by fld)
+// was unmarshaled, in the order in which they were unmarshaled; the struct was unmarshaled from node (which is nil if
+// it was unmarshaled from the nodes of a document) or nodes, and argFields are its fields tagged ",arg". Fields
+// capturing a node's properties or children are represented by the node itself or its first child, respectively. It
+// returns nil if the field was absent.
+func fieldSources(c *unmarshalContext, argFields []*structFieldDetails, name string, fld *structFieldDetails, node *document.Node, nodes []*document.Node) []fieldSource {
+	var sources []fieldSource
+	if node != nil {
+		switch {
+		case fld.Attrs.Has("args"):
+			for i := len(argFields); i < len(node.Arguments); i++ {
+				sources = append(sources, fieldSource{node: node, value: node.Arguments[i]})
+			}
+			return sources
+		case fld.Attrs.Has("props"):
+			if node.Properties.Len() > 0 {
+				return []fieldSource{{node: node}}
+			}
+			return nil
+		case fld.Attrs.Has("children"):
+			if len(nodes) > 0 {
+				return []fieldSource{{node: nodes[0]}}
+			}
+			return nil
+		case fld.Attrs.Has("arg"):
+			if i := slices.Index(argFields, fld); i != -1 && i < len(node.Arguments) {
+				sources = append(sources, fieldSource{node: node, value: node.Arguments[i]})
+			}
+		}
+		for key, val := range node.Properties.Unordered() {
+			if normalizeKey(key, c.indexer.caseSensitive) == name {
+				sources = append(sources, fieldSource{node: node, value: val})
+			}
+		}
+	}
+	for _, child := range nodes {
+		if normalizeKey(child.Name.ValueString(), c.indexer.caseSensitive) == name {
+			sources = append(sources, fieldSource{node: child})
+		}
+	}
+	return sources
+}
+
+// fieldPositions returns the positions of the sources of a field as returned by fieldSources; a field capturing a
+// node's arguments is represented by the position of the first of them
+func fieldPositions(fld *structFieldDetails, sources []fieldSource) []document.Position {
+	if fld.Attrs.Has("args") && len(sources) > 1 {
+		sources = sources[:1]
+	}
+	var positions []document.Position
+	for _, src := range sources {
+		positions = append(positions, src.position())
+	}
+	return positions
+}
+
+// errMissingField returns the error reported when the field named name (and described by fld) is tagged ",required"
+// but absent; node is nil if the field's struct was unmarshaled from the nodes of a document
+func errMissingField(name string, fld *structFieldDetails, node *document.Node) error {
+	switch {
+	case fld.Attrs.Has("args"):
+		return errors.New("missing required arguments")
+	case fld.Attrs.Has("props"):
+		return errors.New("missing required properties")
+	case fld.Attrs.Has("children"):
+		return errors.New("missing required child nodes")
+	case fld.Attrs.Has("arg") && node != nil:
+		return fmt.Errorf("missing required argument %q", name)
+	case node == nil:
+		return fmt.Errorf("missing required node %q", name)
+	default:
+		return fmt.Errorf("missing required property or child node %q", name)
+	}
+}
+
+// fieldErrorFunc reports err, which concerns the struct field being checked, at pos under key; it returns an error per
+// unmarshalContext.fieldError
+type fieldErrorFunc func(err error, pos document.Position, key string) error
+
+// fieldKey returns the key with which errors concerning the field named name (and described by fld) are reported;
+// fields that capture a node's arguments, properties, or children are reported as part of the node itself
+func fieldKey(name string, fld *structFieldDetails) string {
+	if fld.IsCapture() {
+		return ""
+	}
+	return name
+}
+
+// checkStructFields checks the fields of destStruct that are required, have defaults, or have constraints or type
+// annotations, once
+// destStruct has been unmarshaled from node (which is nil if destStruct was unmarshaled from the nodes of a document or
+// the children of a node) or nodes, the children of parent (which is nil for the nodes of a document), and pos is the
+// position at which absent fields are reported:
+//   - an error is returned for each absent field tagged ",required", unless parent is one of c.deferred
+//   - each absent field with a default that still holds its zero value is assigned its default, which is converted
+//     per setReflectValueFromIntf and honors the field's format
+//   - the value of each field with constraints is checked against them
+//   - the values of each field tagged with a type annotation are checked per checkAnnotations
+//   - an error is returned if more than one field tagged with the same "oneof:" group is present
+func checkStructFields(c *unmarshalContext, node *document.Node, parent *document.Node, nodes []*document.Node, pos document.Position, destStruct reflect.Value) error {
+	typeDetails := c.indexer.Get(destStruct.Type())
+	if len(typeDetails.StructCheckedFieldNames) == 0 {
+		return nil
+	}
+
+	var (
+		groups     map[string][]string
+		groupNames []string
+	)
+	argFields := typeDetails.StructAttrs["arg"]
+	for _, name := range typeDetails.StructCheckedFieldNames {
+		fld := typeDetails.StructFields[name]
+		sources := fieldSources(c, argFields, name, fld, node, nodes)
+		positions := fieldPositions(fld, sources)
+		field := fld.GetValueFrom(destStruct)
+		report := func(err error, pos document.Position, key string) error {
+			return c.fieldError(err, pos, key, destStruct.Type(), fld)
+		}
+
+		if len(positions) == 0 {
+			var err error
+			if fld.IsRequired() && !c.deferred[parent] {
+				err = errMissingField(name, fld, node)
+			} else if fld.HasDefault && field.IsZero() {
+				if _, err = setReflectValueFromIntf(c, field, fld.Default, fld.Format); err != nil {
+					err = fmt.Errorf("invalid default value %q: %w", fld.Default, err)
+				}
+			} else {
+				// absent fields are only checked against their constraints if they have been given a default
+				continue
+			}
+			if err = report(err, pos, fieldKey(name, fld)); err != nil {
+				return err
+			}
+			if !fld.HasDefault {
+				continue
+			}
+		}
+
+		if fld.Annotation != "" {
+			if err := checkAnnotations(c, report, structField(destStruct.Type(), fld).Type, name, fld, sources); err != nil {
+				return err
+			}
+		}
+		if fld.Rules == nil {
+			continue
+		}
+		if err := checkField(c, report, name, fld, field, positions, pos); err != nil {
+			return err
+		}
+		if group := fld.Rules.oneOf; group != "" && len(positions) > 0 {
+			if groups == nil {
+				groups = make(map[string][]string)
+			}
+			if _, ok := groups[group]; !ok {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], name)
+		}
+	}
+
+	for _, group := range groupNames {
+		names := groups[group]
+		if len(names) < 2 {
+			continue
+		}
+		fld := typeDetails.StructFields[names[1]]
+		positions := fieldPositions(fld, fieldSources(c, argFields, names[1], fld, node, nodes))
+		if err := c.fieldError(errOneOf(names), positions[0], fieldKey(names[1], fld), destStruct.Type(), fld); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errOneOf returns the error reported when the fields named names, which belong to the same "oneof:" group, are all
+// present
+func errOneOf(names []string) error {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return fmt.Errorf("only one of %s may be present", strings.Join(quoted, ", "))
+}
+
+// checkField checks field, the field of a struct named name (and described by fld), against the constraints declared by
+// its tag options, reporting violations via report; positions are the positions of the values from which it was
+// unmarshaled, and pos is the position at which to report violations if there are none
+func checkField(c *unmarshalContext, report fieldErrorFunc, name string, fld *structFieldDetails, field reflect.Value, positions []document.Position, pos document.Position) error {
+	r := fld.Rules
+	v := reflect.Indirect(field)
+	if !v.IsValid() {
+		return nil
+	}
+	if len(positions) > 0 {
+		// if the field was unmarshaled from more than one value, the last one took precedence
+		pos = positions[len(positions)-1]
+	}
+	key := fieldKey(name, fld)
+
+	if r.minLen != -1 || r.maxLen != -1 {
+		if err := report(checkLength(v, r), pos, key); err != nil {
+			return err
+		}
+	}
+	if !r.hasMin && !r.hasMax && len(r.enum) == 0 && r.match == nil {
+		return nil
+	}
+
+	// the remaining constraints apply to each element of a slice
+	if k := v.Kind(); (k == reflect.Slice || k == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		// the elements of a field tagged ",multiple" were each unmarshaled from a node, unless the field already held
+		// elements before unmarshaling
+		multiple := fld.IsMultiple() && len(positions) == v.Len()
+		for i := 0; i < v.Len(); i++ {
+			el := reflect.Indirect(v.Index(i))
+			if !el.IsValid() {
+				continue
+			}
+			elPos, elKey := pos, key
+			if multiple {
+				elPos, elKey = positions[i], name+"["+strconv.Itoa(i)+"]"
+			}
+			if err := report(checkValue(c, el, fld), elPos, elKey); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return report(checkValue(c, v, fld), pos, key)
+}
+
+// checkLength returns an error if the length of v, which must be a string, slice, array, or map, is outside the bounds
+// in r; the length of a string is its number of characters
+func checkLength(v reflect.Value, r *fieldRules) error {
+	switch v.Kind() {
+	case reflect.String:
+		return checkLen(utf8.RuneCountInString(v.String()), r)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return checkLen(v.Len(), r)
+	default:
+		return fmt.Errorf("tag option len: cannot be used with %s", v.Type())
+	}
+}
+
+// checkLen returns an error if n, the length of a value, is outside the bounds in r
+func checkLen(n int, r *fieldRules) error {
+	switch {
+	case r.minLen == r.maxLen && n != r.minLen:
+		return fmt.Errorf("length %d is not %d", n, r.minLen)
+	case r.minLen != -1 && n < r.minLen:
+		return fmt.Errorf("length %d is less than the minimum of %d", n, r.minLen)
+	case r.maxLen != -1 && n > r.maxLen:
+		return fmt.Errorf("length %d is greater than the maximum of %d", n, r.maxLen)
+	}
+	return nil
+}
+
+// checkValue returns an error if v, a value of the field described by fld (or an element of it), violates the field's
+// min:, max:, enum:, or match: constraints
+func checkValue(c *unmarshalContext, v reflect.Value, fld *structFieldDetails) error {
+	r := fld.Rules
+	if r.hasMin {
+		if n := compareToBound(v, r.minValue); n < 0 {
+			return fmt.Errorf("%s is less than the minimum of %s", valueString(v), r.min)
+		}
+	}
+	if r.hasMax {
+		if n := compareToBound(v, r.maxValue); n > 0 {
+			return fmt.Errorf("%s is greater than the maximum of %s", valueString(v), r.max)
+		}
+	}
+
+	if len(r.enum) > 0 {
+		found := false
+		for _, s := range r.enum {
+			ev, err := ruleValue(c, v.Type(), s, fld.Format)
+			if err != nil {
+				return fmt.Errorf("invalid enum value %q: %w", s, err)
+			}
+			if reflect.DeepEqual(ev.Interface(), v.Interface()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not one of %s", valueString(v), strings.Join(r.enum, ", "))
+		}
+	}
+
+	if r.match != nil {
+		if s := coerce.ToString(v.Interface()); !r.match.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, r.pattern)
+		}
+	}
+	return nil
+}
+
+// ruleValue returns s, the value of a tag option, converted into a new value of type t per setReflectValueFromIntf
+func ruleValue(c *unmarshalContext, t reflect.Type, s string, format string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	_, err := setReflectValueFromIntf(c, v, s, format)
+	return v, err
+}
+
+// compareToBound returns -1, 0, or 1 as v is less than, equal to, or greater than bound, a value of the same type
+// returned by parseBound
+func compareToBound(v reflect.Value, bound reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(v.Int(), bound.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(v.Uint(), bound.Uint())
+	default:
+		return cmp.Compare(v.Float(), bound.Float())
+	}
+}
+
+// valueString returns a representation of v for use in error messages
+func valueString(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
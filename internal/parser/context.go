@@ -17,6 +17,11 @@ func (p ParseFlags) Has(f ParseFlags) bool {
 
 const (
 	ParseComments ParseFlags = 1 << iota
+	// OmitPositions disables recording of source positions (Span) on parsed nodes and values
+	OmitPositions
+	// RecoverErrors causes Parse to record syntax errors on the ParseContext and resynchronize at the next newline,
+	// semicolon, or brace instead of failing
+	RecoverErrors
 )
 
 type ParseContextOptions struct {
@@ -56,6 +61,12 @@ type ParseContext struct {
 
 	lastAddedNode *document.Node
 	recent        recentTokens
+	// most recent token that was not whitespace, a comment, or a terminator; used to locate the end of each node
+	lastSignificant tokenizer.Token
+	// true if a syntax error was encountered and tokens are being discarded until the parser can resynchronize
+	recovering bool
+	// syntax errors encountered while parsing with the RecoverErrors flag
+	errors tokenizer.SyntaxErrors
 }
 
 type pendingComment struct {
@@ -72,6 +83,69 @@ func (p pendingComment) CopyBytes() []byte {
 	return r
 }
 
+// positions returns true if source positions should be recorded on nodes and values
+func (c *ParseContext) positions() bool {
+	return !c.opts.Flags.Has(OmitPositions)
+}
+
+// trackToken records t as the most recent significant token if it is not whitespace, a comment, or a terminator
+func (c *ParseContext) trackToken(t tokenizer.Token) {
+	switch t.ID {
+	case tokenizer.Whitespace, tokenizer.Newline, tokenizer.SingleLineComment, tokenizer.MultiLineComment,
+		tokenizer.TokenComment, tokenizer.Semicolon, tokenizer.Continuation, tokenizer.EOF:
+	default:
+		c.lastSignificant = t
+	}
+}
+
+// typedStart returns the position at which a node name or value t starts, including its type annotation (if valid)
+func typedStart(t tokenizer.Token, typeAnnot tokenizer.Token) document.Position {
+	if !typeAnnot.Valid() {
+		return document.TokenStart(t)
+	}
+	// the opening parenthesis immediately precedes the type annotation
+	p := document.TokenStart(typeAnnot)
+	p.Column--
+	p.Offset--
+	return p
+}
+
+// setNodeName sets node's name from t and records the node's starting position
+func (c *ParseContext) setNodeName(node *document.Node, t tokenizer.Token) error {
+	if err := node.SetNameToken(t); err != nil {
+		return err
+	}
+	if c.positions() {
+		node.Name.Span = document.TokenSpan(t, t)
+		node.Span.Start = typedStart(t, c.typeAnnot)
+	}
+	return nil
+}
+
+// addArgument adds an argument to the current node from t and typeAnnot, recording its position
+func (c *ParseContext) addArgument(t tokenizer.Token, typeAnnot tokenizer.Token) error {
+	node := c.currentNode()
+	if err := node.AddArgumentToken(t, typeAnnot); err != nil {
+		return err
+	}
+	if c.positions() {
+		node.Arguments[len(node.Arguments)-1].Span = document.Span{Start: typedStart(t, typeAnnot), End: document.TokenEnd(t)}
+	}
+	return nil
+}
+
+// addProperty adds a property to the current node from name, value, and typeAnnot, recording its position
+func (c *ParseContext) addProperty(name tokenizer.Token, value tokenizer.Token, typeAnnot tokenizer.Token) error {
+	v, err := c.currentNode().AddPropertyToken(name, value, typeAnnot)
+	if err != nil {
+		return err
+	}
+	if c.positions() {
+		v.Span = document.Span{Start: document.TokenStart(name), End: document.TokenEnd(value)}
+	}
+	return nil
+}
+
 func (c *ParseContext) RelaxedNonCompliant() relaxed.Flags {
 	return c.opts.RelaxedNonCompliant
 }
@@ -107,6 +181,9 @@ func (c *ParseContext) popNode() (*document.Node, error) {
 		return nil, errNodeStackEmpty
 	}
 	node := c.currentNode()
+	if c.positions() && c.lastSignificant.Valid() {
+		node.Span.End = document.TokenEnd(c.lastSignificant)
+	}
 	c.node = c.node[0 : len(c.node)-1]
 	return node, nil
 }
@@ -142,3 +219,28 @@ func (c *ParseContext) popState() (parserState, error) {
 	c.states = c.states[0 : len(c.states)-1]
 	return c.state, nil
 }
+
+// completedNodeCount returns the number of top-level nodes at the start of the document that have been fully parsed
+func (c *ParseContext) completedNodeCount() int {
+	n := len(c.doc.Nodes)
+	if n > 0 && len(c.node) > 0 && c.node[0] == c.doc.Nodes[n-1] {
+		// the last top-level node is still being parsed
+		n--
+	}
+	return n
+}
+
+// TakeCompletedNodes removes any fully-parsed top-level nodes from the document and returns them; the document retains
+// only the node currently being parsed (if any). This allows a caller to process a document one node at a time without
+// accumulating the entire document in memory.
+func (c *ParseContext) TakeCompletedNodes() []*document.Node {
+	n := c.completedNodeCount()
+	if n == 0 {
+		return nil
+	}
+
+	completed := c.doc.Nodes[0:n:n]
+	remain := make([]*document.Node, 0, 4)
+	c.doc.Nodes = append(remain, c.doc.Nodes[n:]...)
+	return completed
+}
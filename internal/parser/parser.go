@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/sblinch/kdl-go/document"
 	"github.com/sblinch/kdl-go/internal/tokenizer"
@@ -67,8 +68,30 @@ func (p *Parser) tokenContext(context []tokenizer.Token, idx int) string {
 	return string(b)
 }
 
-func (p *Parser) annotatedError(err error, t tokenizer.Token, context []tokenizer.Token, contextIndex int) error {
-	return fmt.Errorf("parse failed: %w at line %d, column %d:\n%s", err, t.Line, t.Column, p.tokenContext(context, contextIndex))
+// annotatedError returns a *tokenizer.SyntaxError wrapping err and describing the location of t, the tokens that would
+// have been accepted in state, and a snippet built from the tokens surrounding context[contextIndex]
+func (p *Parser) annotatedError(err error, t tokenizer.Token, state parserState, context []tokenizer.Token, contextIndex int) *tokenizer.SyntaxError {
+	return &tokenizer.SyntaxError{
+		Op:       "parse",
+		Err:      err,
+		Line:     t.Line + 1,
+		Column:   t.Column + 1,
+		Offset:   t.Offset,
+		Token:    t,
+		Expected: expectedTokens(state),
+		Snippet:  p.tokenContext(context, contextIndex),
+	}
+}
+
+// expectedTokens returns the token IDs and classes that have transitions defined in state, in ascending order
+func expectedTokens(state parserState) []tokenizer.TokenID {
+	transitions := stateTransitions[state]
+	ids := make([]tokenizer.TokenID, 0, len(transitions))
+	for id := range transitions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
 }
 
 // Parse parses a single token (usually generated by tokenizer.Scanner) into the given context, and returns a non-nil
@@ -107,12 +130,27 @@ func (p *Parser) parse(c *ParseContext, token tokenizer.Token) error {
 	return err
 }
 
+// Parse parses a single token into the given context and returns a *tokenizer.SyntaxError on failure; if the context
+// was created with the RecoverErrors flag, the error is instead recorded on the context and Parse returns nil
 func (p *Parser) Parse(c *ParseContext, token tokenizer.Token) error {
 	c.recent.Add(token)
+	if c.recovering {
+		c.resync(token)
+		return nil
+	}
+
+	state := c.state
 	if err := p.parse(c, token); err != nil {
 		tokens := c.recent.Get()
-		return p.annotatedError(err, token, tokens, len(tokens)-1)
+		serr := p.annotatedError(err, token, state, tokens, len(tokens)-1)
+		if !c.opts.Flags.Has(RecoverErrors) {
+			return serr
+		}
+		c.Recover(serr)
+		c.resync(token)
+		return nil
 	}
+	c.trackToken(token)
 
 	return nil
 }
@@ -126,9 +164,9 @@ func (p *Parser) ParseAll(tokens []tokenizer.Token) (*document.Document, error)
 // ParseAllContext parses a slice of tokens using the given context and returns the resulting Document, or a non-nil
 // error on failure
 func (p *Parser) ParseAllContext(c *ParseContext, tokens []tokenizer.Token) (*document.Document, error) {
-	for i, t := range tokens {
+	for _, t := range tokens {
 		if err := p.Parse(c, t); err != nil {
-			return nil, p.annotatedError(err, t, tokens, i)
+			return nil, err
 		}
 	}
 
@@ -141,7 +179,7 @@ func (p *Parser) ParseAllContext(c *ParseContext, tokens []tokenizer.Token) (*do
 	}
 
 	if err := p.Parse(c, eof); err != nil {
-		return nil, p.annotatedError(err, eof, tokens, len(tokens)-1)
+		return nil, err
 	}
 
 	return c.doc, nil
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sblinch/kdl-go/document"
 	"github.com/sblinch/kdl-go/internal/generator"
 	"github.com/sblinch/kdl-go/internal/tokenizer"
 	"github.com/sblinch/kdl-go/relaxed"
@@ -977,3 +978,55 @@ func TestParserProfile(t *testing.T) {
 	}
 
 }
+
+func TestParsePositions(t *testing.T) {
+	input := []byte("// leading\nnode 1 (u8)2 key=\"a\\nb\" {\r\n\tchild \"x\"\n}\n(t)other; last\n")
+
+	tokens, err := tokenizer.NewSlice(input).ScanAll()
+	if err != nil {
+		t.Fatalf("failed to tokenize: %v", err)
+	}
+	doc, err := New().ParseAll(tokens)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	span := func(line, col, off, endLine, endCol, endOff int) document.Span {
+		return document.Span{
+			Start: document.Position{Line: line, Column: col, Offset: off},
+			End:   document.Position{Line: endLine, Column: endCol, Offset: endOff},
+		}
+	}
+
+	node := doc.Nodes[0]
+	key, _ := node.Properties.Get("key")
+	tests := []struct {
+		name string
+		got  document.Span
+		want document.Span
+	}{
+		{"node", node.Span, span(2, 1, 11, 4, 2, 50)},
+		{"name", node.Name.Span, span(2, 1, 11, 2, 5, 15)},
+		{"arg", node.Arguments[0].Span, span(2, 6, 16, 2, 7, 17)},
+		{"typedArg", node.Arguments[1].Span, span(2, 8, 18, 2, 13, 23)},
+		{"prop", key.Span, span(2, 14, 24, 2, 24, 34)},
+		{"child", node.Children[0].Span, span(3, 2, 39, 3, 11, 48)},
+		{"typedNode", doc.Nodes[1].Span, span(5, 1, 51, 5, 9, 59)},
+		{"last", doc.Nodes[2].Span, span(5, 11, 61, 5, 15, 65)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Fatalf("got %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+
+	c := New().NewContextOptions(ParseContextOptions{Flags: OmitPositions})
+	if doc, err = New().ParseAllContext(c, tokens); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if doc.Nodes[0].Span.IsValid() || doc.Nodes[0].Arguments[0].Span.IsValid() {
+		t.Fatalf("positions recorded despite OmitPositions")
+	}
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// Recover records err as a syntax error in the document and discards subsequent tokens until the parser can
+// resynchronize at the next newline, semicolon, or brace
+func (c *ParseContext) Recover(err *tokenizer.SyntaxError) {
+	c.errors = append(c.errors, err)
+	c.recovering = true
+}
+
+// Errors returns the syntax errors recorded while parsing with the RecoverErrors flag, in the order they were
+// encountered
+func (c *ParseContext) Errors() tokenizer.SyntaxErrors {
+	return c.errors
+}
+
+// isNodeState returns true if state is one of the states used while parsing a node's name, arguments, and properties
+func isNodeState(state parserState) bool {
+	switch state {
+	case stateNode, stateNodeParams, stateNodeEnd, stateArgProp, stateProperty, statePropertyValue:
+		return true
+	default:
+		return false
+	}
+}
+
+// resync attempts to restore the parser to a consistent state after a syntax error; if t is a newline, semicolon,
+// brace, or EOF, the node being parsed (if any) is terminated and resync returns true, otherwise t is discarded and
+// resync returns false
+func (c *ParseContext) resync(t tokenizer.Token) bool {
+	switch t.ID {
+	case tokenizer.Newline, tokenizer.Semicolon, tokenizer.EOF, tokenizer.BraceOpen, tokenizer.BraceClose:
+	default:
+		return false
+	}
+
+	c.recovering = false
+	c.continuation = false
+	c.ignoreNextNode = false
+	c.ignoreNextArgProp = false
+	c.ident.Clear()
+	c.typeAnnot.Clear()
+
+	// abandon any partially-parsed type annotation
+	for c.state == stateTypeAnnot || c.state == stateTypeDone {
+		if _, err := c.popState(); err != nil {
+			c.state = stateDocument
+		}
+	}
+	inNode := isNodeState(c.state)
+
+	switch t.ID {
+	case tokenizer.BraceOpen:
+		if inNode {
+			// the brace begins the children of the current node
+			c.state = stateNodeParams
+		} else {
+			// the brace begins a child block with no node to belong to, so ignore its contents
+			c.ignoreChildren++
+		}
+		c.pushState(stateChildren)
+
+	case tokenizer.BraceClose:
+		if inNode {
+			_, _, _ = c.popNodeAndState()
+		}
+		if c.state == stateChildren {
+			if c.ignoreChildren > 0 {
+				c.ignoreChildren--
+			}
+			_, _ = c.popState()
+		}
+
+	default:
+		if inNode {
+			_, _, _ = c.popNodeAndState()
+		}
+	}
+
+	return true
+}
@@ -34,7 +34,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 				node = c.addNode()
 			}
 
-			if err := node.SetNameToken(t); err != nil {
+			if err := c.setNodeName(node, t); err != nil {
 				return err
 			}
 
@@ -124,7 +124,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 			} else {
 				node = c.addNode()
 			}
-			if err := node.SetNameToken(t); err != nil {
+			if err := c.setNodeName(node, t); err != nil {
 				return err
 			}
 
@@ -266,7 +266,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if err := c.currentNode().AddArgumentToken(t, c.typeAnnot); err != nil {
+			} else if err := c.addArgument(t, c.typeAnnot); err != nil {
 				return err
 			}
 
@@ -288,7 +288,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 			// a numeric value inside a node declaration is always an argument
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if err := c.currentNode().AddArgumentToken(t, c.typeAnnot); err != nil {
+			} else if err := c.addArgument(t, c.typeAnnot); err != nil {
 				return err
 			}
 
@@ -345,7 +345,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 		tokenizer.TokenComment: func(c *ParseContext, t tokenizer.Token) error {
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if err := c.currentNode().AddArgumentToken(c.ident, c.typeAnnot); err != nil {
+			} else if err := c.addArgument(c.ident, c.typeAnnot); err != nil {
 				return err
 			}
 			c.typeAnnot.Clear()
@@ -361,7 +361,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 			if c.ident.Valid() {
 				if c.ignoreNextArgProp {
 					c.ignoreNextArgProp = false
-				} else if err := c.currentNode().AddArgumentToken(c.ident, c.typeAnnot); err != nil {
+				} else if err := c.addArgument(c.ident, c.typeAnnot); err != nil {
 					return err
 				}
 				c.typeAnnot.Clear()
@@ -392,7 +392,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 			// whitespace indicates it was definitely an arg, not a prop
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if err := c.currentNode().AddArgumentToken(c.ident, c.typeAnnot); err != nil {
+			} else if err := c.addArgument(c.ident, c.typeAnnot); err != nil {
 				return err
 			}
 			c.typeAnnot.Clear()
@@ -407,7 +407,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 				// if we're at the end of the node and have an identifier but didn't find an equal sign, it was just an argument
 				if c.ignoreNextArgProp {
 					c.ignoreNextArgProp = false
-				} else if err := c.currentNode().AddArgumentToken(c.ident, c.typeAnnot); err != nil {
+				} else if err := c.addArgument(c.ident, c.typeAnnot); err != nil {
 					return err
 				}
 				c.typeAnnot.Clear()
@@ -422,7 +422,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 			// if we found a value, but we already have an identifier queued, it was an argument, so save it
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if err := c.currentNode().AddArgumentToken(c.ident, c.typeAnnot); err != nil {
+			} else if err := c.addArgument(c.ident, c.typeAnnot); err != nil {
 				return err
 			}
 			c.typeAnnot.Clear()
@@ -442,7 +442,7 @@ var stateTransitions = map[parserState]map[tokenizer.TokenID]stateTransitionFunc
 		tokenizer.ClassValue: func(c *ParseContext, t tokenizer.Token) error {
 			if c.ignoreNextArgProp {
 				c.ignoreNextArgProp = false
-			} else if _, err := c.currentNode().AddPropertyToken(c.ident, t, c.typeAnnot); err != nil {
+			} else if err := c.addProperty(c.ident, t, c.typeAnnot); err != nil {
 				return err
 			}
 			c.typeAnnot.Clear()
@@ -72,6 +72,12 @@ func isBareIdentifierStartChar(c rune, r relaxed.Flags) bool {
 	if isDigit(c) {
 		return false
 	}
+	switch c {
+	case '.', '_', '?':
+		// the scanner rejects these as the first character of an identifier (see readIdentifier), so identifiers beginning
+		// with them must be quoted
+		return r.Permit(relaxed.NGINXSyntax)
+	}
 
 	return true
 }
@@ -0,0 +1,62 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError describes a failure to scan or parse a KDL document
+type SyntaxError struct {
+	// Op is "scan" if the error occurred while tokenizing the input, or "parse" if it occurred while parsing tokens
+	Op string
+	// Err is the underlying error
+	Err error
+	// Line is the 1-based line number at which the error occurred
+	Line int
+	// Column is the 1-based column number at which the error occurred
+	Column int
+	// Offset is the 0-based byte offset from the start of the input at which the error occurred
+	Offset int
+	// Token is the offending token, if the error occurred while parsing; otherwise Token.Valid() returns false
+	Token Token
+	// Expected lists the token types and classes that would have been accepted in place of Token, if known
+	Expected []TokenID
+	// Snippet contains a sample of the input surrounding the error, with a caret on a second line indicating the
+	// error location
+	Snippet string
+}
+
+// Error returns a description of the error including its location and snippet; for compatibility with earlier
+// releases, parse errors report the 0-based line and column of the offending token
+func (e *SyntaxError) Error() string {
+	if e.Op == "parse" {
+		return fmt.Sprintf("parse failed: %s at line %d, column %d:\n%s", e.Err, e.Line-1, e.Column-1, e.Snippet)
+	}
+	return fmt.Sprintf("scan failed: %s at line %d, column %d\n%s", e.Err, e.Line, e.Column, e.Snippet)
+}
+
+// Unwrap returns the underlying error
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// ExpectedString returns a comma-separated list of the names of the expected token types and classes
+func (e *SyntaxError) ExpectedString() string {
+	names := make([]string, len(e.Expected))
+	for i, id := range e.Expected {
+		names[i] = id.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// SyntaxErrors is a list of syntax errors encountered while parsing a document in error-recovery mode
+type SyntaxErrors []*SyntaxError
+
+// Error returns the descriptions of all errors, separated by newlines
+func (e SyntaxErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -35,6 +35,8 @@ type Scanner struct {
 	peeked              []peeked
 	line                int
 	column              int
+	offset              int
+	lastCR              bool
 	token               Token
 	err                 error
 	marks               []int
@@ -141,11 +143,16 @@ func (s *Scanner) get() (rune, error) {
 	}
 
 	if isNewline(c) {
-		s.line++
-		s.column = 1
+		// a CRLF pair counts as a single line break
+		if c != '\n' || !s.lastCR {
+			s.line++
+		}
+		s.column = 0
 	} else {
 		s.column++
 	}
+	s.lastCR = c == '\r'
+	s.offset += size
 
 	s.input = s.input[size:]
 	s.len -= size
@@ -305,11 +312,17 @@ func (s *Scanner) readUntil(stop func(c rune) bool, includeStopChar bool) ([]byt
 	}
 }
 
-// Offset returns the current byte offset in s.raw
+// Offset returns the current byte offset in s.raw; when streaming from an io.Reader, this is relative to the start of
+// the current input buffer (see InputOffset for the offset relative to the start of the input stream)
 func (s *Scanner) Offset() int {
 	return len(s.raw) - len(s.input)
 }
 
+// InputOffset returns the number of bytes consumed from the start of the input stream
+func (s *Scanner) InputOffset() int {
+	return s.offset
+}
+
 type staticScanner struct {
 	s  Scanner
 	mu sync.Mutex
@@ -331,6 +344,7 @@ func ScanOne(b []byte) (Token, error) {
 	staticScan.s.len = len(b)
 	staticScan.s.peeked = staticScan.s.peeked[:0]
 	staticScan.s.marks = staticScan.s.marks[:0]
+	staticScan.s.line, staticScan.s.column, staticScan.s.offset = 0, 0, 0
 	return staticScan.s.readNext()
 }
 
@@ -339,6 +353,7 @@ func (s *Scanner) readNext() (Token, error) {
 	token := Token{
 		Line:   s.line,
 		Column: s.column,
+		Offset: s.offset,
 	}
 
 	c, size, err := s.peekSize()
@@ -627,7 +642,14 @@ func (s *Scanner) extractLineAtOffset(offset int) string {
 // annotatedError annotates err with the input line/column and positionSummary from the input buffer
 func (s *Scanner) annotatedError(err error) error {
 	line, column := s.Pos()
-	return fmt.Errorf("scan failed: %w at line %d, column %d\n%s", err, line, column, s.extractLineAtOffset(len(s.raw)-len(s.input)))
+	return &SyntaxError{
+		Op:      "scan",
+		Err:     err,
+		Line:    line,
+		Column:  column,
+		Offset:  s.offset,
+		Snippet: s.extractLineAtOffset(len(s.raw) - len(s.input)),
+	}
 }
 
 // SimpleLogger provides a simple logger that writes to stderr; this can be assigned to Scanner.Logger for debugging
@@ -747,6 +769,7 @@ func (s *Scanner) Scan() bool {
 		return true
 	} else if s.err == io.EOF {
 		s.token = eofToken
+		s.token.Line, s.token.Column, s.token.Offset = s.line, s.column, s.offset
 		return true
 	} else {
 		s.err = s.annotatedError(s.err)
@@ -754,6 +777,42 @@ func (s *Scanner) Scan() bool {
 	}
 }
 
+// Resync discards input following a syntax error returned by Err, allowing Scan to continue from the next newline,
+// semicolon, or brace; returns false if the scanner cannot continue (eg: if it has been closed)
+func (s *Scanner) Resync() bool {
+	var serr *SyntaxError
+	if s.err == nil || !errors.As(s.err, &serr) {
+		return s.err == nil
+	}
+
+	s.err = nil
+	s.marks = s.marks[:0]
+	s.peeked = s.peeked[:0]
+	for {
+		if s.len <= utf8.UTFMax*2 {
+			s.refill()
+		}
+		c, err := s.peek()
+		switch {
+		case err == io.EOF:
+			return true
+		case err == ErrInvalidRune:
+			// discard the invalid byte
+			s.input = s.input[1:]
+			s.len--
+			s.offset++
+			s.column++
+		case err != nil:
+			s.err = err
+			return false
+		case isNewline(c), c == ';', c == '{', c == '}':
+			return true
+		default:
+			s.skip()
+		}
+	}
+}
+
 // Token returns the token read by Scan
 func (s *Scanner) Token() Token {
 	return s.token
@@ -125,6 +125,24 @@ func (t TokenID) String() string {
 		return "Continuation"
 	case EOF:
 		return "EOF"
+	case ClassWhitespace:
+		return "ClassWhitespace"
+	case ClassValue:
+		return "ClassValue"
+	case ClassIdentifier:
+		return "ClassIdentifier"
+	case ClassNonStringValue:
+		return "ClassNonStringValue"
+	case ClassNumber:
+		return "ClassNumber"
+	case ClassString:
+		return "ClassString"
+	case ClassTerminator:
+		return "ClassTerminator"
+	case ClassEndOfLine:
+		return "ClassEndOfLine"
+	case ClassComment:
+		return "ClassComment"
 	default:
 		return "(invalid)"
 	}
@@ -136,9 +154,13 @@ type Token struct {
 	ID TokenID
 	// Data contains the literal data for the token; this may be a subslice of the input buffer (if the entire stream
 	// could be read into a single buffer) or a copy of data from the input buffer, so it should not be modified.
-	Data   []byte
-	Line   int
+	Data []byte
+	// Line is the zero-based line number on which the token starts
+	Line int
+	// Column is the zero-based column (in characters) at which the token starts
 	Column int
+	// Offset is the zero-based byte offset from the start of the input at which the token starts
+	Offset int
 }
 
 // String returns a string representation of the token for debugging
@@ -159,5 +181,23 @@ func (t Token) Valid() bool {
 func (t *Token) Clear() {
 	t.ID = Unknown
 	t.Data = nil
-	t.Line, t.Column = 0, 0
+	t.Line, t.Column, t.Offset = 0, 0, 0
+}
+
+// End returns the zero-based line, column, and byte offset immediately following the end of the token
+func (t Token) End() (line int, column int, offset int) {
+	line, column, offset = t.Line, t.Column, t.Offset+len(t.Data)
+	lastCR := false
+	for _, c := range string(t.Data) {
+		if isNewline(c) {
+			if c != '\n' || !lastCR {
+				line++
+			}
+			column = 0
+		} else {
+			column++
+		}
+		lastCR = c == '\r'
+	}
+	return line, column, offset
 }
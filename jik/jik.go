@@ -0,0 +1,357 @@
+// Package jik converts between JSON and KDL documents using the JSON-in-KDL (JiK) mapping.
+//
+// A JiK document contains a single top-level node (conventionally named "-") representing a JSON value:
+//
+//   - a node with a single argument and no properties or children represents a literal (string, number, boolean, or
+//     null)
+//   - a node with multiple arguments, or with children that are all named "-", represents an array; its elements are
+//     the node's arguments followed by the values of its children
+//   - a node with properties, or with children that are not all named "-", represents an object; its members are the
+//     node's properties followed by its children, keyed by name
+//
+// The (array) and (object) type annotations can be used to disambiguate nodes, such as empty arrays and objects or
+// arrays containing a single value.
+//
+// Numbers are converted losslessly: JSON numbers too large or too precise to be represented by an int64 or float64
+// become *big.Int or *big.Float values, and *big.Int and *big.Float values are written to JSON in full.
+package jik
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+const (
+	// nodeName is the name used for array elements and for the top-level node
+	nodeName = "-"
+	// annotArray is the type annotation identifying an array node
+	annotArray document.TypeAnnotation = "array"
+	// annotObject is the type annotation identifying an object node
+	annotObject document.TypeAnnotation = "object"
+)
+
+// FromJSON reads a single JSON value from r and returns the equivalent JiK document
+func FromJSON(r io.Reader) (*document.Document, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	n := document.NewNode()
+	n.SetName(nodeName)
+	if err := fromJSON(dec, n); err != nil {
+		return nil, fmt.Errorf("converting JSON to KDL: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("converting JSON to KDL: unexpected data after top-level value")
+	}
+
+	doc := document.New()
+	doc.AddNode(n)
+	return doc, nil
+}
+
+// fromJSON reads the next JSON value from dec and stores it in n
+func fromJSON(dec *json.Decoder, n *document.Node) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t {
+	case json.Delim('['):
+		var elems []*document.Node
+		literal := true
+		for dec.More() {
+			elem := document.NewNode()
+			elem.SetName(nodeName)
+			if err := fromJSON(dec, elem); err != nil {
+				return err
+			}
+			literal = literal && isLiteral(elem)
+			elems = append(elems, elem)
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+		// arrays of literals are written as arguments; otherwise every element is written as a child so that the
+		// order of the elements is retained
+		if literal {
+			for _, elem := range elems {
+				n.Arguments = append(n.Arguments, elem.Arguments[0])
+			}
+		} else {
+			n.Children = elems
+		}
+		if len(elems) < 2 {
+			n.Type = annotArray
+		}
+
+	case json.Delim('{'):
+		named := false
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			member := document.NewNode()
+			member.SetName(t.(string))
+			if err := fromJSON(dec, member); err != nil {
+				return err
+			}
+			named = named || t.(string) != nodeName
+			n.AddNode(member)
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		// members are written as children to retain their order; an object with no members, or whose members are all
+		// named "-", would otherwise be mistaken for an array
+		if !named {
+			n.Type = annotObject
+		}
+
+	default:
+		v, err := literalValue(t)
+		if err != nil {
+			return err
+		}
+		n.Arguments = append(n.Arguments, v)
+	}
+	return nil
+}
+
+// isLiteral returns true if n represents a literal value
+func isLiteral(n *document.Node) bool {
+	return len(n.Type) == 0 && len(n.Arguments) == 1 && len(n.Children) == 0 && !n.Properties.Exist()
+}
+
+// literalValue returns a Value containing the literal JSON token t
+func literalValue(t json.Token) (*document.Value, error) {
+	switch x := t.(type) {
+	case json.Number:
+		num, err := parseNumber(string(x))
+		if err != nil {
+			return nil, err
+		}
+		return &document.Value{Value: num}, nil
+	case string:
+		return &document.Value{Value: x, Flag: document.FlagQuoted}, nil
+	case bool, nil:
+		return &document.Value{Value: x}, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON token %v", t)
+	}
+}
+
+// parseNumber parses the JSON number s and returns an int64 or float64 if s can be represented exactly by one,
+// otherwise a *big.Int or *big.Float with sufficient precision to represent every digit of s
+func parseNumber(s string) (interface{}, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", s)
+		}
+		return i, nil
+	}
+
+	exact, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		// the shortest representation of f identifies the same decimal value as s only if f retains all of s's digits
+		if r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64)); ok && r.Cmp(exact) == 0 {
+			return f, nil
+		}
+	}
+
+	digits := 0
+	for _, c := range s {
+		if c == 'e' || c == 'E' {
+			break
+		}
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	prec := uint(math.Ceil(float64(digits)*math.Log2(10))) + 1
+	if prec < 64 {
+		prec = 64
+	}
+	f, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s: %w", s, err)
+	}
+	return f, nil
+}
+
+// ToJSON writes the JSON value represented by the single top-level node in doc to w
+func ToJSON(doc *document.Document, w io.Writer) error {
+	if len(doc.Nodes) != 1 {
+		return fmt.Errorf("converting KDL to JSON: expected a single top-level node, found %d", len(doc.Nodes))
+	}
+
+	b, err := appendNode(make([]byte, 0, 1024), doc.Nodes[0])
+	if err != nil {
+		return fmt.Errorf("converting KDL to JSON: %w", err)
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// nodeError returns an error describing a problem with n
+func nodeError(n *document.Node, format string, v ...interface{}) error {
+	msg := fmt.Sprintf(format, v...)
+	if n.Span.IsValid() {
+		return fmt.Errorf("node %s at %s: %s", n.Name.NodeNameString(), n.Span.Start, msg)
+	}
+	return fmt.Errorf("node %s: %s", n.Name.NodeNameString(), msg)
+}
+
+// appendNode appends the JSON representation of the value represented by n to b
+func appendNode(b []byte, n *document.Node) ([]byte, error) {
+	switch n.Type {
+	case annotArray:
+		return appendArray(b, n)
+	case annotObject:
+		return appendObject(b, n)
+	}
+
+	switch {
+	case n.Properties.Exist():
+		return appendObject(b, n)
+	case len(n.Children) > 0:
+		for _, child := range n.Children {
+			if child.Name.ValueString() != nodeName {
+				return appendObject(b, n)
+			}
+		}
+		return appendArray(b, n)
+	case len(n.Arguments) == 1:
+		return appendValue(b, n.Arguments[0])
+	case len(n.Arguments) == 0:
+		return nil, nodeError(n, "empty node is ambiguous; use an (array) or (object) type annotation")
+	default:
+		return appendArray(b, n)
+	}
+}
+
+// appendArray appends the JSON array represented by n to b
+func appendArray(b []byte, n *document.Node) ([]byte, error) {
+	if n.Properties.Exist() {
+		return nil, nodeError(n, "array may not have properties")
+	}
+
+	var err error
+	b = append(b, '[')
+	for i, arg := range n.Arguments {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if b, err = appendValue(b, arg); err != nil {
+			return nil, err
+		}
+	}
+	for i, child := range n.Children {
+		if child.Name.ValueString() != nodeName {
+			return nil, nodeError(child, "array element must be named %q", nodeName)
+		}
+		if i > 0 || len(n.Arguments) > 0 {
+			b = append(b, ',')
+		}
+		if b, err = appendNode(b, child); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, ']'), nil
+}
+
+// appendObject appends the JSON object represented by n to b
+func appendObject(b []byte, n *document.Node) ([]byte, error) {
+	if len(n.Arguments) > 0 {
+		return nil, nodeError(n, "object may not have arguments")
+	}
+
+	var err error
+	b = append(b, '{')
+	props := n.Properties.Unordered()
+	for i, key := range n.Properties.Keys() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if b, err = appendJSON(b, key); err != nil {
+			return nil, err
+		}
+		b = append(b, ':')
+		if b, err = appendValue(b, props[key]); err != nil {
+			return nil, err
+		}
+	}
+	for i, child := range n.Children {
+		if i > 0 || len(props) > 0 {
+			b = append(b, ',')
+		}
+		if b, err = appendJSON(b, child.Name.ValueString()); err != nil {
+			return nil, err
+		}
+		b = append(b, ':')
+		if b, err = appendNode(b, child); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendValue appends the JSON representation of v to b
+func appendValue(b []byte, v *document.Value) ([]byte, error) {
+	switch x := v.Value.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case bool:
+		return strconv.AppendBool(b, x), nil
+	case string:
+		return appendJSON(b, x)
+	case document.SuffixedDecimal:
+		// suffixed decimals (eg: 10ms) have no JSON equivalent, so they are retained as strings
+		return appendJSON(b, x.String())
+	case int64:
+		return strconv.AppendInt(b, x, 10), nil
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return nil, fmt.Errorf("unsupported number %v", x)
+		}
+		return strconv.AppendFloat(b, x, 'g', -1, 64), nil
+	case *big.Int:
+		return x.Append(b, 10), nil
+	case *big.Float:
+		if x.IsInf() {
+			return nil, fmt.Errorf("unsupported number %v", x)
+		}
+		return x.Append(b, 'g', -1), nil
+	default:
+		return appendJSON(b, x)
+	}
+}
+
+// appendJSON appends the JSON encoding of v to b
+func appendJSON(b []byte, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return append(b, bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})...), nil
+}
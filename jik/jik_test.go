@@ -0,0 +1,130 @@
+package jik
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/generator"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+func parseDocument(t *testing.T, input string) *document.Document {
+	t.Helper()
+	s := tokenizer.NewSlice([]byte(input))
+	p := parser.New()
+	c := p.NewContext()
+	for s.Scan() {
+		if err := p.Parse(c, s.Token()); err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatalf("Scan() failed: %v", s.Err())
+	}
+	return c.Document()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		kdl  string
+	}{
+		{name: "string", json: `"hello \"world\" <&>"`, kdl: `- "hello \"world\" <&>"`},
+		{name: "null", json: `null`, kdl: `- null`},
+		{name: "bool", json: `true`, kdl: `- true`},
+		{name: "int", json: `-42`, kdl: `- -42`},
+		{name: "float", json: `0.1`, kdl: `- 0.1`},
+		{name: "big int", json: `123456789012345678901234567890`, kdl: `- 123456789012345678901234567890`},
+		{name: "precise float", json: `3.14159265358979323846264338327950288`},
+		{name: "huge float", json: `1.5e+400`, kdl: `- 1.5E+400`},
+		{name: "precise float kdl", json: `0.1234567890123456789`, kdl: `- 0.1234567890123456789`},
+		{name: "empty array", json: `[]`, kdl: `(array)-`},
+		{name: "single element array", json: `[1]`, kdl: `(array)- 1`},
+		{name: "literal array", json: `[1,"two",null,false]`, kdl: `- 1 "two" null false`},
+		{name: "nested array", json: `[[1,2],[3],[]]`},
+		{name: "mixed array", json: `[1,{"a":2},[3,4]]`},
+		{name: "empty object", json: `{}`, kdl: `(object)-`},
+		{name: "object", json: `{"b":1,"a":[1,2],"c":{"d":null},"-":"dash"}`},
+		{name: "dash object", json: `{"-":1}`, kdl: "(object)- {\n\t- 1\n}"},
+		{name: "nested", json: `{"a":[1,2],"b":{"c":[true]}}`, kdl: "- {\n\ta 1 2\n\tb {\n\t\t(array)c true\n\t}\n}"},
+		{name: "quoted keys", json: `{"with space":1,"":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := FromJSON(strings.NewReader(tt.json))
+			if err != nil {
+				t.Fatalf("FromJSON() failed: %v", err)
+			}
+
+			if tt.kdl != "" {
+				var b bytes.Buffer
+				if err := generator.New(&b).Generate(doc); err != nil {
+					t.Fatalf("Generate() failed: %v", err)
+				}
+				if got := strings.TrimSpace(b.String()); got != tt.kdl {
+					t.Fatalf("FromJSON():\ngot : %s\nwant: %s", got, tt.kdl)
+				}
+			}
+
+			var b bytes.Buffer
+			if err := ToJSON(doc, &b); err != nil {
+				t.Fatalf("ToJSON() failed: %v", err)
+			}
+			if got := strings.TrimSpace(b.String()); got != tt.json {
+				t.Fatalf("ToJSON():\ngot : %s\nwant: %s", got, tt.json)
+			}
+		})
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		kdl     string
+		want    string
+		wantErr bool
+	}{
+		{name: "properties", kdl: `- a=1 b="x" { c 2; }`, want: `{"a":1,"b":"x","c":2}`},
+		{name: "arguments and children", kdl: `- 1 2 { - 3; - a=4; }`, want: `[1,2,3,{"a":4}]`},
+		{name: "annotated array", kdl: `(array)- { - 1; }`, want: `[1]`},
+		{name: "annotated object", kdl: `(object)- { - 1; }`, want: `{"-":1}`},
+		{name: "hex", kdl: `- 0xff`, want: `255`},
+		{name: "empty node", kdl: `-`, wantErr: true},
+		{name: "object with arguments", kdl: `- 1 a=2`, wantErr: true},
+		{name: "array with properties", kdl: `(array)- a=2`, wantErr: true},
+		{name: "array with named children", kdl: `(array)- { a 1; }`, wantErr: true},
+		{name: "multiple nodes", kdl: "- 1\n- 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := ToJSON(parseDocument(t, tt.kdl), &b)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ToJSON(): expected error, got %s", b.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToJSON() failed: %v", err)
+			}
+			if got := strings.TrimSpace(b.String()); got != tt.want {
+				t.Fatalf("ToJSON():\ngot : %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, input := range []string{``, `[1,`, `{"a"}`, `1 2`} {
+		if _, err := FromJSON(strings.NewReader(input)); err == nil {
+			t.Fatalf("FromJSON(%q): expected error", input)
+		}
+	}
+}
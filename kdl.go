@@ -1,6 +1,7 @@
 package kdl
 
 import (
+	"errors"
 	"io"
 
 	"github.com/sblinch/kdl-go/document"
@@ -9,14 +10,38 @@ import (
 	"github.com/sblinch/kdl-go/internal/tokenizer"
 )
 
-func parse(s *tokenizer.Scanner) (*document.Document, error) {
+// newScanner creates a new Scanner that reads from r, configured per opts
+func newScanner(r io.Reader, opts ParseOptions) *tokenizer.Scanner {
+	s := tokenizer.New(r)
+	s.RelaxedNonCompliant = opts.RelaxedNonCompliant
+	s.ParseComments = opts.Flags.Has(parser.ParseComments)
+	return s
+}
+
+// newSliceScanner creates a new Scanner that reads from data, configured per opts
+func newSliceScanner(data []byte, opts ParseOptions) *tokenizer.Scanner {
+	s := tokenizer.NewSlice(data)
+	s.RelaxedNonCompliant = opts.RelaxedNonCompliant
+	s.ParseComments = opts.Flags.Has(parser.ParseComments)
+	return s
+}
+
+// unmarshalParseOptions returns the ParseOptions corresponding to the parsing-related fields of opts
+func unmarshalParseOptions(opts UnmarshalOptions) ParseOptions {
+	popts := ParseOptions{RelaxedNonCompliant: opts.RelaxedNonCompliant}
+	if opts.ParseComments {
+		popts.Flags |= parser.ParseComments
+	}
+	if opts.OmitPositions {
+		popts.Flags |= parser.OmitPositions
+	}
+	return popts
+}
+
+func parse(s *tokenizer.Scanner, opts ParseOptions) (*document.Document, error) {
 	defer s.Close()
 
 	p := parser.New()
-	opts := parser.ParseContextOptions{RelaxedNonCompliant: s.RelaxedNonCompliant}
-	if s.ParseComments {
-		opts.Flags |= parser.ParseComments
-	}
 	c := p.NewContextOptions(opts)
 	for s.Scan() {
 		if err := p.Parse(c, s.Token()); err != nil {
@@ -30,6 +55,35 @@ func parse(s *tokenizer.Scanner) (*document.Document, error) {
 	return c.Document(), nil
 }
 
+// parseRecover parses tokens from s in error-recovery mode, returning the best-effort document and all syntax errors
+func parseRecover(s *tokenizer.Scanner, opts ParseOptions) (*document.Document, SyntaxErrors) {
+	defer s.Close()
+
+	opts.Flags |= parser.RecoverErrors
+	p := parser.New()
+	c := p.NewContextOptions(opts)
+	for {
+		for s.Scan() {
+			// in error-recovery mode, syntax errors are recorded on the context rather than returned
+			_ = p.Parse(c, s.Token())
+		}
+		if s.Err() == nil {
+			break
+		}
+
+		var serr *SyntaxError
+		if !errors.As(s.Err(), &serr) {
+			serr = &SyntaxError{Op: "scan", Err: s.Err()}
+		}
+		c.Recover(serr)
+		if !s.Resync() {
+			break
+		}
+	}
+
+	return c.Document(), c.Errors()
+}
+
 type ParseOptions = parser.ParseContextOptions
 
 var DefaultParseOptions = parser.ParseContextOptions{}
@@ -39,11 +93,17 @@ func Parse(r io.Reader) (*document.Document, error) {
 	return ParseWithOptions(r, DefaultParseOptions)
 }
 
+// ParseWithOptions parses a KDL document from r using the specified options and returns the parsed Document, or a
+// non-nil error on failure
 func ParseWithOptions(r io.Reader, opts ParseOptions) (*document.Document, error) {
-	s := tokenizer.New(r)
-	s.RelaxedNonCompliant = opts.RelaxedNonCompliant
-	s.ParseComments = opts.Flags.Has(parser.ParseComments)
-	return parse(s)
+	return parse(newScanner(r, opts), opts)
+}
+
+// ParseRecover parses a KDL document from r using the specified options, recovering from syntax errors by discarding
+// input up to the next newline, semicolon, or brace. It returns the best-effort Document along with every syntax error
+// encountered, or a nil SyntaxErrors if the document was parsed successfully.
+func ParseRecover(r io.Reader, opts ParseOptions) (*document.Document, SyntaxErrors) {
+	return parseRecover(newScanner(r, opts), opts)
 }
 
 type GenerateOptions = generator.Options
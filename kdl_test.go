@@ -0,0 +1,150 @@
+package kdl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+func TestParseSyntaxError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		op       string
+		line     int
+		column   int
+		offset   int
+		token    tokenizer.TokenID
+		expected tokenizer.TokenID
+		message  string
+	}{
+		{
+			name:     "unexpected token",
+			input:    "node 1\nother =5",
+			op:       "parse",
+			line:     2,
+			column:   7,
+			offset:   13,
+			token:    tokenizer.Equals,
+			expected: tokenizer.ClassTerminator,
+			message:  "parse failed: unexpected Equals in state stateNodeParams at line 1, column 6:\n...1 other =...\n           ^",
+		},
+		{
+			name:    "invalid number",
+			input:   "node 0x",
+			op:      "scan",
+			line:    1,
+			column:  8,
+			offset:  7,
+			token:   tokenizer.Unknown,
+			message: "scan failed: unexpected end of token at line 1, column 8\nnode 0x\n       ^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("Parse(): expected *SyntaxError, got %#v", err)
+			}
+			if se.Op != tt.op || se.Line != tt.line || se.Column != tt.column || se.Offset != tt.offset {
+				t.Fatalf("Parse():\ngot : %s %d:%d@%d\nwant: %s %d:%d@%d", se.Op, se.Line, se.Column, se.Offset, tt.op, tt.line, tt.column, tt.offset)
+			}
+			if se.Token.ID != tt.token {
+				t.Fatalf("Parse() token:\ngot : %s\nwant: %s", se.Token.ID, tt.token)
+			}
+			if tt.expected != tokenizer.Unknown {
+				found := false
+				for _, id := range se.Expected {
+					found = found || id == tt.expected
+				}
+				if !found {
+					t.Fatalf("Parse() expected tokens %s do not include %s", se.ExpectedString(), tt.expected)
+				}
+			} else if len(se.Expected) != 0 {
+				t.Fatalf("Parse() expected tokens:\ngot : %s\nwant: none", se.ExpectedString())
+			}
+			if err.Error() != tt.message {
+				t.Fatalf("Parse() error:\ngot : %q\nwant: %q", err.Error(), tt.message)
+			}
+		})
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		errors []string
+	}{
+		{
+			name:  "valid",
+			input: "a 1\nb 2",
+			want:  "a 1\nb 2\n",
+		},
+		{
+			name:   "newline",
+			input:  "a 1\nb = 2\nc 3",
+			want:   "a 1\nb\nc 3\n",
+			errors: []string{"parse 2:3"},
+		},
+		{
+			name:   "brace",
+			input:  "a 1 = {\n b 2\n}\nc",
+			want:   "a 1 {\n\tb 2\n}\nc\n",
+			errors: []string{"parse 1:5"},
+		},
+		{
+			name:   "stray braces",
+			input:  "}\na\n{\n x 1\n}\nb",
+			want:   "a\nb\n",
+			errors: []string{"parse 1:1", "parse 3:1"},
+		},
+		{
+			name:   "scan errors",
+			input:  "a 0x\nb 2\nc 0b2 3\nd",
+			want:   "a\nb 2\nc\nd\n",
+			errors: []string{"scan 1:5", "scan 3:5"},
+		},
+		{
+			name:   "same line",
+			input:  "a 1; b (; c 3",
+			want:   "a 1\nb\nc 3\n",
+			errors: []string{"parse 1:9"},
+		},
+		{
+			name:   "same line scan errors",
+			input:  "a 0x; b 2; c 0b2 { d 1; }; e",
+			want:   "a\nb 2\nc {\n\td 1\n}\ne\n",
+			errors: []string{"scan 1:5", "scan 1:16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, errs := ParseRecover(strings.NewReader(tt.input), DefaultParseOptions)
+
+			got := make([]string, len(errs))
+			for i, err := range errs {
+				got[i] = fmt.Sprintf("%s %d:%d", err.Op, err.Line, err.Column)
+			}
+			if len(got) != len(tt.errors) || strings.Join(got, ",") != strings.Join(tt.errors, ",") {
+				t.Fatalf("ParseRecover() errors:\ngot : %v\nwant: %v", got, tt.errors)
+			}
+
+			b := bytes.Buffer{}
+			if err := Generate(doc, &b); err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if b.String() != tt.want {
+				t.Fatalf("ParseRecover():\ngot : %q\nwant: %q", b.String(), tt.want)
+			}
+		})
+	}
+}
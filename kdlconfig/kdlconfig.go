@@ -0,0 +1,330 @@
+// Package kdlconfig loads configuration into a tagged Go struct from layers of KDL documents, environment variables,
+// and command-line flags, and reports which layer supplied each value.
+//
+// Each value that a struct holds is identified by its path: the names of the nodes (and property) leading to it from
+// the top level of a document, joined by ".". Given:
+//
+//	type Config struct {
+//		Server struct {
+//			Host string `kdl:",arg"`
+//			Port int    `kdl:"port"`
+//		} `kdl:"server"`
+//		LogLevel string `kdl:"log-level"`
+//	}
+//
+// the paths are "server" (the server node's argument), "server.port", and "log-level". The layers are applied in
+// order, each overriding the values supplied by the ones before it:
+//
+//   - the values already held by the struct, along with any defaults declared by ",default:..." tag options
+//   - each of Loader.Files, unmarshaled in turn
+//   - environment variables named by Loader.EnvPrefix and the path, such as APP_SERVER_PORT and APP_LOG_LEVEL
+//   - the flags in Loader.Flags that are named by a path, such as -server.port, and were set on the command line
+//
+// Environment variables and flags are converted into values of the fields' types as Unmarshal would convert the
+// corresponding values in a document. Only fields holding single values, and the struct fields containing them, are
+// addressable by path; fields tagged ",multiple", maps, and slices can only be supplied by files.
+//
+// Values from files are checked against the constraints declared by their tag options (such as ",min:..." and
+// ",enum:...") as each file is unmarshaled, and the final values from environment variables and flags once every layer
+// has been applied. A field tagged ",required" may be supplied by any layer, and is reported missing only if none of
+// them supplies it.
+package kdlconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/sblinch/kdl-go"
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+	"github.com/sblinch/kdl-go/internal/marshaler"
+)
+
+// Layer identifies a layer of configuration
+type Layer int
+
+const (
+	// LayerDefault is the value held by the struct before it was loaded, or its ",default:..." value
+	LayerDefault Layer = iota
+	// LayerFile is a KDL document listed in Loader.Files
+	LayerFile
+	// LayerEnv is an environment variable
+	LayerEnv
+	// LayerFlag is a command-line flag
+	LayerFlag
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerDefault:
+		return "default"
+	case LayerFile:
+		return "file"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	default:
+		return fmt.Sprintf("Layer(%d)", int(l))
+	}
+}
+
+// Source identifies where a value was found
+type Source struct {
+	Layer Layer
+	// Name is the path of the file, the name of the environment variable, or the name of the flag that supplied the
+	// value, or an empty string for LayerDefault
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Layer.String()
+	}
+	return s.Layer.String() + " " + s.Name
+}
+
+// Sources maps the path of each value to the source of its final value
+type Sources map[string]Source
+
+// Loader loads configuration from its layers
+type Loader struct {
+	// Files lists the paths of the KDL documents to unmarshal, in order
+	Files []string
+	// IgnoreMissing skips files in Files that do not exist, rather than failing
+	IgnoreMissing bool
+	// EnvPrefix is the prefix of the names of the environment variables that override values (see EnvName); if it is
+	// empty, environment variables are not consulted
+	EnvPrefix string
+	// LookupEnv returns the value of an environment variable and true if it is set; if nil, os.LookupEnv is used
+	LookupEnv func(key string) (string, bool)
+	// Flags holds the flags that override values, which must have been parsed; only flags that were set on the command
+	// line and whose names are paths are applied, and the rest are ignored
+	Flags *flag.FlagSet
+	// Options are the options with which files are unmarshaled
+	Options kdl.UnmarshalOptions
+}
+
+// Load loads configuration into v, which must be a pointer to a struct, from files followed by environment variables
+// prefixed with envPrefix; see Loader
+func Load(v interface{}, envPrefix string, files ...string) (Sources, error) {
+	l := Loader{Files: files, EnvPrefix: envPrefix}
+	return l.Load(v)
+}
+
+// EnvName returns the name of the environment variable that overrides the value at path: the upper-cased prefix and
+// path joined by "_", with any other characters that are not letters or digits replaced with "_", eg:
+// EnvName("app", "server.max-conns") returns "APP_SERVER_MAX_CONNS"
+func EnvName(prefix string, path string) string {
+	name := strings.ToUpper(prefix + "_" + path)
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Load loads configuration into v, which must be a pointer to a struct, from each of l's layers in turn, and returns
+// the source of each of its values
+func (l *Loader) Load(v interface{}) (Sources, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("kdlconfig: Load requires a non-nil pointer to a struct")
+	}
+	layers, err := marshaler.NewLayers(rv.Type(), l.Options)
+	if err != nil {
+		return nil, err
+	}
+	settings := layers.Settings
+
+	sources := make(Sources, len(settings))
+	for i := range settings {
+		path := settingPath(settings[i])
+		if err := settings[i].SetDefault(rv); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sources[path] = Source{Layer: LayerDefault}
+	}
+
+	for _, path := range l.Files {
+		doc, err := l.parseFile(path)
+		if errors.Is(err, os.ErrNotExist) && l.IgnoreMissing {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if err := layers.Unmarshal(doc, v); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for i := range settings {
+			if settings[i].In(doc) {
+				sources[settingPath(settings[i])] = Source{Layer: LayerFile, Name: path}
+			}
+		}
+	}
+
+	if l.EnvPrefix != "" {
+		lookup := l.LookupEnv
+		if lookup == nil {
+			lookup = os.LookupEnv
+		}
+		for i := range settings {
+			path := settingPath(settings[i])
+			name := EnvName(l.EnvPrefix, path)
+			if raw, ok := lookup(name); ok {
+				if err := set(layers, &settings[i], rv, raw); err != nil {
+					return nil, fmt.Errorf("environment variable %s: %w", name, err)
+				}
+				sources[path] = Source{Layer: LayerEnv, Name: name}
+			}
+		}
+	}
+
+	if l.Flags != nil {
+		byPath := make(map[string]*marshaler.Setting, len(settings))
+		for i := range settings {
+			byPath[settingPath(settings[i])] = &settings[i]
+		}
+		l.Flags.Visit(func(f *flag.Flag) {
+			path := f.Name
+			if !l.Options.CaseSensitive {
+				path = strings.ToLower(path)
+			}
+			s, ok := byPath[path]
+			if !ok || err != nil {
+				return
+			}
+			if err = set(layers, s, rv, f.Value.String()); err != nil {
+				err = fmt.Errorf("flag -%s: %w", f.Name, err)
+				return
+			}
+			sources[path] = Source{Layer: LayerFlag, Name: f.Name}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// values from files were checked against their constraints as they were unmarshaled, and the rest are checked once
+	// every layer has been applied
+	for i := range settings {
+		source := sources[settingPath(settings[i])]
+		if source.Layer != LayerEnv && source.Layer != LayerFlag {
+			continue
+		}
+		if err := settings[i].Check(rv); err != nil {
+			if source.Layer == LayerEnv {
+				return nil, fmt.Errorf("environment variable %s: %w", source.Name, err)
+			}
+			return nil, fmt.Errorf("flag -%s: %w", source.Name, err)
+		}
+	}
+	if err := layers.CheckRequired(rv); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// DefineFlags defines a flag in l.Flags for each value that v, a struct or pointer to a struct, holds, named by its
+// path, unless a flag with that name has already been defined; flags for booleans may be given without a value, as
+// with flag.Bool
+func (l *Loader) DefineFlags(v interface{}) error {
+	if l.Flags == nil {
+		return errors.New("kdlconfig: DefineFlags requires Flags")
+	}
+	settings, err := marshaler.Settings(reflect.TypeOf(v), l.Options)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		path := settingPath(s)
+		if l.Flags.Lookup(path) != nil {
+			continue
+		}
+		usage := "override " + path
+		if l.EnvPrefix != "" {
+			usage += " (or set " + EnvName(l.EnvPrefix, path) + ")"
+		}
+		l.Flags.Var(&settingFlag{isBool: len(s.Types) == 1 && s.Types[0] == "boolean"}, path, usage)
+	}
+	return nil
+}
+
+// parseFile parses the KDL document at path
+func (l *Loader) parseFile(path string) (*document.Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := kdl.ParseWithOptions(f, kdl.ParseOptions{RelaxedNonCompliant: l.Options.RelaxedNonCompliant})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// settingPath returns the path of s
+func settingPath(s marshaler.Setting) string {
+	return strings.Join(s.Path, ".")
+}
+
+// set converts raw, the text of an environment variable or flag, into a value accepted by s and sets it in v
+func set(layers *marshaler.Layers, s *marshaler.Setting, v reflect.Value, raw string) error {
+	val, err := overrideValue(s, raw)
+	if err != nil {
+		return err
+	}
+	return layers.Set(s, v, val)
+}
+
+// overrideValue converts raw into a value accepted by s: strings are used as-is if s accepts them, and otherwise raw
+// must contain a number or boolean that s accepts
+func overrideValue(s *marshaler.Setting, raw string) (interface{}, error) {
+	if len(s.Types) > 0 && s.Accepts("string") {
+		return raw, nil
+	}
+	val := coerce.FromString(raw)
+	switch {
+	case len(s.Types) == 0 || val == nil:
+		return val, nil
+	case coerce.IsNumeric(val):
+		if !s.Accepts("number") {
+			break
+		}
+		if s.Integer && !coerce.IsInteger(val) {
+			return nil, fmt.Errorf("expected an integer, not %q", raw)
+		}
+		return val, nil
+	case s.Accepts("boolean"):
+		if _, ok := val.(bool); ok {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a %s, not %q", strings.Join(s.Types, " or "), raw)
+}
+
+// settingFlag is the flag.Value of the flags defined by DefineFlags
+type settingFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *settingFlag) String() string {
+	return f.value
+}
+
+func (f *settingFlag) Set(s string) error {
+	f.value = s
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.isBool
+}
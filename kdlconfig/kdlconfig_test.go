@@ -0,0 +1,248 @@
+package kdlconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	Host    string        `kdl:",arg"`
+	Port    int           `kdl:"port"`
+	TLS     bool          `kdl:"tls"`
+	Timeout time.Duration `kdl:"timeout"`
+}
+
+type testConfig struct {
+	Server   testServer  `kdl:"server"`
+	Backup   *testServer `kdl:"backup"`
+	LogLevel string      `kdl:"log-level,default:info"`
+	MaxConns uint16      `kdl:"max-conns"`
+	Mode     os.FileMode `kdl:"mode"`
+	Tags     []string    `kdl:"tags"`
+}
+
+func writeFile(t *testing.T, dir string, name string, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.kdl", `
+server "localhost" port=8080 {
+	timeout "5s"
+}
+max-conns 100
+tags "a" "b"
+`)
+	local := writeFile(t, dir, "local.kdl", `
+server "example.com" tls=true
+backup "backup.example.com"
+`)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.Int("server.port", 0, "")
+	fs.Bool("verbose", false, "")
+	l := Loader{
+		Files:         []string{base, local, filepath.Join(dir, "missing.kdl")},
+		IgnoreMissing: true,
+		EnvPrefix:     "APP",
+		LookupEnv:     testEnv(map[string]string{"APP_SERVER_PORT": "9090", "APP_MAX_CONNS": "200", "APP_MODE": "0640"}),
+		Flags:         fs,
+	}
+	if err := l.DefineFlags(&testConfig{}); err != nil {
+		t.Fatalf("DefineFlags() failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-server.port=9999", "-backup.tls", "-verbose"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig{Server: testServer{Timeout: time.Second}}
+	sources, err := l.Load(&cfg)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := testConfig{
+		Server:   testServer{Host: "example.com", Port: 9999, TLS: true, Timeout: 5 * time.Second},
+		Backup:   &testServer{Host: "backup.example.com", TLS: true},
+		LogLevel: "info",
+		MaxConns: 200,
+		Mode:     0o640,
+		Tags:     []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load() = %+v (backup %+v), want %+v (backup %+v)", cfg, cfg.Backup, want, want.Backup)
+	}
+	if *port != 9999 {
+		t.Errorf("server.port flag = %d, want 9999", *port)
+	}
+
+	wantSources := Sources{
+		"server":         {Layer: LayerFile, Name: local},
+		"server.port":    {Layer: LayerFlag, Name: "server.port"},
+		"server.tls":     {Layer: LayerFile, Name: local},
+		"server.timeout": {Layer: LayerFile, Name: base},
+		"backup":         {Layer: LayerFile, Name: local},
+		"backup.port":    {Layer: LayerDefault},
+		"backup.tls":     {Layer: LayerFlag, Name: "backup.tls"},
+		"backup.timeout": {Layer: LayerDefault},
+		"log-level":      {Layer: LayerDefault},
+		"max-conns":      {Layer: LayerEnv, Name: "APP_MAX_CONNS"},
+		"mode":           {Layer: LayerEnv, Name: "APP_MODE"},
+	}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("Load() sources = %v, want %v", sources, wantSources)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	var cfg testConfig
+	sources, err := Load(&cfg, "")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.LogLevel != "info" || cfg.Backup != nil {
+		t.Errorf("Load() = %+v, want defaults", cfg)
+	}
+	for path, source := range sources {
+		if source.Layer != LayerDefault {
+			t.Errorf("source of %s = %v, want default", path, source)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := writeFile(t, dir, "bad.kdl", "server {\n")
+
+	tests := []struct {
+		name string
+		l    Loader
+		want string
+	}{
+		{"missing file", Loader{Files: []string{filepath.Join(dir, "missing.kdl")}}, "missing.kdl"},
+		{"invalid file", Loader{Files: []string{bad}}, "bad.kdl"},
+		{"integer", Loader{EnvPrefix: "APP", LookupEnv: testEnv(map[string]string{"APP_SERVER_PORT": "http"})}, `environment variable APP_SERVER_PORT: expected a number, not "http"`},
+		{"fraction", Loader{EnvPrefix: "APP", LookupEnv: testEnv(map[string]string{"APP_MAX_CONNS": "1.5"})}, `expected an integer, not "1.5"`},
+		{"boolean", Loader{EnvPrefix: "APP", LookupEnv: testEnv(map[string]string{"APP_SERVER_TLS": "maybe"})}, `expected a boolean, not "maybe"`},
+		{"duration", Loader{EnvPrefix: "APP", LookupEnv: testEnv(map[string]string{"APP_SERVER_TIMEOUT": "soon"})}, `environment variable APP_SERVER_TIMEOUT`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+			_, err := tt.l.Load(&cfg)
+			if err == nil {
+				t.Fatalf("Load() succeeded, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %q, want error containing %q", err.Error(), tt.want)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("server.port", "", "")
+	if err := fs.Parse([]string{"-server.port=x"}); err != nil {
+		t.Fatal(err)
+	}
+	l := Loader{Flags: fs}
+	if _, err := l.Load(&testConfig{}); err == nil || !strings.Contains(err.Error(), "flag -server.port") {
+		t.Errorf("Load() = %v, want flag error", err)
+	}
+
+	if _, err := l.Load(testConfig{}); err == nil {
+		t.Errorf("Load() of a non-pointer succeeded")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		prefix, path, want string
+	}{
+		{"APP", "server.port", "APP_SERVER_PORT"},
+		{"app", "server.max-conns", "APP_SERVER_MAX_CONNS"},
+		{"X", "log-level", "X_LOG_LEVEL"},
+	}
+	for _, tt := range tests {
+		if got := EnvName(tt.prefix, tt.path); got != tt.want {
+			t.Errorf("EnvName(%q, %q) = %q, want %q", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
+
+type checkedConfig struct {
+	Name   string `kdl:"name,required"`
+	Server struct {
+		Host string `kdl:",arg,required"`
+		Port int    `kdl:"port,min:1,max:65535"`
+	} `kdl:"server"`
+	LogLevel string `kdl:"log-level,enum:debug|info"`
+}
+
+func TestLoadChecks(t *testing.T) {
+	dir := t.TempDir()
+	unnamed := writeFile(t, dir, "unnamed.kdl", "server \"localhost\" port=80\n")
+	hostless := writeFile(t, dir, "hostless.kdl", "name \"app\"\nserver port=80\n")
+	invalid := writeFile(t, dir, "invalid.kdl", "name \"app\"\nserver \"localhost\" port=0\n")
+
+	tests := []struct {
+		name  string
+		files []string
+		env   map[string]string
+		flags []string
+		want  string
+	}{
+		{"required from env", nil, map[string]string{"APP_NAME": "app"}, nil, ""},
+		{"required from env over file", []string{unnamed}, map[string]string{"APP_NAME": "app"}, nil, ""},
+		{"required from flag", []string{unnamed}, nil, []string{"-name=app"}, ""},
+		{"required from later file", []string{unnamed, hostless}, nil, nil, ""},
+		{"nested required from env", []string{hostless}, map[string]string{"APP_SERVER": "localhost"}, nil, ""},
+		{"valid flag over invalid env", nil, map[string]string{"APP_NAME": "app", "APP_SERVER": "localhost", "APP_SERVER_PORT": "99999"}, []string{"-server.port=443"}, ""},
+		{"missing required", nil, nil, nil, `name: checkedConfig.Name (string): missing required node "name"`},
+		{"missing required in file", []string{unnamed}, nil, nil, `missing required node "name"`},
+		{"missing nested required", []string{hostless}, nil, nil, `missing required argument "host"`},
+		{"max from env", nil, map[string]string{"APP_NAME": "app", "APP_SERVER_PORT": "99999"}, nil, "environment variable APP_SERVER_PORT: Port (int): 99999 is greater than the maximum of 65535"},
+		{"enum from env", nil, map[string]string{"APP_NAME": "app", "APP_LOG_LEVEL": "trace"}, nil, `environment variable APP_LOG_LEVEL: checkedConfig.LogLevel (string): "trace" is not one of debug, info`},
+		{"min from flag", nil, map[string]string{"APP_NAME": "app"}, []string{"-server.port=0"}, "flag -server.port: Port (int): 0 is less than the minimum of 1"},
+		{"min from file", []string{invalid}, nil, nil, "invalid.kdl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			l := Loader{Files: tt.files, EnvPrefix: "APP", LookupEnv: testEnv(tt.env), Flags: fs}
+			if err := l.DefineFlags(&checkedConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Parse(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			var cfg checkedConfig
+			_, err := l.Load(&cfg)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Load() failed: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Load() succeeded, want error containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Load() = %q, want error containing %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
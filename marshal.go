@@ -107,10 +107,33 @@ func MarshalNodeWithOptions(v interface{}, opts marshaler.MarshalOptions) (*docu
 	return marshaler.MarshalNodeWithOptions(v, opts)
 }
 
+// AddCustomMarshaler registers a function that marshals values of type T into nodes for all Marshal calls. It may be
+// called at any time; functions in MarshalerOptions.Marshalers take precedence over it.
 func AddCustomMarshaler[T any](marshal func(v reflect.Value, node *document.Node) error) {
 	marshaler.AddCustomMarshaler[T](marshal)
 }
 
+// AddCustomValueMarshaler registers a function that marshals values of type T into argument or property values for all
+// Marshal calls. It may be called at any time; functions in MarshalerOptions.Marshalers take precedence over it.
 func AddCustomValueMarshaler[T any](marshal func(v reflect.Value, value *document.Value, format string) error) {
 	marshaler.AddCustomValueMarshaler[T](marshal)
 }
+
+// Marshalers is a registry of custom marshaling functions which can be assigned to MarshalerOptions.Marshalers to apply
+// them to individual Marshal calls or Encoders
+type Marshalers = marshaler.Marshalers
+
+// NewMarshalers returns an empty Marshalers
+func NewMarshalers() *Marshalers {
+	return marshaler.NewMarshalers()
+}
+
+// AddMarshaler registers a function in m that marshals values of type T into nodes
+func AddMarshaler[T any](m *Marshalers, marshal func(v reflect.Value, node *document.Node) error) {
+	marshaler.AddMarshaler[T](m, marshal)
+}
+
+// AddValueMarshaler registers a function in m that marshals values of type T into argument or property values
+func AddValueMarshaler[T any](m *Marshalers, marshal func(v reflect.Value, value *document.Value, format string) error) {
+	marshaler.AddValueMarshaler[T](m, marshal)
+}
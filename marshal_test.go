@@ -3,10 +3,18 @@ package kdl
 import (
 	"bytes"
 	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sblinch/kdl-go/document"
 )
 
 var (
@@ -487,41 +495,6 @@ entry 5678 5 "MDEyMzQ1Njc4OUFCQ0RFRjAxMjM0NTY3ODlBQkNERUY="
 	}
 }
 
-/*
-These tests cannot be run as part of a larger batch as the AddCustomMarshaler calls will panic given that other tests
-have already created Indexers. Uncomment and run individually to test custom marshaling.
-
-func TestNoIndexers(t *testing.T) {
-	var panicky interface{}
-
-	func() {
-		defer func() {
-			panicky = recover()
-		}()
-
-		AddCustomMarshaler[int](func(v reflect.Value, node *document.Node) error {
-			return nil
-		})
-	}()
-	if panicky != nil {
-		t.Errorf("AddCustomMarshaler[int]() panicked %v", panicky)
-	}
-	_, _ = Marshal(32)
-
-	func() {
-		defer func() {
-			panicky = recover()
-		}()
-
-		AddCustomMarshaler[int](func(v reflect.Value, node *document.Node) error {
-			return nil
-		})
-	}()
-	if panicky == nil {
-		t.Errorf("AddCustomMarshaler[int]() did not panic after initial marshaler creation")
-	}
-}
-
 func TestCustomMarshaler(t *testing.T) {
 	type coocooKachoo struct {
 		S string
@@ -577,7 +550,126 @@ func TestCustomValueMarshaler(t *testing.T) {
 }
 
 
-*/
+func TestMarshalersOption(t *testing.T) {
+	type coocooKachoo struct {
+		S string
+	}
+	type snackbar struct {
+		Chugga coocooKachoo `kdl:"chugga"`
+		Choo   coocooKachoo `kdl:"choo"`
+	}
+
+	AddCustomValueMarshaler[coocooKachoo](func(v reflect.Value, value *document.Value, format string) error {
+		value.Value = "global " + v.Field(0).String()
+		return nil
+	})
+
+	v := &snackbar{Chugga: coocooKachoo{S: "foo"}, Choo: coocooKachoo{S: "bar"}}
+
+	// functions in the options take precedence over global functions, and are only used by calls given the options
+	m := NewMarshalers()
+	AddValueMarshaler[coocooKachoo](m, func(v reflect.Value, value *document.Value, format string) error {
+		value.Value = "local " + v.Field(0).String()
+		return nil
+	})
+	opts := MarshalOptions{MarshalerOptions: MarshalerOptions{Marshalers: m}, GeneratorOptions: DefaultGenerateOptions}
+
+	tests := []struct {
+		name string
+		opts *MarshalOptions
+		want string
+	}{
+		{"local", &opts, "chugga \"local foo\"\nchoo \"local bar\"\n"},
+		{"global", nil, "chugga \"global foo\"\nchoo \"global bar\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got []byte
+				err error
+			)
+			if tt.opts != nil {
+				got, err = MarshalWithOptions(v, *tt.opts)
+			} else {
+				got, err = Marshal(v)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("want: %s\n got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMarshalOmitDefaults(t *testing.T) {
+	type listen struct {
+		Addr string        `kdl:",arg"`
+		Port int           `kdl:"port,default:80"`
+		Wait time.Duration `kdl:"wait,format:sec,default:2.5"`
+	}
+	type config struct {
+		Name   string  `kdl:"name,default:x"`
+		Level  *int    `kdl:"level,default:3"`
+		Listen *listen `kdl:"listen"`
+	}
+	three := 3
+	v := config{Name: "x", Level: &three, Listen: &listen{Addr: "::", Port: 80, Wait: 2500 * time.Millisecond}}
+
+	tests := []struct {
+		name string
+		v    config
+		opts MarshalerOptions
+		want string
+	}{
+		{"defaults", v, MarshalerOptions{}, "name \"x\"\nlevel 3\nlisten \"::\" port=80 wait=2.5\n"},
+		{"omitted", v, MarshalerOptions{OmitDefaults: true}, "listen \"::\"\n"},
+		{"changed", config{Name: "y", Listen: &listen{Addr: "::", Port: 81}}, MarshalerOptions{OmitDefaults: true}, "name \"y\"\nlisten \"::\" port=81 wait=0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalWithOptions(tt.v, MarshalOptions{MarshalerOptions: tt.opts, GeneratorOptions: DefaultGenerateOptions})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonicalKDL(t, string(got)) != canonicalKDL(t, tt.want) {
+				t.Fatalf("want: %s\n got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+// canonicalKDL parses the KDL document in data and returns it with the properties of each node sorted by name, so that
+// documents differing only in the order of their properties compare equal
+func canonicalKDL(t *testing.T, data string) string {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", data, err)
+	}
+	sortProperties(doc.Nodes)
+	var b strings.Builder
+	if err := Generate(doc, &b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func sortProperties(nodes []*document.Node) {
+	for _, n := range nodes {
+		keys := n.Properties.Keys()
+		sort.Strings(keys)
+		props := n.Properties
+		n.Properties = document.Properties{}
+		for _, k := range keys {
+			v, _ := props.Get(k)
+			v.Span = document.Span{}
+			n.AddPropertyValue(k, v, "")
+		}
+		sortProperties(n.Children)
+	}
+}
 
 type OctalInt struct {
 	Value int
@@ -669,3 +761,179 @@ schmeckles {
 	}
 
 }
+
+func TestMarshalUnknown(t *testing.T) {
+	input := "name \"x\"\nplugin \"p\" \"q\" level=2 mode=\"fast\" {\n\tcache size=3\n}\nmetrics port=9090\n"
+	var cfg testUnknownConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canonicalKDL(t, string(got)) != canonicalKDL(t, input) {
+		t.Fatalf("want: %s\n got: %s\n", input, got)
+	}
+}
+
+func TestMarshalTypeAnnotations(t *testing.T) {
+	cfg := testAnnotatedConfig{
+		Port:    8080,
+		ID:      "123e4567-e89b-12d3-a456-426614174000",
+		Addr:    "10.0.0.1",
+		Weights: []int{1, 2},
+		Backend: &testAnnotatedBackend{Host: "b"},
+	}
+	want := "port (u16)8080\nid (uuid)\"123e4567-e89b-12d3-a456-426614174000\"\naddr (ipv4)\"10.0.0.1\"\nweights (u8)1 (u8)2\n(http)backend \"b\"\n"
+	got, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want: %s\n got: %s\n", want, got)
+	}
+
+	var again testAnnotatedConfig
+	if err := Unmarshal(got, &again); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if !reflect.DeepEqual(again, cfg) {
+		t.Errorf("got %+v, want %+v", again, cfg)
+	}
+
+	// values that are invalid for their field's annotation are not written
+	type wide struct {
+		Port uint32 `kdl:"port,type:u16"`
+	}
+	type ids struct {
+		IDs []string `kdl:"ids,type:uuid"`
+	}
+	for _, tt := range []struct {
+		v    interface{}
+		want string
+	}{
+		{wide{Port: 70000}, `70000 is out of range for (u16)`},
+		{testAnnotatedConfig{Port: 80}, `"" is not a valid (uuid)`},
+		{ids{IDs: []string{"123e4567-e89b-12d3-a456-426614174000", "x"}}, `"x" is not a valid (uuid)`},
+	} {
+		if _, err := Marshal(tt.v); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Marshal(%+v) error = %v, want %s", tt.v, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalStdlibTypes(t *testing.T) {
+	input := `addr "::1"
+prefix "10.0.0.0/8"
+addrport "10.0.0.1:443"
+ip "192.168.1.1"
+url "https://example.com/a?b=c"
+pattern "^[a-z]+$"
+location "America/New_York"
+int 123456789012345678901234567890
+float 1.5
+rat "1/3"
+mode 0o644
+`
+	var cfg testStdlibConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	got, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != input {
+		t.Fatalf("want: %s\n got: %s\n", input, got)
+	}
+
+	// the built-in functions are replaced by custom marshalers for the same type
+	m := NewMarshalers()
+	AddValueMarshaler[os.FileMode](m, func(v reflect.Value, value *document.Value, format string) error {
+		value.Value = os.FileMode(v.Uint()).String()
+		return nil
+	})
+	opts := MarshalOptions{MarshalerOptions: MarshalerOptions{Marshalers: m}, GeneratorOptions: DefaultGenerateOptions}
+	got, err = MarshalWithOptions(struct {
+		Mode os.FileMode `kdl:"mode"`
+	}{0o755}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mode \"-rwxr-xr-x\"\n"; string(got) != want {
+		t.Fatalf("want: %s\n got: %s\n", want, got)
+	}
+}
+
+func TestMarshalStdlibTypesZero(t *testing.T) {
+	// nil pointers are omitted from nodes and properties, and marshaled as null arguments
+	type values struct {
+		URL  *url.URL            `kdl:",arg"`
+		Args []*regexp.Regexp    `kdl:",args"`
+		Ints map[string]*big.Int `kdl:",props"`
+		Loc  *time.Location      `kdl:"loc"`
+		Rat  *big.Rat            `kdl:"rat"`
+	}
+	type config struct {
+		testStdlibConfig
+		Values values `kdl:"values"`
+	}
+	v := config{Values: values{Args: []*regexp.Regexp{nil}, Ints: map[string]*big.Int{"a": nil}}}
+	want := "addr \"\"\nprefix \"\"\naddrport \"\"\nip \"\"\nmode 0o0\nvalues null null a=null\n"
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("want: %s\n got: %s\n", want, got)
+	}
+}
+
+func TestMarshalNilPointers(t *testing.T) {
+	type item struct {
+		Name string `kdl:",arg"`
+	}
+	type inner struct {
+		Items []*item          `kdl:"item"`
+		Byes  map[string]*item `kdl:"bye,multiple"`
+	}
+	type outer struct {
+		Ptr    *item   `kdl:"ptr"`
+		Items  []*item `kdl:"item,multiple"`
+		Values []*int  `kdl:"values"`
+		Inner  inner   `kdl:"inner"`
+	}
+
+	one := 1
+	v := outer{
+		Items:  []*item{{"a"}, nil, {"b"}},
+		Values: []*int{nil, &one},
+		Inner: inner{
+			Items: []*item{nil, {"c"}},
+			Byes:  map[string]*item{"d": nil},
+		},
+	}
+	want := "item \"a\"\nitem \"b\"\nvalues null 1\ninner {\n\titem \"c\"\n}\n"
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("want: %s\n got: %s\n", want, got)
+	}
+}
+
+func TestMarshalInvalidTag(t *testing.T) {
+	type bad struct {
+		Name string `kdl:"name,match:[a-"`
+	}
+	type config struct {
+		Value interface{} `kdl:"value"`
+	}
+	for _, v := range []interface{}{bad{}, config{Value: bad{}}, map[string]interface{}{"value": &bad{}}} {
+		if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), `invalid match pattern "[a-"`) {
+			t.Errorf("Marshal(%#v) error = %v, want invalid match pattern", v, err)
+		}
+	}
+}
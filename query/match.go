@@ -0,0 +1,254 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+)
+
+// combinator specifies the relationship between the nodes matched by consecutive filters in a selector
+type combinator int
+
+const (
+	// combDescendant matches descendants of the previous nodes (a b)
+	combDescendant combinator = iota
+	// combChild matches children of the previous nodes (a > b)
+	combChild
+	// combNextSibling matches the sibling immediately following each of the previous nodes (a + b)
+	combNextSibling
+	// combSibling matches all siblings following each of the previous nodes (a ~ b)
+	combSibling
+)
+
+// step is a single filter in a selector, along with the combinator relating it to the previous filter
+type step struct {
+	comb   combinator
+	filter filter
+}
+
+// selector is a list of steps; the first step's combinator relates it to the root of the document, so that its filter
+// is applied to every node (combDescendant) or only to top-level nodes (combChild, following top())
+type selector struct {
+	// top is true if the selector began with top()
+	top   bool
+	steps []step
+}
+
+// match returns the nodes in idx that match the selector
+func (s selector) match(idx *index) []*document.Node {
+	current := []*document.Node{idx.root}
+	if s.top && len(s.steps) == 0 {
+		return idx.root.Children
+	}
+
+	for _, st := range s.steps {
+		seen := make(map[*document.Node]bool)
+		next := make([]*document.Node, 0, len(current))
+		add := func(n *document.Node) {
+			if !seen[n] && st.filter.match(n) {
+				seen[n] = true
+				next = append(next, n)
+			}
+		}
+
+		for _, n := range current {
+			switch st.comb {
+			case combDescendant:
+				for _, d := range idx.descendants(n, nil) {
+					add(d)
+				}
+			case combChild:
+				for _, child := range n.Children {
+					add(child)
+				}
+			case combNextSibling:
+				if siblings := idx.siblings(n); len(siblings) > 0 {
+					add(siblings[0])
+				}
+			case combSibling:
+				for _, sibling := range idx.siblings(n) {
+					add(sibling)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// filter matches a node by type annotation, name, and matchers
+type filter struct {
+	// anyType is true if the filter requires a node to have a type annotation
+	anyType bool
+	// typ is the required type annotation, or nil for none
+	typ *string
+	// name is the required node name, or nil for none
+	name     *string
+	matchers []matcher
+}
+
+// match returns true if n matches f
+func (f *filter) match(n *document.Node) bool {
+	if f.anyType && len(n.Type) == 0 {
+		return false
+	}
+	if f.typ != nil && string(n.Type) != *f.typ {
+		return false
+	}
+	if f.name != nil && nodeName(n) != *f.name {
+		return false
+	}
+	for _, m := range f.matchers {
+		if !m.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeName returns the name of n
+func nodeName(n *document.Node) string {
+	if n.Name == nil {
+		return ""
+	}
+	return n.Name.ValueString()
+}
+
+// accessor identifies the part of a node examined by a matcher
+type accessor int
+
+const (
+	// accAny matches any node ([])
+	accAny accessor = iota
+	// accVal accesses an argument (val(n))
+	accVal
+	// accProp accesses a property (prop(name))
+	accProp
+	// accName accesses the node name (name())
+	accName
+	// accTag accesses the node's type annotation (tag())
+	accTag
+)
+
+// operator is a comparison operator
+type operator int
+
+const (
+	opNone operator = iota
+	opEqual
+	opNotEqual
+	opLess
+	opLessEqual
+	opGreater
+	opGreaterEqual
+	opPrefix
+	opSuffix
+	opContains
+)
+
+// operators maps operator symbols to operators, in the order in which they must be tested
+var operators = []struct {
+	sym string
+	op  operator
+}{
+	{">=", opGreaterEqual},
+	{"<=", opLessEqual},
+	{"!=", opNotEqual},
+	{"^=", opPrefix},
+	{"$=", opSuffix},
+	{"*=", opContains},
+	{"=", opEqual},
+	{">", opGreater},
+	{"<", opLess},
+}
+
+// matcher matches a node by comparing part of the node against a value
+type matcher struct {
+	acc   accessor
+	index int
+	key   string
+	op    operator
+	value interface{}
+}
+
+// get returns the value of the part of n identified by m's accessor, and false if n has no such part
+func (m *matcher) get(n *document.Node) (interface{}, bool) {
+	switch m.acc {
+	case accVal:
+		if m.index < len(n.Arguments) {
+			return n.Arguments[m.index].ResolvedValue(), true
+		}
+	case accProp:
+		if v, ok := n.Properties.Get(m.key); ok {
+			return v.ResolvedValue(), true
+		}
+	case accName:
+		return nodeName(n), true
+	case accTag:
+		if len(n.Type) > 0 {
+			return string(n.Type), true
+		}
+	case accAny:
+		return nil, true
+	}
+	return nil, false
+}
+
+// match returns true if n matches m
+func (m *matcher) match(n *document.Node) bool {
+	v, ok := m.get(n)
+	if !ok {
+		return false
+	}
+
+	switch m.op {
+	case opNone:
+		return true
+	case opEqual:
+		return equal(v, m.value)
+	case opNotEqual:
+		return !equal(v, m.value)
+	case opPrefix, opSuffix, opContains:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		switch m.op {
+		case opPrefix:
+			return strings.HasPrefix(s, m.value.(string))
+		case opSuffix:
+			return strings.HasSuffix(s, m.value.(string))
+		default:
+			return strings.Contains(s, m.value.(string))
+		}
+	default:
+		a, aok := coerce.ToBigFloat(v)
+		b, bok := coerce.ToBigFloat(m.value)
+		if !aok || !bok {
+			return false
+		}
+		cmp := a.Cmp(b)
+		switch m.op {
+		case opLess:
+			return cmp < 0
+		case opLessEqual:
+			return cmp <= 0
+		case opGreater:
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}
+}
+
+// equal returns true if a and b are equal; numbers of different types are compared by value
+func equal(a interface{}, b interface{}) bool {
+	an, aok := coerce.ToBigFloat(a)
+	bn, bok := coerce.ToBigFloat(b)
+	if aok || bok {
+		return aok && bok && an.Cmp(bn) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
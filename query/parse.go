@@ -0,0 +1,322 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+// identifierStop lists the characters that terminate an unquoted identifier in a query
+const identifierStop = " \t\r\n[]()>=<!^$*|~+,"
+
+// compiler parses a KQL query into selectors
+type compiler struct {
+	src string
+	pos int
+}
+
+// errorf returns an error describing a problem at the current position in the query
+func (c *compiler) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("invalid query %q at offset %d: %s", c.src, c.pos, fmt.Sprintf(format, v...))
+}
+
+// eof returns true if the entire query has been consumed
+func (c *compiler) eof() bool {
+	return c.pos >= len(c.src)
+}
+
+// peek returns the next byte in the query without consuming it, or 0 at the end of the query
+func (c *compiler) peek() byte {
+	if c.eof() {
+		return 0
+	}
+	return c.src[c.pos]
+}
+
+// describe returns a description of the next character in the query for use in error messages
+func (c *compiler) describe() string {
+	if c.eof() {
+		return "end of query"
+	}
+	return strconv.QuoteRune(rune(c.src[c.pos]))
+}
+
+// skipSpace consumes any whitespace and returns true if any was found
+func (c *compiler) skipSpace() bool {
+	start := c.pos
+	for !c.eof() && strings.IndexByte(" \t\r\n", c.src[c.pos]) != -1 {
+		c.pos++
+	}
+	return c.pos > start
+}
+
+// consume consumes s if it appears next in the query and returns true, otherwise it returns false
+func (c *compiler) consume(s string) bool {
+	if strings.HasPrefix(c.src[c.pos:], s) {
+		c.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// expect consumes s if it appears next in the query, otherwise it returns an error
+func (c *compiler) expect(s string) error {
+	if !c.consume(s) {
+		return c.errorf("expected %q, found %s", s, c.describe())
+	}
+	return nil
+}
+
+// value consumes a KDL value that ends at the first character in stop (or at the end of a quoted or raw string)
+func (c *compiler) value(stop string) (*document.Value, tokenizer.TokenID, error) {
+	rest := c.src[c.pos:]
+	if !strings.HasPrefix(rest, `"`) && !strings.HasPrefix(rest, `r"`) && !strings.HasPrefix(rest, `r#`) {
+		if end := strings.IndexAny(rest, stop); end != -1 {
+			rest = rest[:end]
+		}
+	}
+	if len(rest) == 0 {
+		return nil, tokenizer.Unknown, c.errorf("expected value, found %s", c.describe())
+	}
+
+	t, err := tokenizer.ScanOne([]byte(rest))
+	if err != nil || len(t.Data) == 0 {
+		return nil, tokenizer.Unknown, c.errorf("invalid value %q", rest)
+	}
+	v, err := document.ValueFromToken(t)
+	if err != nil {
+		return nil, tokenizer.Unknown, c.errorf("invalid value %q: %v", rest, err)
+	}
+	c.pos += len(t.Data)
+	return v, t.ID, nil
+}
+
+// identifier consumes a bare identifier or string
+func (c *compiler) identifier() (string, error) {
+	start := c.pos
+	v, id, err := c.value(identifierStop)
+	if err != nil {
+		return "", err
+	}
+	switch id {
+	case tokenizer.BareIdentifier, tokenizer.QuotedString, tokenizer.RawString:
+		return v.ValueString(), nil
+	default:
+		c.pos = start
+		return "", c.errorf("expected identifier, found %s", c.describe())
+	}
+}
+
+// parseQuery parses the entire query
+func (c *compiler) parseQuery() ([]selector, error) {
+	selectors := make([]selector, 0, 1)
+	for {
+		sel, err := c.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+
+		c.skipSpace()
+		if c.eof() {
+			return selectors, nil
+		}
+		if err := c.expect("||"); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseSelector parses a single selector
+func (c *compiler) parseSelector() (selector, error) {
+	sel := selector{}
+	c.skipSpace()
+
+	comb := combDescendant
+	if c.consume("top()") {
+		sel.top = true
+		if !c.nextCombinator(&comb) {
+			return sel, nil
+		}
+	}
+
+	for {
+		f, err := c.parseFilter()
+		if err != nil {
+			return sel, err
+		}
+		sel.steps = append(sel.steps, step{comb: comb, filter: f})
+
+		if !c.nextCombinator(&comb) {
+			return sel, nil
+		}
+	}
+}
+
+// nextCombinator consumes the combinator following a filter and stores it in comb; it returns false if the selector
+// has ended
+func (c *compiler) nextCombinator(comb *combinator) bool {
+	space := c.skipSpace()
+	if c.eof() || strings.HasPrefix(c.src[c.pos:], "||") {
+		return false
+	}
+
+	switch {
+	case c.consume(">>"):
+		*comb = combDescendant
+	case c.consume(">"):
+		*comb = combChild
+	case c.consume("+"):
+		*comb = combNextSibling
+	case c.consume("~"):
+		*comb = combSibling
+	case space:
+		*comb = combDescendant
+	default:
+		// no combinator; let the next filter report the unexpected character
+		*comb = combDescendant
+	}
+	c.skipSpace()
+	return true
+}
+
+// parseFilter parses a filter consisting of an optional type annotation, an optional node name, and any number of
+// matchers
+func (c *compiler) parseFilter() (filter, error) {
+	f := filter{}
+	start := c.pos
+
+	if strings.HasPrefix(c.src[c.pos:], "top()") {
+		return f, c.errorf("top() may only appear at the beginning of a selector")
+	}
+
+	if c.consume("(") {
+		c.skipSpace()
+		if c.consume(")") {
+			f.anyType = true
+		} else {
+			typ, err := c.identifier()
+			if err != nil {
+				return f, err
+			}
+			f.typ = &typ
+			c.skipSpace()
+			if err := c.expect(")"); err != nil {
+				return f, err
+			}
+		}
+	}
+
+	if !c.eof() && strings.IndexByte(identifierStop, c.peek()) == -1 {
+		name, err := c.identifier()
+		if err != nil {
+			return f, err
+		}
+		f.name = &name
+	}
+
+	for c.peek() == '[' {
+		m, err := c.parseMatcher()
+		if err != nil {
+			return f, err
+		}
+		f.matchers = append(f.matchers, m)
+	}
+
+	if c.pos == start {
+		return f, c.errorf("expected node filter, found %s", c.describe())
+	}
+	return f, nil
+}
+
+// parseMatcher parses a matcher enclosed in square brackets
+func (c *compiler) parseMatcher() (matcher, error) {
+	m := matcher{}
+	if err := c.expect("["); err != nil {
+		return m, err
+	}
+	c.skipSpace()
+	if c.consume("]") {
+		m.acc = accAny
+		return m, nil
+	}
+
+	ident, err := c.identifier()
+	if err != nil {
+		return m, err
+	}
+	if !c.consume("(") {
+		// [name] is shorthand for [prop(name)]
+		m.acc = accProp
+		m.key = ident
+	} else {
+		c.skipSpace()
+		switch ident {
+		case "val":
+			m.acc = accVal
+			if c.peek() != ')' {
+				v, _, err := c.value(identifierStop)
+				if err != nil {
+					return m, err
+				}
+				index, ok := v.Value.(int64)
+				if !ok || index < 0 {
+					return m, c.errorf("invalid argument index %s", v.String())
+				}
+				m.index = int(index)
+			}
+		case "prop":
+			m.acc = accProp
+			if m.key, err = c.identifier(); err != nil {
+				return m, err
+			}
+		case "name":
+			m.acc = accName
+		case "tag":
+			m.acc = accTag
+		default:
+			return m, c.errorf("unsupported accessor %s()", ident)
+		}
+		c.skipSpace()
+		if err := c.expect(")"); err != nil {
+			return m, err
+		}
+	}
+
+	c.skipSpace()
+	for _, o := range operators {
+		if c.consume(o.sym) {
+			m.op = o.op
+			break
+		}
+	}
+	if m.op != opNone {
+		c.skipSpace()
+		v, _, err := c.value(" \t\r\n]")
+		if err != nil {
+			return m, err
+		}
+		m.value = v.ResolvedValue()
+
+		switch m.op {
+		case opPrefix, opSuffix, opContains:
+			if _, ok := m.value.(string); !ok {
+				return m, c.errorf("operator requires a string value")
+			}
+		case opLess, opLessEqual, opGreater, opGreaterEqual:
+			if _, ok := coerce.ToBigFloat(m.value); !ok {
+				return m, c.errorf("operator requires a numeric value")
+			}
+		}
+		c.skipSpace()
+	}
+
+	if err := c.expect("]"); err != nil {
+		return m, err
+	}
+	return m, nil
+}
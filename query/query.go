@@ -0,0 +1,148 @@
+// Package query implements the KDL Query Language (KQL) for selecting nodes from a document.Document.
+//
+// A query consists of one or more selectors separated by ||; a node matches the query if it matches any of its
+// selectors. Each selector is a list of filters separated by combinators:
+//
+//	a > b     b is a child of a
+//	a b       b is a descendant of a
+//	a >> b    b is a descendant of a
+//	a + b     b immediately follows its sibling a
+//	a ~ b     b follows its sibling a
+//
+// A filter consists of an optional type annotation, an optional node name, and any number of matchers, and matches a
+// node if all of its parts match:
+//
+//	server               nodes named server
+//	(tls)server          nodes named server with the type annotation tls
+//	()                   nodes with any type annotation
+//	[val()]              nodes with at least one argument
+//	[val(1) = 443]       nodes whose second argument is 443
+//	[prop(port)]         nodes with a port property; may be abbreviated as [port]
+//	[port >= 1024]       nodes whose port property is a number greater than or equal to 1024
+//	[name() ^= "db"]     nodes whose name begins with db
+//	[tag() = "tls"]      nodes with the type annotation tls
+//	[]                   any node
+//
+// Matchers support the operators =, !=, <, <=, >, >= (numbers only), and ^=, $=, *= (strings only; starts with, ends
+// with, and contains, respectively).
+//
+// A selector may begin with top(), which restricts the following filter to top-level nodes; top() on its own matches
+// all top-level nodes.
+package query
+
+import (
+	"sort"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// Query is a compiled KQL query
+type Query struct {
+	src       string
+	selectors []selector
+}
+
+// Compile parses a KQL query and returns a Query that can be used to match nodes, or a non-nil error if the query is
+// invalid
+func Compile(q string) (*Query, error) {
+	c := compiler{src: q}
+	selectors, err := c.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: q, selectors: selectors}, nil
+}
+
+// MustCompile is like Compile, but panics if the query is invalid
+func MustCompile(q string) *Query {
+	query, err := Compile(q)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
+
+// Select compiles the KQL query q and returns the nodes in doc that match it, or a non-nil error if the query is invalid
+func Select(doc *document.Document, q string) ([]*document.Node, error) {
+	query, err := Compile(q)
+	if err != nil {
+		return nil, err
+	}
+	return query.Match(doc), nil
+}
+
+// String returns the source text of the query
+func (q *Query) String() string {
+	return q.src
+}
+
+// Match returns the nodes in doc that match the query, in document order
+func (q *Query) Match(doc *document.Document) []*document.Node {
+	idx := newIndex(doc)
+
+	matched := make(map[*document.Node]bool)
+	for _, sel := range q.selectors {
+		for _, n := range sel.match(idx) {
+			matched[n] = true
+		}
+	}
+
+	result := make([]*document.Node, 0, len(matched))
+	for n := range matched {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return idx.order[result[i]] < idx.order[result[j]] })
+	return result
+}
+
+// index records the relationships between the nodes in a document
+type index struct {
+	// root is a synthetic node whose children are the document's top-level nodes
+	root *document.Node
+	// order maps each node to its position in a depth-first traversal of the document
+	order map[*document.Node]int
+	// parent maps each node to its parent node
+	parent map[*document.Node]*document.Node
+	// position maps each node to its position within its parent's children
+	position map[*document.Node]int
+}
+
+// newIndex indexes the nodes in doc
+func newIndex(doc *document.Document) *index {
+	idx := &index{
+		root:     &document.Node{Children: doc.Nodes},
+		order:    make(map[*document.Node]int),
+		parent:   make(map[*document.Node]*document.Node),
+		position: make(map[*document.Node]int),
+	}
+	idx.add(idx.root)
+	return idx
+}
+
+// add indexes the children of n and their descendants
+func (idx *index) add(n *document.Node) {
+	for i, child := range n.Children {
+		idx.order[child] = len(idx.order)
+		idx.parent[child] = n
+		idx.position[child] = i
+		idx.add(child)
+	}
+}
+
+// descendants appends the descendants of n to result in depth-first order
+func (idx *index) descendants(n *document.Node, result []*document.Node) []*document.Node {
+	for _, child := range n.Children {
+		result = append(result, child)
+		result = idx.descendants(child, result)
+	}
+	return result
+}
+
+// siblings returns the siblings following n
+func (idx *index) siblings(n *document.Node) []*document.Node {
+	parent, ok := idx.parent[n]
+	if !ok {
+		return nil
+	}
+	return parent.Children[idx.position[n]+1:]
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+const queryInput = `
+server "alpha" port=8080 {
+	listen "0.0.0.0" 80
+	(tls)listen "::" 443
+	backlog 1000
+}
+server "beta" port=0x1bb {
+	listen "127.0.0.1" 8443
+	database "primary" {
+		listen "/tmp/db.sock"
+	}
+}
+(internal)cache size=1.5 name="shared-cache"
+`
+
+func parseDocument(t *testing.T, input string) *document.Document {
+	t.Helper()
+	s := tokenizer.NewSlice([]byte(input))
+	p := parser.New()
+	c := p.NewContext()
+	for s.Scan() {
+		if err := p.Parse(c, s.Token()); err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatalf("Scan() failed: %v", s.Err())
+	}
+	return c.Document()
+}
+
+// describe returns a short description of each node, consisting of its name and its first argument (if any)
+func describe(nodes []*document.Node) string {
+	desc := make([]string, len(nodes))
+	for i, n := range nodes {
+		desc[i] = n.Name.ValueString()
+		if len(n.Arguments) > 0 {
+			desc[i] += ":" + n.Arguments[0].ValueString()
+		}
+	}
+	return strings.Join(desc, ",")
+}
+
+func TestQueryMatch(t *testing.T) {
+	doc := parseDocument(t, queryInput)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "server", want: "server:alpha,server:beta"},
+		{query: "listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1,listen:/tmp/db.sock"},
+		{query: "server > listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1"},
+		{query: "server>listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1"},
+		{query: "server >> listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1,listen:/tmp/db.sock"},
+		{query: "server>>listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1,listen:/tmp/db.sock"},
+		{query: "top() >> database > listen", want: "listen:/tmp/db.sock"},
+		{query: "server listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1,listen:/tmp/db.sock"},
+		{query: "top()", want: "server:alpha,server:beta,cache"},
+		{query: "top() > listen", want: ""},
+		{query: "top() > server[port] > listen", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1"},
+		{query: "server[port = 443] > listen", want: "listen:127.0.0.1"},
+		{query: "server[prop(port) >= 1000]", want: "server:alpha"},
+		{query: "server[val() = \"beta\"]", want: "server:beta"},
+		{query: "listen[val(1) > 100]", want: "listen:::,listen:127.0.0.1"},
+		{query: "listen[val(1)]", want: "listen:0.0.0.0,listen:::,listen:127.0.0.1"},
+		{query: "listen[val() ^= \"/\"]", want: "listen:/tmp/db.sock"},
+		{query: "[name() $= \"log\"]", want: "backlog:1000"},
+		{query: "[name *= cache]", want: "cache"},
+		{query: "(tls)listen", want: "listen:::"},
+		{query: "()", want: "listen:::,cache"},
+		{query: "[tag() = internal]", want: "cache"},
+		{query: "[tag() != internal]", want: "listen:::"},
+		{query: "listen + backlog", want: "backlog:1000"},
+		{query: "listen + listen", want: "listen:::"},
+		{query: "listen ~ database", want: "database:primary"},
+		{query: "database || backlog", want: "backlog:1000,database:primary"},
+		{query: "cache[size = 1.5] || cache[size = 2]", want: "cache"},
+		{query: "[]", want: "server:alpha,listen:0.0.0.0,listen:::,backlog:1000,server:beta,listen:127.0.0.1,database:primary,listen:/tmp/db.sock,cache"},
+		{query: `"server"[ val() = "alpha" ] listen[ val(1) = 443 ]`, want: "listen:::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile() failed: %v", err)
+			}
+			if got := describe(q.Match(doc)); got != tt.want {
+				t.Fatalf("Match():\ngot : %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCompileErrors(t *testing.T) {
+	queries := []string{
+		"",
+		"a >",
+		"a >>",
+		"a >> > b",
+		"a > top()",
+		"a[",
+		"a[val(x)]",
+		"a[val() ^= 1]",
+		"a[val() > \"x\"]",
+		"a[values()]",
+		"(a",
+		"a ||",
+		"a )",
+	}
+	for _, query := range queries {
+		if _, err := Compile(query); err == nil {
+			t.Fatalf("Compile(%q): expected error", query)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package kdl
+
+import (
+	"reflect"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/marshaler"
+)
+
+// SchemaFor returns a KDL Schema document (see package schema) describing the documents that Unmarshal accepts for
+// t, which must be a struct, map, or interface type or a pointer to one.
+//
+// The schema describes values in the form in which Marshal produces them, so it is stricter than Unmarshal, which
+// coerces values between types and matches names case-insensitively by default. Fields tagged ",required" are
+// required properties (or, at the top level of the document, nodes), and the "min:", "max:", "enum:", "len:", and
+// "match:" tag options become the corresponding validations; bounds on durations and "oneof:" groups are not
+// reflected in the schema.
+func SchemaFor(t reflect.Type) (*document.Document, error) {
+	return marshaler.SchemaFor(t)
+}
+
+// JSONSchemaFor returns a JSON Schema describing t, which must be a struct or map type or a pointer to one, for use by
+// tools that support JSON Schema but not KDL Schema. The schema describes the values that Unmarshal stores in t as a
+// tree of JSON objects keyed by the names of the corresponding KDL nodes and properties. Constraints declared by tag
+// options are reflected as they are by SchemaFor.
+func JSONSchemaFor(t reflect.Type) ([]byte, error) {
+	return marshaler.JSONSchemaFor(t)
+}
@@ -0,0 +1,475 @@
+// Package schema validates KDL documents against a KDL Schema.
+//
+// A schema is itself a KDL document consisting of a single top-level document node, which describes the nodes that
+// may appear in a document:
+//
+//	document {
+//	    node "server" {
+//	        min 1
+//	        value { min 1; max 1; type "string"; }
+//	        prop "port" { required true; type "number"; ">" 0; "<=" 65535; }
+//	        children {
+//	            node "listen" { value { format "ipv4" "ipv6"; }; }
+//	        }
+//	    }
+//	}
+//
+// The following schema nodes are supported:
+//
+//   - document and children: node, node-names, other-nodes-allowed, tag, tag-names, other-tags-allowed, info
+//     (ignored), definitions
+//   - node: min, max, value, prop, prop-names, other-props-allowed, children, tag
+//   - value: min, max, plus any value validation
+//   - prop: required, plus any value validation
+//   - value validations: type, enum, pattern, min-length, max-length, format, %, >, >=, <, <=, tag
+//
+// The node, prop, value, and children schema nodes accept a ref property containing a KDL query (see package query)
+// that selects the schema node to use in their place, typically one found under definitions.
+//
+// A tag schema node within document or children describes the nodes with the type annotation given by its argument,
+// and contains node, node-names, and other-nodes-allowed schema nodes just as children does; nodes with that type
+// annotation are validated against it rather than against the enclosing document or children node.
+//
+// As specified by the KDL Schema specification, nodes and properties that are not described by a schema are
+// rejected unless other-nodes-allowed or other-props-allowed is true. Nodes with type annotations that are not
+// described by a tag schema node are rejected unless other-tags-allowed is true, but only where tag or tag-names
+// schema nodes are present; elsewhere, type annotations are permitted so that schemas that do not mention them
+// continue to accept annotated nodes. Arguments are only validated if a value schema node is present.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/query"
+)
+
+// Schema is a compiled KDL Schema
+type Schema struct {
+	root *nodesRule
+}
+
+// nodesRule describes the nodes permitted at the top level of a document or in a node's children
+type nodesRule struct {
+	nodes             []*nodeRule
+	nodeNames         *validations
+	otherNodesAllowed bool
+	// tags describes the nodes with particular type annotations
+	tags     []*tagRule
+	tagNames *validations
+	// otherTagsAllowed is true if type annotations not described by tags are allowed
+	otherTagsAllowed bool
+}
+
+// tagRule describes the nodes with a type annotation
+type tagRule struct {
+	// name is the type annotation, or nil if the rule applies to any type annotation
+	name  *string
+	nodes *nodesRule
+}
+
+// nodeRule describes a node
+type nodeRule struct {
+	// name is the node name, or nil if the rule applies to any node
+	name     *string
+	min      *int
+	max      *int
+	values   *valuesRule
+	props    []*propRule
+	propKeys *validations
+	// otherPropsAllowed is true if properties not described by props are allowed
+	otherPropsAllowed bool
+	children          *nodesRule
+	tag               *validations
+}
+
+// valuesRule describes a node's arguments
+type valuesRule struct {
+	min *int
+	max *int
+	validations
+}
+
+// propRule describes a property
+type propRule struct {
+	key      string
+	required bool
+	validations
+}
+
+// compiler compiles a schema document into a Schema
+type compiler struct {
+	doc *document.Document
+	// compiled node and children rules, keyed by schema node; used to resolve recursive references
+	nodes    map[*document.Node]*nodeRule
+	children map[*document.Node]*nodesRule
+}
+
+// New compiles the KDL Schema in doc, returning a Schema that can be used to validate documents, or a non-nil error if
+// the schema is invalid
+func New(doc *document.Document) (*Schema, error) {
+	c := &compiler{
+		doc:      doc,
+		nodes:    make(map[*document.Node]*nodeRule),
+		children: make(map[*document.Node]*nodesRule),
+	}
+
+	for _, n := range doc.Nodes {
+		if nodeName(n) == "document" {
+			root, err := c.compileNodes(n)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{root: root}, nil
+		}
+	}
+	return nil, fmt.Errorf("schema: missing document node")
+}
+
+// schemaError returns an error describing a problem with schema node n
+func schemaError(n *document.Node, format string, v ...interface{}) error {
+	return fmt.Errorf("schema: %s at %s: %s", nodeName(n), n.Span.Start, fmt.Sprintf(format, v...))
+}
+
+// nodeName returns the name of n
+func nodeName(n *document.Node) string {
+	if n.Name == nil {
+		return ""
+	}
+	return n.Name.ValueString()
+}
+
+// resolve returns the schema node referenced by n's ref property, or n itself if it has no ref property
+func (c *compiler) resolve(n *document.Node) (*document.Node, error) {
+	ref, ok := n.Properties.Get("ref")
+	if !ok {
+		return n, nil
+	}
+	s, ok := ref.ResolvedValue().(string)
+	if !ok {
+		return nil, schemaError(n, "ref must be a string")
+	}
+	matches, err := query.Select(c.doc, s)
+	if err != nil {
+		return nil, schemaError(n, "invalid ref: %v", err)
+	}
+	if len(matches) == 0 {
+		return nil, schemaError(n, "ref %q does not match any schema node", s)
+	}
+	if matches[0] == n {
+		return nil, schemaError(n, "ref %q refers to itself", s)
+	}
+	return matches[0], nil
+}
+
+// compileNodes compiles the node descriptions in the children of n
+func (c *compiler) compileNodes(n *document.Node) (*nodesRule, error) {
+	n, err := c.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := c.children[n]; ok {
+		return r, nil
+	}
+	r := &nodesRule{}
+	c.children[n] = r
+
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "node":
+			nr, err := c.compileNode(child)
+			if err != nil {
+				return nil, err
+			}
+			r.nodes = append(r.nodes, nr)
+		case "node-names":
+			if r.nodeNames, err = c.compileValidations(child); err != nil {
+				return nil, err
+			}
+		case "other-nodes-allowed":
+			if r.otherNodesAllowed, err = boolArg(child); err != nil {
+				return nil, err
+			}
+		case "tag":
+			tr, err := c.compileTag(child)
+			if err != nil {
+				return nil, err
+			}
+			r.tags = append(r.tags, tr)
+		case "tag-names":
+			if r.tagNames, err = c.compileValidations(child); err != nil {
+				return nil, err
+			}
+		case "other-tags-allowed":
+			if r.otherTagsAllowed, err = boolArg(child); err != nil {
+				return nil, err
+			}
+		case "info", "definitions":
+			// informational, or only used as the target of a ref
+		default:
+			return nil, schemaError(child, "unsupported schema node")
+		}
+	}
+	return r, nil
+}
+
+// compileTag compiles the description n of the nodes with a type annotation
+func (c *compiler) compileTag(n *document.Node) (*tagRule, error) {
+	r := &tagRule{}
+	if len(n.Arguments) > 0 {
+		s, ok := n.Arguments[0].ResolvedValue().(string)
+		if !ok {
+			return nil, schemaError(n, "tag name must be a string")
+		}
+		r.name = &s
+	}
+	var err error
+	if r.nodes, err = c.compileNodes(n); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// compileNode compiles the node description n
+func (c *compiler) compileNode(n *document.Node) (*nodeRule, error) {
+	var name *string
+	if len(n.Arguments) > 0 {
+		s, ok := n.Arguments[0].ResolvedValue().(string)
+		if !ok {
+			return nil, schemaError(n, "node name must be a string")
+		}
+		name = &s
+	}
+
+	n, err := c.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if name == nil && len(n.Arguments) > 0 {
+		if s, ok := n.Arguments[0].ResolvedValue().(string); ok {
+			name = &s
+		}
+	}
+	if r, ok := c.nodes[n]; ok {
+		if name == r.name || (name != nil && r.name != nil && *name == *r.name) {
+			return r, nil
+		}
+		nr := *r
+		nr.name = name
+		return &nr, nil
+	}
+
+	r := &nodeRule{name: name, children: &nodesRule{}}
+	c.nodes[n] = r
+
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "min":
+			if r.min, err = intArg(child); err != nil {
+				return nil, err
+			}
+		case "max":
+			if r.max, err = intArg(child); err != nil {
+				return nil, err
+			}
+		case "value":
+			if r.values, err = c.compileValues(child); err != nil {
+				return nil, err
+			}
+		case "prop":
+			pr, err := c.compileProp(child)
+			if err != nil {
+				return nil, err
+			}
+			r.props = append(r.props, pr)
+		case "prop-names":
+			if r.propKeys, err = c.compileValidations(child); err != nil {
+				return nil, err
+			}
+		case "other-props-allowed":
+			if r.otherPropsAllowed, err = boolArg(child); err != nil {
+				return nil, err
+			}
+		case "children":
+			if r.children, err = c.compileNodes(child); err != nil {
+				return nil, err
+			}
+		case "tag":
+			if r.tag, err = c.compileValidations(child); err != nil {
+				return nil, err
+			}
+		case "description", "id":
+			// informational
+		default:
+			return nil, schemaError(child, "unsupported schema node")
+		}
+	}
+	return r, nil
+}
+
+// compileValues compiles the argument description n
+func (c *compiler) compileValues(n *document.Node) (*valuesRule, error) {
+	n, err := c.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &valuesRule{}
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "min":
+			if r.min, err = intArg(child); err != nil {
+				return nil, err
+			}
+		case "max":
+			if r.max, err = intArg(child); err != nil {
+				return nil, err
+			}
+		default:
+			if err := r.validations.add(c, child); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// compileProp compiles the property description n
+func (c *compiler) compileProp(n *document.Node) (*propRule, error) {
+	r := &propRule{}
+	if len(n.Arguments) > 0 {
+		r.key, _ = n.Arguments[0].ResolvedValue().(string)
+	}
+
+	n, err := c.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if r.key == "" && len(n.Arguments) > 0 {
+		r.key, _ = n.Arguments[0].ResolvedValue().(string)
+	}
+	if r.key == "" {
+		return nil, schemaError(n, "prop requires a string key")
+	}
+
+	for _, child := range n.Children {
+		switch nodeName(child) {
+		case "required":
+			if r.required, err = boolArg(child); err != nil {
+				return nil, err
+			}
+		case "description", "id":
+			// informational
+		default:
+			if err := r.validations.add(c, child); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// compileValidations compiles a list of value validations, such as those in prop-names or node-names
+func (c *compiler) compileValidations(n *document.Node) (*validations, error) {
+	n, err := c.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validations{}
+	// a validation list with arguments but no children (eg: tag "a" "b") is shorthand for enum
+	if len(n.Children) == 0 && len(n.Arguments) > 0 {
+		for _, arg := range n.Arguments {
+			v.enum = append(v.enum, arg.ResolvedValue())
+		}
+		return v, nil
+	}
+	for _, child := range n.Children {
+		if err := v.add(c, child); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// intArg returns the single non-negative integer argument of n
+func intArg(n *document.Node) (*int, error) {
+	if len(n.Arguments) != 1 {
+		return nil, schemaError(n, "expected a single integer argument")
+	}
+	i, ok := n.Arguments[0].ResolvedValue().(int64)
+	if !ok || i < 0 {
+		return nil, schemaError(n, "expected a non-negative integer argument")
+	}
+	r := int(i)
+	return &r, nil
+}
+
+// boolArg returns the single boolean argument of n
+func boolArg(n *document.Node) (bool, error) {
+	if len(n.Arguments) != 1 {
+		return false, schemaError(n, "expected a single boolean argument")
+	}
+	b, ok := n.Arguments[0].ResolvedValue().(bool)
+	if !ok {
+		return false, schemaError(n, "expected a boolean argument")
+	}
+	return b, nil
+}
+
+// stringArgs returns the string arguments of n
+func stringArgs(n *document.Node) ([]string, error) {
+	if len(n.Arguments) == 0 {
+		return nil, schemaError(n, "expected at least one string argument")
+	}
+	r := make([]string, len(n.Arguments))
+	for i, arg := range n.Arguments {
+		s, ok := arg.ResolvedValue().(string)
+		if !ok {
+			return nil, schemaError(n, "expected string arguments")
+		}
+		r[i] = s
+	}
+	return r, nil
+}
+
+// Violation describes a part of a document that does not conform to a schema
+type Violation struct {
+	// Path identifies the offending node by its name and the names of its ancestors, separated by " > "
+	Path string
+	// Node is the offending node, or the parent of a missing node; nil if the violation applies to the top level of the
+	// document
+	Node *document.Node
+	// Position is the location of the violation in the document, if known
+	Position document.Position
+	// Message describes the violation
+	Message string
+}
+
+// Error returns a description of the violation including its location
+func (v *Violation) Error() string {
+	b := strings.Builder{}
+	if v.Position.IsValid() {
+		b.WriteString(v.Position.String())
+		b.WriteString(": ")
+	}
+	if v.Path != "" {
+		b.WriteString(v.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(v.Message)
+	return b.String()
+}
+
+// Violations is a list of schema violations
+type Violations []*Violation
+
+// Error returns the descriptions of all violations, separated by newlines
+func (v Violations) Error() string {
+	msgs := make([]string, len(v))
+	for i, violation := range v {
+		msgs[i] = violation.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,225 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+const testSchema = `
+document {
+	info {
+		title "Server configuration"
+	}
+	node "server" {
+		min 1
+		value { min 1; max 1; type "string"; pattern "^[a-z]+$"; }
+		prop "port" { required true; type "number"; ">" 0; "<=" 65535; }
+		prop "mode" { enum "active" "passive"; }
+		children {
+			node "listen" {
+				value { min 1; format "ipv4" "ipv6"; }
+			}
+			node "tags" {
+				value { type "string"; min-length 2; max-length 8; }
+			}
+			node ref=r#"[id="limits"]"#
+		}
+	}
+	node "log" {
+		max 1
+		tag "file" "syslog"
+		prop-names { pattern "^[a-z]+$"; }
+		other-props-allowed true
+	}
+	definitions {
+		node "limits" id="limits" {
+			prop "connections" { type "number"; "%" 10; }
+			children {
+				node "limits" ref=r#"[id="limits"]"#
+			}
+		}
+	}
+}
+`
+
+func parseDocument(t *testing.T, input string) *document.Document {
+	t.Helper()
+	s := tokenizer.NewSlice([]byte(input))
+	p := parser.New()
+	c := p.NewContext()
+	for s.Scan() {
+		if err := p.Parse(c, s.Token()); err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatalf("Scan() failed: %v", s.Err())
+	}
+	return c.Document()
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "valid",
+			input: `server "alpha" port=8080 mode="active" {
+	listen "127.0.0.1" "::1"
+	tags "web" "prod"
+	limits connections=100 {
+		limits connections=20
+	}
+}
+(syslog)log facility="local0"
+`,
+		},
+		{
+			name:  "missing node",
+			input: `(file)log`,
+			want:  []string{`node "server" must appear at least 1 time(s)`},
+		},
+		{
+			name: "invalid values",
+			input: `server "Alpha" "beta" port=70000 mode="standby" {
+	listen "localhost"
+	tags "x" "toolongvalue" 5
+	limits connections=15
+}`,
+			want: []string{
+				`1:1: server: expected at most 1 argument(s), found 2`,
+				`1:8: server: argument 0: value "Alpha" does not match pattern "^[a-z]+$"`,
+				`1:23: server: property "port": value 70000 must be less than or equal to 65535`,
+				`1:34: server: property "mode": value "standby" is not one of the permitted values`,
+				`2:9: server > listen: argument 0: value "localhost" is not in the format "ipv4", "ipv6"`,
+				`3:7: server > tags: argument 0: value "x" is shorter than 2 characters`,
+				`3:11: server > tags: argument 1: value "toolongvalue" is longer than 8 characters`,
+				`3:26: server > tags: argument 2: expected type "string", found number`,
+				`4:9: server > limits: property "connections": value 15 is not a multiple of 10`,
+			},
+		},
+		{
+			name: "unknown nodes and properties",
+			input: `server "a" port=1 extra=true {
+	unknown
+}
+log Bad=1
+(other)log
+other`,
+			want: []string{
+				`1:19: server: property "extra" is not allowed here`,
+				`2:2: server > unknown: node "unknown" is not allowed here`,
+				`4:1: log: missing type annotation`,
+				`4:5: log: property name: value "Bad" does not match pattern "^[a-z]+$"`,
+				`5:1: log: node "log" may appear at most 1 time(s)`,
+				`5:1: log: type annotation: value "other" is not one of the permitted values`,
+				`6:1: other: node "other" is not allowed here`,
+			},
+		},
+		{
+			name:  "missing property",
+			input: `server "a"`,
+			want:  []string{`1:1: server: missing required property "port"`},
+		},
+	}
+
+	s, err := New(parseDocument(t, testSchema))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := s.Validate(parseDocument(t, tt.input))
+			got := make([]string, len(violations))
+			for i, v := range violations {
+				got[i] = v.Error()
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("Validate():\ngot : %s\nwant: %s", strings.Join(got, "\n      "), strings.Join(tt.want, "\n      "))
+			}
+		})
+	}
+}
+
+func TestSchemaTags(t *testing.T) {
+	const tagSchema = `
+document {
+	node "plain"
+	tag "http" {
+		node "backend" { min 1; prop "url" { required true; }; }
+	}
+	tag-names { pattern "^[a-z]+$"; }
+}
+`
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "valid",
+			input: "plain\n(http)backend url=\"x\"",
+		},
+		{
+			name:  "invalid",
+			input: "(http)plain\n(tcp)backend\n(Bad)plain",
+			want: []string{
+				`1:1: plain: node "plain" is not allowed here`,
+				`2:1: backend: type annotation "tcp" is not allowed here`,
+				`3:1: plain: type annotation: value "Bad" does not match pattern "^[a-z]+$"`,
+				`3:1: plain: type annotation "Bad" is not allowed here`,
+				`node "backend" must appear at least 1 time(s)`,
+			},
+		},
+	}
+
+	s, err := New(parseDocument(t, tagSchema))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := s.Validate(parseDocument(t, tt.input))
+			got := make([]string, len(violations))
+			for i, v := range violations {
+				got[i] = v.Error()
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("Validate():\ngot : %s\nwant: %s", strings.Join(got, "\n      "), strings.Join(tt.want, "\n      "))
+			}
+		})
+	}
+
+	// other-tags-allowed permits type annotations without a tag schema node
+	s, err = New(parseDocument(t, `document { tag "http" { other-nodes-allowed true; }; other-tags-allowed true; other-nodes-allowed true; }`))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if v := s.Validate(parseDocument(t, "(tcp)backend\n(http)backend")); v != nil {
+		t.Fatalf("Validate() = %v, want no violations", v)
+	}
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	schemas := []string{
+		`node "x"`,
+		`document { bogus; }`,
+		`document { node "x" { value { type "integer"; }; }; }`,
+		`document { node "x" { value { format "nonsense"; }; }; }`,
+		`document { node "x" { min -1; }; }`,
+		`document { node "x" { value { pattern "("; }; }; }`,
+		`document { node ref=r#"[id="missing"]"#; }`,
+	}
+	for _, schema := range schemas {
+		if _, err := New(parseDocument(t, schema)); err == nil {
+			t.Fatalf("New(%q): expected error", schema)
+		}
+	}
+}
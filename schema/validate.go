@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+// validator accumulates the violations found while validating a document
+type validator struct {
+	violations Violations
+}
+
+// Validate validates doc against the schema and returns every violation found, or nil if doc conforms to the schema
+func (s *Schema) Validate(doc *document.Document) Violations {
+	v := &validator{}
+	v.nodes(s.root, doc.Nodes, nil, "")
+	return v.violations
+}
+
+// addf records a violation
+func (v *validator) addf(n *document.Node, pos document.Position, path string, format string, args ...interface{}) {
+	v.violations = append(v.violations, &Violation{
+		Path:     path,
+		Node:     n,
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// childPath returns the path of a node named name within path
+func childPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + " > " + name
+}
+
+// nodes validates the list of nodes, which are the children of parent (or the top-level nodes of the document if
+// parent is nil) at path, against r
+func (v *validator) nodes(r *nodesRule, nodes []*document.Node, parent *document.Node, path string) {
+	counts := make(map[*nodeRule]int, len(r.nodes))
+	for _, n := range nodes {
+		name := nodeName(n)
+		npath := childPath(path, name)
+
+		rules := r
+		if n.Type != "" {
+			if r.tagNames != nil {
+				for _, msg := range r.tagNames.check(string(n.Type), "") {
+					v.addf(n, n.Span.Start, npath, "type annotation: %s", msg)
+				}
+			}
+			if tr := r.matchTag(string(n.Type)); tr != nil {
+				rules = tr.nodes
+			} else if (len(r.tags) > 0 || r.tagNames != nil) && !r.otherTagsAllowed {
+				v.addf(n, n.Span.Start, npath, "type annotation %s is not allowed here", describe(string(n.Type)))
+				continue
+			}
+		}
+
+		if rules.nodeNames != nil {
+			for _, msg := range rules.nodeNames.check(name, "") {
+				v.addf(n, n.Span.Start, npath, "node name: %s", msg)
+			}
+		}
+
+		rule := rules.match(name)
+		if rule == nil {
+			if !rules.otherNodesAllowed {
+				v.addf(n, n.Span.Start, npath, "node %s is not allowed here", describe(name))
+			}
+			continue
+		}
+
+		counts[rule]++
+		if rule.max != nil && counts[rule] == *rule.max+1 {
+			v.addf(n, n.Span.Start, npath, "node %s may appear at most %d time(s)", describe(name), *rule.max)
+		}
+		v.node(rule, n, npath)
+	}
+
+	var pos document.Position
+	if parent != nil {
+		pos = parent.Span.Start
+	}
+	v.minimums(r.nodes, counts, parent, pos, path)
+	for _, tr := range r.tags {
+		v.minimums(tr.nodes.nodes, counts, parent, pos, path)
+	}
+}
+
+// minimums records a violation for each of rules whose node appeared fewer times than its minimum, according to counts
+func (v *validator) minimums(rules []*nodeRule, counts map[*nodeRule]int, parent *document.Node, pos document.Position, path string) {
+	for _, rule := range rules {
+		if rule.min != nil && counts[rule] < *rule.min {
+			name := "*"
+			if rule.name != nil {
+				name = *rule.name
+			}
+			v.addf(parent, pos, path, "node %s must appear at least %d time(s)", describe(name), *rule.min)
+		}
+	}
+}
+
+// matchTag returns the rule describing nodes with the type annotation tag: a rule for that specific annotation if one
+// exists, otherwise a rule applying to any annotation, or nil if there is no such rule
+func (r *nodesRule) matchTag(tag string) *tagRule {
+	var wildcard *tagRule
+	for _, tr := range r.tags {
+		if tr.name == nil {
+			if wildcard == nil {
+				wildcard = tr
+			}
+		} else if *tr.name == tag {
+			return tr
+		}
+	}
+	return wildcard
+}
+
+// match returns the rule describing nodes named name: a rule for that specific name if one exists, otherwise a rule
+// applying to any node, or nil if there is no such rule
+func (r *nodesRule) match(name string) *nodeRule {
+	var wildcard *nodeRule
+	for _, rule := range r.nodes {
+		if rule.name == nil {
+			if wildcard == nil {
+				wildcard = rule
+			}
+		} else if *rule.name == name {
+			return rule
+		}
+	}
+	return wildcard
+}
+
+// node validates n at path against r
+func (v *validator) node(r *nodeRule, n *document.Node, path string) {
+	if r.tag != nil {
+		if len(n.Type) == 0 {
+			v.addf(n, n.Span.Start, path, "missing type annotation")
+		} else {
+			for _, msg := range r.tag.check(string(n.Type), "") {
+				v.addf(n, n.Span.Start, path, "type annotation: %s", msg)
+			}
+		}
+	}
+
+	if r.values != nil {
+		if r.values.min != nil && len(n.Arguments) < *r.values.min {
+			v.addf(n, n.Span.Start, path, "expected at least %d argument(s), found %d", *r.values.min, len(n.Arguments))
+		}
+		if r.values.max != nil && len(n.Arguments) > *r.values.max {
+			v.addf(n, n.Span.Start, path, "expected at most %d argument(s), found %d", *r.values.max, len(n.Arguments))
+		}
+		for i, arg := range n.Arguments {
+			for _, msg := range r.values.check(arg.ResolvedValue(), arg.Type) {
+				v.addf(n, valuePosition(n, arg), path, "argument %d: %s", i, msg)
+			}
+		}
+	}
+
+	props := n.Properties.Unordered()
+	described := make(map[string]bool, len(r.props))
+	for _, pr := range r.props {
+		described[pr.key] = true
+		val, ok := props[pr.key]
+		if !ok {
+			if pr.required {
+				v.addf(n, n.Span.Start, path, "missing required property %s", describe(pr.key))
+			}
+			continue
+		}
+		for _, msg := range pr.check(val.ResolvedValue(), val.Type) {
+			v.addf(n, valuePosition(n, val), path, "property %s: %s", describe(pr.key), msg)
+		}
+	}
+	for _, key := range sortedKeys(props) {
+		val := props[key]
+		if r.propKeys != nil {
+			for _, msg := range r.propKeys.check(key, "") {
+				v.addf(n, valuePosition(n, val), path, "property name: %s", msg)
+			}
+		}
+		if !described[key] && !r.otherPropsAllowed {
+			v.addf(n, valuePosition(n, val), path, "property %s is not allowed here", describe(key))
+		}
+	}
+
+	v.nodes(r.children, n.Children, n, path)
+}
+
+// valuePosition returns the position of val if known, otherwise the position of n
+func valuePosition(n *document.Node, val *document.Value) document.Position {
+	if val.Span.IsValid() {
+		return val.Span.Start
+	}
+	return n.Span.Start
+}
+
+// sortedKeys returns the keys of props in ascending order
+func sortedKeys(props map[string]*document.Value) []string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,311 @@
+package schema
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/coerce"
+	"github.com/sblinch/kdl-go/query"
+)
+
+// validations describes the constraints on a single value
+type validations struct {
+	types      []string
+	enum       []interface{}
+	patterns   []*regexp.Regexp
+	minLength  *int
+	maxLength  *int
+	formats    []string
+	multipleOf *big.Float
+	gt         *big.Float
+	gte        *big.Float
+	lt         *big.Float
+	lte        *big.Float
+	tag        *validations
+}
+
+// valueTypes lists the value types supported by the type validation
+var valueTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"boolean": true,
+	"null":    true,
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*$`)
+
+// formats maps the names of the formats supported by the format validation to functions that validate a string
+var formats = map[string]func(s string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse("15:04:05.999999999", s)
+		return err == nil
+	},
+	"decimal": func(s string) bool {
+		_, ok := new(big.Float).SetString(s)
+		return ok
+	},
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	},
+	"ipv6": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil
+	},
+	"url": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	},
+	"email": func(s string) bool {
+		_, err := mail.ParseAddress(s)
+		return err == nil
+	},
+	"hostname": func(s string) bool {
+		return len(s) <= 253 && hostnamePattern.MatchString(s)
+	},
+	"uuid": uuidPattern.MatchString,
+	"regex": func(s string) bool {
+		_, err := regexp.Compile(s)
+		return err == nil
+	},
+	"base64": func(s string) bool {
+		_, err := base64.StdEncoding.DecodeString(s)
+		return err == nil
+	},
+	"kdl-query": func(s string) bool {
+		_, err := query.Compile(s)
+		return err == nil
+	},
+}
+
+// add compiles the validation described by n and adds it to v
+func (v *validations) add(c *compiler, n *document.Node) error {
+	var err error
+	switch name := nodeName(n); name {
+	case "type":
+		if v.types, err = stringArgs(n); err != nil {
+			return err
+		}
+		for _, t := range v.types {
+			if !valueTypes[t] {
+				return schemaError(n, "unsupported type %q", t)
+			}
+		}
+	case "enum":
+		for _, arg := range n.Arguments {
+			v.enum = append(v.enum, arg.ResolvedValue())
+		}
+	case "pattern":
+		patterns, err := stringArgs(n)
+		if err != nil {
+			return err
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return schemaError(n, "invalid pattern: %v", err)
+			}
+			v.patterns = append(v.patterns, re)
+		}
+	case "min-length":
+		if v.minLength, err = intArg(n); err != nil {
+			return err
+		}
+	case "max-length":
+		if v.maxLength, err = intArg(n); err != nil {
+			return err
+		}
+	case "format":
+		if v.formats, err = stringArgs(n); err != nil {
+			return err
+		}
+		for _, f := range v.formats {
+			if _, ok := formats[f]; !ok {
+				return schemaError(n, "unsupported format %q", f)
+			}
+		}
+	case "%", ">", ">=", "<", "<=":
+		if len(n.Arguments) != 1 {
+			return schemaError(n, "expected a single numeric argument")
+		}
+		f, ok := coerce.ToBigFloat(n.Arguments[0].ResolvedValue())
+		if !ok {
+			return schemaError(n, "expected a numeric argument")
+		}
+		switch name {
+		case "%":
+			if f.Sign() == 0 {
+				return schemaError(n, "expected a non-zero argument")
+			}
+			v.multipleOf = f
+		case ">":
+			v.gt = f
+		case ">=":
+			v.gte = f
+		case "<":
+			v.lt = f
+		case "<=":
+			v.lte = f
+		}
+	case "tag":
+		if v.tag, err = c.compileValidations(n); err != nil {
+			return err
+		}
+	case "description":
+		// informational
+	default:
+		return schemaError(n, "unsupported validation")
+	}
+	return nil
+}
+
+// check validates val (with type annotation typ) and returns a description of each failed validation
+func (v *validations) check(val interface{}, typ document.TypeAnnotation) []string {
+	var failed []string
+	fail := func(format string, args ...interface{}) {
+		failed = append(failed, fmt.Sprintf(format, args...))
+	}
+
+	if len(v.types) > 0 {
+		t := typeOf(val)
+		ok := false
+		for _, want := range v.types {
+			ok = ok || want == t
+		}
+		if !ok {
+			fail("expected type %s, found %s", joinQuoted(v.types), t)
+		}
+	}
+
+	if len(v.enum) > 0 {
+		ok := false
+		for _, want := range v.enum {
+			ok = ok || equal(val, want)
+		}
+		if !ok {
+			fail("value %s is not one of the permitted values", describe(val))
+		}
+	}
+
+	if s, ok := val.(string); ok {
+		for _, re := range v.patterns {
+			if !re.MatchString(s) {
+				fail("value %s does not match pattern %q", describe(val), re.String())
+			}
+		}
+		length := utf8.RuneCountInString(s)
+		if v.minLength != nil && length < *v.minLength {
+			fail("value %s is shorter than %d characters", describe(val), *v.minLength)
+		}
+		if v.maxLength != nil && length > *v.maxLength {
+			fail("value %s is longer than %d characters", describe(val), *v.maxLength)
+		}
+		if len(v.formats) > 0 {
+			ok := false
+			for _, f := range v.formats {
+				ok = ok || formats[f](s)
+			}
+			if !ok {
+				fail("value %s is not in the format %s", describe(val), joinQuoted(v.formats))
+			}
+		}
+	}
+
+	if f, ok := coerce.ToBigFloat(val); ok {
+		if v.multipleOf != nil && !new(big.Float).Quo(f, v.multipleOf).IsInt() {
+			fail("value %s is not a multiple of %s", describe(val), v.multipleOf.String())
+		}
+		if v.gt != nil && f.Cmp(v.gt) <= 0 {
+			fail("value %s must be greater than %s", describe(val), v.gt.String())
+		}
+		if v.gte != nil && f.Cmp(v.gte) < 0 {
+			fail("value %s must be greater than or equal to %s", describe(val), v.gte.String())
+		}
+		if v.lt != nil && f.Cmp(v.lt) >= 0 {
+			fail("value %s must be less than %s", describe(val), v.lt.String())
+		}
+		if v.lte != nil && f.Cmp(v.lte) > 0 {
+			fail("value %s must be less than or equal to %s", describe(val), v.lte.String())
+		}
+	}
+
+	if v.tag != nil {
+		if len(typ) == 0 {
+			failed = append(failed, "missing type annotation")
+		} else {
+			for _, msg := range v.tag.check(string(typ), "") {
+				failed = append(failed, "type annotation: "+msg)
+			}
+		}
+	}
+
+	return failed
+}
+
+// typeOf returns the schema type name of val
+func typeOf(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := coerce.ToBigFloat(val); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+// describe returns a short representation of val for use in messages
+func describe(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return document.QuoteString(v)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// joinQuoted returns a comma-separated list of the quoted strings in s
+func joinQuoted(s []string) string {
+	b := make([]byte, 0, 32)
+	for i, v := range s {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, document.QuoteString(v)...)
+	}
+	return string(b)
+}
+
+// equal returns true if a and b are equal; numbers of different types are compared by value
+func equal(a interface{}, b interface{}) bool {
+	an, aok := coerce.ToBigFloat(a)
+	bn, bok := coerce.ToBigFloat(b)
+	if aok || bok {
+		return aok && bok && an.Cmp(bn) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,391 @@
+package kdl
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sblinch/kdl-go/internal/marshaler"
+	"github.com/sblinch/kdl-go/schema"
+)
+
+type testSchemaLimits struct {
+	Connections uint16 `kdl:"connections,required"`
+	Rate        float64
+}
+
+type testSchemaServer struct {
+	Host    string            `kdl:",arg"`
+	Port    int               `kdl:"port"`
+	Tags    []string          `kdl:"tags"`
+	Timeout time.Duration     `kdl:"timeout,format:sec"`
+	Limits  *testSchemaLimits `kdl:"limits"`
+	Labels  map[string]string `kdl:"labels"`
+}
+
+type testSchemaTree struct {
+	Name     string            `kdl:",arg"`
+	Children []*testSchemaTree `kdl:"branch,multiple"`
+}
+
+type testSchemaConfig struct {
+	Servers []testSchemaServer           `kdl:"server,multiple"`
+	Users   map[string]map[string]string `kdl:"user,multiple"`
+	Started time.Time                    `kdl:"started"`
+	Tree    testSchemaTree               `kdl:"tree"`
+	Data    []byte                       `kdl:"data"`
+	Extra   interface{}                  `kdl:"extra"`
+	Ignored string                       `kdl:"-"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	doc, err := SchemaFor(reflect.TypeOf(&testSchemaConfig{}))
+	if err != nil {
+		t.Fatalf("SchemaFor() failed: %v", err)
+	}
+	s, err := schema.New(doc)
+	if err != nil {
+		var buf bytes.Buffer
+		_ = Generate(doc, &buf)
+		t.Fatalf("schema.New() failed: %v\n%s", err, buf.String())
+	}
+
+	valid := []string{
+		``,
+		`server "a.example.com" port=80 { tags "web" "public"; timeout 1.5; }`,
+		`server "a.example.com" { port 80; limits connections=10 { rate 2.5; }; labels env="prod" { tier "1"; }; }
+		server "b.example.com"`,
+		`user "alice" email="alice@example.com"; user "bob" { shell "/bin/sh"; }`,
+		`started "2024-01-02T03:04:05Z"`,
+		`tree "root" { branch "a" { branch "a1"; }; branch "b"; }`,
+		`data "aGVsbG8="; extra 1 2 a=3 { anything; }`,
+	}
+	for _, input := range valid {
+		d, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", input, err)
+		}
+		var cfg testSchemaConfig
+		if err := UnmarshalDocument(d, &cfg); err != nil {
+			t.Errorf("Unmarshal(%q) failed: %v", input, err)
+		}
+		if v := s.Validate(d); v != nil {
+			t.Errorf("Validate(%q) failed: %v", input, v)
+		}
+	}
+
+	invalid := []struct {
+		input string
+		want  string
+	}{
+		{`unknown 1`, `node "unknown" is not allowed here`},
+		{`server "a" "b"`, `expected at most 1 argument(s), found 2`},
+		{`server "a" port="http"`, `property "port": expected type "number", found string`},
+		{`server "a" { port 1.5; }`, `not a multiple of 1`},
+		{`server "a" { limits connections=70000; }`, `property "connections"`},
+		{`server "a" { limits { bogus 1; }; }`, `node "bogus" is not allowed here`},
+		{`server "a" { port 1; port 2; }`, `may appear at most 1 time(s)`},
+		{`user`, `expected at least 1 argument(s), found 0`},
+		{`started "yesterday"`, `format`},
+		{`tree "root" { branch "a" { leaf "x"; }; }`, `node "leaf" is not allowed here`},
+		{`tree "root" bogus=1`, `property "bogus" is not allowed here`},
+	}
+	for _, tt := range invalid {
+		d, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		v := s.Validate(d)
+		if v == nil {
+			t.Errorf("Validate(%q) succeeded, want error containing %q", tt.input, tt.want)
+		} else if !strings.Contains(v.Error(), tt.want) {
+			t.Errorf("Validate(%q) = %q, want error containing %q", tt.input, v.Error(), tt.want)
+		}
+	}
+
+	// the output of Marshal must conform to the schema
+	cfg := testSchemaConfig{
+		Servers: []testSchemaServer{{Host: "a", Port: 80, Tags: []string{"x"}, Timeout: time.Second, Limits: &testSchemaLimits{Connections: 5, Rate: 1}}},
+		Users:   map[string]map[string]string{"alice": {"shell": "/bin/sh"}},
+		Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tree:    testSchemaTree{Name: "root", Children: []*testSchemaTree{{Name: "a"}}},
+		Data:    []byte("hello"),
+	}
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	d, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if v := s.Validate(d); v != nil {
+		t.Errorf("Validate(Marshal()) failed: %v\n%s", v, data)
+	}
+}
+
+func TestSchemaForStdlibTypes(t *testing.T) {
+	doc, err := SchemaFor(reflect.TypeOf(testStdlibConfig{}))
+	if err != nil {
+		t.Fatalf("SchemaFor() failed: %v", err)
+	}
+	s, err := schema.New(doc)
+	if err != nil {
+		t.Fatalf("schema.New() failed: %v", err)
+	}
+
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{`url "https://example.com"; pattern "^a"; location "UTC"; int 12; float 1.5; rat "1/3"`, true},
+		{`rat 2`, true},
+		{"mode 0o755\n", true},
+		{`mode "0755"`, true},
+		{`url 1`, false},
+		{`int 1.5`, false},
+		{`mode true`, false},
+	}
+	for _, tt := range tests {
+		d, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		if v := s.Validate(d); (v == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", tt.input, v, tt.valid)
+		}
+	}
+}
+
+func TestSchemaForOutput(t *testing.T) {
+	doc, err := SchemaFor(reflect.TypeOf(testSchemaTree{}))
+	if err != nil {
+		t.Fatalf("SchemaFor() failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Generate(doc, &buf); err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	want := `document id="github.com/sblinch/kdl-go.testSchemaTree" {
+	node "branch" {
+		value {
+			max 1
+			type "string"
+		}
+		children ref="[id=\"github.com/sblinch/kdl-go.testSchemaTree\"]"
+	}
+}
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	data, err := JSONSchemaFor(reflect.TypeOf(testSchemaServer{}))
+	if err != nil {
+		t.Fatalf("JSONSchemaFor() failed: %v", err)
+	}
+	want := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "host": {
+      "type": "string"
+    },
+    "port": {
+      "type": "integer"
+    },
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
+    "timeout": {
+      "type": "number"
+    },
+    "limits": {
+      "type": "object",
+      "properties": {
+        "connections": {
+          "type": "integer",
+          "minimum": 0,
+          "maximum": 65535
+        },
+        "rate": {
+          "type": "number"
+        }
+      },
+      "required": [
+        "connections"
+      ],
+      "additionalProperties": false
+    },
+    "labels": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    }
+  },
+  "additionalProperties": false
+}`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	data, err = JSONSchemaFor(reflect.TypeOf(testSchemaTree{}))
+	if err != nil {
+		t.Fatalf("JSONSchemaFor() failed: %v", err)
+	}
+	want = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$ref": "#/$defs/github.com~1sblinch~1kdl-go.testSchemaTree",
+  "$defs": {
+    "github.com/sblinch/kdl-go.testSchemaTree": {
+      "type": "object",
+      "properties": {
+        "name": {
+          "type": "string"
+        },
+        "branch": {
+          "type": "array",
+          "items": {
+            "$ref": "#/$defs/github.com~1sblinch~1kdl-go.testSchemaTree"
+          }
+        }
+      },
+      "additionalProperties": false
+    }
+  }
+}`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+type testSchemaConstrainedServer struct {
+	Host string   `kdl:",arg,required,len:1-16"`
+	Port int      `kdl:"port,required,min:1,max:65535"`
+	Tags []string `kdl:"tags,len:-2,enum:a|b|c"`
+}
+
+type testSchemaConstrained struct {
+	Name    string                        `kdl:"name,required,match:[a-z]+"`
+	Level   string                        `kdl:"level,enum:debug|info"`
+	Server  []testSchemaConstrainedServer `kdl:"server,multiple,len:1-"`
+	Include []string                      `kdl:"include,multiple,len:-2"`
+}
+
+func TestSchemaForConstraints(t *testing.T) {
+	doc, err := SchemaFor(reflect.TypeOf(testSchemaConstrained{}))
+	if err != nil {
+		t.Fatalf("SchemaFor() failed: %v", err)
+	}
+	s, err := schema.New(doc)
+	if err != nil {
+		t.Fatalf("schema.New() failed: %v", err)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`name "a"; level "info"; server "x" port=80 { tags "a" "b"; }; include "1"; include "2"`, ""},
+		{`server "x" port=80`, `node "name" must appear at least 1 time(s)`},
+		{`name "a"`, `node "server" must appear at least 1 time(s)`},
+		{`name "A"; server "x" port=80`, `does not match pattern`},
+		{`name "a"; level "warn"; server "x" port=80`, `is not one of`},
+		{`name "a"; server port=80`, `expected at least 1 argument(s), found 0`},
+		{`name "a"; server "01234567890123456" port=80`, `longer than 16 characters`},
+		{`name "a"; server "x"`, `missing required property "port"`},
+		{`name "a"; server "x" port=0 {}`, `property "port"`},
+		{`name "a"; server "x" port=80 { tags "a" "b" "c"; }`, `expected at most 2 argument(s), found 3`},
+		{`name "a"; server "x" port=80 { tags "d"; }`, `is not one of`},
+		{`name "a"; server "x" port=80; include "1"; include "2"; include "3"`, `node "include" may appear at most 2 time(s)`},
+	}
+	for _, tt := range tests {
+		d, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		v := s.Validate(d)
+		if tt.want == "" {
+			if v != nil {
+				t.Errorf("Validate(%q) failed: %v", tt.input, v)
+			}
+		} else if v == nil || !strings.Contains(v.Error(), tt.want) {
+			t.Errorf("Validate(%q) = %v, want error containing %q", tt.input, v, tt.want)
+		}
+	}
+
+	data, err := JSONSchemaFor(reflect.TypeOf(testSchemaConstrainedServer{}))
+	if err != nil {
+		t.Fatalf("JSONSchemaFor() failed: %v", err)
+	}
+	want := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "host": {
+      "type": "string",
+      "minLength": 1,
+      "maxLength": 16
+    },
+    "port": {
+      "type": "integer",
+      "minimum": 1,
+      "maximum": 65535
+    },
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "string",
+        "enum": [
+          "a",
+          "b",
+          "c"
+        ]
+      },
+      "maxItems": 2
+    }
+  },
+  "required": [
+    "host",
+    "port"
+  ],
+  "additionalProperties": false
+}`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSchemaForErrors(t *testing.T) {
+	type badMultiple struct {
+		A string `kdl:"a,multiple"`
+	}
+	tests := []struct {
+		name string
+		typ  reflect.Type
+		want string
+	}{
+		{"scalar", reflect.TypeOf(0), marshaler.ErrStructOrMap.Error()},
+		{"multiple", reflect.TypeOf(badMultiple{}), "tag `,multiple` used on string; must be slice or map"},
+		{"func", reflect.TypeOf(struct{ F func() }{}), "cannot describe func()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SchemaFor(tt.typ); err == nil || err.Error() != tt.want {
+				t.Errorf("SchemaFor() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := JSONSchemaFor(reflect.TypeOf("")); !errors.Is(err, marshaler.ErrStructOrMap) {
+		t.Errorf("JSONSchemaFor() error = %v, want %v", err, marshaler.ErrStructOrMap)
+	}
+}
@@ -0,0 +1,51 @@
+package kdl
+
+import (
+	"github.com/sblinch/kdl-go/internal/marshaler"
+)
+
+// UnionDiscriminator identifies the part of a node that names the concrete type of a union member
+type UnionDiscriminator = marshaler.UnionDiscriminator
+
+// UnionByAnnotation returns a UnionDiscriminator that names a member's type with the node's type annotation, eg:
+// (s3)storage bucket="b"
+func UnionByAnnotation() UnionDiscriminator {
+	return marshaler.UnionByAnnotation()
+}
+
+// UnionByProperty returns a UnionDiscriminator that names a member's type with the property called name, eg:
+// storage type="s3" bucket="b"
+func UnionByProperty(name string) UnionDiscriminator {
+	return marshaler.UnionByProperty(name)
+}
+
+// UnionByArgument returns a UnionDiscriminator that names a member's type with the argument at index, eg:
+// storage "s3" bucket="b"
+func UnionByArgument(index int) UnionDiscriminator {
+	return marshaler.UnionByArgument(index)
+}
+
+// Unions is a registry of interface types whose concrete types are chosen by a discriminator in each node, which can
+// be assigned to UnmarshalOptions.Unions and MarshalerOptions.Unions to apply them to individual calls
+type Unions = marshaler.Unions
+
+// NewUnions returns an empty Unions
+func NewUnions() *Unions {
+	return marshaler.NewUnions()
+}
+
+// AddUnion registers the interface type I in u. A node unmarshaled into a value of type I is unmarshaled into a new
+// value of the type of the member of members keyed by the node's discriminator, which is not itself unmarshaled into
+// the member; a value of type I is marshaled with the key of its type in members as its discriminator. The values of
+// members serve only to identify their types, eg:
+//
+//	kdl.AddUnion[Storage](u, kdl.UnionByAnnotation(), map[string]Storage{"s3": &S3Storage{}, "file": &FileStorage{}})
+func AddUnion[I any](u *Unions, discriminator UnionDiscriminator, members map[string]I) {
+	marshaler.AddUnion[I](u, discriminator, members)
+}
+
+// AddCustomUnion registers the interface type I for all Marshal and Unmarshal calls, as for AddUnion. It may be called
+// at any time; interface types in UnmarshalOptions.Unions and MarshalerOptions.Unions take precedence over it.
+func AddCustomUnion[I any](discriminator UnionDiscriminator, members map[string]I) {
+	marshaler.AddCustomUnion[I](discriminator, members)
+}
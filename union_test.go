@@ -0,0 +1,124 @@
+package kdl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testStorage interface {
+	Describe() string
+}
+
+type testS3Storage struct {
+	Bucket string `kdl:"bucket"`
+	Region string `kdl:"region,omitempty"`
+}
+
+func (s *testS3Storage) Describe() string { return "s3:" + s.Bucket }
+
+type testFileStorage struct {
+	Path string `kdl:",arg"`
+}
+
+func (s testFileStorage) Describe() string { return "file:" + s.Path }
+
+type testStorageConfig struct {
+	Primary testStorage   `kdl:"primary"`
+	Backups []testStorage `kdl:"backup,multiple"`
+}
+
+func testStorageUnions(d UnionDiscriminator) *Unions {
+	u := NewUnions()
+	AddUnion[testStorage](u, d, map[string]testStorage{"s3": &testS3Storage{}, "file": testFileStorage{}})
+	return u
+}
+
+func TestUnion(t *testing.T) {
+	tests := []struct {
+		name          string
+		discriminator UnionDiscriminator
+		input         string
+	}{
+		{
+			"annotation",
+			UnionByAnnotation(),
+			"(s3)primary bucket=\"b\" region=\"r\"\n(file)backup \"/srv\"\n(s3)backup bucket=\"c\"\n",
+		},
+		{
+			"property",
+			UnionByProperty("type"),
+			"primary type=\"s3\" bucket=\"b\" region=\"r\"\nbackup \"/srv\" type=\"file\"\nbackup type=\"s3\" bucket=\"c\"\n",
+		},
+		{
+			"argument",
+			UnionByArgument(0),
+			"primary \"s3\" bucket=\"b\" region=\"r\"\nbackup \"file\" \"/srv\"\nbackup \"s3\" bucket=\"c\"\n",
+		},
+	}
+	want := testStorageConfig{
+		Primary: &testS3Storage{Bucket: "b", Region: "r"},
+		Backups: []testStorage{testFileStorage{Path: "/srv"}, &testS3Storage{Bucket: "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := testStorageUnions(tt.discriminator)
+			var cfg testStorageConfig
+			if err := UnmarshalWithOptions([]byte(tt.input), &cfg, UnmarshalOptions{Unions: u}); err != nil {
+				t.Fatalf("Unmarshal() failed: %v", err)
+			}
+			if !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("got %#v, want %#v", cfg, want)
+			}
+
+			got, err := MarshalWithOptions(cfg, MarshalOptions{MarshalerOptions: MarshalerOptions{Unions: u}, GeneratorOptions: DefaultGenerateOptions})
+			if err != nil {
+				t.Fatalf("Marshal() failed: %v", err)
+			}
+			// the order in which the discriminator property is written among the others is unspecified, so the output
+			// is compared by unmarshaling it again
+			var again testStorageConfig
+			if err := UnmarshalWithOptions(got, &again, UnmarshalOptions{Unions: u}); err != nil {
+				t.Fatalf("Unmarshal(%q) failed: %v", got, err)
+			}
+			if !reflect.DeepEqual(again, want) {
+				t.Errorf("Marshal() = %q, which unmarshals to %#v", got, again)
+			}
+			if tt.name != "property" && string(got) != tt.input {
+				t.Errorf("Marshal() = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestUnionErrors(t *testing.T) {
+	u := testStorageUnions(UnionByAnnotation())
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`primary bucket="b"`, "missing type annotation"},
+		{`(gcs)primary bucket="b"`, `unknown type "gcs"`},
+		{`(s3)primary bucket="b" path="x"`, "unexpected"},
+	}
+	for _, tt := range tests {
+		var cfg testStorageConfig
+		err := UnmarshalWithOptions([]byte(tt.input), &cfg, UnmarshalOptions{Unions: u})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+
+	type other struct{ testStorage }
+	cfg := testStorageConfig{Primary: &other{}}
+	if _, err := MarshalWithOptions(cfg, MarshalOptions{MarshalerOptions: MarshalerOptions{Unions: u}, GeneratorOptions: DefaultGenerateOptions}); err == nil || !strings.Contains(err.Error(), "is not a registered member") {
+		t.Errorf("Marshal() error = %v, want unregistered member", err)
+	}
+
+	// interface types with methods cannot receive generic values
+	var cfg2 testStorageConfig
+	if err := Unmarshal([]byte(`(s3)primary bucket="b"`), &cfg2); err == nil || !strings.Contains(err.Error(), "not a registered union") {
+		t.Errorf("Unmarshal() error = %v, want unregistered union", err)
+	}
+}
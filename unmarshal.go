@@ -6,6 +6,7 @@ import (
 
 	"github.com/sblinch/kdl-go/document"
 	"github.com/sblinch/kdl-go/internal/marshaler"
+	"github.com/sblinch/kdl-go/internal/parser"
 	"github.com/sblinch/kdl-go/internal/tokenizer"
 )
 
@@ -26,21 +27,88 @@ type ValueUnmarshaler interface {
 type Decoder struct {
 	r       io.Reader
 	Options marshaler.UnmarshalOptions
+
+	// streaming state used by More, NextNode, and DecodeNext
+	s       *tokenizer.Scanner
+	p       *parser.Parser
+	c       *parser.ParseContext
+	pending []*document.Node
+	err     error
+	done    bool
 }
 
 // Decode decodes KDL from the Decoder's reader into v; v must contain a pointer type. Returns a non-nil error on
 // failure.
 func (d *Decoder) Decode(v interface{}) error {
-	s := tokenizer.New(d.r)
-	s.RelaxedNonCompliant = d.Options.RelaxedNonCompliant
-	s.ParseComments = d.Options.ParseComments
-	if doc, err := parse(s); err != nil {
+	opts := unmarshalParseOptions(d.Options)
+	if doc, err := parse(newScanner(d.r, opts), opts); err != nil {
 		return err
 	} else {
 		return marshaler.UnmarshalWithOptions(doc, v, d.Options)
 	}
 }
 
+// fill reads and parses tokens from the Decoder's reader until at least one complete top-level node is available, the
+// input is exhausted, or an error occurs
+func (d *Decoder) fill() {
+	if d.s == nil {
+		opts := unmarshalParseOptions(d.Options)
+		d.s = newScanner(d.r, opts)
+		d.p = parser.New()
+		d.c = d.p.NewContextOptions(opts)
+	}
+
+	for len(d.pending) == 0 && !d.done && d.err == nil {
+		if !d.s.Scan() {
+			d.err = d.s.Err()
+			d.done = true
+		} else if err := d.p.Parse(d.c, d.s.Token()); err != nil {
+			d.err = err
+		}
+		d.pending = d.c.TakeCompletedNodes()
+	}
+
+	if d.done || d.err != nil {
+		_ = d.s.Close()
+	}
+}
+
+// More reports whether another top-level node (or a pending error) is available from NextNode or DecodeNext.
+func (d *Decoder) More() bool {
+	d.fill()
+	return len(d.pending) > 0 || d.err != nil
+}
+
+// NextNode parses and returns the next top-level node from the Decoder's reader. Only one top-level node is held in
+// memory at a time, so NextNode can be used to process arbitrarily large documents whose individual top-level nodes
+// are of a reasonable size. Returns io.EOF when no further nodes are available, or a non-nil error on failure.
+//
+// NextNode and DecodeNext must not be mixed with Decode on the same Decoder.
+func (d *Decoder) NextNode() (*document.Node, error) {
+	d.fill()
+	if len(d.pending) == 0 {
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, io.EOF
+	}
+
+	node := d.pending[0]
+	d.pending[0] = nil
+	d.pending = d.pending[1:]
+	return node, nil
+}
+
+// DecodeNext decodes the next top-level node from the Decoder's reader into v; v must contain a pointer type. Returns
+// io.EOF when no further nodes are available, or a non-nil error on failure.
+func (d *Decoder) DecodeNext(v interface{}) error {
+	node, err := d.NextNode()
+	if err != nil {
+		return err
+	}
+	return marshaler.UnmarshalNodeWithOptions(node, v, d.Options)
+}
+
 // NewDecoder returns a Decoder that reads from r
 func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r: r}
@@ -49,7 +117,7 @@ func NewDecoder(r io.Reader) *Decoder {
 // Unmarshal unmarshals KDL from data into v; v must contain a pointer type. Returns a non-nil error on failure.
 func Unmarshal(data []byte, v interface{}) error {
 	s := tokenizer.NewSlice(data)
-	if doc, err := parse(s); err != nil {
+	if doc, err := parse(s, DefaultParseOptions); err != nil {
 		return err
 	} else {
 		return marshaler.Unmarshal(doc, v)
@@ -59,13 +127,11 @@ func Unmarshal(data []byte, v interface{}) error {
 // UnmarshalWithOptions unmarshals KDL from data into v with the specified options; v must contain a pointer type.
 // Returns a non-nil error on failure.
 func UnmarshalWithOptions(data []byte, v interface{}, opts UnmarshalOptions) error {
-	s := tokenizer.NewSlice(data)
-	s.RelaxedNonCompliant = opts.RelaxedNonCompliant
-	s.ParseComments = opts.ParseComments
-	if doc, err := parse(s); err != nil {
+	popts := unmarshalParseOptions(opts)
+	if doc, err := parse(newSliceScanner(data, popts), popts); err != nil {
 		return err
 	} else {
-		return marshaler.Unmarshal(doc, v)
+		return marshaler.UnmarshalWithOptions(doc, v, opts)
 	}
 }
 
@@ -85,10 +151,34 @@ func UnmarshalNodeWithOptions(node *document.Node, v interface{}, opts Unmarshal
 	return marshaler.UnmarshalNodeWithOptions(node, v, opts)
 }
 
+// AddCustomUnmarshaler registers a function that unmarshals nodes into values of type T for all Unmarshal calls. It may
+// be called at any time; functions in UnmarshalOptions.Unmarshalers take precedence over it.
 func AddCustomUnmarshaler[T any](unmarshal func(node *document.Node, v reflect.Value) error) {
 	marshaler.AddCustomUnmarshaler[T](unmarshal)
 }
 
+// AddCustomValueUnmarshaler registers a function that unmarshals argument or property values into values of type T for
+// all Unmarshal calls. It may be called at any time; functions in UnmarshalOptions.Unmarshalers take precedence over
+// it.
 func AddCustomValueUnmarshaler[T any](unmarshal func(value *document.Value, v reflect.Value, format string) error) {
 	marshaler.AddCustomValueUnmarshaler[T](unmarshal)
 }
+
+// Unmarshalers is a registry of custom unmarshaling functions which can be assigned to UnmarshalOptions.Unmarshalers
+// to apply them to individual Unmarshal calls or Decoders
+type Unmarshalers = marshaler.Unmarshalers
+
+// NewUnmarshalers returns an empty Unmarshalers
+func NewUnmarshalers() *Unmarshalers {
+	return marshaler.NewUnmarshalers()
+}
+
+// AddUnmarshaler registers a function in u that unmarshals nodes into values of type T
+func AddUnmarshaler[T any](u *Unmarshalers, unmarshal func(node *document.Node, v reflect.Value) error) {
+	marshaler.AddUnmarshaler[T](u, unmarshal)
+}
+
+// AddValueUnmarshaler registers a function in u that unmarshals argument or property values into values of type T
+func AddValueUnmarshaler[T any](u *Unmarshalers, unmarshal func(value *document.Value, v reflect.Value, format string) error) {
+	marshaler.AddValueUnmarshaler[T](u, unmarshal)
+}
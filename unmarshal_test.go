@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"runtime"
 	"runtime/pprof"
 	"strconv"
@@ -1332,10 +1338,6 @@ map key="skipped" key="value"
 	}
 }
 
-/*
-These tests cannot be run as part of a larger batch as the AddCustomUnmarshaler calls will panic given that other tests
-have already created Indexers. Uncomment and run individually to test custom unmarshaling.
-
 func TestCustomUnmarshaler(t *testing.T) {
 	type coocooKachoo struct {
 		S string
@@ -1394,4 +1396,717 @@ func TestCustomValueUnmarshaler(t *testing.T) {
 		t.Fatalf("want: %s\n got: %s\n", want, got)
 	}
 }
-*/
+
+func TestUnmarshalersOption(t *testing.T) {
+	type coocooKachoo struct {
+		S string
+	}
+	type snackbar struct {
+		Chugga coocooKachoo `kdl:"chugga"`
+	}
+
+	// registering after a type has been unmarshaled must take effect without panicking
+	if err := Unmarshal([]byte(`chugga S="plain"`), &snackbar{}); err != nil {
+		t.Fatal(err)
+	}
+	AddCustomValueUnmarshaler[coocooKachoo](func(value *document.Value, v reflect.Value, format string) error {
+		v.Field(0).SetString("global " + value.ValueString())
+		return nil
+	})
+
+	u := NewUnmarshalers()
+	AddUnmarshaler[coocooKachoo](u, func(node *document.Node, v reflect.Value) error {
+		if len(node.Arguments) == 0 {
+			return errors.New("no arguments on this node")
+		}
+		v.Field(0).SetString("local " + node.Arguments[0].ValueString())
+		return nil
+	})
+
+	tests := []struct {
+		name string
+		opts UnmarshalOptions
+		want string
+	}{
+		{"local", UnmarshalOptions{Unmarshalers: u}, "local choo choo"},
+		{"global", UnmarshalOptions{}, "global choo choo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &snackbar{}
+			if err := UnmarshalWithOptions([]byte(`chugga "choo choo"`), v, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if v.Chugga.S != tt.want {
+				t.Fatalf("want: %s\n got: %s\n", tt.want, v.Chugga.S)
+			}
+		})
+	}
+}
+
+func TestDecoderNextNode(t *testing.T) {
+	data := `
+person "Bob" age=76 {
+	active true
+}
+person "Jane" age=32; person "Sally" age=48
+// trailing comment
+`
+	type person struct {
+		Name   string `kdl:",arg"`
+		Age    int    `kdl:"age"`
+		Active bool   `kdl:"active"`
+	}
+
+	dec := NewDecoder(strings.NewReader(data))
+	var got []person
+	for dec.More() {
+		var p person
+		if err := dec.DecodeNext(&p); err != nil {
+			t.Fatalf("DecodeNext() error = %v", err)
+		}
+		got = append(got, p)
+	}
+	if _, err := dec.NextNode(); err != io.EOF {
+		t.Fatalf("NextNode() error = %v, want io.EOF", err)
+	}
+
+	want := []person{{"Bob", 76, true}, {"Jane", 32, false}, {"Sally", 48, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DecodeNext():\ngot : %#v\nwant: %#v", got, want)
+	}
+
+	// nodes are decoded as the input is read, rather than once all of it has been read
+	const count = 10000
+	r := strings.NewReader(strings.Repeat(`person "Bob" age=76`+"\n", count))
+	dec = NewDecoder(r)
+	var p person
+	if err := dec.DecodeNext(&p); err != nil {
+		t.Fatalf("DecodeNext() error = %v", err)
+	}
+	if r.Len() == 0 {
+		t.Fatalf("DecodeNext() read the entire input before returning the first node")
+	}
+	n := 1
+	for ; dec.More(); n++ {
+		if err := dec.DecodeNext(&p); err != nil {
+			t.Fatalf("DecodeNext() error = %v", err)
+		}
+	}
+	if n != count || r.Len() != 0 {
+		t.Fatalf("DecodeNext() decoded %d nodes with %d bytes unread, want %d nodes with none unread", n, r.Len(), count)
+	}
+}
+
+func TestDecoderNextNodeError(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("first 1\nsecond 2 {\n"))
+	if node, err := dec.NextNode(); err != nil {
+		t.Fatalf("NextNode() error = %v", err)
+	} else if node.Name.ValueString() != "first" {
+		t.Fatalf("NextNode() returned %s, want first", node.Name.ValueString())
+	}
+
+	if !dec.More() {
+		t.Fatalf("More() = false, want true for pending error")
+	}
+	if _, err := dec.NextNode(); err == nil || err == io.EOF {
+		t.Fatalf("NextNode() error = %v, want parse error", err)
+	}
+}
+
+type testCollectListen struct {
+	Addr string        `kdl:",arg"`
+	Port int           `kdl:"port"`
+	Wait time.Duration `kdl:"wait"`
+}
+
+type testCollectServer struct {
+	Name   string            `kdl:",arg"`
+	Listen testCollectListen `kdl:"listen"`
+	Limits map[string]time.Duration
+}
+
+type testCollectConfig struct {
+	Servers []testCollectServer `kdl:"server,multiple"`
+	Level   int                 `kdl:"level"`
+	Debug   bool                `kdl:"debug"`
+}
+
+func TestUnmarshalCollectErrors(t *testing.T) {
+	data := `server "a" {
+    listen "0.0.0.0" port=80 wait="forever"
+    bogus 1
+}
+server "b" "extra" {
+    listen "::" { port 8080; wait "soon"; }
+    limits idle="1m" busy="never"
+}
+level 1 2
+debug true
+`
+	type wantError struct {
+		path, pos, field, typ, cause string
+	}
+	want := []wantError{
+		{"server[0] > listen > wait", "2:30", "testCollectListen.Wait", "time.Duration", `time: invalid duration "forever"`},
+		{"server[0] > bogus", "3:5", "", "kdl.testCollectServer", `no struct field into which to unmarshal node "bogus"`},
+		{"server[1]", "5:12", "testCollectConfig.Servers", "kdl.testCollectServer", "server has unexpected arguments"},
+		{"server[1] > listen > wait", "6:30", "testCollectListen.Wait", "time.Duration", `time: invalid duration "soon"`},
+		{"server[1] > limits > busy", "7:22", "testCollectServer.Limits", "map[string]time.Duration", `time: invalid duration "never"`},
+		{"level", "9:1", "testCollectConfig.Level", "int", "level expects 1 argument(s), 2 provided"},
+	}
+
+	var cfg testCollectConfig
+	err := UnmarshalWithOptions([]byte(data), &cfg, UnmarshalOptions{CollectErrors: true})
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Unmarshal() error = %v, want UnmarshalErrors", err)
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Unmarshal() returned %d errors, want %d:\n%v", len(errs), len(want), err)
+	}
+	for i, e := range errs {
+		typ := ""
+		if e.Type != nil {
+			typ = e.Type.String()
+		}
+		got := wantError{e.Path, e.Position.String(), e.Field, typ, e.Err.Error()}
+		if got != want[i] {
+			t.Errorf("error %d:\ngot : %#v\nwant: %#v", i, got, want[i])
+		}
+	}
+
+	// unmarshaling continues past each error
+	if len(cfg.Servers) != 2 || cfg.Servers[1].Listen.Port != 8080 || cfg.Servers[1].Limits["idle"] != time.Minute || !cfg.Debug {
+		t.Errorf("Unmarshal() did not continue past errors: %+v", cfg)
+	}
+
+	wantMsg := `2:30: server[0] > listen > wait: testCollectListen.Wait (time.Duration): time: invalid duration "forever"`
+	if msg := strings.SplitN(err.Error(), "\n", 2)[0]; msg != wantMsg {
+		t.Errorf("Error() = %q, want %q", msg, wantMsg)
+	}
+
+	// without CollectErrors, unmarshaling stops at the first error
+	err = UnmarshalWithOptions([]byte(data), &testCollectConfig{}, UnmarshalOptions{})
+	if err == nil || err.Error() != `server: listen: time: invalid duration "forever"` {
+		t.Errorf("Unmarshal() error = %v", err)
+	}
+
+	// a document without errors yields a nil error
+	if err := UnmarshalWithOptions([]byte(`level 1`), &testCollectConfig{}, UnmarshalOptions{CollectErrors: true}); err != nil {
+		t.Errorf("Unmarshal() error = %v, want nil", err)
+	}
+}
+
+func TestUnmarshalNodeCollectErrors(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`listen "::" "extra" port=1 wait="soon"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l testCollectListen
+	err = UnmarshalNodeWithOptions(doc.Nodes[0], &l, UnmarshalOptions{CollectErrors: true})
+	want := "1:13: listen: listen has unexpected arguments\n" +
+		`1:28: listen > wait: testCollectListen.Wait (time.Duration): time: invalid duration "soon"`
+	if err == nil || err.Error() != want {
+		t.Errorf("UnmarshalNode() error = %v, want %v", err, want)
+	}
+	if l.Addr != "::" || l.Port != 1 {
+		t.Errorf("UnmarshalNode() did not continue past errors: %+v", l)
+	}
+}
+
+type testDefaultListen struct {
+	Addr string        `kdl:",arg,required"`
+	Port int           `kdl:"port,required"`
+	Wait time.Duration `kdl:"wait,format:sec,default:2.5"`
+}
+
+type testDefaultConfig struct {
+	Name    string             `kdl:"name,required"`
+	Level   int                `kdl:"level,default:3"`
+	Mode    string             `kdl:"mode,default:fast"`
+	Started time.Time          `kdl:"started,format:'2006-01-02',default:2024-01-02"`
+	Listen  *testDefaultListen `kdl:"listen"`
+}
+
+func TestUnmarshalRequiredDefault(t *testing.T) {
+	var cfg testDefaultConfig
+	if err := Unmarshal([]byte(`name "x"; listen "::" port=80`), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	want := testDefaultConfig{
+		Name:    "x",
+		Level:   3,
+		Mode:    "fast",
+		Started: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Listen:  &testDefaultListen{Addr: "::", Port: 80, Wait: 2500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	// values present in the document, or already set in the destination, are not replaced by defaults
+	cfg = testDefaultConfig{Level: 7}
+	if err := Unmarshal([]byte(`name "x"; mode "slow"; listen "::" { port 80; wait 1; }`), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if cfg.Level != 7 || cfg.Mode != "slow" || cfg.Listen.Wait != time.Second {
+		t.Errorf("defaults replaced existing values: %+v", cfg)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`level 1`, `1:1: testDefaultConfig.Name (string): missing required node "name"`},
+		{"name \"x\"\nlisten port=1", `listen: 2:1: testDefaultListen.Addr (string): missing required argument "addr"`},
+		{"name \"x\"\nlisten \"::\"", `listen: 2:1: testDefaultListen.Port (int): missing required property or child node "port"`},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.input), &testDefaultConfig{})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%q) error = %v, want %v", tt.input, err, tt.want)
+		}
+		var ue *UnmarshalError
+		if !errors.As(err, &ue) {
+			t.Errorf("Unmarshal(%q) error is not an *UnmarshalError", tt.input)
+		}
+	}
+
+	// missing fields are collected with their paths
+	err := UnmarshalWithOptions([]byte("listen\nlisten \"::\" port=1"), &testDefaultConfig{}, UnmarshalOptions{CollectErrors: true})
+	wantErrs := `1:1: listen: testDefaultListen.Addr (string): missing required argument "addr"` + "\n" +
+		`1:1: listen > port: testDefaultListen.Port (int): missing required property or child node "port"` + "\n" +
+		`1:1: name: testDefaultConfig.Name (string): missing required node "name"`
+	if err == nil || err.Error() != wantErrs {
+		t.Errorf("Unmarshal() error = %v, want %v", err, wantErrs)
+	}
+
+	// missing children are reported at the position of their parent
+	type childrenConfig struct {
+		Listen struct {
+			Children testDefaultConfig `kdl:",children"`
+		} `kdl:"listen"`
+	}
+	err = Unmarshal([]byte("\nlisten {\n\tlevel 1\n}\n"), &childrenConfig{})
+	if want := `listen: 2:1: testDefaultConfig.Name (string): missing required node "name"`; err == nil || err.Error() != want {
+		t.Errorf("Unmarshal() error = %v, want %v", err, want)
+	}
+
+	type badDefault struct {
+		Wait time.Duration `kdl:"wait,default:forever"`
+	}
+	if err := Unmarshal([]byte(``), &badDefault{}); err == nil || !strings.Contains(err.Error(), `invalid default value "forever"`) {
+		t.Errorf("Unmarshal() error = %v, want invalid default", err)
+	}
+}
+
+type testValidateListen struct {
+	Addr  string   `kdl:",arg,match:[0-9a-f.:]+"`
+	Port  int      `kdl:"port,min:1,max:65535"`
+	Proto string   `kdl:"proto,enum:tcp|udp,default:tcp"`
+	Tags  []string `kdl:"tags,len:-2,enum:a|b|c"`
+}
+
+type testValidateConfig struct {
+	Name    string                `kdl:"name,len:1-8"`
+	Timeout time.Duration         `kdl:"timeout,min:1s,max:1m"`
+	Socket  string                `kdl:"socket,oneof:endpoint"`
+	Listen  []*testValidateListen `kdl:"listen,multiple,oneof:endpoint"`
+}
+
+func TestUnmarshalValidation(t *testing.T) {
+	var cfg testValidateConfig
+	input := "name \"web\"\ntimeout \"30s\"\nlisten \"::1\" port=80 { tags \"a\"; }\nlisten \"10.0.0.1\" port=443 proto=\"udp\""
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	want := testValidateConfig{
+		Name:    "web",
+		Timeout: 30 * time.Second,
+		Listen: []*testValidateListen{
+			{Addr: "::1", Port: 80, Proto: "tcp", Tags: []string{"a"}},
+			{Addr: "10.0.0.1", Port: 443, Proto: "udp"},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`name ""`, `1:1: testValidateConfig.Name (string): length 0 is less than the minimum of 1`},
+		{`name "abcdefghi"`, `1:1: testValidateConfig.Name (string): length 9 is greater than the maximum of 8`},
+		{`timeout "2m"`, `1:1: testValidateConfig.Timeout (time.Duration): 2m0s is greater than the maximum of 1m`},
+		{`timeout "10ms"`, `1:1: testValidateConfig.Timeout (time.Duration): 10ms is less than the minimum of 1s`},
+		{"name \"x\"\nlisten \"::\" port=0\n", `listen: 2:13: testValidateListen.Port (int): 0 is less than the minimum of 1`},
+		{`listen "x" port=1`, `listen: 1:8: testValidateListen.Addr (string): "x" does not match [0-9a-f.:]+`},
+		{`listen "::" port=1 proto="sctp"`, `listen: 1:20: testValidateListen.Proto (string): "sctp" is not one of tcp, udp`},
+		{`listen "::" port=1 { tags "a" "d"; }`, `listen: 1:22: testValidateListen.Tags ([]string): "d" is not one of a, b, c`},
+		{`listen "::" port=1 { tags "a" "b" "c"; }`, `listen: 1:22: testValidateListen.Tags ([]string): length 3 is greater than the maximum of 2`},
+		{"socket \"/tmp/s\"\nlisten \"::\" port=1", `2:1: testValidateConfig.Listen ([]*kdl.testValidateListen): only one of "socket", "listen" may be present`},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.input), &testValidateConfig{})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%q) error = %v, want %v", tt.input, err, tt.want)
+		}
+		var ue *UnmarshalError
+		if !errors.As(err, &ue) {
+			t.Errorf("Unmarshal(%q) error is not an *UnmarshalError", tt.input)
+		}
+	}
+
+	// violations are collected with their paths, including the index of each node of a field tagged ",multiple"
+	err := UnmarshalWithOptions([]byte("name \"\"\nlisten \"::\" port=0\nlisten \"::\" port=1 proto=\"x\""), &testValidateConfig{}, UnmarshalOptions{CollectErrors: true})
+	wantErrs := `1:1: name: testValidateConfig.Name (string): length 0 is less than the minimum of 1` + "\n" +
+		`2:13: listen[0] > port: testValidateListen.Port (int): 0 is less than the minimum of 1` + "\n" +
+		`3:20: listen[1] > proto: testValidateListen.Proto (string): "x" is not one of tcp, udp`
+	if err == nil || err.Error() != wantErrs {
+		t.Errorf("Unmarshal() error = %v, want %v", err, wantErrs)
+	}
+
+	// only the first error for each field is collected, so the missing argument is not also reported as a violation
+	err = UnmarshalWithOptions([]byte("listen \"::\" port=0\nname"), &testValidateConfig{}, UnmarshalOptions{CollectErrors: true})
+	wantErrs = `1:13: listen[0] > port: testValidateListen.Port (int): 0 is less than the minimum of 1` + "\n" +
+		`2:1: name: testValidateConfig.Name (string): name expects 1 argument(s), 0 provided`
+	if err == nil || err.Error() != wantErrs {
+		t.Errorf("Unmarshal() error = %v, want %v", err, wantErrs)
+	}
+
+	// quoted patterns are matched without their quotes
+	type quoted struct {
+		Code string `kdl:"code,match:'[A-Z]+'"`
+	}
+	if err := Unmarshal([]byte(`code "ABC"`), &quoted{}); err != nil {
+		t.Errorf("Unmarshal() failed: %v", err)
+	}
+	if err := Unmarshal([]byte(`code "'ABC'"`), &quoted{}); err == nil {
+		t.Errorf("Unmarshal() of a quoted value succeeded")
+	}
+
+	// invalid constraints are rejected when the type is indexed, even if the field is absent
+	badRules := []struct {
+		v    interface{}
+		want string
+	}{
+		{&struct {
+			Size string `kdl:"size,len:x"`
+		}{}, `invalid len "x"`},
+		{&struct {
+			Port int `kdl:"port,min:abc"`
+		}{}, `invalid min value "abc"`},
+		{&struct {
+			Port uint8 `kdl:"port,max:300"`
+		}{}, `invalid max value "300"`},
+		{&struct {
+			Wait time.Duration `kdl:"wait,max:soon"`
+		}{}, `invalid max value "soon"`},
+		{&struct {
+			Name string `kdl:"name,min:1"`
+		}{}, `tag option min: cannot be used with string`},
+		{&struct {
+			Name string `kdl:"name,match:[a-"`
+		}{}, `invalid match pattern "[a-"`},
+	}
+	for _, tt := range badRules {
+		if err := Unmarshal([]byte(``), tt.v); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal() error = %v, want %s", err, tt.want)
+		}
+	}
+}
+
+type testUnknownPlugin struct {
+	Name  string                 `kdl:",arg"`
+	Level int                    `kdl:"level"`
+	Extra map[string]interface{} `kdl:",unknown"`
+}
+
+type testUnknownConfig struct {
+	Name    string             `kdl:"name"`
+	Plugin  *testUnknownPlugin `kdl:"plugin"`
+	Unknown []*document.Node   `kdl:",unknown"`
+}
+
+func TestUnmarshalUnknown(t *testing.T) {
+	input := "name \"x\"\nplugin \"p\" \"q\" level=2 mode=\"fast\" {\n\tcache size=3\n}\nmetrics port=9090\n"
+	var cfg testUnknownConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if cfg.Name != "x" || cfg.Plugin == nil || cfg.Plugin.Name != "p" || cfg.Plugin.Level != 2 {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	wantExtra := map[string]interface{}{"1": "q", "mode": "fast", "cache": map[string]interface{}{"size": int64(3)}}
+	if !reflect.DeepEqual(cfg.Plugin.Extra, wantExtra) {
+		t.Errorf("got extra %#v, want %#v", cfg.Plugin.Extra, wantExtra)
+	}
+	if len(cfg.Unknown) != 1 || cfg.Unknown[0].Name.ValueString() != "metrics" {
+		t.Errorf("got unknown nodes %v, want [metrics]", cfg.Unknown)
+	}
+
+	// nothing is captured if every node, argument, and property corresponds to a field
+	cfg = testUnknownConfig{}
+	if err := Unmarshal([]byte("name \"x\"\nplugin \"p\" level=1"), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if cfg.Unknown != nil || cfg.Plugin.Extra != nil {
+		t.Errorf("unexpected captured content %+v %+v", cfg.Unknown, cfg.Plugin.Extra)
+	}
+
+	// nodes that a map cannot represent faithfully are rejected rather than captured lossily
+	type mapUnknown struct {
+		Name  string                 `kdl:"name"`
+		Extra map[string]interface{} `kdl:",unknown"`
+	}
+	for input, want := range map[string]string{
+		"name \"x\"\next 1 2 a=3\next 4":   `unknown node "ext" is repeated`,
+		"name \"x\"\next 1 { deep true; }": `unknown node "ext" has child nodes`,
+		"name \"x\"\nother":                `unknown node "other" has no arguments or properties`,
+	} {
+		if err := Unmarshal([]byte(input), &mapUnknown{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Unmarshal(%q) error = %v, want %q", input, err, want)
+		}
+	}
+
+	type badUnknown struct {
+		Rest []string `kdl:",unknown"`
+	}
+	if err := Unmarshal([]byte("a 1"), &badUnknown{}); err == nil || !strings.Contains(err.Error(), "must be a []*document.Node or a map with string keys") {
+		t.Errorf("Unmarshal() error = %v, want invalid type", err)
+	}
+}
+
+type testAnnotatedBackend struct {
+	Host string `kdl:",arg"`
+}
+
+type testAnnotatedConfig struct {
+	Port    uint16                `kdl:"port,type:u16"`
+	ID      string                `kdl:"id,type:uuid"`
+	Addr    string                `kdl:"addr,child,type:ipv4"`
+	Weights []int                 `kdl:"weights,type:u8"`
+	Backend *testAnnotatedBackend `kdl:"backend,type:http"`
+}
+
+func TestUnmarshalTypeAnnotations(t *testing.T) {
+	input := "port (u16)8080\nid \"123e4567-e89b-12d3-a456-426614174000\"\naddr (ipv4)\"10.0.0.1\"\nweights 1 (u8)2\n(http)backend \"b\"\n"
+	var cfg testAnnotatedConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	want := testAnnotatedConfig{
+		Port:    8080,
+		ID:      "123e4567-e89b-12d3-a456-426614174000",
+		Addr:    "10.0.0.1",
+		Weights: []int{1, 2},
+		Backend: &testAnnotatedBackend{Host: "b"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"port (i32)80\n", `1:6: testAnnotatedConfig.Port (uint16): type annotation (i32) conflicts with the field's type (u16)`},
+		{"port 70000\n", `1:6: testAnnotatedConfig.Port (uint16): 70000 is out of range for (u16)`},
+		{"port 1.5\n", `1:6: testAnnotatedConfig.Port (uint16): 1.5 is not an integer`},
+		{`id "42"`, `1:4: testAnnotatedConfig.ID (string): "42" is not a valid (uuid)`},
+		{`addr "::1"`, `1:6: testAnnotatedConfig.Addr (string): "::1" is not a valid (ipv4)`},
+		{`weights 1 256`, `1:11: testAnnotatedConfig.Weights ([]int): 256 is out of range for (u8)`},
+		{`(grpc)backend "b"`, `1:1: testAnnotatedConfig.Backend (*kdl.testAnnotatedBackend): type annotation (grpc) conflicts with the field's type (http)`},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.input), &testAnnotatedConfig{})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%q) error = %v, want %v", tt.input, err, tt.want)
+		}
+	}
+
+	// the reserved annotations are checked against the values of fields tagged with them
+	for _, tt := range []struct {
+		annotation string
+		valid      []string
+		invalid    []string
+	}{
+		{"i8", []string{"-128", "127"}, []string{"-129", "128", `"1"`}},
+		{"u64", []string{"0", "18446744073709551615"}, []string{"-1", "18446744073709551616"}},
+		{"f32", []string{"1.5", "3"}, []string{"1e39", `"x"`}},
+		{"decimal", []string{"1.5", `"1.10"`}, []string{`"x"`}},
+		{"date-time", []string{`"2024-01-02T03:04:05Z"`}, []string{`"2024-01-02"`}},
+		{"date", []string{`"2024-01-02"`}, []string{`"2024-13-02"`}},
+		{"time", []string{`"03:04:05"`, `"03:04:05.5+01:00"`}, []string{`"3pm"`}},
+		{"ipv6", []string{`"::1"`}, []string{`"10.0.0.1"`}},
+		{"url", []string{`"https://example.com/"`}, []string{`"/relative"`}},
+		{"regex", []string{`"^a+$"`}, []string{`"("`}},
+		{"base64", []string{`"aGk="`}, []string{`"a"`}},
+		{"custom", []string{`"anything"`, "1"}, nil},
+	} {
+		typ := reflect.StructOf([]reflect.StructField{{
+			Name: "V",
+			Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+			Tag:  reflect.StructTag(`kdl:"v,type:` + tt.annotation + `"`),
+		}})
+		for _, v := range tt.valid {
+			if err := Unmarshal([]byte("v "+v+"\n"), reflect.New(typ).Interface()); err != nil {
+				t.Errorf("Unmarshal(%q) into (%s) failed: %v", v, tt.annotation, err)
+			}
+		}
+		for _, v := range tt.invalid {
+			if err := Unmarshal([]byte("v "+v+"\n"), reflect.New(typ).Interface()); err == nil {
+				t.Errorf("Unmarshal(%q) into (%s) succeeded, want error", v, tt.annotation)
+			}
+		}
+	}
+
+	// the annotations of values unmarshaled into fields without a type option are not checked
+	type untagged struct {
+		Plain string   `kdl:"plain"`
+		Host  string   `kdl:"host"`
+		Ports []uint16 `kdl:"ports"`
+	}
+	var u untagged
+	if err := Unmarshal([]byte("plain (u8)300\nhost (ipv4)\"nope\"\nports 80 (u8)443\n"), &u); err != nil {
+		t.Errorf("Unmarshal() failed: %v", err)
+	}
+	if want := (untagged{Plain: "300", Host: "nope", Ports: []uint16{80, 443}}); !reflect.DeepEqual(u, want) {
+		t.Errorf("got %+v, want %+v", u, want)
+	}
+}
+
+func TestUnmarshalAnnotatedIntf(t *testing.T) {
+	type config struct {
+		Created interface{}            `kdl:"created"`
+		Typed   string                 `kdl:"typed"`
+		Hosts   []interface{}          `kdl:"hosts"`
+		Extra   map[string]interface{} `kdl:"extra"`
+	}
+	input := "created (date-time)\"2024-01-02T03:04:05Z\"\ntyped (date-time)\"2024-01-02T03:04:05Z\"\nhosts (ipv6)\"::1\" \"x\"\nextra id=(uuid)\"123e4567-e89b-12d3-a456-426614174000\" key=(base64)\"aGk=\"\n"
+	var cfg config
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	want := config{
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Typed:   "2024-01-02T03:04:05Z",
+		Hosts:   []interface{}{netip.MustParseAddr("::1"), "x"},
+		Extra: map[string]interface{}{
+			"id":  [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+			"key": []byte("hi"),
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %#v, want %#v", cfg, want)
+	}
+
+	var m map[string]interface{}
+	if err := Unmarshal([]byte(`amount (decimal)"1.10"`), &m); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if f, ok := m["amount"].(*big.Float); !ok || f.String() != "1.1" {
+		t.Errorf("got %#v, want *big.Float 1.1", m["amount"])
+	}
+
+	if err := Unmarshal([]byte(`created (date-time)"soon"`), &cfg); err == nil || !strings.Contains(err.Error(), "(date-time)") {
+		t.Errorf("Unmarshal() error = %v, want invalid date-time", err)
+	}
+	if err := Unmarshal([]byte(`hosts (ipv4)"::1"`), &cfg); err == nil || !strings.Contains(err.Error(), `"::1" is not an IPv4 address`) {
+		t.Errorf("Unmarshal() error = %v, want invalid ipv4", err)
+	}
+}
+
+type testStdlibConfig struct {
+	Addr     netip.Addr     `kdl:"addr"`
+	Prefix   netip.Prefix   `kdl:"prefix"`
+	AddrPort netip.AddrPort `kdl:"addrport"`
+	IP       net.IP         `kdl:"ip"`
+	URL      *url.URL       `kdl:"url"`
+	Pattern  *regexp.Regexp `kdl:"pattern"`
+	Location *time.Location `kdl:"location"`
+	Int      *big.Int       `kdl:"int"`
+	Float    *big.Float     `kdl:"float"`
+	Rat      *big.Rat       `kdl:"rat"`
+	Mode     os.FileMode    `kdl:"mode"`
+}
+
+func TestUnmarshalStdlibTypes(t *testing.T) {
+	input := `addr "::1"
+prefix "10.0.0.0/8"
+addrport "10.0.0.1:443"
+ip "192.168.1.1"
+url "https://example.com/a?b=c"
+pattern "^[a-z]+$"
+location "America/New_York"
+int 123456789012345678901234567890
+float 1.5
+rat "1/3"
+mode 0o644
+`
+	var cfg testStdlibConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	checks := []struct {
+		name      string
+		got, want string
+	}{
+		{"addr", cfg.Addr.String(), "::1"},
+		{"prefix", cfg.Prefix.String(), "10.0.0.0/8"},
+		{"addrport", cfg.AddrPort.String(), "10.0.0.1:443"},
+		{"ip", cfg.IP.String(), "192.168.1.1"},
+		{"url", cfg.URL.String(), "https://example.com/a?b=c"},
+		{"pattern", cfg.Pattern.String(), "^[a-z]+$"},
+		{"location", cfg.Location.String(), "America/New_York"},
+		{"int", cfg.Int.String(), "123456789012345678901234567890"},
+		{"float", cfg.Float.String(), "1.5"},
+		{"rat", cfg.Rat.String(), "1/3"},
+		{"mode", cfg.Mode.String(), "-rw-r--r--"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %s, want %s", c.name, c.got, c.want)
+		}
+	}
+
+	// file modes may also be given as strings containing octal numbers
+	var m struct {
+		Mode os.FileMode `kdl:"mode"`
+	}
+	if err := Unmarshal([]byte(`mode "0755"`), &m); err != nil || m.Mode != 0o755 {
+		t.Errorf("Unmarshal() = %v, %v, want 0755", m.Mode, err)
+	}
+
+	// pointers are assigned the locations and expressions returned by the standard library rather than copies of them,
+	// whether they are unmarshaled from nodes, properties, or slice elements
+	var p struct {
+		Location *time.Location `kdl:"location"`
+		Server   struct {
+			Location *time.Location `kdl:"location"`
+		} `kdl:"server"`
+		Locations []*time.Location `kdl:"locations"`
+		Patterns  []*regexp.Regexp `kdl:"patterns"`
+	}
+	p.Location = time.UTC
+	if err := Unmarshal([]byte("location \"Local\"\nserver location=\"UTC\"\nlocations \"UTC\" \"Local\"\npatterns \"^a+$\"\n"), &p); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if p.Location != time.Local || p.Server.Location != time.UTC || len(p.Locations) != 2 || p.Locations[0] != time.UTC || p.Locations[1] != time.Local {
+		t.Errorf("got locations %v %v %v, want the standard library's", p.Location, p.Server.Location, p.Locations)
+	}
+	if time.UTC.String() != "UTC" {
+		t.Errorf("time.UTC was overwritten with %q", time.UTC.String())
+	}
+	if len(p.Patterns) != 1 || !p.Patterns[0].MatchString("aaa") {
+		t.Errorf("got patterns %v", p.Patterns)
+	}
+
+	for _, input := range []string{`location "Nowhere/Special"`, `url ":"`, `mode "rwx"`, `mode -1`} {
+		if err := Unmarshal([]byte(input), &testStdlibConfig{}); err == nil {
+			t.Errorf("Unmarshal(%q) succeeded, want error", input)
+		}
+	}
+}
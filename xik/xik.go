@@ -0,0 +1,390 @@
+// Package xik converts between XML and KDL documents using the XML-in-KDL (XiK) mapping.
+//
+// Each XML construct is represented by a KDL node:
+//
+//   - an element is a node with the element's name, whose properties are the element's attributes and whose children
+//     are the element's content; an element containing only text may instead have the text as its single argument
+//   - text is a node named "-" with the text as its single argument
+//   - a comment is a node named "!" with the comment as its single argument
+//   - a processing instruction is a node named "?" followed by its target, whose properties are the instruction's
+//     pseudo-attributes (or whose single argument is the instruction's content, if it cannot be parsed as
+//     pseudo-attributes)
+//   - a directive such as <!DOCTYPE html> is a node named "!" followed by the directive's keyword, whose single
+//     argument is the remainder of the directive
+//
+// For example:
+//
+//	<?xml version="1.0"?>
+//	<!-- a comment -->
+//	<book id="1"><title>Dune</title>A <em>classic</em>.</book>
+//
+// is represented as:
+//
+//	"?xml" version="1.0"
+//	! " a comment "
+//	book id="1" {
+//	    title "Dune"
+//	    - "A "
+//	    em "classic"
+//	    - "."
+//	}
+//
+// Namespace prefixes are retained as part of element and attribute names (eg: xsl:template). Whitespace-only text
+// between elements is discarded.
+//
+// Attribute order is retained: each property created from an attribute is given a span whose offset reflects the
+// attribute's position within its element, as document.Properties.Keys orders properties by their source positions.
+// The spans' line and column are those reported by the xml.Decoder for FromXML, and 1:1 for other token readers.
+package xik
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sblinch/kdl-go/document"
+)
+
+const (
+	// textName is the name of a node representing text
+	textName = "-"
+	// commentName is the name of a node representing a comment
+	commentName = "!"
+	// procInstPrefix prefixes the name of a node representing a processing instruction
+	procInstPrefix = "?"
+	// directivePrefix prefixes the name of a node representing a directive
+	directivePrefix = "!"
+)
+
+// rawTokenReader is an xml.TokenReader that returns raw tokens from an xml.Decoder, so that namespace prefixes are
+// retained as written
+type rawTokenReader struct {
+	dec *xml.Decoder
+}
+
+// Token returns the next raw token
+func (r rawTokenReader) Token() (xml.Token, error) {
+	return r.dec.RawToken()
+}
+
+// InputPos returns the line and column of the end of the token most recently returned by Token
+func (r rawTokenReader) InputPos() (int, int) {
+	return r.dec.InputPos()
+}
+
+// InputOffset returns the offset of the end of the token most recently returned by Token
+func (r rawTokenReader) InputOffset() int64 {
+	return r.dec.InputOffset()
+}
+
+// positioner is implemented by token readers that report the position of the token most recently read
+type positioner interface {
+	InputPos() (line, column int)
+	InputOffset() int64
+}
+
+// tokenPosition returns the position of the token most recently read from tr if tr reports it, or 1:1 otherwise
+func tokenPosition(tr xml.TokenReader) document.Position {
+	if p, ok := tr.(positioner); ok {
+		line, column := p.InputPos()
+		return document.Position{Line: line, Column: column, Offset: int(p.InputOffset())}
+	}
+	return document.Position{Line: 1, Column: 1}
+}
+
+// addAttrs adds a property to n for each of attrs, giving each a span at pos offset by the attribute's index so that
+// document.Properties.Keys returns them in their original order
+func addAttrs(n *document.Node, attrs []xml.Attr, pos document.Position) {
+	for i, attr := range attrs {
+		v := n.AddProperty(name(attr.Name), attr.Value, "")
+		v.Flag = document.FlagQuoted
+		v.Span.Start = pos
+		v.Span.Start.Offset += i
+		v.Span.End = v.Span.Start
+	}
+}
+
+// FromXML reads the XML document in r and returns the equivalent XiK document
+func FromXML(r io.Reader) (*document.Document, error) {
+	return FromTokens(rawTokenReader{dec: xml.NewDecoder(r)})
+}
+
+// FromTokens reads the XML tokens in tr and returns the equivalent XiK document; each element and attribute name is
+// converted to a node or property name of the form "space:local", or "local" if the name has no space
+func FromTokens(tr xml.TokenReader) (*document.Document, error) {
+	doc := document.New()
+	var stack []*document.Node
+
+	add := func(n *document.Node) {
+		if len(stack) == 0 {
+			doc.AddNode(n)
+		} else {
+			stack[len(stack)-1].AddNode(n)
+		}
+	}
+
+	for {
+		t, err := tr.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("converting XML to KDL: %w", err)
+		}
+
+		switch x := t.(type) {
+		case xml.StartElement:
+			n := document.NewNode()
+			n.SetName(name(x.Name))
+			addAttrs(n, x.Attr, tokenPosition(tr))
+			add(n)
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("converting XML to KDL: unexpected end element </%s>", name(x.Name))
+			}
+			n := stack[len(stack)-1]
+			if got, want := name(x.Name), n.Name.ValueString(); got != want {
+				return nil, fmt.Errorf("converting XML to KDL: element <%s> closed by </%s>", want, got)
+			}
+			stack = stack[:len(stack)-1]
+			simplify(n)
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				// only whitespace may appear outside of the root element
+				continue
+			}
+			addText(stack[len(stack)-1], string(x))
+
+		case xml.Comment:
+			n := document.NewNode()
+			n.SetName(commentName)
+			n.AddArgument(string(x), "").Flag = document.FlagQuoted
+			add(n)
+
+		case xml.ProcInst:
+			add(procInstNode(x, tokenPosition(tr)))
+
+		case xml.Directive:
+			keyword, rest := string(x), ""
+			if i := strings.IndexAny(keyword, " \t\r\n"); i != -1 {
+				keyword, rest = keyword[:i], strings.TrimSpace(keyword[i+1:])
+			}
+			n := document.NewNode()
+			n.SetName(directivePrefix + keyword)
+			if rest != "" {
+				n.AddArgument(rest, "").Flag = document.FlagQuoted
+			}
+			add(n)
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("converting XML to KDL: element <%s> is not closed", stack[len(stack)-1].Name.ValueString())
+	}
+	return doc, nil
+}
+
+// name returns the KDL name for the XML name n
+func name(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// addText adds text to the content of n, merging it with any text immediately preceding it
+func addText(n *document.Node, text string) {
+	if len(n.Children) > 0 {
+		if last := n.Children[len(n.Children)-1]; isText(last) {
+			last.Arguments[0].Value = last.Arguments[0].Value.(string) + text
+			return
+		}
+	}
+	child := document.NewNode()
+	child.SetName(textName)
+	child.AddArgument(text, "").Flag = document.FlagQuoted
+	n.AddNode(child)
+}
+
+// isText returns true if n is a text node
+func isText(n *document.Node) bool {
+	return n.Name.ValueString() == textName && len(n.Arguments) == 1 && len(n.Children) == 0
+}
+
+// simplify removes whitespace-only text from the content of element n if it contains other nodes, or moves its text to
+// its argument if it contains only text
+func simplify(n *document.Node) {
+	if len(n.Children) == 1 && isText(n.Children[0]) {
+		n.Arguments = n.Children[0].Arguments
+		n.Children = nil
+		return
+	}
+
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if isText(child) && strings.TrimSpace(child.Arguments[0].Value.(string)) == "" {
+			continue
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// procInstNode returns the node representing processing instruction pi, read at pos
+func procInstNode(pi xml.ProcInst, pos document.Position) *document.Node {
+	n := document.NewNode()
+	n.SetName(procInstPrefix + pi.Target)
+
+	// parse the instruction's content as pseudo-attributes by wrapping it in an element
+	dec := xml.NewDecoder(strings.NewReader("<pi " + string(pi.Inst) + "/>"))
+	if t, err := dec.RawToken(); err == nil {
+		if start, ok := t.(xml.StartElement); ok {
+			addAttrs(n, start.Attr, pos)
+			return n
+		}
+	}
+
+	if inst := strings.TrimSpace(string(pi.Inst)); inst != "" {
+		n.AddArgument(inst, "").Flag = document.FlagQuoted
+	}
+	return n
+}
+
+// ToXML writes the XML document represented by the XiK document doc to w
+func ToXML(doc *document.Document, w io.Writer) error {
+	enc := xml.NewEncoder(w)
+	if err := ToTokens(doc, enc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// ToTokens encodes the XML tokens represented by the XiK document doc to enc; the caller is responsible for flushing
+// or closing enc
+func ToTokens(doc *document.Document, enc *xml.Encoder) error {
+	for _, n := range doc.Nodes {
+		if err := encodeNode(enc, n); err != nil {
+			return fmt.Errorf("converting KDL to XML: %w", err)
+		}
+	}
+	return nil
+}
+
+// nodeError returns an error describing a problem with n
+func nodeError(n *document.Node, format string, v ...interface{}) error {
+	msg := fmt.Sprintf(format, v...)
+	if n.Span.IsValid() {
+		return fmt.Errorf("node %s at %s: %s", n.Name.NodeNameString(), n.Span.Start, msg)
+	}
+	return fmt.Errorf("node %s: %s", n.Name.NodeNameString(), msg)
+}
+
+// text returns the single argument of n, the empty string if n has no arguments, or an error if n has more than one
+// argument
+func text(n *document.Node) (string, error) {
+	switch len(n.Arguments) {
+	case 0:
+		return "", nil
+	case 1:
+		return n.Arguments[0].ValueString(), nil
+	default:
+		return "", nodeError(n, "expected at most one argument, found %d", len(n.Arguments))
+	}
+}
+
+// leaf returns the single argument of n, or an error if n has properties or children
+func leaf(n *document.Node) (string, error) {
+	if n.Properties.Exist() || len(n.Children) > 0 {
+		return "", nodeError(n, "unexpected properties or children")
+	}
+	return text(n)
+}
+
+// encodeNode encodes the XML token(s) represented by n to enc
+func encodeNode(enc *xml.Encoder, n *document.Node) error {
+	nodeName := n.Name.ValueString()
+	switch {
+	case nodeName == textName:
+		s, err := leaf(n)
+		if err != nil {
+			return err
+		}
+		return enc.EncodeToken(xml.CharData(s))
+
+	case nodeName == commentName:
+		s, err := leaf(n)
+		if err != nil {
+			return err
+		}
+		return enc.EncodeToken(xml.Comment(s))
+
+	case strings.HasPrefix(nodeName, procInstPrefix):
+		if len(n.Children) > 0 {
+			return nodeError(n, "unexpected children")
+		}
+		s, err := text(n)
+		if err != nil {
+			return err
+		}
+		inst := []byte(s)
+		for _, key := range n.Properties.Keys() {
+			val, _ := n.Properties.Get(key)
+			if len(inst) > 0 {
+				inst = append(inst, ' ')
+			}
+			inst = append(inst, key...)
+			inst = append(inst, '=', '"')
+			inst = appendEscaped(inst, val.ValueString())
+			inst = append(inst, '"')
+		}
+		return enc.EncodeToken(xml.ProcInst{Target: nodeName[len(procInstPrefix):], Inst: inst})
+
+	case strings.HasPrefix(nodeName, directivePrefix):
+		s, err := leaf(n)
+		if err != nil {
+			return err
+		}
+		directive := nodeName[len(directivePrefix):]
+		if s != "" {
+			directive += " " + s
+		}
+		return enc.EncodeToken(xml.Directive(directive))
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: nodeName}}
+	for _, key := range n.Properties.Keys() {
+		val, _ := n.Properties.Get(key)
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key}, Value: val.ValueString()})
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	s, err := text(n)
+	if err != nil {
+		return err
+	}
+	if s != "" {
+		if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+			return err
+		}
+	}
+	for _, child := range n.Children {
+		if err := encodeNode(enc, child); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// appendEscaped appends the XML-escaped representation of s to b
+func appendEscaped(b []byte, s string) []byte {
+	var buf bytes.Buffer
+	// xml.EscapeText only returns errors from the underlying writer, which cannot fail here
+	_ = xml.EscapeText(&buf, []byte(s))
+	return append(b, buf.Bytes()...)
+}
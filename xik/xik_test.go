@@ -0,0 +1,184 @@
+package xik
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/sblinch/kdl-go/document"
+	"github.com/sblinch/kdl-go/internal/generator"
+	"github.com/sblinch/kdl-go/internal/parser"
+	"github.com/sblinch/kdl-go/internal/tokenizer"
+)
+
+func parseDocument(t *testing.T, input string) *document.Document {
+	t.Helper()
+	s := tokenizer.NewSlice([]byte(input))
+	p := parser.New()
+	c := p.NewContext()
+	for s.Scan() {
+		if err := p.Parse(c, s.Token()); err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatalf("Scan() failed: %v", s.Err())
+	}
+	return c.Document()
+}
+
+func TestFromXML(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		kdl  string
+	}{
+		{
+			name: "text",
+			xml:  `<title lang="en">Dune &amp; Sons</title>`,
+			kdl:  `title "Dune & Sons" lang="en"`,
+		},
+		{
+			name: "mixed content",
+			xml:  "<book id=\"1\">\n  <title>Dune</title>\n  A <em>classic</em>.\n</book>",
+			kdl:  "book id=\"1\" {\n\ttitle \"Dune\"\n\t- \"\\n  A \"\n\tem \"classic\"\n\t- \".\\n\"\n}",
+		},
+		{
+			name: "prolog",
+			xml:  "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE html>\n<!-- note -->\n<html/>",
+			kdl:  "\"?xml\" version=\"1.0\" encoding=\"UTF-8\"\n!DOCTYPE \"html\"\n! \" note \"\nhtml",
+		},
+		{
+			name: "processing instruction content",
+			xml:  `<?php echo 1; ?><r/>`,
+			kdl:  "\"?php\" \"echo 1;\"\nr",
+		},
+		{
+			name: "namespaces",
+			xml:  `<x:root xmlns:x="urn:x" x:attr="1"><x:child/></x:root>`,
+			kdl:  "x:root xmlns:x=\"urn:x\" x:attr=\"1\" {\n\tx:child\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := FromXML(strings.NewReader(tt.xml))
+			if err != nil {
+				t.Fatalf("FromXML() failed: %v", err)
+			}
+			var b bytes.Buffer
+			if err := generator.New(&b).Generate(doc); err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if got := strings.TrimSpace(b.String()); got != tt.kdl {
+				t.Fatalf("FromXML():\ngot : %s\nwant: %s", got, tt.kdl)
+			}
+		})
+	}
+}
+
+func TestToXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		kdl     string
+		xml     string
+		wantErr bool
+	}{
+		{
+			name: "round trip",
+			kdl:  "\"?xml\" version=\"1.0\"\n!DOCTYPE \"html\"\nhtml lang=\"en\" {\n\t! \" note \"\n\tp \"a < b\" class=\"x\"\n\tp {\n\t\t- \"A \"\n\t\tem \"classic\"\n\t\t- \".\"\n\t}\n\tbr\n}",
+			xml:  `<?xml version="1.0"?><!DOCTYPE html><html lang="en"><!-- note --><p class="x">a &lt; b</p><p>A <em>classic</em>.</p><br></br></html>`,
+		},
+		{
+			name: "attribute values",
+			kdl:  `a b=1 c=true d=null`,
+			xml:  `<a b="1" c="true" d="null"></a>`,
+		},
+		{
+			name: "processing instruction argument",
+			kdl:  `"?php" "echo 1;"`,
+			xml:  `<?php echo 1;?>`,
+		},
+		{name: "multiple arguments", kdl: `a 1 2`, wantErr: true},
+		{name: "text with children", kdl: `- "x" { a; }`, wantErr: true},
+		{name: "comment with properties", kdl: `! "x" a=1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := ToXML(parseDocument(t, tt.kdl), &b)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ToXML(): expected error, got %s", b.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToXML() failed: %v", err)
+			}
+			if got := b.String(); got != tt.xml {
+				t.Fatalf("ToXML():\ngot : %s\nwant: %s", got, tt.xml)
+			}
+		})
+	}
+}
+
+func TestFromXMLErrors(t *testing.T) {
+	for _, input := range []string{`<a>`, `<a></b>`, `</a>`, `<a x=1/>`} {
+		if _, err := FromXML(strings.NewReader(input)); err == nil {
+			t.Fatalf("FromXML(%q): expected error", input)
+		}
+	}
+}
+
+// tokenReaderFunc adapts a function to an xml.TokenReader
+type tokenReaderFunc func() (xml.Token, error)
+
+func (f tokenReaderFunc) Token() (xml.Token, error) {
+	return f()
+}
+
+func TestAttributeOrder(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`<a z="1" b="2" m="3"/>`, `<a z="1" b="2" m="3"></a>`},
+		{`<?xml version="1.0" encoding="UTF-8"?><a/>`, `<?xml version="1.0" encoding="UTF-8"?><a></a>`},
+	}
+	var b bytes.Buffer
+	for _, tt := range tests {
+		doc, err := FromXML(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("FromXML() failed: %v", err)
+		}
+		b.Reset()
+		if err := ToXML(doc, &b); err != nil {
+			t.Fatalf("ToXML() failed: %v", err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Fatalf("ToXML():\ngot : %s\nwant: %s", got, tt.want)
+		}
+	}
+
+	// token readers that do not report positions retain attribute order as well
+	dec := xml.NewDecoder(strings.NewReader(`<a z="1" b="2" m="3"/>`))
+	doc, err := FromTokens(tokenReaderFunc(dec.RawToken))
+	if err != nil {
+		t.Fatalf("FromTokens() failed: %v", err)
+	}
+	if got, want := strings.Join(doc.Nodes[0].Properties.Keys(), ","), "z,b,m"; got != want {
+		t.Fatalf("Keys() = %s, want %s", got, want)
+	}
+
+	// parsed documents retain their source order in either case
+	b.Reset()
+	if err := ToXML(parseDocument(t, `a z=1 y=2 x=3`), &b); err != nil {
+		t.Fatalf("ToXML() failed: %v", err)
+	}
+	if got, want := b.String(), `<a z="1" y="2" x="3"></a>`; got != want {
+		t.Fatalf("ToXML():\ngot : %s\nwant: %s", got, want)
+	}
+}